@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+
+	"git-sync/internal/repository"
+	"git-sync/internal/sync"
+)
+
+// setupCachePrune регистрирует флаги команды cache prune
+func setupCachePrune(flags *flag.FlagSet, opts *options) {
+	flags.DurationVar(&opts.unusedFor, "unused-for", 0, "также удалить зеркала пар конфигурации, которые не обновлялись дольше указанного срока, например 720h")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "только вывести зеркала, которые были бы удалены")
+}
+
+// runCachePrune удаляет из кэша зеркала, которые не используются ни одной парой конфигурации
+func runCachePrune(ctx context.Context, opts options, stdout, stderr io.Writer) int {
+	if len(opts.pairs) > 0 {
+		fmt.Fprintln(stderr, "Команда cache prune не принимает --pair: сохраняются зеркала всех пар конфигурации")
+		return exitConfig
+	}
+	cfg, pairs, err := loadConfig(ctx, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitConfig
+	}
+
+	repoManager := newManager(cfg)
+	keep := make([]string, 0, 2*len(pairs))
+	for _, pair := range pairs {
+		pairID := repository.PairID(pair.GitlabURL, pair.PrivateRepoURL)
+		keep = append(keep,
+			repoManager.MirrorPath(pairID, sync.SideGitlab, pair.GitlabURL),
+			repoManager.MirrorPath(pairID, sync.SidePrivate, pair.PrivateRepoURL))
+	}
+	pruned, err := repoManager.PruneCache(keep, opts.unusedFor, opts.dryRun)
+
+	verb := "Удалено"
+	if opts.dryRun {
+		verb = "Будет удалено"
+	}
+	var freed int64
+	for _, entry := range pruned {
+		freed += entry.Size
+		remote := entry.URL
+		if remote == "" {
+			remote = "поврежденное зеркало"
+		}
+		fmt.Fprintf(stdout, "%s: %s (%s, %s)\n", verb, remote, entry.Path, formatSize(entry.Size))
+	}
+	fmt.Fprintf(stdout, "%s зеркал в %s: %d, %s\n", verb, repoManager.CacheDir(), len(pruned), formatSize(freed))
+
+	if err != nil {
+		fmt.Fprintf(stderr, "Ошибка очистки кэша: %v\n", err)
+		return exitFailed
+	}
+	return exitOK
+}
+
+// formatSize возвращает размер в байтах в удобном для чтения виде
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d Б", size)
+	}
+	value, suffix := float64(size)/unit, "КБ"
+	for _, next := range []string{"МБ", "ГБ", "ТБ"} {
+		if value < unit {
+			break
+		}
+		value, suffix = value/unit, next
+	}
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"text/tabwriter"
+
+	"git-sync/configs"
+	"git-sync/internal/gitlab"
+	"git-sync/internal/repository"
+	"git-sync/internal/state"
+	"git-sync/internal/sync"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// loadConfig загружает конфигурацию, регистрирует ее секреты для скрытия в выводе, получает адреса
+// репозиториев пар с числовым gitlab_project_id и выбирает пары, указанные флагами --pair
+func loadConfig(ctx context.Context, opts options) (*configs.Config, []configs.RepositoryPair, error) {
+	cfg, err := configs.LoadConfig(opts.configPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка загрузки конфигурации: %w", err)
+	}
+	opts.redactor.Add(cfg.Secrets()...)
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("ошибка конфигурации %s:\n%w", opts.configPath, err)
+	}
+	resolved, err := resolveProjectIDs(ctx, cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка конфигурации: %w", err)
+	}
+	if resolved {
+		// Адреса, полученные через API, могут повторять адреса других пар
+		if err := cfg.Validate(); err != nil {
+			return nil, nil, fmt.Errorf("ошибка конфигурации %s:\n%w", opts.configPath, err)
+		}
+	}
+	pairs, err := cfg.SelectPairs(opts.pairs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("ошибка конфигурации: %w", err)
+	}
+	return cfg, pairs, nil
+}
+
+// newManager создает менеджер репозиториев по конфигурации
+func newManager(cfg *configs.Config) *repository.Manager {
+	repoManager := repository.NewManager(cfg.TempDir)
+	if cfg.CacheDir != "" {
+		repoManager.SetCacheDir(cfg.CacheDir)
+	}
+	repoManager.SetOperationTimeout(cfg.GitOperationTimeout())
+	retry := cfg.RetrySettings()
+	repoManager.SetRetryPolicy(repository.RetryPolicy{
+		MaxAttempts:    retry.MaxAttempts,
+		InitialBackoff: retry.InitialBackoff,
+		MaxBackoff:     retry.MaxBackoff,
+		Jitter:         *retry.Jitter,
+	})
+	return repoManager
+}
+
+// pairContext ограничивает ctx временем обработки одной пары, если задан pair_timeout
+func pairContext(ctx context.Context, cfg *configs.Config) (context.Context, context.CancelFunc) {
+	if cfg.PairTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, cfg.PairTimeout)
+}
+
+// newLogic создает логику синхронизации по конфигурации
+func newLogic(cfg *configs.Config) *sync.Logic {
+	// Инициализация логики синхронизации
+	syncLogic := sync.NewLogic(newManager(cfg))
+	if cfg.StateDir != "" {
+		syncLogic.SetStateStore(state.NewStore(cfg.StateDir))
+	}
+	syncLogic.SetGitlabAPI(gitlabAPI(cfg))
+	return syncLogic
+}
+
+// resolveProjectIDs заполняет адреса репозиториев пар, заданных числовым gitlab_project_id, адресом
+// HTTP(S) проекта из GitLab API и сообщает, был ли получен хотя бы один адрес
+func resolveProjectIDs(ctx context.Context, cfg *configs.Config) (bool, error) {
+	resolved := false
+	for i := range cfg.Repositories {
+		pair := &cfg.Repositories[i]
+		projectID, ok := pair.NumericProjectID()
+		if !ok || pair.GitlabURL != "" {
+			continue
+		}
+		gitlabCred, _, err := cfg.PairCredentials(*pair)
+		if err != nil {
+			return resolved, fmt.Errorf("repositories[%d] (%s): %w", i, pair.PairName(), err)
+		}
+		token, err := gitlabToken(cfg, gitlabCred)
+		if err != nil {
+			return resolved, fmt.Errorf("repositories[%d] (%s): %w", i, pair.PairName(), err)
+		}
+		client, err := gitlab.NewClient(cfg.GitlabAPIURL(), token, projectID)
+		if err != nil {
+			return resolved, err
+		}
+		project, err := client.GetProject(ctx)
+		if err != nil {
+			return resolved, fmt.Errorf("repositories[%d]: gitlab_project_id %d: %w", i, projectID, err)
+		}
+		if project.HTTPURLToRepo == "" {
+			return resolved, fmt.Errorf("repositories[%d]: gitlab_project_id %d: GitLab не вернул адрес репозитория", i, projectID)
+		}
+		pair.GitlabURL = project.HTTPURLToRepo
+		resolved = true
+	}
+	return resolved, nil
+}
+
+// gitlabToken возвращает токен GitLab API: секрет учетных данных типа token или gitlab_token
+func gitlabToken(cfg *configs.Config, cred configs.Credential) (string, error) {
+	if cred.Type == configs.CredentialToken {
+		return cred.SecretValue()
+	}
+	return cfg.GitlabToken, nil
+}
+
+// gitlabAPI возвращает способ получения клиента GitLab API для проекта пары. Токен - секрет учетных данных
+// GitLab пары типа token или gitlab_token; адрес API - gitlab_base_url, если gitlab_url пары на том же хосте,
+// иначе хост из gitlab_url. Для локальных репозиториев и без токена клиент не создается.
+func gitlabAPI(cfg *configs.Config) sync.GitlabAPIFactory {
+	return func(ctx context.Context, pair configs.RepositoryPair, cred configs.Credential) (gitlab.API, error) {
+		remote, err := repository.ParseRemoteURL(pair.GitlabURL, cfg.GitlabBaseURL)
+		if err != nil || remote.Scheme == "file" {
+			return nil, nil
+		}
+		token, err := gitlabToken(cfg, cred)
+		if err != nil || token == "" {
+			return nil, err
+		}
+
+		apiURL := remote.BaseURL
+		if cfg.GitlabBaseURL != "" && remote.OnInstance(cfg.GitlabBaseURL) {
+			apiURL = cfg.GitlabAPIURL()
+		}
+		// Числовой gitlab_project_id уже является ссылкой на проект в API
+		projectID, ok := pair.NumericProjectID()
+		if !ok {
+			if projectID, err = gitlab.GetProjectIDByPath(ctx, pair.GitlabURL, apiURL, token); err != nil {
+				return nil, err
+			}
+		}
+		client, err := gitlab.NewClient(apiURL, token, projectID)
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+}
+
+// runSync синхронизирует выбранные пары репозиториев и выводит сводку.
+// Одновременно синхронизируется не более concurrency пар из конфигурации.
+func runSync(ctx context.Context, opts options, stdout, stderr io.Writer) int {
+	cfg, pairs, err := loadConfig(ctx, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitConfig
+	}
+	syncLogic := newLogic(cfg)
+
+	// Итоги собираются в порядке пар конфигурации независимо от порядка завершения
+	reports := make([]pairReport, len(pairs))
+	forEachPair(pairs, cfg.PairConcurrency(), func(i int, repoPair configs.RepositoryPair) {
+		reports[i] = syncPair(ctx, cfg, syncLogic, repoPair, stdout)
+	})
+
+	fmt.Fprintln(stdout)
+	printSummary(stdout, reports)
+	fmt.Fprintln(stdout, "Сервис синхронизации завершил работу.")
+	return exitCode(reports)
+}
+
+// syncPair синхронизирует одну пару репозиториев. Строки вывода и журнала пары начинаются с ее имени.
+func syncPair(ctx context.Context, cfg *configs.Config, syncLogic *sync.Logic, repoPair configs.RepositoryPair, stdout io.Writer) pairReport {
+	out := pairLogger(stdout, repoPair, 0)
+	logger := pairLogger(log.Writer(), repoPair, log.Flags())
+	ctx, cancel := pairContext(ctx, cfg)
+	defer cancel()
+
+	out.Printf("Синхронизация репозиториев: %s <-> %s", repoPair.GitlabURL, repoPair.PrivateRepoURL)
+	result := &sync.Result{GitlabURL: repoPair.GitlabURL, PrivateRepoURL: repoPair.PrivateRepoURL}
+	gitlabCred, privateCred, err := cfg.PairCredentials(repoPair)
+	if err == nil {
+		result, err = syncLogic.WithLogger(logger).Synchronize(ctx, repoPair, gitlabCred, privateCred)
+	}
+
+	for _, conflict := range result.Conflicts() {
+		logger.Printf("Конфликт %s: %s", conflict.Name, conflict.Message)
+	}
+	for _, blocked := range result.WithStatus(sync.StatusBlocked) {
+		logger.Printf("Ветка %s заблокирована защитой GitLab: %s", blocked.Name, blocked.Message)
+	}
+	for _, forced := range result.WithStatus(sync.StatusForced) {
+		out.Printf("Ветка %s перезаписана (%s): %s", forced.Name, forced.Direction, forced.Message)
+	}
+	for _, merged := range result.WithStatus(sync.StatusMerged) {
+		out.Printf("Ветка %s объединена: %s", merged.Name, merged.Message)
+	}
+	for _, deletion := range result.WithStatus(sync.StatusWouldDelete) {
+		out.Printf("Dry-run: %s будет удалена (%s): %s", deletion.Name, deletion.Direction, deletion.Message)
+	}
+	for _, retry := range result.Retries {
+		if retry.Succeeded {
+			out.Printf("Повтор %s %s: выполнено с попытки %d", retry.Operation, retry.URL, retry.Attempts)
+		} else {
+			logger.Printf("Повтор %s %s: не выполнено за %d попыток", retry.Operation, retry.URL, retry.Attempts)
+		}
+	}
+	if err != nil {
+		logger.Printf("Ошибка синхронизации %s <-> %s: %v", repoPair.GitlabURL, repoPair.PrivateRepoURL, err)
+	} else {
+		out.Printf("Синхронизация %s <-> %s завершена успешно.", repoPair.GitlabURL, repoPair.PrivateRepoURL)
+	}
+	return pairReport{name: repoPair.PairName(), result: result, err: err}
+}
+
+// runValidate загружает конфигурацию и сообщает о найденных ошибках
+func runValidate(ctx context.Context, opts options, stdout, stderr io.Writer) int {
+	_, pairs, err := loadConfig(ctx, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitConfig
+	}
+	fmt.Fprintf(stdout, "Конфигурация %s корректна, пар репозиториев: %d\n", opts.configPath, len(pairs))
+	return exitOK
+}
+
+// runStatus выводит ветки и теги, которые различаются на сторонах, и действие, которое выполнила бы синхронизация
+func runStatus(ctx context.Context, opts options, stdout, stderr io.Writer) int {
+	cfg, pairs, err := loadConfig(ctx, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitConfig
+	}
+	syncLogic := newLogic(cfg)
+
+	// Пары сверяются в пуле, а выводятся в порядке конфигурации
+	type pairStatus struct {
+		decisions []sync.Decision
+		err       error
+	}
+	statuses := make([]pairStatus, len(pairs))
+	forEachPair(pairs, cfg.PairConcurrency(), func(i int, repoPair configs.RepositoryPair) {
+		gitlabCred, privateCred, err := cfg.PairCredentials(repoPair)
+		if err == nil {
+			ctx, cancel := pairContext(ctx, cfg)
+			defer cancel()
+			logger := pairLogger(log.Writer(), repoPair, log.Flags())
+			statuses[i].decisions, err = syncLogic.WithLogger(logger).Status(ctx, repoPair, gitlabCred, privateCred)
+		}
+		statuses[i].err = err
+	})
+
+	code := exitOK
+	for i, repoPair := range pairs {
+		fmt.Fprintf(stdout, "Пара %s: %s <-> %s\n", repoPair.PairName(), repoPair.GitlabURL, repoPair.PrivateRepoURL)
+		if err := statuses[i].err; err != nil {
+			fmt.Fprintf(stderr, "Ошибка получения статуса %s: %v\n", repoPair.PairName(), err)
+			code = exitFailed
+			continue
+		}
+		printDecisions(stdout, statuses[i].decisions)
+	}
+	return code
+}
+
+// printDecisions выводит таблицу различающихся ссылок
+func printDecisions(w io.Writer, decisions []sync.Decision) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	differences := 0
+	for _, d := range decisions {
+		if d.Action == sync.ActionNone {
+			continue
+		}
+		if differences == 0 {
+			fmt.Fprintln(tw, "  ВИД\tИМЯ\tGITLAB\tPRIVATE\tДЕЙСТВИЕ\tНАПРАВЛЕНИЕ\tПРИЧИНА")
+		}
+		differences++
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			d.Kind, d.Name, shortHash(d.Gitlab), shortHash(d.Private), d.Action, orDash(d.Direction), orDash(d.Reason))
+	}
+	tw.Flush()
+	if differences == 0 {
+		fmt.Fprintln(w, "  Различий нет")
+	}
+}
+
+// runVersion выводит версию сервиса
+func runVersion(_ context.Context, _ options, stdout, _ io.Writer) int {
+	fmt.Fprintf(stdout, "git-sync-service %s\n", version)
+	return exitOK
+}
+
+// shortHash возвращает короткий hash или "-", если ссылки нет
+func shortHash(hash plumbing.Hash) string {
+	if hash.IsZero() {
+		return "-"
+	}
+	return hash.String()[:7]
+}
+
+// orDash возвращает "-" для пустой строки
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
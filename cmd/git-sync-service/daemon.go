@@ -0,0 +1,464 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
+	"time"
+
+	"git-sync/configs"
+	"git-sync/internal/redact"
+	"git-sync/internal/schedule"
+	"git-sync/internal/sync"
+	"git-sync/internal/webhook"
+)
+
+// webhookShutdownTimeout время на завершение обработки запросов webhook при остановке
+const webhookShutdownTimeout = 5 * time.Second
+
+// runDaemon синхронизирует пары по их расписаниям, пока процесс не получит SIGINT или SIGTERM.
+// Если задан webhook.listen, события push запускают синхронизацию пары вне расписания.
+// SIGHUP перечитывает конфигурацию: начатые синхронизации завершаются с прежними настройками.
+func runDaemon(ctx context.Context, opts options, stdout, stderr io.Writer) int {
+	d := newDaemon(stdout, stderr, func() (*configs.Config, []configs.RepositoryPair, error) {
+		return loadConfig(ctx, opts)
+	})
+	d.redactor = opts.redactor
+	cfg, pairs, err := d.load()
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitConfig
+	}
+
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+
+	d.configure(cfg, pairs)
+	if cfg.Webhook.Listen != "" {
+		stopServer, err := d.serveWebhook(ctx, cfg, pairs)
+		if err != nil {
+			fmt.Fprintln(stderr, err)
+			return exitConfig
+		}
+		defer stopServer()
+	}
+	fmt.Fprintf(stdout, "Сервис синхронизации запущен в режиме daemon, пар: %d. SIGHUP перечитывает конфигурацию.\n", len(pairs))
+	d.run(ctx, reload)
+	fmt.Fprintln(stdout, "Сервис синхронизации завершил работу.")
+	return exitOK
+}
+
+// daemon планировщик синхронизации пар по расписаниям. Состояние пар меняется только
+// в горутине run, синхронизации выполняются в отдельных горутинах.
+type daemon struct {
+	stdout, stderr io.Writer
+	// load загружает конфигурацию при запуске и по SIGHUP
+	load func() (*configs.Config, []configs.RepositoryPair, error)
+	// syncPair синхронизирует одну пару; в тестах подменяется
+	syncPair func(ctx context.Context, cfg *configs.Config, syncLogic *sync.Logic, pair configs.RepositoryPair) pairReport
+	now      func() time.Time
+	// redactor скрывает секреты конфигураций, с которыми выполняются синхронизации; nil, если не задан
+	redactor *redact.Redactor
+
+	// generation текущая конфигурация
+	generation *daemonGeneration
+	// pairs пары по имени, включая удаленные из конфигурации, синхронизация которых еще выполняется
+	pairs map[string]*scheduledPair
+	// running число выполняющихся синхронизаций всех поколений
+	running int
+	// waiting пары, синхронизация которых ждет свободного места: одновременно выполняется
+	// не более concurrency текущей конфигурации синхронизаций
+	waiting []string
+	// finished получает имя пары, синхронизация которой завершилась
+	finished chan string
+	// loaded получает конфигурацию, перечитанную по SIGHUP. Загрузка выполняется вне горутины run:
+	// секреты exec: и обращения к GitLab API не должны задерживать завершение и запуск синхронизаций
+	loaded chan loadedConfig
+	// loading конфигурация перечитывается; reloadAgain SIGHUP получен во время загрузки
+	loading, reloadAgain bool
+	// triggers получает события webhook
+	triggers chan trigger
+	// webhook обработчик событий; nil, если прием событий не включен
+	webhook *webhook.Handler
+	// webhookListen адрес, на котором принимаются события. Изменяется только перезапуском
+	webhookListen string
+}
+
+// trigger событие webhook для пары
+type trigger struct {
+	pair  string
+	event *webhook.Event
+}
+
+// loadedConfig результат загрузки конфигурации
+type loadedConfig struct {
+	cfg   *configs.Config
+	pairs []configs.RepositoryPair
+	err   error
+}
+
+// scheduledPair пара и ее расписание
+type scheduledPair struct {
+	pair     configs.RepositoryPair
+	schedule schedule.Schedule
+	// generation конфигурация, с которой будет выполнен следующий запуск
+	generation *daemonGeneration
+	next       time.Time
+	// triggered время синхронизации, запрошенной событием webhook. Каждое следующее событие
+	// откладывает его на webhook.debounce, поэтому серия push приводит к одной синхронизации
+	triggered time.Time
+	// running синхронизация пары выполняется или ждет свободного места; следующий запуск по расписанию пропускается
+	running bool
+	// runGeneration конфигурация выполняющейся синхронизации
+	runGeneration *daemonGeneration
+	// rerun событие получено во время синхронизации: пара синхронизируется повторно сразу после ее завершения
+	rerun bool
+	// removed пара удалена из конфигурации и будет забыта после завершения синхронизации
+	removed bool
+}
+
+// daemonGeneration настройки одной загрузки конфигурации. Синхронизации, начатые до SIGHUP,
+// завершаются со своим поколением.
+type daemonGeneration struct {
+	cfg       *configs.Config
+	syncLogic *sync.Logic
+}
+
+func newDaemon(stdout, stderr io.Writer, load func() (*configs.Config, []configs.RepositoryPair, error)) *daemon {
+	d := &daemon{
+		stdout:   stdout,
+		stderr:   stderr,
+		load:     load,
+		now:      time.Now,
+		pairs:    make(map[string]*scheduledPair),
+		finished: make(chan string),
+		loaded:   make(chan loadedConfig, 1),
+		triggers: make(chan trigger),
+	}
+	d.syncPair = func(ctx context.Context, cfg *configs.Config, syncLogic *sync.Logic, pair configs.RepositoryPair) pairReport {
+		return syncPair(ctx, cfg, syncLogic, pair, d.stdout)
+	}
+	return d
+}
+
+// configure применяет загруженную конфигурацию: добавляет новые пары, обновляет расписания
+// и забывает пары, которых больше нет в конфигурации
+func (d *daemon) configure(cfg *configs.Config, pairs []configs.RepositoryPair) {
+	generation := &daemonGeneration{cfg: cfg, syncLogic: newLogic(cfg)}
+	d.generation = generation
+	now := d.now()
+	seen := make(map[string]bool, len(pairs))
+
+	for _, pair := range pairs {
+		name := pair.PairName()
+		out := pairLogger(d.stdout, pair, 0)
+		sched, err := schedule.Parse(cfg.PairSchedule(pair))
+		if err != nil {
+			fmt.Fprintf(d.stderr, "[%s] Неверное расписание, пара не будет синхронизироваться: %v\n", name, err)
+			continue
+		}
+		seen[name] = true
+
+		p, exists := d.pairs[name]
+		switch {
+		case !exists:
+			p = &scheduledPair{next: firstRun(sched, now)}
+			d.pairs[name] = p
+		case p.removed || p.schedule.String() != sched.String():
+			p.next = sched.Next(now)
+		}
+		p.pair, p.schedule, p.generation, p.removed = pair, sched, generation, false
+		out.Printf("Расписание %s, следующий запуск %s", sched, p.next.Format(time.DateTime))
+	}
+
+	for name, p := range d.pairs {
+		if seen[name] {
+			continue
+		}
+		if p.running {
+			p.removed = true
+		} else {
+			delete(d.pairs, name)
+		}
+	}
+	d.updateSecrets()
+}
+
+// updateSecrets заменяет секреты, которые скрываются в выводе, секретами текущей конфигурации
+// и конфигураций, с которыми еще выполняются синхронизации
+func (d *daemon) updateSecrets() {
+	// Во время загрузки конфигурации в redactor уже добавлены ее секреты; они заменяются после загрузки
+	if d.redactor == nil || d.loading {
+		return
+	}
+	secrets := d.generation.cfg.Secrets()
+	for _, p := range d.pairs {
+		if p.runGeneration != nil && p.runGeneration != d.generation {
+			secrets = append(secrets, p.runGeneration.cfg.Secrets()...)
+		}
+	}
+	d.redactor.Set(secrets...)
+}
+
+// firstRun возвращает время первого запуска пары: пара с интервалом синхронизируется сразу,
+// с cron-выражением - в ближайшее подходящее время
+func firstRun(sched schedule.Schedule, now time.Time) time.Time {
+	if _, ok := sched.(schedule.Interval); ok {
+		return now
+	}
+	return sched.Next(now)
+}
+
+// run запускает синхронизации по расписанию, пока не отменен ctx, и перечитывает конфигурацию
+// по сигналу из reload. После отмены ctx ждет завершения начатых синхронизаций.
+func (d *daemon) run(ctx context.Context, reload <-chan os.Signal) {
+	for {
+		var wake <-chan time.Time
+		var timer *time.Timer
+		if next, ok := d.nextRun(); ok {
+			timer = time.NewTimer(next.Sub(d.now()))
+			wake = timer.C
+		}
+
+		select {
+		case <-ctx.Done():
+			d.dropWaiting()
+			for d.running > 0 {
+				d.finish(<-d.finished)
+			}
+			return
+		case <-wake:
+			d.startDue(ctx)
+		case name := <-d.finished:
+			d.finish(name)
+			d.startWaiting(ctx)
+		case t := <-d.triggers:
+			d.trigger(t)
+		case <-reload:
+			d.reload()
+		case loaded := <-d.loaded:
+			d.applyReload(loaded)
+			d.startWaiting(ctx)
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// nextRun возвращает ближайшее время запуска по расписанию или событию среди пар конфигурации
+func (d *daemon) nextRun() (time.Time, bool) {
+	var next time.Time
+	for _, p := range d.pairs {
+		if p.removed {
+			continue
+		}
+		for _, at := range []time.Time{p.next, p.triggered} {
+			if !at.IsZero() && (next.IsZero() || at.Before(next)) {
+				next = at
+			}
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// startDue запускает синхронизацию пар, время которых наступило по расписанию или после события.
+// Если предыдущая синхронизация пары еще выполняется, запуск по расписанию пропускается,
+// а синхронизация по событию откладывается до ее завершения.
+func (d *daemon) startDue(ctx context.Context) {
+	now := d.now()
+	names := make([]string, 0, len(d.pairs))
+	for name := range d.pairs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := d.pairs[name]
+		scheduled := !p.next.IsZero() && !p.next.After(now)
+		triggered := !p.triggered.IsZero() && !p.triggered.After(now)
+		if p.removed || (!scheduled && !triggered) {
+			continue
+		}
+		if scheduled {
+			p.next = p.schedule.Next(now)
+		}
+		if triggered {
+			p.triggered = time.Time{}
+		}
+
+		switch {
+		case p.running && triggered:
+			p.rerun = true
+			pairLogger(d.stdout, p.pair, 0).Printf("Синхронизация еще выполняется, пара будет синхронизирована повторно после ее завершения")
+		case p.running:
+			pairLogger(d.stdout, p.pair, 0).Printf("Запуск пропущен: предыдущая синхронизация еще выполняется, следующий запуск %s",
+				p.next.Format(time.DateTime))
+		default:
+			d.start(ctx, name, p)
+		}
+	}
+}
+
+// start синхронизирует пару или ставит ее в очередь, если выполняется concurrency синхронизаций
+func (d *daemon) start(ctx context.Context, name string, p *scheduledPair) {
+	p.running = true
+	if d.running >= d.generation.cfg.PairConcurrency() {
+		d.waiting = append(d.waiting, name)
+		return
+	}
+	d.launch(ctx, name, p)
+}
+
+// launch синхронизирует пару в отдельной горутине с конфигурацией ее поколения
+func (d *daemon) launch(ctx context.Context, name string, p *scheduledPair) {
+	d.running++
+	generation, pair := p.generation, p.pair
+	p.runGeneration = generation
+	go func() {
+		defer func() { d.finished <- name }()
+		d.syncPair(ctx, generation.cfg, generation.syncLogic, pair)
+	}()
+}
+
+// startWaiting запускает синхронизации пар из очереди, пока есть свободные места
+func (d *daemon) startWaiting(ctx context.Context) {
+	for len(d.waiting) > 0 && d.running < d.generation.cfg.PairConcurrency() {
+		name := d.waiting[0]
+		d.waiting = d.waiting[1:]
+		p := d.pairs[name]
+		if p.removed {
+			delete(d.pairs, name)
+			continue
+		}
+		d.launch(ctx, name, p)
+	}
+}
+
+// dropWaiting отменяет синхронизации, которые ждут свободного места
+func (d *daemon) dropWaiting() {
+	for _, name := range d.waiting {
+		p := d.pairs[name]
+		p.running = false
+		if p.removed {
+			delete(d.pairs, name)
+		}
+	}
+	d.waiting = nil
+}
+
+// finish отмечает завершение синхронизации пары
+func (d *daemon) finish(name string) {
+	d.running--
+	p := d.pairs[name]
+	p.running = false
+	previous := p.runGeneration != d.generation
+	p.runGeneration = nil
+	if previous {
+		// Секреты прежней конфигурации больше не нужны, если с ней не выполняется других синхронизаций
+		d.updateSecrets()
+	}
+	if p.removed {
+		delete(d.pairs, name)
+		return
+	}
+	if p.rerun {
+		p.rerun = false
+		p.triggered = d.now()
+		return
+	}
+	pairLogger(d.stdout, p.pair, 0).Printf("Следующий запуск %s", p.next.Format(time.DateTime))
+}
+
+// reload начинает перечитывать конфигурацию в отдельной горутине. SIGHUP, полученный во время
+// загрузки, перечитывает конфигурацию еще раз после ее завершения.
+func (d *daemon) reload() {
+	if d.loading {
+		d.reloadAgain = true
+		return
+	}
+	d.loading = true
+	go func() {
+		cfg, pairs, err := d.load()
+		d.loaded <- loadedConfig{cfg: cfg, pairs: pairs, err: err}
+	}()
+}
+
+// applyReload применяет перечитанную конфигурацию. При ошибке продолжается работа с прежней конфигурацией.
+func (d *daemon) applyReload(loaded loadedConfig) {
+	d.loading = false
+	if d.reloadAgain {
+		d.reloadAgain = false
+		defer d.reload()
+	}
+	if loaded.err != nil {
+		fmt.Fprintf(d.stderr, "Конфигурация не перечитана, продолжается работа с прежней: %v\n", loaded.err)
+		d.updateSecrets()
+		return
+	}
+	cfg, pairs := loaded.cfg, loaded.pairs
+	d.configure(cfg, pairs)
+	if d.webhook != nil {
+		d.webhook.Update(cfg.Webhook.Secret, pairs)
+	}
+	if cfg.Webhook.Listen != d.webhookListen {
+		fmt.Fprintf(d.stderr, "Изменение webhook.listen вступит в силу после перезапуска, события принимаются на %q\n", d.webhookListen)
+	}
+	fmt.Fprintf(d.stdout, "Конфигурация перечитана, пар: %d\n", len(pairs))
+}
+
+// trigger откладывает синхронизацию пары события на webhook.debounce от момента события
+func (d *daemon) trigger(t trigger) {
+	p, ok := d.pairs[t.pair]
+	if !ok || p.removed {
+		return
+	}
+	delay := p.generation.cfg.Webhook.DebounceDelay()
+	p.triggered = d.now().Add(delay)
+	pairLogger(d.stdout, p.pair, 0).Printf("Событие %s от %s (%s): синхронизация через %s",
+		t.event.Kind, t.event.Provider, t.event.Ref, delay)
+}
+
+// webhookHandler создает обработчик событий, который передает события пар в цикл планировщика
+func (d *daemon) webhookHandler(ctx context.Context, cfg *configs.Config, pairs []configs.RepositoryPair) *webhook.Handler {
+	d.webhook = webhook.NewHandler(cfg.Webhook.Secret, pairs, func(pair configs.RepositoryPair, event *webhook.Event) {
+		select {
+		case d.triggers <- trigger{pair: pair.PairName(), event: event}:
+		case <-ctx.Done():
+		}
+	})
+	return d.webhook
+}
+
+// serveWebhook запускает HTTP-сервер приема событий на webhook.listen и возвращает функцию его остановки
+func (d *daemon) serveWebhook(ctx context.Context, cfg *configs.Config, pairs []configs.RepositoryPair) (func(), error) {
+	listener, err := net.Listen("tcp", cfg.Webhook.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось запустить прием событий webhook: %w", err)
+	}
+	d.webhookListen = cfg.Webhook.Listen
+
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Webhook.WebhookPath(), d.webhookHandler(ctx, cfg, pairs))
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Fprintf(d.stderr, "Ошибка сервера webhook: %v\n", err)
+		}
+	}()
+	fmt.Fprintf(d.stdout, "События webhook принимаются на http://%s%s\n", listener.Addr(), cfg.Webhook.WebhookPath())
+
+	return func() {
+		shutdownCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), webhookShutdownTimeout)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}, nil
+}
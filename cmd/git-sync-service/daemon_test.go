@@ -0,0 +1,418 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	stdsync "sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"git-sync/configs"
+	"git-sync/internal/redact"
+	"git-sync/internal/sync"
+)
+
+// fakeSyncs записывает запуски синхронизации пар вместо обращения к репозиториям
+type fakeSyncs struct {
+	mu       stdsync.Mutex
+	started  map[string]int
+	active   map[string]int
+	overlaps []string
+	// total и maxTotal число выполняющихся синхронизаций всех пар и его наибольшее значение
+	total, maxTotal int
+	// duration длительность синхронизации по имени пары
+	duration map[string]time.Duration
+	// canceled число синхронизаций, контекст которых был отменен до завершения
+	canceled int
+}
+
+func newFakeSyncs(duration map[string]time.Duration) *fakeSyncs {
+	return &fakeSyncs{started: make(map[string]int), active: make(map[string]int), duration: duration}
+}
+
+func (f *fakeSyncs) sync(ctx context.Context, _ *configs.Config, _ *sync.Logic, pair configs.RepositoryPair) pairReport {
+	name := pair.PairName()
+	f.mu.Lock()
+	f.started[name]++
+	if f.active[name]++; f.active[name] > 1 {
+		f.overlaps = append(f.overlaps, name)
+	}
+	if f.total++; f.total > f.maxTotal {
+		f.maxTotal = f.total
+	}
+	duration := f.duration[name]
+	f.mu.Unlock()
+
+	time.Sleep(duration)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.active[name]--
+	f.total--
+	if ctx.Err() != nil {
+		f.canceled++
+	}
+	return pairReport{name: name, result: &sync.Result{}}
+}
+
+func (f *fakeSyncs) count(name string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.started[name]
+}
+
+// syncBuffer буфер вывода, который можно читать, пока планировщик пишет в него
+type syncBuffer struct {
+	mu  stdsync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// writeDaemonConfig записывает конфигурацию с парами и их расписаниями
+func writeDaemonConfig(t *testing.T, path string, schedules map[string]string, extra ...string) {
+	t.Helper()
+	dir := filepath.Dir(path)
+	content := "temp_dir: \"" + filepath.Join(dir, "work") + "\"\nconcurrency: 4\n" + strings.Join(extra, "") + "repositories:\n"
+	for _, name := range []string{"alpha", "beta", "gamma"} {
+		sched, ok := schedules[name]
+		if !ok {
+			continue
+		}
+		content += "  - name: \"" + name + "\"\n" +
+			"    gitlab_url: \"" + filepath.Join(dir, name+"-gitlab.git") + "\"\n" +
+			"    private_repo_url: \"" + filepath.Join(dir, name+"-private.git") + "\"\n" +
+			"    schedule: \"" + sched + "\"\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+}
+
+// startTestDaemon запускает планировщик с подмененной синхронизацией и возвращает функцию его остановки
+func startTestDaemon(t *testing.T, configPath string, fake *fakeSyncs, reload chan os.Signal) (d *daemon, stdout, stderr *syncBuffer, stop func()) {
+	t.Helper()
+	stdout, stderr = &syncBuffer{}, &syncBuffer{}
+	opts := options{configPath: configPath, redactor: &redact.Redactor{}}
+	d = newDaemon(stdout, stderr, func() (*configs.Config, []configs.RepositoryPair, error) {
+		return loadConfig(context.Background(), opts)
+	})
+	d.redactor = opts.redactor
+	d.syncPair = fake.sync
+
+	cfg, pairs, err := d.load()
+	if err != nil {
+		t.Fatalf("Не удалось загрузить конфигурацию: %v", err)
+	}
+	d.configure(cfg, pairs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if cfg.Webhook.Secret != "" {
+		d.webhookHandler(ctx, cfg, pairs)
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.run(ctx, reload)
+	}()
+	return d, stdout, stderr, func() {
+		cancel()
+		select {
+		case <-done:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Планировщик не остановился после отмены контекста")
+		}
+	}
+}
+
+// waitFor ждет выполнения условия
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("Не дождались: %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestDaemonSchedulesWithoutOverlap(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeDaemonConfig(t, configPath, map[string]string{"alpha": "50ms", "beta": "0 0 1 1 *"})
+	fake := newFakeSyncs(map[string]time.Duration{"alpha": 180 * time.Millisecond})
+
+	_, stdout, _, stop := startTestDaemon(t, configPath, fake, make(chan os.Signal))
+	waitFor(t, "трех запусков alpha", func() bool { return fake.count("alpha") >= 3 })
+	stop()
+
+	if len(fake.overlaps) > 0 {
+		t.Errorf("Синхронизации пары выполнялись одновременно: %v", fake.overlaps)
+	}
+	if fake.count("beta") != 0 {
+		t.Errorf("Пара с cron-расписанием запущена раньше срока: %d", fake.count("beta"))
+	}
+	if !strings.Contains(stdout.String(), "[alpha] Запуск пропущен: предыдущая синхронизация еще выполняется") {
+		t.Errorf("Нет сообщения о пропуске запуска:\n%s", stdout.String())
+	}
+	if !strings.Contains(stdout.String(), "[beta] Расписание 0 0 1 1 *, следующий запуск") {
+		t.Errorf("Нет сообщения о расписании пары:\n%s", stdout.String())
+	}
+}
+
+func TestDaemonReload(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeDaemonConfig(t, configPath, map[string]string{"alpha": "1h", "beta": "1h"})
+	fake := newFakeSyncs(map[string]time.Duration{"alpha": 300 * time.Millisecond})
+	reload := make(chan os.Signal, 1)
+
+	_, stdout, stderr, stop := startTestDaemon(t, configPath, fake, reload)
+	defer stop()
+	waitFor(t, "первых запусков", func() bool { return fake.count("alpha") == 1 && fake.count("beta") == 1 })
+
+	// Ошибочная конфигурация не останавливает работу
+	if err := os.WriteFile(configPath, []byte("repositories: ["), 0644); err != nil {
+		t.Fatalf("Не удалось записать конфигурацию: %v", err)
+	}
+	reload <- syscall.SIGHUP
+	waitFor(t, "сообщения об ошибке конфигурации", func() bool {
+		return strings.Contains(stderr.String(), "Конфигурация не перечитана")
+	})
+
+	// alpha еще синхронизируется: она удаляется из конфигурации, gamma добавляется
+	writeDaemonConfig(t, configPath, map[string]string{"beta": "1h", "gamma": "50ms"})
+	reload <- syscall.SIGHUP
+	waitFor(t, "запусков новой пары", func() bool { return fake.count("gamma") >= 2 })
+
+	fake.mu.Lock()
+	if fake.canceled != 0 {
+		t.Errorf("Перезагрузка конфигурации прервала синхронизаций: %d", fake.canceled)
+	}
+	fake.mu.Unlock()
+	if fake.count("alpha") != 1 || fake.count("beta") != 1 {
+		t.Errorf("Неожиданные запуски: alpha %d, beta %d", fake.count("alpha"), fake.count("beta"))
+	}
+	if !strings.Contains(stdout.String(), "Конфигурация перечитана, пар: 2") {
+		t.Errorf("Нет сообщения о перезагрузке конфигурации:\n%s", stdout)
+	}
+}
+
+// Тест, что ограничение concurrency действует на синхронизации, начатые с прежней конфигурацией
+func TestDaemonReloadKeepsConcurrency(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeSingleConcurrencyConfig := func(schedules map[string]string) {
+		t.Helper()
+		writeDaemonConfig(t, configPath, schedules)
+		content, err := os.ReadFile(configPath)
+		if err != nil {
+			t.Fatalf("Не удалось прочитать конфигурацию: %v", err)
+		}
+		content = bytes.Replace(content, []byte("concurrency: 4"), []byte("concurrency: 1"), 1)
+		if err := os.WriteFile(configPath, content, 0644); err != nil {
+			t.Fatalf("Не удалось записать конфигурацию: %v", err)
+		}
+	}
+	writeSingleConcurrencyConfig(map[string]string{"alpha": "1h"})
+	fake := newFakeSyncs(map[string]time.Duration{"alpha": 300 * time.Millisecond, "beta": 50 * time.Millisecond})
+	reload := make(chan os.Signal, 1)
+
+	_, stdout, _, stop := startTestDaemon(t, configPath, fake, reload)
+	defer stop()
+	waitFor(t, "запуска alpha", func() bool { return fake.count("alpha") == 1 })
+
+	// beta добавляется, пока alpha синхронизируется с прежней конфигурацией, и ждет ее завершения
+	writeSingleConcurrencyConfig(map[string]string{"alpha": "1h", "beta": "1h"})
+	reload <- syscall.SIGHUP
+	waitFor(t, "запуска beta", func() bool { return fake.count("beta") == 1 })
+
+	fake.mu.Lock()
+	if fake.maxTotal != 1 {
+		t.Errorf("Одновременно выполнялось %d синхронизаций при concurrency: 1", fake.maxTotal)
+	}
+	fake.mu.Unlock()
+	if !strings.Contains(stdout.String(), "Конфигурация перечитана, пар: 2") {
+		t.Errorf("Нет сообщения о перезагрузке конфигурации:\n%s", stdout)
+	}
+}
+
+// Тест, что после перезагрузки скрываются секреты новой конфигурации, а секреты прежней - пока
+// с ней выполняются синхронизации
+func TestDaemonReloadSecrets(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeDaemonConfig(t, configPath, map[string]string{"alpha": "1h"}, "gitlab_token: \"old-secret\"\n")
+	fake := newFakeSyncs(map[string]time.Duration{"alpha": 300 * time.Millisecond})
+	reload := make(chan os.Signal, 1)
+
+	d, stdout, _, stop := startTestDaemon(t, configPath, fake, reload)
+	defer stop()
+	waitFor(t, "запуска alpha", func() bool { return fake.count("alpha") == 1 })
+
+	writeDaemonConfig(t, configPath, map[string]string{"alpha": "1h"}, "gitlab_token: \"new-secret\"\n")
+	reload <- syscall.SIGHUP
+	waitFor(t, "перезагрузки конфигурации", func() bool { return strings.Contains(stdout.String(), "Конфигурация перечитана") })
+	if got := d.redactor.String("old-secret new-secret"); got != "*** ***" {
+		t.Errorf("Во время синхронизации с прежней конфигурацией должны скрываться оба секрета, получено %q", got)
+	}
+
+	waitFor(t, "замены секретов после завершения alpha", func() bool {
+		return d.redactor.String("old-secret new-secret") == "old-secret ***"
+	})
+}
+
+// Тест, что долгая загрузка конфигурации по SIGHUP не останавливает завершение и запуск синхронизаций
+func TestDaemonReloadDoesNotBlockSyncs(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeDaemonConfig(t, configPath, map[string]string{"alpha": "50ms"})
+	fake := newFakeSyncs(nil)
+	release := make(chan struct{})
+	var releaseOnce stdsync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	var loads int
+	stdout, stderr := &syncBuffer{}, &syncBuffer{}
+	d := newDaemon(stdout, stderr, func() (*configs.Config, []configs.RepositoryPair, error) {
+		// Первая загрузка выполняется при запуске, следующие ждут release, как долгий секрет exec:
+		if loads++; loads > 1 {
+			<-release
+		}
+		return loadConfig(context.Background(), options{configPath: configPath, redactor: &redact.Redactor{}})
+	})
+	d.syncPair = fake.sync
+	cfg, pairs, err := d.load()
+	if err != nil {
+		t.Fatalf("Не удалось загрузить конфигурацию: %v", err)
+	}
+	d.configure(cfg, pairs)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reload := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		d.run(ctx, reload)
+	}()
+	defer func() {
+		unblock()
+		cancel()
+		<-done
+	}()
+	waitFor(t, "запуска alpha", func() bool { return fake.count("alpha") >= 1 })
+
+	reload <- syscall.SIGHUP
+	started := fake.count("alpha")
+	waitFor(t, "запусков alpha во время загрузки конфигурации", func() bool { return fake.count("alpha") >= started+3 })
+
+	unblock()
+	waitFor(t, "перезагрузки конфигурации", func() bool {
+		return strings.Contains(stdout.String(), "Конфигурация перечитана, пар: 1")
+	})
+	if stderr.String() != "" {
+		t.Errorf("Неожиданные ошибки:\n%s", stderr)
+	}
+}
+
+func TestDaemonWaitsForRunningSyncs(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeDaemonConfig(t, configPath, map[string]string{"alpha": "1h"})
+	fake := newFakeSyncs(map[string]time.Duration{"alpha": 200 * time.Millisecond})
+
+	_, _, _, stop := startTestDaemon(t, configPath, fake, make(chan os.Signal))
+	waitFor(t, "запуска alpha", func() bool { return fake.count("alpha") == 1 })
+	stop()
+
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	if fake.active["alpha"] != 0 {
+		t.Error("Планировщик остановился, не дождавшись синхронизации")
+	}
+	if fake.canceled != 1 {
+		t.Errorf("Синхронизация не получила отмену контекста: %d", fake.canceled)
+	}
+}
+
+func TestRunDaemonConfigError(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeDaemonConfig(t, configPath, map[string]string{"alpha": "every day"})
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"daemon", "--config", configPath}, &stdout, &stderr); code != exitConfig {
+		t.Fatalf("Ожидался код %d, получен %d", exitConfig, code)
+	}
+	if !strings.Contains(stderr.String(), "schedule:") {
+		t.Errorf("Нет ошибки расписания: %s", stderr.String())
+	}
+}
+
+func TestDaemonWebhookDebounce(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeDaemonConfig(t, configPath, map[string]string{"alpha": "0 0 1 1 *", "beta": "0 0 1 1 *"},
+		"webhook:\n  secret: \"hook-secret\"\n  debounce: 100ms\n")
+	fake := newFakeSyncs(map[string]time.Duration{"beta": 300 * time.Millisecond})
+
+	d, stdout, _, stop := startTestDaemon(t, configPath, fake, make(chan os.Signal))
+	defer stop()
+	server := httptest.NewServer(d.webhook)
+	defer server.Close()
+
+	push := func(name string) {
+		t.Helper()
+		body := `{"ref": "refs/heads/main", "repository": {"clone_url": "` + filepath.Join(filepath.Dir(configPath), name+"-private.git") + `"}}`
+		mac := hmac.New(sha256.New, []byte("hook-secret"))
+		mac.Write([]byte(body))
+		req, _ := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(body))
+		req.Header.Set("X-Gitea-Event", "push")
+		req.Header.Set("X-Gitea-Signature", hex.EncodeToString(mac.Sum(nil)))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("Событие не отправлено: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusAccepted {
+			t.Fatalf("Ожидался код 202, получен %d", resp.StatusCode)
+		}
+	}
+
+	// Серия событий объединяется в одну синхронизацию
+	for i := 0; i < 3; i++ {
+		push("alpha")
+	}
+	waitFor(t, "синхронизации alpha по событию", func() bool { return fake.count("alpha") == 1 })
+	time.Sleep(300 * time.Millisecond)
+	if got := fake.count("alpha"); got != 1 {
+		t.Errorf("Серия событий привела к %d синхронизациям", got)
+	}
+	if fake.count("beta") != 0 {
+		t.Error("Событие другой пары запустило синхронизацию beta")
+	}
+
+	// Событие во время синхронизации повторяет ее после завершения
+	push("beta")
+	waitFor(t, "синхронизации beta по событию", func() bool { return fake.count("beta") == 1 })
+	push("beta")
+	waitFor(t, "повторной синхронизации beta", func() bool { return fake.count("beta") == 2 })
+
+	if len(fake.overlaps) > 0 {
+		t.Errorf("Синхронизации пары выполнялись одновременно: %v", fake.overlaps)
+	}
+	if !strings.Contains(stdout.String(), "[alpha] Событие push от gitea (refs/heads/main): синхронизация через 100ms") {
+		t.Errorf("Нет сообщения о событии:\n%s", stdout.String())
+	}
+}
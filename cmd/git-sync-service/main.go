@@ -1,39 +1,232 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
+	"io"
 	"log"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
-	"git-sync/configs"
-	"git-sync/internal/repository"
-	"git-sync/internal/sync"
+	"git-sync/internal/redact"
 )
 
+// version версия сервиса, задается при сборке: -ldflags "-X main.version=1.2.3"
+var version = "dev"
+
+const (
+	// defaultConfigPath путь к конфигурации, если он не задан флагом или переменной окружения
+	defaultConfigPath = "configs/config.yaml"
+	// configEnvVar переменная окружения с путем к конфигурации
+	configEnvVar = "GIT_SYNC_CONFIG"
+)
+
+// Коды завершения процесса
+const (
+	// exitOK все пары обработаны без ошибок и конфликтов
+	exitOK = 0
+	// exitFailed обработка хотя бы одной пары завершилась ошибкой
+	exitFailed = 1
+	// exitConfig ошибка конфигурации или аргументов командной строки
+	exitConfig = 2
+	// exitConflicts ошибок нет, но остались конфликты, требующие ручного разрешения
+	exitConflicts = 3
+	// exitInterrupted работа остановлена сигналом SIGINT или SIGTERM
+	exitInterrupted = 130
+)
+
+// options общие параметры команд
+type options struct {
+	configPath string
+	pairs      []string
+	// format формат вывода команды plan: text или json
+	format string
+	// unusedFor срок, после которого cache prune удаляет неиспользуемые зеркала; 0 - не учитывать
+	unusedFor time.Duration
+	// dryRun команда cache prune только выводит зеркала, которые были бы удалены
+	dryRun bool
+	// redactor скрывает секреты конфигурации в выводе команды и логах
+	redactor *redact.Redactor
+}
+
+// command подкоманда сервиса
+type command struct {
+	name        string
+	description string
+	// usesConfig означает, что команда принимает флаги --config и --pair
+	usesConfig bool
+	// run выполняет команду; ctx отменяется при получении SIGINT или SIGTERM
+	run func(ctx context.Context, opts options, stdout, stderr io.Writer) int
+	// setup регистрирует собственные флаги команды
+	setup func(flags *flag.FlagSet, opts *options)
+}
+
+var commands = []command{
+	{"sync", "синхронизировать пары репозиториев (команда по умолчанию)", true, runSync, nil},
+	{"daemon", "синхронизировать пары по расписанию, SIGHUP перечитывает конфигурацию", true, runDaemon, nil},
+	{"validate", "загрузить и проверить конфигурацию", true, runValidate, nil},
+	{"status", "показать различия веток и тегов без изменения репозиториев", true, runStatus, nil},
+	{"plan", "показать изменения ссылок, которые выполнила бы синхронизация", true, runPlan, setupPlan},
+	{"cache prune", "удалить из кэша зеркала репозиториев, которых нет в конфигурации", true, runCachePrune, setupCachePrune},
+	{"version", "показать версию", false, runVersion, nil},
+}
+
 func main() {
-	// Загрузка конфигурации
-	cfg, err := configs.LoadConfig("configs/config.yaml")
-	if err != nil {
-		log.Fatalf("Ошибка загрузки конфигурации: %v", err)
-	}
-
-	// Инициализация менеджера репозиториев
-	repoManager := repository.NewManager(cfg.TempDir)
-
-	// Инициализация логики синхронизации
-	syncLogic := sync.NewLogic(repoManager)
-
-	// Выполнение синхронизации для каждой пары репозиториев
-	for _, repoPair := range cfg.Repositories {
-		fmt.Printf("Синхронизация репозиториев: %s <-> %s\n", repoPair.GitlabURL, repoPair.PrivateRepoURL)
-		err := syncLogic.Synchronize(repoPair.GitlabURL, repoPair.PrivateRepoURL, cfg.GitlabToken, cfg.SSHKeyPath)
-		if err != nil {
-			log.Printf("Ошибка синхронизации %s <-> %s: %v\n", repoPair.GitlabURL, repoPair.PrivateRepoURL, err)
-		} else {
-			fmt.Printf("Синхронизация %s <-> %s завершена успешно.\n", repoPair.GitlabURL, repoPair.PrivateRepoURL)
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run разбирает аргументы командной строки, выполняет команду и возвращает код завершения.
+// Флаг --config можно указать как до, так и после имени команды.
+// Сообщения пакета log выводятся в stderr; секреты из конфигурации скрываются во всем выводе команды.
+// Вывод можно писать из нескольких горутин одновременно.
+func run(args []string, stdout, stderr io.Writer) int {
+	redactor := &redact.Redactor{}
+	stdout = &lockedWriter{w: redactor.Writer(stdout)}
+	stderr = &lockedWriter{w: redactor.Writer(stderr)}
+	logOutput := log.Writer()
+	log.SetOutput(stderr)
+	defer log.SetOutput(logOutput)
+
+	global := flag.NewFlagSet("git-sync-service", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	global.Usage = func() { printUsage(stderr) }
+	configPath := global.String("config", "", "путь к файлу конфигурации (по умолчанию $"+configEnvVar+" или "+defaultConfigPath+")")
+	if err := global.Parse(args); err != nil {
+		return parseExitCode(err)
+	}
+
+	args = global.Args()
+	name := "sync"
+	if len(args) > 0 {
+		name, args = args[0], args[1:]
+	}
+	// Команда из двух слов, например cache prune
+	if len(args) > 0 {
+		if _, ok := findCommand(name + " " + args[0]); ok {
+			name, args = name+" "+args[0], args[1:]
 		}
 	}
 
-	fmt.Println("Сервис синхронизации завершил работу.")
-	os.Exit(0)
+	cmd, ok := findCommand(name)
+	if !ok {
+		fmt.Fprintf(stderr, "Неизвестная команда %q\n\n", name)
+		printUsage(stderr)
+		return exitConfig
+	}
+
+	flags := flag.NewFlagSet("git-sync-service "+cmd.name, flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	opts := options{redactor: redactor}
+	if cmd.usesConfig {
+		flags.StringVar(&opts.configPath, "config", *configPath, "путь к файлу конфигурации")
+		flags.Var((*stringList)(&opts.pairs), "pair", "имя пары репозиториев; можно указать несколько раз")
+	}
+	if cmd.setup != nil {
+		cmd.setup(flags, &opts)
+	}
+	if err := flags.Parse(args); err != nil {
+		return parseExitCode(err)
+	}
+	if flags.NArg() > 0 {
+		fmt.Fprintf(stderr, "Лишние аргументы команды %s: %s\n", cmd.name, strings.Join(flags.Args(), " "))
+		return exitConfig
+	}
+
+	opts.configPath = resolveConfigPath(opts.configPath)
+	ctx, stop := shutdownContext(stderr)
+	defer stop()
+	code := cmd.run(ctx, opts, stdout, stderr)
+	if ctx.Err() != nil {
+		return exitInterrupted
+	}
+	return code
+}
+
+// shutdownContext возвращает контекст, который отменяется первым сигналом SIGINT или SIGTERM:
+// команда не начинает новых пар и ссылок, а уже начатые push завершаются. Повторный сигнал
+// завершает процесс немедленно. Функция stop прекращает перехват сигналов.
+func shutdownContext(stderr io.Writer) (context.Context, func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case sig := <-signals:
+			fmt.Fprintf(stderr, "Получен сигнал %s: завершение после текущих операций, повторный сигнал прервет их\n", sig)
+			cancel()
+		case <-done:
+			return
+		}
+		select {
+		case <-signals:
+			fmt.Fprintln(stderr, "Работа прервана")
+			os.Exit(exitInterrupted)
+		case <-done:
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
+
+// resolveConfigPath выбирает путь к конфигурации: флаг, затем переменная окружения, затем путь по умолчанию
+func resolveConfigPath(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if env := os.Getenv(configEnvVar); env != "" {
+		return env
+	}
+	return defaultConfigPath
+}
+
+// findCommand ищет подкоманду по имени
+func findCommand(name string) (command, bool) {
+	for _, cmd := range commands {
+		if cmd.name == name {
+			return cmd, true
+		}
+	}
+	return command{}, false
+}
+
+// parseExitCode возвращает код завершения для ошибки разбора флагов
+func parseExitCode(err error) int {
+	if err == flag.ErrHelp {
+		return exitOK
+	}
+	return exitConfig
+}
+
+// printUsage выводит справку по командам
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "Использование: git-sync-service [--config <путь>] <команда> [--pair <имя>]...")
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Команды:")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.description)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Путь к конфигурации берется из флага --config, переменной окружения %s или равен %s.\n", configEnvVar, defaultConfigPath)
+}
+
+// stringList значение флага, который можно указать несколько раз
+type stringList []string
+
+func (s *stringList) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringList) Set(value string) error {
+	*s = append(*s, value)
+	return nil
 }
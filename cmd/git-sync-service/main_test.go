@@ -1,9 +1,23 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
+
+	"git-sync/configs"
+	"git-sync/internal/redact"
+	"git-sync/internal/sync"
 )
 
 // Тест для проверки, что main функция не паникует при отсутствии конфигурации
@@ -262,3 +276,415 @@ func BenchmarkConfigPath(b *testing.B) {
 		_ = filepath.IsLocal(configPath)
 	}
 }
+
+// writeTestConfig создает файл конфигурации с двумя парами репозиториев
+func writeTestConfig(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	configContent := `
+gitlab_token: "test_token"
+temp_dir: "` + filepath.Join(dir, "work") + `"
+repositories:
+  - gitlab_url: "https://gitlab.com/user/repo1.git"
+    private_repo_url: "https://private.com/user/repo1.git"
+  - name: "docs"
+    gitlab_url: "https://gitlab.com/user/documentation.git"
+    private_repo_url: "https://private.com/user/documentation.git"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+	return configPath
+}
+
+func TestRunVersion(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"version"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Ожидался код %d, получен %d: %s", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), version) {
+		t.Errorf("Вывод не содержит версию: %q", stdout.String())
+	}
+}
+
+func TestRunValidateConfigSources(t *testing.T) {
+	configPath := writeTestConfig(t)
+
+	testCases := []struct {
+		name string
+		args []string
+		env  string
+	}{
+		{"FlagAfterCommand", []string{"validate", "--config", configPath}, ""},
+		{"FlagBeforeCommand", []string{"--config", configPath, "validate"}, ""},
+		{"EnvVar", []string{"validate"}, configPath},
+		{"FlagOverridesEnv", []string{"validate", "--config", configPath}, "/nonexistent/config.yaml"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(configEnvVar, tc.env)
+
+			var stdout, stderr bytes.Buffer
+			if code := run(tc.args, &stdout, &stderr); code != exitOK {
+				t.Fatalf("Ожидался код %d, получен %d: %s", exitOK, code, stderr.String())
+			}
+			if !strings.Contains(stdout.String(), "пар репозиториев: 2") {
+				t.Errorf("Неожиданный вывод: %q", stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunValidateErrors(t *testing.T) {
+	configPath := writeTestConfig(t)
+	t.Setenv(configEnvVar, "")
+
+	// Конфигурация без токена для HTTPS-адресов GitLab не проходит проверку
+	invalidPath := filepath.Join(t.TempDir(), "invalid.yaml")
+	invalidContent := "temp_dir: \"" + t.TempDir() + "\"\nrepositories:\n" +
+		"  - gitlab_url: \"https://gitlab.com/user/repo1.git\"\n    private_repo_url: \"/srv/git/repo1.git\"\n"
+	if err := os.WriteFile(invalidPath, []byte(invalidContent), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected int
+	}{
+		{"MissingConfig", []string{"validate", "--config", filepath.Join(t.TempDir(), "missing.yaml")}, exitConfig},
+		{"UnknownPair", []string{"validate", "--config", configPath, "--pair", "unknown"}, exitConfig},
+		{"InvalidConfig", []string{"validate", "--config", invalidPath}, exitConfig},
+		{"UnknownCommand", []string{"deploy"}, exitConfig},
+		{"UnknownFlag", []string{"validate", "--verbose"}, exitConfig},
+		{"ExtraArguments", []string{"validate", "--config", configPath, "extra"}, exitConfig},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			if code := run(tc.args, &stdout, &stderr); code != tc.expected {
+				t.Errorf("Ожидался код %d, получен %d: %s", tc.expected, code, stderr.String())
+			}
+		})
+	}
+}
+
+func TestRunValidateSelectedPairs(t *testing.T) {
+	configPath := writeTestConfig(t)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"validate", "--config", configPath, "--pair", "docs", "--pair", "repo1"}, &stdout, &stderr)
+	if code != exitOK {
+		t.Fatalf("Ожидался код %d, получен %d: %s", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "пар репозиториев: 2") {
+		t.Errorf("Неожиданный вывод: %q", stdout.String())
+	}
+}
+
+// Тест, что адрес репозитория пары с числовым gitlab_project_id получается через GitLab API
+func TestLoadConfigNumericProjectID(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v4/projects/42" || r.Header.Get("PRIVATE-TOKEN") != "secret-token" {
+			http.Error(w, `{"message":"404 Not found"}`, http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"id":42,"path_with_namespace":"group/app","http_url_to_repo":"http://%s/group/app.git"}`, r.Host)
+	}))
+	defer server.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := "temp_dir: \"" + t.TempDir() + "\"\ngitlab_base_url: \"" + server.URL + "\"\ngitlab_token: \"secret-token\"\n" +
+		"repositories:\n  - gitlab_project_id: 42\n    private_repo_url: \"/srv/git/app.git\"\n"
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+
+	_, pairs, err := loadConfig(context.Background(), options{configPath: configPath, redactor: &redact.Redactor{}})
+	if err != nil {
+		t.Fatalf("Не удалось загрузить конфигурацию: %v", err)
+	}
+	if len(pairs) != 1 || pairs[0].GitlabURL != server.URL+"/group/app.git" {
+		t.Errorf("Адрес репозитория не получен через API: %+v", pairs)
+	}
+
+	// Проект, которого нет в GitLab, - ошибка конфигурации
+	content = strings.Replace(content, "gitlab_project_id: 42", "gitlab_project_id: 7", 1)
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"validate", "--config", configPath}, &stdout, &stderr); code != exitConfig {
+		t.Errorf("Ожидался код %d, получен %d", exitConfig, code)
+	}
+	if !strings.Contains(stderr.String(), "gitlab_project_id 7") {
+		t.Errorf("Ошибка не называет проект: %q", stderr.String())
+	}
+}
+
+// Тест, что gitlab_base_url используется только для пар на хосте экземпляра, а пары других
+// экземпляров GitLab обращаются к API своего хоста
+func TestGitlabAPIInstanceHost(t *testing.T) {
+	newServer := func(project string, id int) (*httptest.Server, *[]string) {
+		var requests []string
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.URL.EscapedPath())
+			if r.URL.EscapedPath() != "/api/v4/projects/"+project {
+				http.Error(w, `{"message":"404 Project Not Found"}`, http.StatusNotFound)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"id":%d}`, id)
+		}))
+		t.Cleanup(server.Close)
+		return server, &requests
+	}
+	primary, primaryRequests := newServer("group%2Fapp", 1)
+	other, otherRequests := newServer("team%2Flib", 2)
+	// Второй экземпляр доступен по другому имени хоста
+	otherURL := strings.Replace(other.URL, "127.0.0.1", "localhost", 1)
+
+	cfg := &configs.Config{GitlabBaseURL: primary.URL, GitlabToken: "secret-token"}
+	factory := gitlabAPI(cfg)
+	for _, pair := range []configs.RepositoryPair{
+		{GitlabURL: primary.URL + "/group/app.git", PrivateRepoURL: "/srv/git/app.git"},
+		{GitlabURL: otherURL + "/team/lib.git", PrivateRepoURL: "/srv/git/lib.git"},
+	} {
+		api, err := factory(context.Background(), pair, configs.Credential{})
+		if err != nil || api == nil {
+			t.Fatalf("Не удалось создать клиент API для %s: %v", pair.GitlabURL, err)
+		}
+	}
+
+	if want := []string{"/api/v4/projects/group%2Fapp"}; !slices.Equal(*primaryRequests, want) {
+		t.Errorf("Запросы к экземпляру gitlab_base_url: %v, ожидалось %v", *primaryRequests, want)
+	}
+	if want := []string{"/api/v4/projects/team%2Flib"}; !slices.Equal(*otherRequests, want) {
+		t.Errorf("Запросы к второму экземпляру: %v, ожидалось %v", *otherRequests, want)
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	ok := &sync.Result{Branches: []sync.RefResult{{Name: "main", Status: sync.StatusUpdated}}}
+	conflicted := &sync.Result{Branches: []sync.RefResult{{Name: "main", Status: sync.StatusConflict}}}
+
+	testCases := []struct {
+		name     string
+		reports  []pairReport
+		expected int
+	}{
+		{"NoPairs", nil, exitOK},
+		{"AllGood", []pairReport{{name: "a", result: ok}, {name: "b", result: ok}}, exitOK},
+		{"Conflicts", []pairReport{{name: "a", result: ok}, {name: "b", result: conflicted}}, exitConflicts},
+		{"Failed", []pairReport{{name: "a", result: conflicted}, {name: "b", result: &sync.Result{}, err: errors.New("clone")}}, exitFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := exitCode(tc.reports); got != tc.expected {
+				t.Errorf("Ожидался код %d, получен %d", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestPrintSummary(t *testing.T) {
+	reports := []pairReport{
+		{name: "repo1", result: &sync.Result{
+			Branches: []sync.RefResult{
+				{Name: "main", Status: sync.StatusUpdated},
+				{Name: "feature", Status: sync.StatusCreated},
+				{Name: "diverged", Status: sync.StatusConflict},
+				{Name: "release", Status: sync.StatusBlocked},
+			},
+			Duration: 1500 * time.Millisecond,
+		}},
+		{name: "docs", result: &sync.Result{}, err: errors.New("repository not found")},
+	}
+
+	var out bytes.Buffer
+	printSummary(&out, reports)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Ожидалось 4 строки сводки, получено %d:\n%s", len(lines), out.String())
+	}
+	if fields := strings.Fields(lines[1]); strings.Join(fields, " ") != "repo1 конфликты 1 1 0 0 1 1 1.5s" {
+		t.Errorf("Неверная строка сводки: %q", lines[1])
+	}
+	if fields := strings.Fields(lines[2]); fields[0] != "docs" || fields[1] != "ошибка" {
+		t.Errorf("Неверная строка сводки: %q", lines[2])
+	}
+	if !strings.Contains(lines[3], "repository not found") {
+		t.Errorf("Ожидалась ошибка пары docs: %q", lines[3])
+	}
+}
+
+func TestRunSyncFailedPair(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	configContent := "temp_dir: \"" + filepath.Join(dir, "work") + "\"\n" +
+		"repositories:\n" +
+		"  - gitlab_url: \"" + filepath.Join(dir, "missing-gitlab.git") + "\"\n" +
+		"    private_repo_url: \"" + filepath.Join(dir, "missing-private.git") + "\"\n"
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"sync", "--config", configPath}, &stdout, &stderr); code != exitFailed {
+		t.Errorf("Ожидался код %d, получен %d", exitFailed, code)
+	}
+	if !strings.Contains(stdout.String(), "missing-gitlab") || !strings.Contains(stdout.String(), "ошибка") {
+		t.Errorf("Сводка не содержит ошибку пары: %s", stdout.String())
+	}
+}
+
+func TestRunSyncConcurrency(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	configContent := "temp_dir: \"" + filepath.Join(dir, "work") + "\"\n" +
+		"concurrency: 3\n" +
+		"repositories:\n"
+	names := []string{"alpha", "beta", "gamma", "delta"}
+	for _, name := range names {
+		configContent += "  - name: \"" + name + "\"\n" +
+			"    gitlab_url: \"" + filepath.Join(dir, name+"-gitlab.git") + "\"\n" +
+			"    private_repo_url: \"" + filepath.Join(dir, name+"-private.git") + "\"\n"
+	}
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"sync", "--config", configPath}, &stdout, &stderr); code != exitFailed {
+		t.Errorf("Ожидался код %d, получен %d", exitFailed, code)
+	}
+
+	// Строки пар начинаются с имени пары, сводка выводится в порядке конфигурации
+	output, summary, _ := strings.Cut(stdout.String(), "\n\n")
+	for _, line := range strings.Split(output, "\n") {
+		name := strings.TrimPrefix(strings.SplitN(line, "] ", 2)[0], "[")
+		if !strings.HasPrefix(line, "[") || !strings.Contains(line, name+"-gitlab.git") {
+			t.Errorf("Строка вывода без префикса пары: %q", line)
+		}
+	}
+	for _, line := range strings.Split(strings.TrimSpace(stderr.String()), "\n") {
+		if !strings.Contains(line, "[") || !strings.Contains(line, "] ") {
+			t.Errorf("Строка журнала без префикса пары: %q", line)
+		}
+	}
+	lines := strings.Split(summary, "\n")
+	for i, name := range names {
+		if fields := strings.Fields(lines[i+1]); len(fields) == 0 || fields[0] != name {
+			t.Errorf("Ожидалась пара %s в строке сводки %d: %q", name, i+1, lines[i+1])
+		}
+	}
+}
+
+func TestShutdownContext(t *testing.T) {
+	var stderr bytes.Buffer
+	ctx, stop := shutdownContext(&lockedWriter{w: &stderr})
+	defer stop()
+
+	// Первый сигнал перехватывается и только отменяет контекст
+	if err := syscall.Kill(os.Getpid(), syscall.SIGINT); err != nil {
+		t.Fatalf("Не удалось отправить сигнал: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("Контекст не отменен после SIGINT")
+	}
+}
+
+func TestRunRedactsSecrets(t *testing.T) {
+	const secret = "glpat-s3cret-value"
+	t.Setenv("GIT_SYNC_TEST_TOKEN", secret)
+
+	// Секрет попадает в путь репозитория, а значит и в сообщения об ошибках и логи
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	configContent := "gitlab_token: \"${env:GIT_SYNC_TEST_TOKEN}\"\n" +
+		"temp_dir: \"" + filepath.Join(dir, "work") + "\"\n" +
+		"repositories:\n" +
+		"  - name: \"secret\"\n" +
+		"    gitlab_url: \"" + filepath.Join(dir, "missing-"+secret+".git") + "\"\n" +
+		"    private_repo_url: \"" + filepath.Join(dir, "missing-private.git") + "\"\n"
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"sync", "--config", configPath}, &stdout, &stderr); code != exitFailed {
+		t.Errorf("Ожидался код %d, получен %d", exitFailed, code)
+	}
+	output := stdout.String() + stderr.String()
+	if strings.Contains(output, secret) {
+		t.Errorf("Вывод содержит секрет:\n%s", output)
+	}
+	if !strings.Contains(output, "missing-***.git") {
+		t.Errorf("Вывод не содержит скрытый секрет:\n%s", output)
+	}
+}
+
+func TestRunSecretReferenceErrors(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	configContent := "gitlab_token: \"file:" + filepath.Join(dir, "missing-token") + "\"\n" +
+		"temp_dir: \"" + filepath.Join(dir, "work") + "\"\n" +
+		"repositories:\n" +
+		"  - gitlab_url: \"https://gitlab.com/user/repo1.git\"\n" +
+		"    private_repo_url: \"https://private.com/user/repo1.git\"\n"
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"validate", "--config", configPath}, &stdout, &stderr); code != exitConfig {
+		t.Errorf("Ожидался код %d, получен %d", exitConfig, code)
+	}
+	if !strings.Contains(stderr.String(), "gitlab_token: не удалось прочитать секрет из файла") {
+		t.Errorf("Неожиданная ошибка: %s", stderr.String())
+	}
+}
+
+func TestRunCachePrune(t *testing.T) {
+	configPath := writeTestConfig(t)
+	cacheDir := filepath.Join(filepath.Dir(configPath), "work", "mirrors")
+	stale := filepath.Join(cacheDir, "removed-pair", "gitlab-removed.git")
+	if err := os.MkdirAll(stale, 0755); err != nil {
+		t.Fatalf("Не удалось создать директорию: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"cache", "prune", "--config", configPath, "--dry-run"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Ожидался код %d, получен %d: %s", exitOK, code, stderr.String())
+	}
+	if !strings.Contains(stdout.String(), "Будет удалено: поврежденное зеркало ("+stale) {
+		t.Errorf("Вывод не содержит зеркало для удаления: %q", stdout.String())
+	}
+	if _, err := os.Stat(stale); err != nil {
+		t.Errorf("Dry-run удалил зеркало: %v", err)
+	}
+
+	stdout.Reset()
+	if code := run([]string{"--config", configPath, "cache", "prune"}, &stdout, &stderr); code != exitOK {
+		t.Fatalf("Ожидался код %d, получен %d: %s", exitOK, code, stderr.String())
+	}
+	if _, err := os.Stat(stale); !os.IsNotExist(err) {
+		t.Errorf("Зеркало не удалено: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Удалено зеркал в "+cacheDir+": 1") {
+		t.Errorf("Неверная сводка: %q", stdout.String())
+	}
+
+	if code := run([]string{"cache", "prune", "--config", configPath, "--pair", "docs"}, &stdout, &stderr); code != exitConfig {
+		t.Errorf("Ожидался код %d для --pair, получен %d", exitConfig, code)
+	}
+}
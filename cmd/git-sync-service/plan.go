@@ -0,0 +1,115 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"text/tabwriter"
+
+	"git-sync/configs"
+	"git-sync/internal/sync"
+)
+
+// Форматы вывода плана
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
+// pairPlan план одной пары в выводе команды plan
+type pairPlan struct {
+	Pair string `json:"pair"`
+	*sync.Plan
+	Error string `json:"error,omitempty"`
+}
+
+// setupPlan регистрирует флаги команды plan
+func setupPlan(flags *flag.FlagSet, opts *options) {
+	flags.StringVar(&opts.format, "format", formatText, "формат вывода: text или json")
+}
+
+// runPlan выводит изменения ссылок, которые выполнила бы синхронизация, ничего не отправляя
+func runPlan(ctx context.Context, opts options, stdout, stderr io.Writer) int {
+	if opts.format != formatText && opts.format != formatJSON {
+		fmt.Fprintf(stderr, "Неизвестный формат вывода %q, ожидается text или json\n", opts.format)
+		return exitConfig
+	}
+
+	cfg, pairs, err := loadConfig(ctx, opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitConfig
+	}
+	syncLogic := newLogic(cfg)
+
+	// Планы строятся в пуле, а выводятся в порядке конфигурации
+	plans := make([]pairPlan, len(pairs))
+	forEachPair(pairs, cfg.PairConcurrency(), func(i int, repoPair configs.RepositoryPair) {
+		var plan *sync.Plan
+		gitlabCred, privateCred, err := cfg.PairCredentials(repoPair)
+		if err == nil {
+			ctx, cancel := pairContext(ctx, cfg)
+			defer cancel()
+			logger := pairLogger(log.Writer(), repoPair, log.Flags())
+			plan, err = syncLogic.WithLogger(logger).Plan(ctx, repoPair, gitlabCred, privateCred)
+		}
+		plans[i] = pairPlan{Pair: repoPair.PairName(), Plan: plan}
+		if err != nil {
+			plans[i].Error = err.Error()
+		}
+	})
+
+	code := exitOK
+	for _, plan := range plans {
+		if plan.Error != "" {
+			code = exitFailed
+		}
+	}
+
+	if opts.format == formatJSON {
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(plans); err != nil {
+			fmt.Fprintf(stderr, "Ошибка вывода плана: %v\n", err)
+			return exitFailed
+		}
+		return code
+	}
+
+	for _, plan := range plans {
+		printPlan(stdout, plan)
+	}
+	return code
+}
+
+// printPlan выводит план пары в виде таблицы
+func printPlan(w io.Writer, plan pairPlan) {
+	fmt.Fprintf(w, "Пара %s: %s <-> %s\n", plan.Pair, plan.GitlabURL, plan.PrivateRepoURL)
+	if plan.Error != "" {
+		fmt.Fprintf(w, "  Ошибка: %s\n", plan.Error)
+		return
+	}
+	if len(plan.Updates) == 0 {
+		fmt.Fprintln(w, "  Изменений нет")
+		return
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "  ССЫЛКА\tСТОРОНА\tБЫЛО\tСТАНЕТ\tДЕЙСТВИЕ\tПРИЧИНА")
+	for _, u := range plan.Updates {
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\t%s\t%s\n",
+			u.Ref, orDash(u.Side), shortSHA(u.OldHash), shortSHA(u.NewHash), u.Action, orDash(u.Reason))
+	}
+	tw.Flush()
+}
+
+// shortSHA сокращает SHA из плана или возвращает "-", если значения нет
+func shortSHA(sha string) string {
+	if len(sha) < 7 {
+		return orDash(sha)
+	}
+	return sha[:7]
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"git-sync/internal/sync"
+)
+
+func TestRunPlanJSON(t *testing.T) {
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "config.yaml")
+	configContent := "temp_dir: \"" + filepath.Join(dir, "work") + "\"\n" +
+		"repositories:\n" +
+		"  - name: \"missing\"\n" +
+		"    gitlab_url: \"" + filepath.Join(dir, "missing-gitlab.git") + "\"\n" +
+		"    private_repo_url: \"" + filepath.Join(dir, "missing-private.git") + "\"\n"
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"plan", "--config", configPath, "--format", "json"}, &stdout, &stderr); code != exitFailed {
+		t.Errorf("Ожидался код %d, получен %d: %s", exitFailed, code, stderr.String())
+	}
+
+	var plans []struct {
+		Pair    string               `json:"pair"`
+		Updates []sync.PlannedUpdate `json:"updates"`
+		Error   string               `json:"error"`
+	}
+	if err := json.Unmarshal(stdout.Bytes(), &plans); err != nil {
+		t.Fatalf("Вывод не является JSON: %v\n%s", err, stdout.String())
+	}
+	if len(plans) != 1 || plans[0].Pair != "missing" || plans[0].Error == "" {
+		t.Errorf("Ожидался план пары missing с ошибкой, получено %+v", plans)
+	}
+}
+
+func TestRunPlanUnknownFormat(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	if code := run([]string{"plan", "--format", "yaml"}, &stdout, &stderr); code != exitConfig {
+		t.Errorf("Ожидался код %d, получен %d", exitConfig, code)
+	}
+}
+
+func TestPrintPlan(t *testing.T) {
+	plan := pairPlan{
+		Pair: "repo1",
+		Plan: &sync.Plan{
+			GitlabURL:      "https://gitlab.com/user/repo1.git",
+			PrivateRepoURL: "git@private.com:user/repo1.git",
+			Updates: []sync.PlannedUpdate{
+				{Ref: "refs/heads/main", Side: sync.SidePrivate, OldHash: strings.Repeat("a", 40), NewHash: strings.Repeat("b", 40), Action: sync.ActionFastForward},
+				{Ref: "refs/tags/v2.0", Action: sync.ActionConflict, Reason: "тег указывает на разные объекты"},
+			},
+		},
+	}
+
+	var out bytes.Buffer
+	printPlan(&out, plan)
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("Ожидалось 4 строки, получено %d:\n%s", len(lines), out.String())
+	}
+	if got := strings.Join(strings.Fields(lines[2]), " "); got != "refs/heads/main private aaaaaaa bbbbbbb fast-forward -" {
+		t.Errorf("Неверная строка плана: %q", got)
+	}
+	if got := strings.Join(strings.Fields(lines[3]), " "); got != "refs/tags/v2.0 - - - conflict тег указывает на разные объекты" {
+		t.Errorf("Неверная строка плана: %q", got)
+	}
+}
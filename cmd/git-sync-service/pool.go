@@ -0,0 +1,56 @@
+package main
+
+import (
+	"io"
+	"log"
+	stdsync "sync"
+
+	"git-sync/configs"
+)
+
+// lockedWriter сериализует запись из нескольких горутин: каждое сообщение log.Logger
+// и fmt.Fprint* записывается одним вызовом Write, поэтому строки пар не перемешиваются
+type lockedWriter struct {
+	mu stdsync.Mutex
+	w  io.Writer
+}
+
+func (w *lockedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.w.Write(p)
+}
+
+// pairLogger создает журнал пары: каждая строка начинается с имени пары,
+// чтобы вывод одновременно синхронизируемых пар можно было прочитать
+func pairLogger(w io.Writer, pair configs.RepositoryPair, flags int) *log.Logger {
+	return log.New(w, "["+pair.PairName()+"] ", flags|log.Lmsgprefix)
+}
+
+// forEachPair вызывает process для каждой пары, обрабатывая одновременно не более concurrency пар.
+// Возвращает управление, когда обработаны все пары.
+func forEachPair(pairs []configs.RepositoryPair, concurrency int, process func(i int, pair configs.RepositoryPair)) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	if concurrency > len(pairs) {
+		concurrency = len(pairs)
+	}
+
+	indexes := make(chan int)
+	var wg stdsync.WaitGroup
+	for worker := 0; worker < concurrency; worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				process(i, pairs[i])
+			}
+		}()
+	}
+	for i := range pairs {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"git-sync/internal/sync"
+)
+
+// pairReport итог обработки одной пары репозиториев
+type pairReport struct {
+	name   string
+	result *sync.Result
+	err    error
+}
+
+// outcome возвращает итог пары для сводной таблицы
+func (r pairReport) outcome() string {
+	switch {
+	case r.err != nil:
+		return "ошибка"
+	case r.result.HasConflicts():
+		return "конфликты"
+	default:
+		return "ok"
+	}
+}
+
+// printSummary выводит сводную таблицу по всем парам
+func printSummary(w io.Writer, reports []pairReport) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ПАРА\tИТОГ\tОБНОВЛЕНО\tСОЗДАНО\tУДАЛЕНО\tПРОПУЩЕНО\tКОНФЛИКТЫ\tЗАБЛОКИРОВАНО\tВРЕМЯ")
+	for _, report := range reports {
+		summary := report.result.Summary()
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%d\t%d\t%s\n",
+			report.name, report.outcome(), summary.Updated, summary.Created, summary.Deleted,
+			summary.Skipped, summary.Conflicted, summary.Blocked, report.result.Duration.Round(time.Millisecond))
+	}
+	tw.Flush()
+
+	for _, report := range reports {
+		if report.err != nil {
+			fmt.Fprintf(w, "Ошибка %s: %v\n", report.name, report.err)
+		}
+	}
+}
+
+// exitCode выбирает код завершения по итогам всех пар: ошибки важнее конфликтов
+func exitCode(reports []pairReport) int {
+	code := exitOK
+	for _, report := range reports {
+		if report.err != nil {
+			return exitFailed
+		}
+		if report.result.HasConflicts() {
+			code = exitConflicts
+		}
+	}
+	return code
+}
@@ -3,24 +3,222 @@ package configs
 import (
 	"fmt"
 	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 // Config структура для хранения конфигурации сервиса
 type Config struct {
-	GitlabToken  string           `yaml:"gitlab_token"`
-	SSHKeyPath   string           `yaml:"ssh_key_path"`
-	TempDir      string           `yaml:"temp_dir"`
-	Repositories []RepositoryPair `yaml:"repositories"`
+	// GitlabToken токен GitLab или ссылка на него: ${env:NAME}, file:<путь>, exec:<команда>
+	GitlabToken string `yaml:"gitlab_token"`
+	// GitlabBaseURL адрес экземпляра GitLab, например https://gitlab.com. Нужен для пар, заданных gitlab_project_id
+	GitlabBaseURL string `yaml:"gitlab_base_url"`
+	// GitlabAPIPath путь к API GitLab относительно GitlabBaseURL. По умолчанию /api/v4
+	GitlabAPIPath string `yaml:"gitlab_api_path"`
+	SSHKeyPath    string `yaml:"ssh_key_path"`
+	// SSHKnownHosts файл known_hosts по умолчанию для всех SSH-подключений
+	SSHKnownHosts string `yaml:"ssh_known_hosts"`
+	// SSHHostKeyCheck режим проверки ключей SSH-серверов по умолчанию: strict, accept-new или insecure
+	SSHHostKeyCheck string `yaml:"ssh_host_key_check"`
+	TempDir         string `yaml:"temp_dir"`
+	// CacheDir директория постоянного кэша bare-зеркал репозиториев. По умолчанию <temp_dir>/mirrors
+	CacheDir string `yaml:"cache_dir"`
+	StateDir string `yaml:"state_dir"`
+	// Concurrency число пар, которые синхронизируются одновременно. По умолчанию 1 - пары обрабатываются по очереди
+	Concurrency int `yaml:"concurrency"`
+	// OperationTimeout ограничение времени одной сетевой операции: fetch или push. По умолчанию DefaultOperationTimeout
+	OperationTimeout time.Duration `yaml:"operation_timeout"`
+	// PairTimeout ограничение времени обработки одной пары. По умолчанию не ограничено
+	PairTimeout time.Duration `yaml:"pair_timeout"`
+	// Retry повторы сетевых операций после временных ошибок
+	Retry RetryConfig `yaml:"retry"`
+	// Schedule расписание синхронизации пар в режиме daemon: интервал (15m) или cron-выражение.
+	// По умолчанию DefaultSchedule
+	Schedule string `yaml:"schedule"`
+	// Webhook прием событий push от GitLab, GitHub и Gitea в режиме daemon
+	Webhook WebhookConfig `yaml:"webhook"`
+	// Credentials именованные учетные данные, на которые ссылаются стороны пар
+	Credentials  map[string]Credential `yaml:"credentials"`
+	Repositories []RepositoryPair      `yaml:"repositories"`
 }
 
+// DefaultGitlabAPIPath путь к API GitLab, если gitlab_api_path не задан
+const DefaultGitlabAPIPath = "/api/v4"
+
+// DefaultOperationTimeout ограничение времени сетевой операции, если operation_timeout не задан
+const DefaultOperationTimeout = 10 * time.Minute
+
+// DefaultSchedule расписание пар в режиме daemon, если schedule не задан ни для пары, ни в конфигурации
+const DefaultSchedule = "15m"
+
+// RetryConfig настройки повтора сетевых операций после временных ошибок: превышения времени,
+// сброса соединения, ответов сервера 5xx. Незаданные поля принимают значения по умолчанию.
+type RetryConfig struct {
+	// MaxAttempts наибольшее число попыток операции, включая первую. 1 отключает повторы
+	MaxAttempts int `yaml:"max_attempts"`
+	// InitialBackoff задержка перед первым повтором, каждая следующая удваивается
+	InitialBackoff time.Duration `yaml:"initial_backoff"`
+	// MaxBackoff наибольшая задержка между попытками
+	MaxBackoff time.Duration `yaml:"max_backoff"`
+	// Jitter доля случайного отклонения задержки от 0 до 1
+	Jitter *float64 `yaml:"jitter"`
+}
+
+// WebhookConfig настройки HTTP-сервера, который принимает события push и запускает синхронизацию пары
+type WebhookConfig struct {
+	// Listen адрес HTTP-сервера, например :8080. Пустой адрес отключает прием событий
+	Listen string `yaml:"listen"`
+	// Path путь, на который отправляются события. По умолчанию DefaultWebhookPath
+	Path string `yaml:"path"`
+	// Secret секретный токен GitLab (X-Gitlab-Token) и ключ подписи GitHub и Gitea или ссылка на него
+	Secret string `yaml:"secret"`
+	// Debounce задержка синхронизации после события: события пары за это время объединяются в одну синхронизацию.
+	// По умолчанию DefaultWebhookDebounce
+	Debounce time.Duration `yaml:"debounce"`
+}
+
+// Значения по умолчанию для раздела webhook
+const (
+	DefaultWebhookPath     = "/webhook"
+	DefaultWebhookDebounce = 5 * time.Second
+)
+
+// WebhookPath возвращает путь приема событий с учетом значения по умолчанию
+func (w WebhookConfig) WebhookPath() string {
+	if w.Path == "" {
+		return DefaultWebhookPath
+	}
+	return w.Path
+}
+
+// DebounceDelay возвращает задержку синхронизации после события с учетом значения по умолчанию
+func (w WebhookConfig) DebounceDelay() time.Duration {
+	if w.Debounce == 0 {
+		return DefaultWebhookDebounce
+	}
+	return w.Debounce
+}
+
+// Значения по умолчанию для раздела retry
+const (
+	DefaultRetryMaxAttempts    = 3
+	DefaultRetryInitialBackoff = time.Second
+	DefaultRetryMaxBackoff     = 30 * time.Second
+	DefaultRetryJitter         = 0.2
+)
+
 // RepositoryPair структура для пары репозиториев
 type RepositoryPair struct {
+	// Name имя пары для выбора в командной строке. По умолчанию - имя репозитория GitLab
+	Name           string `yaml:"name"`
 	GitlabURL      string `yaml:"gitlab_url"`
 	PrivateRepoURL string `yaml:"private_repo_url"`
+	// GitlabProjectID путь проекта в GitLab (например, group/repo) или его числовой ID - альтернатива gitlab_url.
+	// URL репозитория строится из gitlab_base_url; для числового ID он запрашивается через GitLab API,
+	// см. NumericProjectID
+	GitlabProjectID string `yaml:"gitlab_project_id"`
+	// LocalPath путь к локальному репозиторию - альтернатива private_repo_url
+	LocalPath string `yaml:"local_path"`
+	// GitlabCredential имя учетных данных из раздела credentials для GitLab. По умолчанию - gitlab_token
+	GitlabCredential string `yaml:"gitlab_credential"`
+	// PrivateCredential имя учетных данных из раздела credentials для приватного репозитория.
+	// По умолчанию - ssh_key_path
+	PrivateCredential string `yaml:"private_credential"`
+
+	// PropagateDeletions включает удаление веток и тегов, удаленных на одной из сторон
+	PropagateDeletions bool `yaml:"propagate_deletions"`
+	// DeletionsDryRun только сообщает об удалениях, не выполняя их
+	DeletionsDryRun bool `yaml:"deletions_dry_run"`
+	// ProtectedRefs шаблоны имен веток и тегов (path.Match), которые никогда не удаляются
+	ProtectedRefs []string `yaml:"protected_refs"`
+	// ConflictStrategy способ разрешения разошедшихся веток: skip, prefer_gitlab, prefer_private,
+	// conflict_branch, merge или merge_request. По умолчанию skip
+	ConflictStrategy string `yaml:"conflict_strategy"`
+	// MergeRequestTitle и MergeRequestDescription шаблоны text/template заголовка и описания merge request
+	// стратегии merge_request. По умолчанию - встроенные шаблоны со списками разошедшихся коммитов
+	MergeRequestTitle       string `yaml:"merge_request_title"`
+	MergeRequestDescription string `yaml:"merge_request_description"`
+	// ProtectionOverride временно ослабляет защиту ветки в GitLab, если перезапись ветки по стратегии
+	// prefer_private запрещена правилами защиты: allow_force_push или unprotect. Исходная защита
+	// восстанавливается после перезаписи. По умолчанию защита не изменяется
+	ProtectionOverride string `yaml:"protection_override"`
+	// Schedule расписание синхронизации пары в режиме daemon. По умолчанию - schedule конфигурации
+	Schedule string `yaml:"schedule"`
+}
+
+// PairName возвращает имя пары: заданное в конфигурации или имя репозитория из GitLab URL
+func (p RepositoryPair) PairName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	if _, ok := p.NumericProjectID(); ok && p.GitlabURL == "" {
+		return p.GitlabProjectID
+	}
+	return strings.TrimSuffix(path.Base(strings.TrimRight(p.GitlabURL, "/")), ".git")
+}
+
+// NumericProjectID возвращает числовой ID проекта GitLab, если gitlab_project_id задан числом.
+// URL репозитория такой пары не заполняется при загрузке конфигурации: его нужно получить через GitLab API.
+func (p RepositoryPair) NumericProjectID() (int64, bool) {
+	id, err := strconv.ParseInt(strings.TrimSpace(p.GitlabProjectID), 10, 64)
+	return id, err == nil && id > 0
+}
+
+// SelectPairs возвращает пары с указанными именами в порядке конфигурации.
+// Пустой список имен выбирает все пары; неизвестное имя - ошибка.
+func (c *Config) SelectPairs(names []string) ([]RepositoryPair, error) {
+	if len(names) == 0 {
+		return c.Repositories, nil
+	}
+
+	wanted := make(map[string]bool, len(names))
+	for _, name := range names {
+		wanted[name] = false
+	}
+	var pairs []RepositoryPair
+	for _, pair := range c.Repositories {
+		if _, ok := wanted[pair.PairName()]; ok {
+			wanted[pair.PairName()] = true
+			pairs = append(pairs, pair)
+		}
+	}
+	for _, name := range names {
+		if !wanted[name] {
+			return nil, fmt.Errorf("пара репозиториев %q не найдена в конфигурации", name)
+		}
+	}
+	return pairs, nil
 }
 
+// Стратегии разрешения разошедшихся веток
+const (
+	// ConflictSkip оставляет ветки без изменений и сообщает о конфликте
+	ConflictSkip = "skip"
+	// ConflictPreferGitlab перезаписывает ветку в приватном репозитории версией из GitLab
+	ConflictPreferGitlab = "prefer_gitlab"
+	// ConflictPreferPrivate перезаписывает ветку в GitLab версией из приватного репозитория
+	ConflictPreferPrivate = "prefer_private"
+	// ConflictBranch сохраняет версию приватного репозитория в ветке sync-conflict/<branch>/<date>
+	ConflictBranch = "conflict_branch"
+	// ConflictMerge создает коммит слияния, если изменения сторон не затрагивают одни и те же файлы
+	ConflictMerge = "merge"
+	// ConflictMergeRequest отправляет версию приватного репозитория в ветку sync/<branch> в GitLab
+	// и открывает merge request из нее в <branch>
+	ConflictMergeRequest = "merge_request"
+)
+
+// Способы временно ослабить защиту ветки в GitLab для перезаписи
+const (
+	// ProtectionAllowForcePush разрешает force push в правилах защиты ветки
+	ProtectionAllowForcePush = "allow_force_push"
+	// ProtectionUnprotect снимает защиту ветки и затем создает правила заново
+	ProtectionUnprotect = "unprotect"
+)
+
 // LoadConfig загружает конфигурацию из указанного файла
 func LoadConfig(filePath string) (*Config, error) {
 	data, err := os.ReadFile(filePath)
@@ -28,11 +226,101 @@ func LoadConfig(filePath string) (*Config, error) {
 		return nil, fmt.Errorf("не удалось прочитать файл конфигурации %s: %w", filePath, err)
 	}
 
+	// Строгий разбор: неизвестные и повторяющиеся ключи считаются ошибкой с указанием номера строки
 	var cfg Config
-	err = yaml.Unmarshal(data, &cfg)
+	err = yaml.UnmarshalStrict(data, &cfg)
 	if err != nil {
 		return nil, fmt.Errorf("не удалось распарсить файл конфигурации %s: %w", filePath, err)
 	}
 
+	if err := cfg.resolvePairs(); err != nil {
+		return nil, fmt.Errorf("ошибка в файле конфигурации %s: %w", filePath, err)
+	}
+	if err := cfg.resolveSecrets(); err != nil {
+		return nil, fmt.Errorf("не удалось получить секреты из файла конфигурации %s: %w", filePath, err)
+	}
+
 	return &cfg, nil
 }
+
+// resolvePairs заполняет URL пар, заданных через gitlab_project_id и local_path
+func (c *Config) resolvePairs() error {
+	for i := range c.Repositories {
+		pair := &c.Repositories[i]
+
+		if pair.GitlabProjectID != "" {
+			if pair.GitlabURL != "" {
+				return fmt.Errorf("repositories[%d]: gitlab_url и gitlab_project_id нельзя задавать одновременно", i)
+			}
+			if c.GitlabBaseURL == "" {
+				return fmt.Errorf("repositories[%d]: для gitlab_project_id требуется gitlab_base_url", i)
+			}
+			if _, ok := pair.NumericProjectID(); !ok {
+				pair.GitlabURL = strings.TrimRight(c.GitlabBaseURL, "/") + "/" + strings.Trim(pair.GitlabProjectID, "/") + ".git"
+			}
+		}
+
+		if pair.LocalPath != "" {
+			if pair.PrivateRepoURL != "" {
+				return fmt.Errorf("repositories[%d]: private_repo_url и local_path нельзя задавать одновременно", i)
+			}
+			pair.PrivateRepoURL = pair.LocalPath
+		}
+	}
+	return nil
+}
+
+// GitlabAPIURL возвращает адрес API GitLab: gitlab_base_url и gitlab_api_path
+func (c *Config) GitlabAPIURL() string {
+	apiPath := c.GitlabAPIPath
+	if apiPath == "" {
+		apiPath = DefaultGitlabAPIPath
+	}
+	return strings.TrimRight(c.GitlabBaseURL, "/") + "/" + strings.TrimLeft(apiPath, "/")
+}
+
+// PairConcurrency возвращает число пар, которые обрабатываются одновременно
+func (c *Config) PairConcurrency() int {
+	if c.Concurrency < 1 {
+		return 1
+	}
+	return c.Concurrency
+}
+
+// GitOperationTimeout возвращает ограничение времени одной сетевой операции с учетом значения по умолчанию
+func (c *Config) GitOperationTimeout() time.Duration {
+	if c.OperationTimeout == 0 {
+		return DefaultOperationTimeout
+	}
+	return c.OperationTimeout
+}
+
+// PairSchedule возвращает расписание пары в режиме daemon с учетом расписания конфигурации и значения по умолчанию
+func (c *Config) PairSchedule(pair RepositoryPair) string {
+	switch {
+	case pair.Schedule != "":
+		return pair.Schedule
+	case c.Schedule != "":
+		return c.Schedule
+	}
+	return DefaultSchedule
+}
+
+// RetrySettings возвращает настройки повторов, в которых незаданные поля заменены значениями по умолчанию
+func (c *Config) RetrySettings() RetryConfig {
+	retry := c.Retry
+	if retry.MaxAttempts == 0 {
+		retry.MaxAttempts = DefaultRetryMaxAttempts
+	}
+	if retry.InitialBackoff == 0 {
+		retry.InitialBackoff = DefaultRetryInitialBackoff
+	}
+	if retry.MaxBackoff == 0 {
+		retry.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	if retry.Jitter == nil {
+		jitter := DefaultRetryJitter
+		retry.Jitter = &jitter
+	}
+	return retry
+}
@@ -3,7 +3,9 @@ package configs
 import (
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadConfig(t *testing.T) {
@@ -15,6 +17,8 @@ func TestLoadConfig(t *testing.T) {
 gitlab_token: "test_token"
 ssh_key_path: "/path/to/ssh/key"
 temp_dir: "/tmp/git-sync"
+state_dir: "/var/lib/git-sync/state"
+pair_timeout: "30m"
 repositories:
   - gitlab_url: "https://gitlab.com/user/repo1.git"
     private_repo_url: "git@private.com:user/repo1.git"
@@ -46,6 +50,20 @@ repositories:
 			t.Errorf("Ожидался TempDir '/tmp/git-sync', получен '%s'", cfg.TempDir)
 		}
 
+		if cfg.StateDir != "/var/lib/git-sync/state" {
+			t.Errorf("Ожидался StateDir '/var/lib/git-sync/state', получен '%s'", cfg.StateDir)
+		}
+
+		if cfg.PairTimeout != 30*time.Minute || cfg.GitOperationTimeout() != DefaultOperationTimeout {
+			t.Errorf("Неверные ограничения времени: пара %s, операция %s", cfg.PairTimeout, cfg.GitOperationTimeout())
+		}
+
+		retry := cfg.RetrySettings()
+		if retry.MaxAttempts != DefaultRetryMaxAttempts || retry.InitialBackoff != DefaultRetryInitialBackoff ||
+			retry.MaxBackoff != DefaultRetryMaxBackoff || *retry.Jitter != DefaultRetryJitter {
+			t.Errorf("Неверные настройки повторов по умолчанию: %+v", retry)
+		}
+
 		if len(cfg.Repositories) != 2 {
 			t.Errorf("Ожидалось 2 репозитория, получено %d", len(cfg.Repositories))
 		}
@@ -92,6 +110,126 @@ invalid_yaml: [unclosed_bracket
 			t.Error("Ожидалась ошибка при загрузке невалидного YAML")
 		}
 	})
+
+	// Тест строгого разбора: неизвестные ключи отклоняются с номером строки
+	t.Run("UnknownKey", func(t *testing.T) {
+		unknownKeyPath := filepath.Join(tempDir, "unknown_key.yaml")
+		content := "gitlab_token: \"test_token\"\nrepositories:\n" +
+			"  - gitlab_url: \"https://gitlab.com/user/repo1.git\"\n" +
+			"    private_repo: \"git@private.com:user/repo1.git\"\n"
+		if err := os.WriteFile(unknownKeyPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+		}
+
+		_, err := LoadConfig(unknownKeyPath)
+		if err == nil {
+			t.Fatal("Ожидалась ошибка для неизвестного ключа")
+		}
+		if !strings.Contains(err.Error(), "line 4") || !strings.Contains(err.Error(), "private_repo") {
+			t.Errorf("Ошибка должна содержать ключ и номер строки: %v", err)
+		}
+	})
+
+	// Тест пар, заданных путем проекта GitLab и локальным путем
+	t.Run("ProjectIDAndLocalPath", func(t *testing.T) {
+		projectPath := filepath.Join(tempDir, "project.yaml")
+		content := `
+gitlab_base_url: "https://gitlab.example.com/"
+repositories:
+  - gitlab_project_id: "group/repo"
+    local_path: "/srv/git/repo"
+`
+		if err := os.WriteFile(projectPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+		}
+
+		cfg, err := LoadConfig(projectPath)
+		if err != nil {
+			t.Fatalf("Ожидалась успешная загрузка конфигурации, получена ошибка: %v", err)
+		}
+		pair := cfg.Repositories[0]
+		if pair.GitlabURL != "https://gitlab.example.com/group/repo.git" {
+			t.Errorf("Неверный GitlabURL: %s", pair.GitlabURL)
+		}
+		if pair.PrivateRepoURL != "/srv/git/repo" {
+			t.Errorf("Неверный PrivateRepoURL: %s", pair.PrivateRepoURL)
+		}
+		if pair.PairName() != "repo" {
+			t.Errorf("Неверное имя пары: %s", pair.PairName())
+		}
+		if cfg.GitlabAPIURL() != "https://gitlab.example.com/api/v4" {
+			t.Errorf("Неверный адрес API: %s", cfg.GitlabAPIURL())
+		}
+	})
+
+	// Тест пары, заданной числовым ID проекта: адрес репозитория получается через API при запуске
+	t.Run("NumericProjectID", func(t *testing.T) {
+		projectPath := filepath.Join(tempDir, "numeric.yaml")
+		content := `
+gitlab_base_url: "https://gitlab.example.com"
+repositories:
+  - gitlab_project_id: 42
+    local_path: "/srv/git/repo"
+`
+		if err := os.WriteFile(projectPath, []byte(content), 0644); err != nil {
+			t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+		}
+
+		cfg, err := LoadConfig(projectPath)
+		if err != nil {
+			t.Fatalf("Ожидалась успешная загрузка конфигурации, получена ошибка: %v", err)
+		}
+		pair := cfg.Repositories[0]
+		if id, ok := pair.NumericProjectID(); !ok || id != 42 {
+			t.Errorf("Ожидался числовой ID проекта 42, получено %d (%v)", id, ok)
+		}
+		if pair.GitlabURL != "" {
+			t.Errorf("GitlabURL не должен строиться из числового ID: %s", pair.GitlabURL)
+		}
+		if pair.PairName() != "42" {
+			t.Errorf("Неверное имя пары: %s", pair.PairName())
+		}
+	})
+
+	// Тест конфликтующих способов задания стороны пары
+	t.Run("MixedPairFields", func(t *testing.T) {
+		testCases := map[string]string{
+			"ProjectWithoutBaseURL": "repositories:\n  - gitlab_project_id: \"group/repo\"\n    local_path: \"/srv/git/repo\"\n",
+			"ProjectAndURL": "gitlab_base_url: \"https://gitlab.example.com\"\nrepositories:\n" +
+				"  - gitlab_project_id: \"group/repo\"\n    gitlab_url: \"https://gitlab.example.com/group/repo.git\"\n    local_path: \"/srv/git/repo\"\n",
+			"LocalPathAndURL": "repositories:\n  - gitlab_url: \"https://gitlab.com/group/repo.git\"\n" +
+				"    local_path: \"/srv/git/repo\"\n    private_repo_url: \"git@private.com:group/repo.git\"\n",
+		}
+		for name, content := range testCases {
+			t.Run(name, func(t *testing.T) {
+				path := filepath.Join(t.TempDir(), "config.yaml")
+				if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+					t.Fatalf("Не удалось создать тестовый файл конфигурации: %v", err)
+				}
+				if _, err := LoadConfig(path); err == nil {
+					t.Error("Ожидалась ошибка загрузки конфигурации")
+				}
+			})
+		}
+	})
+
+	// Тест поставляемого примера конфигурации
+	t.Run("ShippedExample", func(t *testing.T) {
+		// Пример ссылается на токен в переменной окружения
+		t.Setenv("GITLAB_INTERNAL_TOKEN", "example-token")
+		cfg, err := LoadConfig("config.yaml")
+		if err != nil {
+			t.Fatalf("Пример конфигурации не загружается: %v", err)
+		}
+		if len(cfg.Repositories) != 3 {
+			t.Errorf("Ожидалось 3 репозитория, получено %d", len(cfg.Repositories))
+		}
+		for _, pair := range cfg.Repositories {
+			if _, _, err := cfg.PairCredentials(pair); err != nil {
+				t.Errorf("Пара %s: %v", pair.PairName(), err)
+			}
+		}
+	})
 }
 
 func TestRepositoryPair(t *testing.T) {
@@ -108,3 +246,134 @@ func TestRepositoryPair(t *testing.T) {
 		t.Errorf("Неверный PrivateRepoURL: %s", repo.PrivateRepoURL)
 	}
 }
+
+func TestSelectPairs(t *testing.T) {
+	cfg := &Config{Repositories: []RepositoryPair{
+		{GitlabURL: "https://gitlab.com/user/repo1.git"},
+		{Name: "docs", GitlabURL: "https://gitlab.com/user/documentation.git"},
+		{GitlabURL: "https://gitlab.com/user/repo3/"},
+	}}
+
+	names := []string{cfg.Repositories[0].PairName(), cfg.Repositories[1].PairName(), cfg.Repositories[2].PairName()}
+	expectedNames := []string{"repo1", "docs", "repo3"}
+	for i := range names {
+		if names[i] != expectedNames[i] {
+			t.Errorf("Ожидалось имя пары %q, получено %q", expectedNames[i], names[i])
+		}
+	}
+
+	all, err := cfg.SelectPairs(nil)
+	if err != nil || len(all) != 3 {
+		t.Errorf("Без фильтра ожидались все 3 пары, получено %d (%v)", len(all), err)
+	}
+
+	selected, err := cfg.SelectPairs([]string{"repo3", "docs"})
+	if err != nil {
+		t.Fatalf("SelectPairs вернул ошибку: %v", err)
+	}
+	if len(selected) != 2 || selected[0].PairName() != "docs" || selected[1].PairName() != "repo3" {
+		t.Errorf("Ожидались пары docs и repo3 в порядке конфигурации, получено %v", selected)
+	}
+
+	if _, err := cfg.SelectPairs([]string{"unknown"}); err == nil {
+		t.Error("Ожидалась ошибка для неизвестной пары")
+	}
+}
+
+func TestPairCredentials(t *testing.T) {
+	cfg := &Config{
+		GitlabToken: "legacy-token",
+		SSHKeyPath:  "/home/user/.ssh/id_rsa",
+		Credentials: map[string]Credential{
+			"gitlab-b": {Type: CredentialToken, Secret: "token-b"},
+			"agent":    {Type: CredentialSSHAgent, Username: "deploy"},
+		},
+	}
+
+	t.Run("Legacy", func(t *testing.T) {
+		gitlab, private, err := cfg.PairCredentials(RepositoryPair{})
+		if err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+		if gitlab.Type != CredentialToken || gitlab.Secret != "legacy-token" {
+			t.Errorf("Неверные учетные данные GitLab: %+v", gitlab)
+		}
+		if private.Type != CredentialSSHKey || private.KeyPath != "/home/user/.ssh/id_rsa" {
+			t.Errorf("Неверные учетные данные приватного репозитория: %+v", private)
+		}
+	})
+
+	t.Run("Named", func(t *testing.T) {
+		gitlab, private, err := cfg.PairCredentials(RepositoryPair{GitlabCredential: "gitlab-b", PrivateCredential: "agent"})
+		if err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+		if gitlab.Secret != "token-b" {
+			t.Errorf("Неверные учетные данные GitLab: %+v", gitlab)
+		}
+		if private.Type != CredentialSSHAgent || private.UsernameOr(DefaultSSHUsername) != "deploy" {
+			t.Errorf("Неверные учетные данные приватного репозитория: %+v", private)
+		}
+	})
+
+	t.Run("SSHDefaults", func(t *testing.T) {
+		withDefaults := *cfg
+		withDefaults.SSHKnownHosts = "/etc/git-sync/known_hosts"
+		withDefaults.SSHHostKeyCheck = HostKeyAcceptNew
+		withDefaults.Credentials = map[string]Credential{
+			"agent":    {Type: CredentialSSHAgent},
+			"insecure": {Type: CredentialSSHAgent, HostKeyCheck: HostKeyInsecure},
+		}
+		gitlab, private, err := withDefaults.PairCredentials(RepositoryPair{GitlabCredential: "agent", PrivateCredential: "insecure"})
+		if err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+		if gitlab.KnownHosts != "/etc/git-sync/known_hosts" || gitlab.HostKeyCheck != HostKeyAcceptNew {
+			t.Errorf("Глобальные настройки SSH не применены: %+v", gitlab)
+		}
+		if private.KnownHosts != "/etc/git-sync/known_hosts" || private.HostKeyCheck != HostKeyInsecure {
+			t.Errorf("Настройки учетных данных должны иметь приоритет: %+v", private)
+		}
+
+		legacy, _, err := withDefaults.PairCredentials(RepositoryPair{})
+		if err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+		if legacy.KnownHosts != "" || legacy.HostKeyCheck != "" {
+			t.Errorf("Настройки SSH не должны применяться к токену: %+v", legacy)
+		}
+	})
+
+	t.Run("Unknown", func(t *testing.T) {
+		if _, _, err := cfg.PairCredentials(RepositoryPair{GitlabCredential: "missing"}); err == nil {
+			t.Error("Ожидалась ошибка для неизвестных учетных данных")
+		}
+	})
+}
+
+func TestCredentialSecretValue(t *testing.T) {
+	t.Setenv("GIT_SYNC_TEST_SECRET", "from-env")
+
+	testCases := []struct {
+		name     string
+		cred     Credential
+		expected string
+		wantErr  bool
+	}{
+		{"Literal", Credential{Secret: "literal", SecretEnv: "GIT_SYNC_TEST_SECRET"}, "literal", false},
+		{"Env", Credential{SecretEnv: "GIT_SYNC_TEST_SECRET"}, "from-env", false},
+		{"MissingEnv", Credential{SecretEnv: "GIT_SYNC_TEST_MISSING"}, "", true},
+		{"Empty", Credential{}, "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := tc.cred.SecretValue()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("Ошибка %v, ожидалась ошибка: %v", err, tc.wantErr)
+			}
+			if value != tc.expected {
+				t.Errorf("Ожидался секрет %q, получен %q", tc.expected, value)
+			}
+		})
+	}
+}
@@ -0,0 +1,185 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// Типы учетных данных
+const (
+	// CredentialToken токен доступа по HTTP(S), например Personal Access Token GitLab
+	CredentialToken = "token"
+	// CredentialBasic имя пользователя и пароль по HTTP(S)
+	CredentialBasic = "basic"
+	// CredentialSSHKey приватный SSH-ключ из файла
+	CredentialSSHKey = "ssh-key"
+	// CredentialSSHAgent ключи SSH-агента из SSH_AUTH_SOCK
+	CredentialSSHAgent = "ssh-agent"
+)
+
+// Режимы проверки ключей SSH-серверов
+const (
+	// HostKeyStrict подключение только к серверам, ключ которых есть в known_hosts (по умолчанию)
+	HostKeyStrict = "strict"
+	// HostKeyAcceptNew ключ неизвестного сервера добавляется в known_hosts, измененный ключ отклоняется
+	HostKeyAcceptNew = "accept-new"
+	// HostKeyInsecure ключ сервера не проверяется
+	HostKeyInsecure = "insecure"
+)
+
+// DefaultTokenUsername имя пользователя для токенов: GitLab принимает Personal Access Token с любым именем, кроме пустого
+const DefaultTokenUsername = "oauth2"
+
+// DefaultSSHUsername имя пользователя SSH по умолчанию
+const DefaultSSHUsername = "git"
+
+// Credential учетные данные для доступа к репозиторию. Нулевое значение означает доступ без аутентификации.
+type Credential struct {
+	Type     string `yaml:"type"`
+	Username string `yaml:"username"`
+	// Secret токен или пароль либо ссылка на него: ${env:NAME}, file:<путь>, exec:<команда>.
+	// Ссылка заменяется значением при загрузке конфигурации
+	Secret string `yaml:"secret"`
+	// SecretEnv имя переменной окружения, из которой читается секрет, если Secret не задан
+	SecretEnv string `yaml:"secret_env"`
+	// KeyPath путь к приватному ключу для типа ssh-key
+	KeyPath string `yaml:"key_path"`
+	// Passphrase пароль зашифрованного ключа key_path или ссылка на него, как у secret
+	Passphrase string `yaml:"passphrase"`
+	// KnownHosts файл known_hosts для проверки ключей SSH-серверов. По умолчанию ssh_known_hosts,
+	// затем $SSH_KNOWN_HOSTS или ~/.ssh/known_hosts
+	KnownHosts string `yaml:"known_hosts"`
+	// HostKeyCheck режим проверки ключей SSH-серверов: strict, accept-new или insecure.
+	// По умолчанию ssh_host_key_check, затем strict
+	HostKeyCheck string `yaml:"host_key_check"`
+}
+
+// IsSSH проверяет, используются ли учетные данные для доступа по SSH
+func (c Credential) IsSSH() bool {
+	return c.Type == CredentialSSHKey || c.Type == CredentialSSHAgent
+}
+
+// IsHTTP проверяет, используются ли учетные данные для доступа по HTTP(S)
+func (c Credential) IsHTTP() bool {
+	return c.Type == CredentialToken || c.Type == CredentialBasic
+}
+
+// SecretValue возвращает секрет из поля secret или из переменной окружения secret_env
+func (c Credential) SecretValue() (string, error) {
+	if c.Secret != "" {
+		return c.Secret, nil
+	}
+	if c.SecretEnv != "" {
+		if value := os.Getenv(c.SecretEnv); value != "" {
+			return value, nil
+		}
+		return "", fmt.Errorf("переменная окружения %s не задана", c.SecretEnv)
+	}
+	return "", errors.New("секрет не задан")
+}
+
+// UsernameOr возвращает имя пользователя или значение по умолчанию
+func (c Credential) UsernameOr(fallback string) string {
+	if c.Username != "" {
+		return c.Username
+	}
+	return fallback
+}
+
+// PairCredentials возвращает учетные данные сторон пары. Если пара не ссылается на раздел credentials,
+// для GitLab используется gitlab_token, а для приватного репозитория - ssh_key_path.
+func (c *Config) PairCredentials(pair RepositoryPair) (gitlab, private Credential, err error) {
+	gitlab, err = c.credential(pair.GitlabCredential)
+	if err != nil {
+		return Credential{}, Credential{}, fmt.Errorf("gitlab_credential: %w", err)
+	}
+	if pair.GitlabCredential == "" && c.GitlabToken != "" {
+		gitlab = Credential{Type: CredentialToken, Secret: c.GitlabToken}
+	}
+
+	private, err = c.credential(pair.PrivateCredential)
+	if err != nil {
+		return Credential{}, Credential{}, fmt.Errorf("private_credential: %w", err)
+	}
+	if pair.PrivateCredential == "" && c.SSHKeyPath != "" {
+		private = Credential{Type: CredentialSSHKey, KeyPath: c.SSHKeyPath}
+	}
+	return c.withSSHDefaults(gitlab), c.withSSHDefaults(private), nil
+}
+
+// withSSHDefaults дополняет SSH-учетные данные глобальными настройками проверки ключей серверов
+func (c *Config) withSSHDefaults(cred Credential) Credential {
+	if !cred.IsSSH() {
+		return cred
+	}
+	if cred.KnownHosts == "" {
+		cred.KnownHosts = c.SSHKnownHosts
+	}
+	if cred.HostKeyCheck == "" {
+		cred.HostKeyCheck = c.SSHHostKeyCheck
+	}
+	return cred
+}
+
+// credential ищет учетные данные по имени; пустое имя означает доступ без аутентификации
+func (c *Config) credential(name string) (Credential, error) {
+	if name == "" {
+		return Credential{}, nil
+	}
+	cred, ok := c.Credentials[name]
+	if !ok {
+		return Credential{}, fmt.Errorf("учетные данные %q не найдены в разделе credentials", name)
+	}
+	return cred, nil
+}
+
+// validateCredential проверяет тип и обязательные поля учетных данных
+func validateCredential(cred Credential) []error {
+	var errs []error
+	switch cred.Type {
+	case CredentialToken, CredentialBasic:
+		if cred.Secret == "" && cred.SecretEnv == "" {
+			errs = append(errs, errors.New("требуется secret или secret_env"))
+		}
+		if cred.Type == CredentialBasic && cred.Username == "" {
+			errs = append(errs, errors.New("для типа basic требуется username"))
+		}
+		sshFields := [][2]string{
+			{"key_path", cred.KeyPath}, {"passphrase", cred.Passphrase},
+			{"known_hosts", cred.KnownHosts}, {"host_key_check", cred.HostKeyCheck},
+		}
+		for _, field := range sshFields {
+			if field[1] != "" {
+				errs = append(errs, fmt.Errorf("%s не используется с типом %s", field[0], cred.Type))
+			}
+		}
+	case CredentialSSHKey:
+		if cred.KeyPath == "" {
+			errs = append(errs, errors.New("для типа ssh-key требуется key_path"))
+		} else if _, err := os.Stat(cred.KeyPath); err != nil {
+			errs = append(errs, fmt.Errorf("key_path: %w", err))
+		}
+	case CredentialSSHAgent:
+		if cred.KeyPath != "" || cred.Passphrase != "" {
+			errs = append(errs, errors.New("key_path и passphrase не используются с типом ssh-agent"))
+		}
+	case "":
+		errs = append(errs, errors.New("не задан type: token, basic, ssh-key или ssh-agent"))
+	default:
+		errs = append(errs, fmt.Errorf("неизвестный type %q: ожидается token, basic, ssh-key или ssh-agent", cred.Type))
+	}
+	if err := validateHostKeyCheck(cred.HostKeyCheck); err != nil {
+		errs = append(errs, fmt.Errorf("host_key_check: %w", err))
+	}
+	return errs
+}
+
+// validateHostKeyCheck проверяет режим проверки ключей SSH-серверов
+func validateHostKeyCheck(mode string) error {
+	switch mode {
+	case "", HostKeyStrict, HostKeyAcceptNew, HostKeyInsecure:
+		return nil
+	}
+	return fmt.Errorf("неизвестный режим %q: ожидается strict, accept-new или insecure", mode)
+}
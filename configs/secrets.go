@@ -0,0 +1,154 @@
+package configs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Префиксы ссылок на секреты
+const (
+	// secretFilePrefix секрет читается из файла: file:/run/secrets/gitlab_token
+	secretFilePrefix = "file:"
+	// secretExecPrefix секрет - стандартный вывод команды: exec:pass show gitlab/token
+	secretExecPrefix = "exec:"
+)
+
+// secretEnvRef ссылка на переменную окружения: ${env:GITLAB_TOKEN}
+var secretEnvRef = regexp.MustCompile(`^\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// secretExecTimeout время ожидания команды, возвращающей секрет
+var secretExecTimeout = 30 * time.Second
+
+// ResolveSecret возвращает значение секрета по ссылке. Поддерживаются ссылки ${env:NAME}, file:<путь>
+// и exec:<команда> (аргументы разделяются пробелами, оболочка не используется). Значение без префикса
+// возвращается как есть. Завершающие переводы строки в файле и выводе команды отбрасываются.
+// Ошибки не содержат значения секрета.
+func ResolveSecret(ref string) (string, error) {
+	switch {
+	case secretEnvRef.MatchString(ref):
+		name := secretEnvRef.FindStringSubmatch(ref)[1]
+		value := os.Getenv(name)
+		if value == "" {
+			return "", fmt.Errorf("переменная окружения %s не задана", name)
+		}
+		return value, nil
+
+	case strings.HasPrefix(ref, secretFilePrefix):
+		path := strings.TrimPrefix(ref, secretFilePrefix)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("не удалось прочитать секрет из файла: %w", err)
+		}
+		value := strings.TrimRight(string(data), "\r\n")
+		if value == "" {
+			return "", fmt.Errorf("файл секрета %s пуст", path)
+		}
+		return value, nil
+
+	case strings.HasPrefix(ref, secretExecPrefix):
+		return execSecret(strings.TrimPrefix(ref, secretExecPrefix))
+	}
+	return ref, nil
+}
+
+// execSecret выполняет команду и возвращает ее стандартный вывод
+func execSecret(command string) (string, error) {
+	args := strings.Fields(command)
+	if len(args) == 0 {
+		return "", errors.New("не задана команда для exec:")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), secretExecTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("команда %s не завершилась за %s", args[0], secretExecTimeout)
+		}
+		if message := strings.TrimSpace(stderr.String()); message != "" {
+			return "", fmt.Errorf("команда %s завершилась с ошибкой: %w: %s", args[0], err, message)
+		}
+		return "", fmt.Errorf("команда %s завершилась с ошибкой: %w", args[0], err)
+	}
+
+	value := strings.TrimRight(stdout.String(), "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("команда %s не вывела секрет", args[0])
+	}
+	return value, nil
+}
+
+// resolveSecrets заменяет ссылки на секреты в gitlab_token, webhook.secret, secret и passphrase учетных данных их значениями.
+// Секреты из secret_env также читаются при загрузке, чтобы ошибки обнаруживались до синхронизации.
+func (c *Config) resolveSecrets() error {
+	var errs []error
+
+	if c.GitlabToken != "" {
+		value, err := ResolveSecret(c.GitlabToken)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("gitlab_token: %w", err))
+		}
+		c.GitlabToken = value
+	}
+
+	if c.Webhook.Secret != "" {
+		value, err := ResolveSecret(c.Webhook.Secret)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("webhook.secret: %w", err))
+		}
+		c.Webhook.Secret = value
+	}
+
+	for _, name := range sortedKeys(c.Credentials) {
+		cred := c.Credentials[name]
+		var err error
+		if cred.Secret != "" {
+			cred.Secret, err = ResolveSecret(cred.Secret)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("credentials.%s.secret: %w", name, err))
+			}
+		} else if cred.SecretEnv != "" {
+			cred.Secret, err = cred.SecretValue()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("credentials.%s.secret_env: %w", name, err))
+			}
+		}
+		if cred.Passphrase != "" {
+			cred.Passphrase, err = ResolveSecret(cred.Passphrase)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("credentials.%s.passphrase: %w", name, err))
+			}
+		}
+		c.Credentials[name] = cred
+	}
+	return errors.Join(errs...)
+}
+
+// Secrets возвращает значения всех секретов конфигурации, которые нужно скрывать в логах и сообщениях об ошибках
+func (c *Config) Secrets() []string {
+	var secrets []string
+	for _, secret := range []string{c.GitlabToken, c.Webhook.Secret} {
+		if secret != "" {
+			secrets = append(secrets, secret)
+		}
+	}
+	for _, name := range sortedKeys(c.Credentials) {
+		for _, secret := range []string{c.Credentials[name].Secret, c.Credentials[name].Passphrase} {
+			if secret != "" {
+				secrets = append(secrets, secret)
+			}
+		}
+	}
+	return secrets
+}
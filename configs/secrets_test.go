@@ -0,0 +1,140 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveSecret(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_SYNC_TEST_SECRET", "from-env")
+
+	secretFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatalf("Не удалось создать файл секрета: %v", err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0600); err != nil {
+		t.Fatalf("Не удалось создать файл секрета: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		ref      string
+		expected string
+		errPart  string
+	}{
+		{"Literal", "plain-token", "plain-token", ""},
+		{"Env", "${env:GIT_SYNC_TEST_SECRET}", "from-env", ""},
+		{"EnvMissing", "${env:GIT_SYNC_TEST_MISSING}", "", "переменная окружения GIT_SYNC_TEST_MISSING не задана"},
+		{"EnvNotWholeValue", "prefix-${env:GIT_SYNC_TEST_SECRET}", "prefix-${env:GIT_SYNC_TEST_SECRET}", ""},
+		{"File", "file:" + secretFile, "from-file", ""},
+		{"FileMissing", "file:" + filepath.Join(dir, "missing"), "", "не удалось прочитать секрет из файла"},
+		{"FileEmpty", "file:" + emptyFile, "", "пуст"},
+		{"Exec", "exec:echo from-exec", "from-exec", ""},
+		{"ExecFailure", "exec:false", "", "команда false завершилась с ошибкой"},
+		{"ExecNoOutput", "exec:true", "", "не вывела секрет"},
+		{"ExecEmpty", "exec:", "", "не задана команда"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := ResolveSecret(tc.ref)
+			if tc.errPart != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.errPart) {
+					t.Fatalf("Ожидалась ошибка с %q, получено %v", tc.errPart, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if value != tc.expected {
+				t.Errorf("Ожидался секрет %q, получен %q", tc.expected, value)
+			}
+		})
+	}
+}
+
+func TestLoadConfigResolvesSecrets(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("GIT_SYNC_TEST_TOKEN", "env-token")
+	t.Setenv("GIT_SYNC_TEST_PASSWORD", "env-password")
+
+	secretFile := filepath.Join(dir, "password")
+	if err := os.WriteFile(secretFile, []byte("file-password\n"), 0600); err != nil {
+		t.Fatalf("Не удалось создать файл секрета: %v", err)
+	}
+
+	configPath := filepath.Join(dir, "config.yaml")
+	content := `
+gitlab_token: "${env:GIT_SYNC_TEST_TOKEN}"
+credentials:
+  file:
+    type: "basic"
+    username: "bot"
+    secret: "file:` + secretFile + `"
+  env:
+    type: "token"
+    secret_env: "GIT_SYNC_TEST_PASSWORD"
+  key:
+    type: "ssh-key"
+    key_path: "` + secretFile + `"
+    passphrase: "exec:echo key-passphrase"
+repositories:
+  - gitlab_url: "https://gitlab.com/group/repo.git"
+    private_repo_url: "https://gitea.example.com/org/repo.git"
+    private_credential: "file"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+
+	cfg, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Ожидалась успешная загрузка конфигурации, получена ошибка: %v", err)
+	}
+	if cfg.GitlabToken != "env-token" {
+		t.Errorf("Неверный gitlab_token: %q", cfg.GitlabToken)
+	}
+	if cfg.Credentials["file"].Secret != "file-password" {
+		t.Errorf("Неверный секрет из файла: %q", cfg.Credentials["file"].Secret)
+	}
+	if cfg.Credentials["env"].Secret != "env-password" {
+		t.Errorf("Неверный секрет из secret_env: %q", cfg.Credentials["env"].Secret)
+	}
+	if cfg.Credentials["key"].Passphrase != "key-passphrase" {
+		t.Errorf("Неверный пароль ключа из команды: %q", cfg.Credentials["key"].Passphrase)
+	}
+
+	secrets := strings.Join(cfg.Secrets(), ",")
+	if secrets != "env-token,env-password,file-password,key-passphrase" {
+		t.Errorf("Неверный список секретов: %q", secrets)
+	}
+}
+
+func TestLoadConfigSecretErrors(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+gitlab_token: "${env:GIT_SYNC_TEST_MISSING}"
+credentials:
+  broken:
+    type: "token"
+    secret: "exec:false"
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл конфигурации: %v", err)
+	}
+
+	_, err := LoadConfig(configPath)
+	if err == nil {
+		t.Fatal("Ожидалась ошибка получения секретов")
+	}
+	for _, expected := range []string{"gitlab_token: переменная окружения GIT_SYNC_TEST_MISSING не задана", "credentials.broken.secret: команда false"} {
+		if !strings.Contains(err.Error(), expected) {
+			t.Errorf("Ошибка не содержит %q: %v", expected, err)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package configs
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// defaultPorts порты схем, которые не влияют на адрес репозитория
+var defaultPorts = map[string]string{"http": "80", "https": "443", "ssh": "22", "git": "9418"}
+
+// DefaultPort возвращает порт схемы scheme по умолчанию или пустую строку для неизвестной схемы
+func DefaultPort(scheme string) string {
+	return defaultPorts[scheme]
+}
+
+// NormalizeURL приводит адрес репозитория к виду, по которому разные записи одного remote совпадают:
+// SCP-подобные адреса переводятся в ssh://, схема и хост приводятся к нижнему регистру, имя пользователя,
+// порт по умолчанию, завершающий слеш и суффикс .git отбрасываются, локальные пути становятся абсолютными.
+func NormalizeURL(repoURL string) string {
+	raw := strings.TrimSpace(repoURL)
+	if !strings.Contains(raw, "://") {
+		if host, path, ok := SplitSCP(raw); ok {
+			raw = "ssh://" + host + "/" + strings.TrimPrefix(path, "/")
+		} else {
+			if abs, err := filepath.Abs(raw); err == nil {
+				raw = abs
+			}
+			return TrimRepoSuffix(filepath.ToSlash(filepath.Clean(raw)))
+		}
+	}
+
+	u, err := url.Parse(raw)
+	if err != nil {
+		return TrimRepoSuffix(raw)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if scheme == "file" {
+		return TrimRepoSuffix(filepath.ToSlash(filepath.Clean(u.Path)))
+	}
+	host := strings.ToLower(u.Hostname())
+	if port := u.Port(); port != "" && port != defaultPorts[scheme] {
+		host += ":" + port
+	}
+	return scheme + "://" + host + TrimRepoSuffix(u.Path)
+}
+
+// SplitSCP разбирает SCP-подобный адрес [user@]host:path, который git понимает как SSH.
+// Пути Windows вида C:\repo не считаются SCP-подобными адресами.
+func SplitSCP(raw string) (host, path string, ok bool) {
+	colon := strings.Index(raw, ":")
+	if colon <= 0 || strings.ContainsAny(raw[:colon], `/\`) {
+		return "", "", false
+	}
+	host = raw[:colon]
+	if at := strings.LastIndex(host, "@"); at >= 0 {
+		host = host[at+1:]
+	}
+	if len(host) <= 1 {
+		// Буква диска в пути Windows, например C:\repo
+		return "", "", false
+	}
+	return strings.ToLower(host), raw[colon+1:], true
+}
+
+// TrimRepoSuffix отбрасывает завершающие слеши и суффикс .git
+func TrimRepoSuffix(path string) string {
+	return strings.TrimSuffix(strings.TrimRight(path, "/"), ".git")
+}
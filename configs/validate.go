@@ -0,0 +1,297 @@
+package configs
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"text/template"
+
+	"git-sync/internal/schedule"
+)
+
+// URL-схемы, поддерживаемые при синхронизации
+var supportedSchemes = map[string]bool{"http": true, "https": true, "ssh": true, "git": true, "file": true}
+
+// Validate проверяет конфигурацию: учетные данные, адреса репозиториев, наличие аутентификации для каждой пары,
+// повторяющиеся пары, настройки синхронизации и возможность записи во временную директорию и кэш.
+// Возвращает все найденные ошибки сразу.
+func (c *Config) Validate() error {
+	var errs []error
+
+	if err := checkWritableDir(c.TempDir); err != nil {
+		errs = append(errs, fmt.Errorf("temp_dir: %w", err))
+	}
+	if c.CacheDir != "" {
+		if err := checkWritableDir(c.CacheDir); err != nil {
+			errs = append(errs, fmt.Errorf("cache_dir: %w", err))
+		}
+	}
+	if c.Concurrency < 0 {
+		errs = append(errs, fmt.Errorf("concurrency: ожидается положительное число, получено %d", c.Concurrency))
+	}
+	if c.OperationTimeout < 0 {
+		errs = append(errs, fmt.Errorf("operation_timeout: ожидается положительная длительность, получено %s", c.OperationTimeout))
+	}
+	if c.PairTimeout < 0 {
+		errs = append(errs, fmt.Errorf("pair_timeout: ожидается положительная длительность, получено %s", c.PairTimeout))
+	}
+	errs = append(errs, validateRetry(c.Retry)...)
+	errs = append(errs, validateWebhook(c.Webhook)...)
+	if c.Schedule != "" {
+		if _, err := schedule.Parse(c.Schedule); err != nil {
+			errs = append(errs, fmt.Errorf("schedule: %w", err))
+		}
+	}
+	if err := validateHostKeyCheck(c.SSHHostKeyCheck); err != nil {
+		errs = append(errs, fmt.Errorf("ssh_host_key_check: %w", err))
+	}
+	if c.GitlabBaseURL != "" {
+		if u, err := url.Parse(c.GitlabBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("gitlab_base_url: ожидается адрес вида https://gitlab.example.com, получено %q", c.GitlabBaseURL))
+		}
+	}
+
+	for _, name := range sortedKeys(c.Credentials) {
+		for _, err := range validateCredential(c.Credentials[name]) {
+			errs = append(errs, fmt.Errorf("credentials.%s: %w", name, err))
+		}
+	}
+
+	pairsByURL := make(map[string]int)
+	pairsByName := make(map[string]int)
+	for i, pair := range c.Repositories {
+		prefix := fmt.Sprintf("repositories[%d] (%s)", i, pair.PairName())
+		for _, err := range c.validatePair(pair) {
+			errs = append(errs, fmt.Errorf("%s: %w", prefix, err))
+		}
+
+		// Адреса сравниваются в нормализованном виде, как при выборе директории пары в кэше
+		gitlabKey := NormalizeURL(pair.GitlabURL)
+		if projectID, ok := pair.NumericProjectID(); ok && pair.GitlabURL == "" {
+			gitlabKey = fmt.Sprintf("project:%d", projectID)
+		}
+		key := gitlabKey + "\n" + NormalizeURL(pair.PrivateRepoURL)
+		if first, ok := pairsByURL[key]; ok {
+			errs = append(errs, fmt.Errorf("%s: пара повторяет repositories[%d]", prefix, first))
+		} else {
+			pairsByURL[key] = i
+		}
+		if first, ok := pairsByName[pair.PairName()]; ok {
+			errs = append(errs, fmt.Errorf("%s: имя пары совпадает с repositories[%d], задайте уникальное поле name", prefix, first))
+		} else {
+			pairsByName[pair.PairName()] = i
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateRetry проверяет раздел retry
+func validateRetry(retry RetryConfig) []error {
+	var errs []error
+	if retry.MaxAttempts < 0 {
+		errs = append(errs, fmt.Errorf("retry.max_attempts: ожидается положительное число, получено %d", retry.MaxAttempts))
+	}
+	if retry.InitialBackoff < 0 {
+		errs = append(errs, fmt.Errorf("retry.initial_backoff: ожидается положительная длительность, получено %s", retry.InitialBackoff))
+	}
+	if retry.MaxBackoff < 0 {
+		errs = append(errs, fmt.Errorf("retry.max_backoff: ожидается положительная длительность, получено %s", retry.MaxBackoff))
+	}
+	if retry.InitialBackoff > 0 && retry.MaxBackoff > 0 && retry.InitialBackoff > retry.MaxBackoff {
+		errs = append(errs, fmt.Errorf("retry.initial_backoff: %s больше max_backoff %s", retry.InitialBackoff, retry.MaxBackoff))
+	}
+	if retry.Jitter != nil && (*retry.Jitter < 0 || *retry.Jitter > 1) {
+		errs = append(errs, fmt.Errorf("retry.jitter: ожидается число от 0 до 1, получено %g", *retry.Jitter))
+	}
+	return errs
+}
+
+// validateWebhook проверяет раздел webhook. Без секрета сервер принимал бы события от любого отправителя,
+// поэтому при заданном listen секрет обязателен.
+func validateWebhook(webhook WebhookConfig) []error {
+	var errs []error
+	if webhook.Listen != "" && webhook.Secret == "" {
+		errs = append(errs, errors.New("webhook.secret: обязателен, если задан webhook.listen"))
+	}
+	if webhook.Path != "" && !strings.HasPrefix(webhook.Path, "/") {
+		errs = append(errs, fmt.Errorf("webhook.path: ожидается путь, начинающийся с /, получено %q", webhook.Path))
+	}
+	if webhook.Debounce < 0 {
+		errs = append(errs, fmt.Errorf("webhook.debounce: ожидается положительная длительность, получено %s", webhook.Debounce))
+	}
+	return errs
+}
+
+// validatePair проверяет адреса, аутентификацию и настройки одной пары
+func (c *Config) validatePair(pair RepositoryPair) []error {
+	var errs []error
+
+	gitlabKind, err := repoURLKind(pair.GitlabURL)
+	if _, ok := pair.NumericProjectID(); ok && pair.GitlabURL == "" {
+		// Адрес репозитория пары с числовым ID получается через API GitLab по адресу gitlab_base_url
+		gitlabKind, err = urlHTTP, nil
+	}
+	if err != nil {
+		errs = append(errs, fmt.Errorf("gitlab_url: %w", err))
+	}
+	privateKind, err := repoURLKind(pair.PrivateRepoURL)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("private_repo_url: %w", err))
+	}
+
+	gitlabCred, privateCred, err := c.PairCredentials(pair)
+	if err != nil {
+		errs = append(errs, err)
+	} else {
+		if err := checkSideAuth(gitlabKind, gitlabCred, true); err != nil {
+			errs = append(errs, fmt.Errorf("GitLab репозиторий: %w", err))
+		}
+		if err := checkSideAuth(privateKind, privateCred, false); err != nil {
+			errs = append(errs, fmt.Errorf("приватный репозиторий: %w", err))
+		}
+	}
+	if privateKind == urlSSH && pair.PrivateCredential == "" && c.SSHKeyPath != "" {
+		if _, err := os.Stat(c.SSHKeyPath); err != nil {
+			errs = append(errs, fmt.Errorf("ssh_key_path: %w", err))
+		}
+	}
+
+	switch pair.ConflictStrategy {
+	case "", ConflictSkip, ConflictPreferGitlab, ConflictPreferPrivate, ConflictBranch, ConflictMerge, ConflictMergeRequest:
+	default:
+		errs = append(errs, fmt.Errorf("conflict_strategy: неизвестная стратегия %q", pair.ConflictStrategy))
+	}
+	for _, tmpl := range []struct{ field, text string }{
+		{"merge_request_title", pair.MergeRequestTitle},
+		{"merge_request_description", pair.MergeRequestDescription},
+	} {
+		if _, err := template.New(tmpl.field).Parse(tmpl.text); err != nil {
+			errs = append(errs, fmt.Errorf("%s: неверный шаблон: %w", tmpl.field, err))
+		}
+	}
+	switch pair.ProtectionOverride {
+	case "":
+	case ProtectionAllowForcePush, ProtectionUnprotect:
+		if pair.ConflictStrategy != ConflictPreferPrivate {
+			errs = append(errs, fmt.Errorf("protection_override применяется только с conflict_strategy: %s", ConflictPreferPrivate))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("protection_override: неизвестное значение %q", pair.ProtectionOverride))
+	}
+	if pair.Schedule != "" {
+		if _, err := schedule.Parse(pair.Schedule); err != nil {
+			errs = append(errs, fmt.Errorf("schedule: %w", err))
+		}
+	}
+	for _, pattern := range pair.ProtectedRefs {
+		if _, err := path.Match(pattern, ""); err != nil {
+			errs = append(errs, fmt.Errorf("protected_refs: неверный шаблон %q: %w", pattern, err))
+		}
+	}
+	if pair.PropagateDeletions && c.StateDir == "" {
+		errs = append(errs, errors.New("propagate_deletions требует state_dir"))
+	}
+	return errs
+}
+
+// checkSideAuth проверяет, что учетные данные подходят к адресу стороны пары.
+// Для GitLab по HTTP(S) токен обязателен, приватный репозиторий по HTTP(S) может быть публичным.
+func checkSideAuth(kind string, cred Credential, requireHTTPAuth bool) error {
+	switch kind {
+	case urlHTTP:
+		if cred.IsSSH() {
+			return fmt.Errorf("учетные данные типа %s нельзя использовать для адреса HTTP(S)", cred.Type)
+		}
+		if requireHTTPAuth && cred.Type == "" {
+			return errors.New("для адреса HTTP(S) требуется gitlab_token или gitlab_credential")
+		}
+	case urlSSH:
+		if cred.IsHTTP() {
+			return fmt.Errorf("учетные данные типа %s нельзя использовать для адреса SSH", cred.Type)
+		}
+		if cred.Type == "" {
+			return errors.New("для адреса SSH требуется ssh_key_path или учетные данные типа ssh-key или ssh-agent")
+		}
+	}
+	return nil
+}
+
+// sortedKeys возвращает ключи словаря в порядке сортировки, чтобы ошибки выводились стабильно
+func sortedKeys(m map[string]Credential) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Виды адресов репозиториев
+const (
+	urlLocal = "local"
+	urlHTTP  = "http"
+	urlSSH   = "ssh"
+	urlOther = "other"
+)
+
+// repoURLKind проверяет адрес репозитория и возвращает его вид
+func repoURLKind(raw string) (string, error) {
+	if raw == "" {
+		return "", errors.New("адрес репозитория не задан")
+	}
+	if strings.ContainsAny(raw, " \t\n") {
+		return "", fmt.Errorf("адрес %q содержит пробельные символы", raw)
+	}
+
+	if strings.Contains(raw, "://") {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return "", fmt.Errorf("неверный адрес %q: %w", raw, err)
+		}
+		if !supportedSchemes[u.Scheme] {
+			return "", fmt.Errorf("неподдерживаемая схема %q в адресе %q", u.Scheme, raw)
+		}
+		if u.Scheme != "file" && u.Host == "" {
+			return "", fmt.Errorf("в адресе %q не указан хост", raw)
+		}
+		if strings.Trim(u.Path, "/") == "" {
+			return "", fmt.Errorf("в адресе %q не указан путь репозитория", raw)
+		}
+		switch u.Scheme {
+		case "http", "https":
+			return urlHTTP, nil
+		case "ssh":
+			return urlSSH, nil
+		case "file":
+			return urlLocal, nil
+		}
+		return urlOther, nil
+	}
+
+	if _, path, ok := SplitSCP(raw); ok && path != "" {
+		return urlSSH, nil
+	}
+	return urlLocal, nil
+}
+
+// checkWritableDir проверяет, что директорию можно создать и в нее можно записать файл
+func checkWritableDir(dir string) error {
+	if dir == "" {
+		return errors.New("директория не задана")
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("не удалось создать директорию: %w", err)
+	}
+	f, err := os.CreateTemp(dir, ".git-sync-write-check-*")
+	if err != nil {
+		return fmt.Errorf("нет прав на запись: %w", err)
+	}
+	name := f.Name()
+	f.Close()
+	return os.Remove(name)
+}
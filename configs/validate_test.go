@@ -0,0 +1,340 @@
+package configs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRepoURLKind(t *testing.T) {
+	testCases := []struct {
+		url      string
+		expected string
+		wantErr  bool
+	}{
+		{"https://gitlab.com/group/repo.git", urlHTTP, false},
+		{"http://gitlab.local:8080/group/sub/repo.git", urlHTTP, false},
+		{"git@github.com:org/repo.git", urlSSH, false},
+		{"github.com:org/repo.git", urlSSH, false},
+		{"ssh://git@host:2222/org/repo.git", urlSSH, false},
+		{"git://host/org/repo.git", urlOther, false},
+		{"file:///srv/git/repo.git", urlLocal, false},
+		{"/srv/git/repo.git", urlLocal, false},
+		{"./repo", urlLocal, false},
+		{`C:\repos\repo`, urlLocal, false},
+		{"C:/repos/repo", urlLocal, false},
+		{"./group:repo", urlLocal, false},
+		{"", "", true},
+		{"https://gitlab.com", "", true},
+		{"https:///group/repo.git", "", true},
+		{"ftp://host/repo.git", "", true},
+		{"https://gitlab.com/group/my repo.git", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.url, func(t *testing.T) {
+			kind, err := repoURLKind(tc.url)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("Ожидалась ошибка для %q, получен вид %q", tc.url, kind)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Неожиданная ошибка: %v", err)
+			}
+			if kind != tc.expected {
+				t.Errorf("Ожидался вид %q, получен %q", tc.expected, kind)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, []byte("key"), 0600); err != nil {
+		t.Fatalf("Не удалось создать файл ключа: %v", err)
+	}
+
+	valid := func() Config {
+		return Config{
+			GitlabToken: "token",
+			SSHKeyPath:  keyPath,
+			TempDir:     filepath.Join(t.TempDir(), "work"),
+			Repositories: []RepositoryPair{
+				{GitlabURL: "https://gitlab.com/group/repo1.git", PrivateRepoURL: "git@private.com:org/repo1.git"},
+				{GitlabURL: "https://gitlab.com/group/repo2.git", PrivateRepoURL: "/srv/git/repo2.git"},
+			},
+		}
+	}
+
+	testCases := []struct {
+		name   string
+		modify func(cfg *Config)
+		errors []string
+	}{
+		{"Valid", func(cfg *Config) {}, nil},
+		{
+			"MissingToken",
+			func(cfg *Config) { cfg.GitlabToken = "" },
+			[]string{"repositories[0] (repo1): GitLab репозиторий: для адреса HTTP(S) требуется gitlab_token", "repositories[1] (repo2)"},
+		},
+		{
+			"LocalGitlabWithoutToken",
+			func(cfg *Config) {
+				cfg.GitlabToken = ""
+				cfg.Repositories = cfg.Repositories[1:]
+				cfg.Repositories[0].GitlabURL = "/srv/git/gitlab-repo2.git"
+			},
+			nil,
+		},
+		{
+			"NegativeConcurrency",
+			func(cfg *Config) { cfg.Concurrency = -1 },
+			[]string{"concurrency: ожидается положительное число"},
+		},
+		{
+			"NegativeTimeouts",
+			func(cfg *Config) { cfg.OperationTimeout, cfg.PairTimeout = -time.Second, -time.Minute },
+			[]string{"operation_timeout: ожидается положительная длительность", "pair_timeout:"},
+		},
+		{
+			"InvalidSchedule",
+			func(cfg *Config) {
+				cfg.Schedule = "-1m"
+				cfg.Repositories[0].Schedule = "61 * * * *"
+			},
+			[]string{"schedule: интервал -1m должен быть положительным", "schedule: ожидается интервал (например, 15m) или cron-выражение"},
+		},
+		{
+			"WebhookWithoutSecret",
+			func(cfg *Config) {
+				cfg.Webhook = WebhookConfig{Listen: ":8080", Path: "hooks", Debounce: -time.Second}
+			},
+			[]string{"webhook.secret: обязателен", "webhook.path: ожидается путь", "webhook.debounce:"},
+		},
+		{
+			"InvalidRetry",
+			func(cfg *Config) {
+				jitter := 1.5
+				cfg.Retry = RetryConfig{MaxAttempts: -1, InitialBackoff: time.Minute, MaxBackoff: time.Second, Jitter: &jitter}
+			},
+			[]string{"retry.max_attempts:", "retry.initial_backoff: 1m0s больше max_backoff", "retry.jitter: ожидается число от 0 до 1"},
+		},
+		{
+			"MissingSSHKey",
+			func(cfg *Config) { cfg.SSHKeyPath = "" },
+			[]string{"требуется ssh_key_path"},
+		},
+		{
+			"NonexistentSSHKey",
+			func(cfg *Config) { cfg.SSHKeyPath = filepath.Join(t.TempDir(), "missing") },
+			[]string{"ssh_key_path:"},
+		},
+		{
+			"NamedCredentials",
+			func(cfg *Config) {
+				cfg.GitlabToken, cfg.SSHKeyPath = "", ""
+				cfg.Credentials = map[string]Credential{
+					"gitlab":  {Type: CredentialToken, SecretEnv: "GITLAB_TOKEN"},
+					"private": {Type: CredentialSSHKey, KeyPath: keyPath, Username: "deploy"},
+					"gitea":   {Type: CredentialBasic, Username: "bot", Secret: "password"},
+				}
+				cfg.Repositories[0].GitlabCredential = "gitlab"
+				cfg.Repositories[0].PrivateCredential = "private"
+				cfg.Repositories[1].GitlabCredential = "gitlab"
+				cfg.Repositories[1].PrivateRepoURL = "https://gitea.example.com/org/repo2.git"
+				cfg.Repositories[1].PrivateCredential = "gitea"
+			},
+			nil,
+		},
+		{
+			"UnknownCredential",
+			func(cfg *Config) { cfg.Repositories[0].PrivateCredential = "missing" },
+			[]string{`private_credential: учетные данные "missing" не найдены`},
+		},
+		{
+			"InvalidCredentials",
+			func(cfg *Config) {
+				cfg.Credentials = map[string]Credential{
+					"empty":    {},
+					"basic":    {Type: CredentialBasic, Secret: "password"},
+					"token":    {Type: CredentialToken},
+					"key":      {Type: CredentialSSHKey},
+					"unknown":  {Type: "kerberos"},
+					"mode":     {Type: CredentialSSHAgent, HostKeyCheck: "yes"},
+					"agent":    {Type: CredentialSSHAgent, Passphrase: "secret"},
+					"tokenssh": {Type: CredentialToken, Secret: "token", KnownHosts: "/tmp/known_hosts"},
+				}
+			},
+			[]string{
+				"credentials.empty: не задан type",
+				"credentials.basic: для типа basic требуется username",
+				"credentials.token: требуется secret или secret_env",
+				"credentials.key: для типа ssh-key требуется key_path",
+				`credentials.unknown: неизвестный type "kerberos"`,
+				`credentials.mode: host_key_check: неизвестный режим "yes"`,
+				"credentials.agent: key_path и passphrase не используются с типом ssh-agent",
+				"credentials.tokenssh: known_hosts не используется с типом token",
+			},
+		},
+		{
+			"CredentialDoesNotMatchURL",
+			func(cfg *Config) {
+				cfg.Credentials = map[string]Credential{"agent": {Type: CredentialSSHAgent}}
+				cfg.Repositories[0].GitlabCredential = "agent"
+			},
+			[]string{"GitLab репозиторий: учетные данные типа ssh-agent нельзя использовать для адреса HTTP(S)"},
+		},
+		{
+			"InvalidURL",
+			func(cfg *Config) { cfg.Repositories[0].GitlabURL = "ftp://gitlab.com/group/repo1.git" },
+			[]string{"gitlab_url: неподдерживаемая схема"},
+		},
+		{
+			"MissingPrivateURL",
+			func(cfg *Config) { cfg.Repositories[1].PrivateRepoURL = "" },
+			[]string{"private_repo_url: адрес репозитория не задан"},
+		},
+		{
+			"DuplicatePair",
+			func(cfg *Config) {
+				duplicate := cfg.Repositories[0]
+				duplicate.Name = "copy"
+				cfg.Repositories = append(cfg.Repositories, duplicate)
+			},
+			[]string{"repositories[2] (copy): пара повторяет repositories[0]"},
+		},
+		{
+			"DuplicateNormalizedURL",
+			func(cfg *Config) {
+				cfg.Repositories = []RepositoryPair{
+					{Name: "first", GitlabURL: "https://host/g/app.git", PrivateRepoURL: "/srv/git/app.git"},
+					{Name: "second", GitlabURL: "https://host/g/app", PrivateRepoURL: "/srv/git/app"},
+					{Name: "third", GitlabURL: "HTTPS://Host/g/app/", PrivateRepoURL: "/srv/git/app/"},
+				}
+			},
+			[]string{
+				"repositories[1] (second): пара повторяет repositories[0]",
+				"repositories[2] (third): пара повторяет repositories[0]",
+			},
+		},
+		{
+			"DuplicateName",
+			func(cfg *Config) { cfg.Repositories[1].Name = "repo1" },
+			[]string{"имя пары совпадает с repositories[0]"},
+		},
+		{
+			"UnknownStrategy",
+			func(cfg *Config) { cfg.Repositories[0].ConflictStrategy = "rebase" },
+			[]string{`conflict_strategy: неизвестная стратегия "rebase"`},
+		},
+		{
+			"UnknownProtectionOverride",
+			func(cfg *Config) {
+				cfg.Repositories[0].ConflictStrategy = ConflictPreferPrivate
+				cfg.Repositories[0].ProtectionOverride = "disable"
+			},
+			[]string{`protection_override: неизвестное значение "disable"`},
+		},
+		{
+			"ProtectionOverrideWithoutForce",
+			func(cfg *Config) { cfg.Repositories[0].ProtectionOverride = ProtectionUnprotect },
+			[]string{"protection_override применяется только с conflict_strategy: prefer_private"},
+		},
+		{
+			"InvalidMergeRequestTemplate",
+			func(cfg *Config) {
+				cfg.Repositories[0].ConflictStrategy = ConflictMergeRequest
+				cfg.Repositories[0].MergeRequestTitle = "Синхронизация {{.Branch"
+			},
+			[]string{"merge_request_title: неверный шаблон"},
+		},
+		{
+			"InvalidProtectedPattern",
+			func(cfg *Config) { cfg.Repositories[0].ProtectedRefs = []string{"release/["} },
+			[]string{"protected_refs: неверный шаблон"},
+		},
+		{
+			"DeletionsWithoutState",
+			func(cfg *Config) { cfg.Repositories[0].PropagateDeletions = true },
+			[]string{"propagate_deletions требует state_dir"},
+		},
+		{
+			"NumericProjectID",
+			func(cfg *Config) {
+				cfg.GitlabBaseURL = "https://gitlab.com"
+				cfg.Repositories[0].GitlabURL, cfg.Repositories[0].GitlabProjectID = "", "42"
+			},
+			nil,
+		},
+		{
+			"NumericProjectIDWithoutToken",
+			func(cfg *Config) {
+				cfg.GitlabToken = ""
+				cfg.GitlabBaseURL = "https://gitlab.com"
+				cfg.Repositories = cfg.Repositories[1:]
+				cfg.Repositories[0].GitlabURL, cfg.Repositories[0].GitlabProjectID = "", "42"
+			},
+			[]string{"repositories[0] (42): GitLab репозиторий: для адреса HTTP(S) требуется gitlab_token"},
+		},
+		{
+			"InvalidBaseURL",
+			func(cfg *Config) { cfg.GitlabBaseURL = "gitlab.example.com" },
+			[]string{"gitlab_base_url:"},
+		},
+		{
+			"MissingTempDir",
+			func(cfg *Config) { cfg.TempDir = "" },
+			[]string{"temp_dir: директория не задана"},
+		},
+		{
+			"TempDirIsFile",
+			func(cfg *Config) { cfg.TempDir = keyPath },
+			[]string{"temp_dir: не удалось создать директорию"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := valid()
+			tc.modify(&cfg)
+
+			err := cfg.Validate()
+			if len(tc.errors) == 0 {
+				if err != nil {
+					t.Fatalf("Ожидалась корректная конфигурация, получена ошибка: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Ожидалась ошибка проверки конфигурации")
+			}
+			for _, expected := range tc.errors {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Ошибка не содержит %q:\n%v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateReadOnlyTempDir(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Права на директорию не ограничивают пользователя root")
+	}
+
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0555); err != nil {
+		t.Fatalf("Не удалось изменить права директории: %v", err)
+	}
+	t.Cleanup(func() { os.Chmod(dir, 0755) })
+
+	cfg := Config{TempDir: dir}
+	err := cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "temp_dir: нет прав на запись") {
+		t.Errorf("Ожидалась ошибка записи во временную директорию, получено: %v", err)
+	}
+}
@@ -0,0 +1,62 @@
+package gitlab
+
+import (
+	"context"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// API операции GitLab API над одним проектом, которые нужны синхронизации: проект, файлы, ветки,
+// защищенные ветки, коммиты, теги и merge request. Реализуется Client; в тестах - MockClient.
+type API interface {
+	// GetProject возвращает проект
+	GetProject(ctx context.Context) (*gitlab.Project, error)
+
+	// GetFileContent возвращает содержимое файла в ветке
+	GetFileContent(ctx context.Context, branch, filePath string) ([]byte, error)
+	// CreateOrUpdateFile создает файл в ветке или заменяет его содержимое отдельным коммитом
+	CreateOrUpdateFile(ctx context.Context, branch, filePath, content, commitMessage string) error
+
+	// ListBranches возвращает все ветки проекта
+	ListBranches(ctx context.Context) ([]*gitlab.Branch, error)
+	// GetBranchHeadCommitID возвращает SHA последнего коммита ветки
+	GetBranchHeadCommitID(ctx context.Context, branch string) (string, error)
+	// CreateBranch создает ветку branchName из ref
+	CreateBranch(ctx context.Context, branchName, ref string) error
+	// UpdateBranchHead приводит содержимое ветки к коммиту commitSHA
+	UpdateBranchHead(ctx context.Context, branchName, commitSHA string) error
+	// DeleteBranch удаляет ветку
+	DeleteBranch(ctx context.Context, branchName string) error
+
+	// ListProtectedBranches возвращает правила защиты веток проекта
+	ListProtectedBranches(ctx context.Context) ([]*gitlab.ProtectedBranch, error)
+	// GetProtectedBranch возвращает правило защиты ветки или шаблона имен веток
+	GetProtectedBranch(ctx context.Context, branch string) (*gitlab.ProtectedBranch, error)
+	// ProtectBranch создает правило защиты ветки или шаблона имен веток
+	ProtectBranch(ctx context.Context, opt *gitlab.ProtectRepositoryBranchesOptions) error
+	// UpdateProtectedBranch изменяет правило защиты ветки или шаблона имен веток
+	UpdateProtectedBranch(ctx context.Context, name string, opt *gitlab.UpdateProtectedBranchOptions) error
+	// UnprotectBranch удаляет правило защиты ветки или шаблона имен веток
+	UnprotectBranch(ctx context.Context, name string) error
+
+	// GetCommit возвращает коммит
+	GetCommit(ctx context.Context, sha string) (*gitlab.Commit, error)
+	// CreateCommit создает коммит с набором действий над файлами
+	CreateCommit(ctx context.Context, opt *gitlab.CreateCommitOptions) (*gitlab.Commit, error)
+
+	// ListTags возвращает все теги проекта
+	ListTags(ctx context.Context) ([]*gitlab.Tag, error)
+	// CreateTag создает тег tagName на ref
+	CreateTag(ctx context.Context, tagName, ref string) error
+	// DeleteTag удаляет тег
+	DeleteTag(ctx context.Context, tagName string) error
+
+	// ListOpenMergeRequests возвращает открытые merge request из ветки sourceBranch в ветку targetBranch
+	ListOpenMergeRequests(ctx context.Context, sourceBranch, targetBranch string) ([]*gitlab.BasicMergeRequest, error)
+	// CreateMergeRequest создает merge request
+	CreateMergeRequest(ctx context.Context, opt *gitlab.CreateMergeRequestOptions) (*gitlab.MergeRequest, error)
+	// UpdateMergeRequest изменяет merge request с внутренним номером iid
+	UpdateMergeRequest(ctx context.Context, iid int64, opt *gitlab.UpdateMergeRequestOptions) (*gitlab.MergeRequest, error)
+}
+
+var _ API = (*Client)(nil)
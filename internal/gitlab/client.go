@@ -1,22 +1,43 @@
 package gitlab
 
 import (
+	"context"
 	"fmt"
 	"net/http"
-	"strings"
 
-	gitlab "gitlab.com/gitlab-org/api/client-go" // Исправлено на правильный импорт
+	"git-sync/internal/repository"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
 )
 
+// perPage число записей на странице списков GitLab API, наибольшее допустимое
+const perPage = 100
+
+// listAll собирает все страницы списка, запрашивая страницу list, пока GitLab сообщает о следующей
+func listAll[T any](list func(page int64) ([]T, *gitlab.Response, error)) ([]T, error) {
+	var all []T
+	for page := int64(1); ; {
+		items, resp, err := list(page)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+		if resp.NextPage == 0 {
+			return all, nil
+		}
+		page = resp.NextPage
+	}
+}
+
 // Client обертка для GitLab API
 type Client struct {
-	Client    *gitlab.Client // Изменено на экспортируемое поле
-	ProjectID int            // Изменено на экспортируемое поле
+	Client    *gitlab.Client
+	ProjectID int64
 }
 
 // NewClient создает новый экземпляр GitLab клиента
 // projectID должен быть получен до вызова этой функции, например, с помощью GetProjectIDByPath
-func NewClient(gitlabURL, token string, projectID int) (*Client, error) {
+func NewClient(gitlabURL, token string, projectID int64) (*Client, error) {
 	gitClient, err := gitlab.NewClient(token, gitlab.WithBaseURL(gitlabURL))
 	if err != nil {
 		return nil, fmt.Errorf("не удалось создать GitLab клиент: %w", err)
@@ -28,11 +49,16 @@ func NewClient(gitlabURL, token string, projectID int) (*Client, error) {
 	}, nil
 }
 
-// GetProjectIDByPath получает ID проекта GitLab по его полному пути (например, "group/subgroup/project-name")
-func GetProjectIDByPath(repoURL, gitlabURL, token string) (int, error) {
-	projectPath := getProjectPathFromURL(repoURL)
-	if projectPath == "" {
-		return 0, fmt.Errorf("не удалось извлечь путь проекта из URL: %s", repoURL)
+// GetProjectIDByPath получает ID проекта GitLab по адресу его репозитория: HTTPS, SSH или SCP-подобному,
+// в том числе во вложенных группах. gitlabURL - адрес экземпляра или его API, может содержать относительный
+// корень (https://host/gitlab); если он пуст, используется хост из repoURL.
+func GetProjectIDByPath(ctx context.Context, repoURL, gitlabURL, token string) (int64, error) {
+	remote, err := repository.ParseRemoteURL(repoURL, gitlabURL)
+	if err != nil {
+		return 0, fmt.Errorf("не удалось извлечь путь проекта из URL: %w", err)
+	}
+	if gitlabURL == "" {
+		gitlabURL = remote.BaseURL
 	}
 
 	// Создаем временный клиент для получения ID проекта
@@ -41,73 +67,68 @@ func GetProjectIDByPath(repoURL, gitlabURL, token string) (int, error) {
 		return 0, fmt.Errorf("не удалось создать временный GitLab клиент для получения ID проекта: %w", err)
 	}
 
-	project, _, err := tempGitClient.Projects.GetProject(projectPath, &gitlab.GetProjectOptions{})
+	project, _, err := tempGitClient.Projects.GetProject(remote.PathWithNamespace, &gitlab.GetProjectOptions{}, gitlab.WithContext(ctx))
 	if err != nil {
-		return 0, fmt.Errorf("не удалось получить ID проекта по пути %s: %w", projectPath, err)
+		return 0, fmt.Errorf("не удалось получить ID проекта по пути %s: %w", remote.PathWithNamespace, err)
 	}
 	return project.ID, nil
 }
 
-// getProjectPathFromURL извлекает путь проекта из URL GitLab
-func getProjectPathFromURL(repoURL string) string {
-	// Пример: https://gitlab.com/group/subgroup/project.git
-	// Ожидаем "group/subgroup/project"
-	parts := strings.Split(repoURL, "/")
-	if len(parts) < 3 {
-		return ""
+// GetProject получает проект GitLab
+func (c *Client) GetProject(ctx context.Context) (*gitlab.Project, error) {
+	project, _, err := c.Client.Projects.GetProject(c.ProjectID, &gitlab.GetProjectOptions{}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить проект %d: %w", c.ProjectID, err)
 	}
-	// Удаляем ".git" если есть
-	projectName := strings.TrimSuffix(parts[len(parts)-1], ".git")
-	// Собираем путь проекта
-	projectPathParts := parts[len(parts)-3 : len(parts)-1]
-	projectPathParts = append(projectPathParts, projectName)
-	return strings.Join(projectPathParts, "/")
+	return project, nil
 }
 
 // GetFileContent получает содержимое файла из репозитория GitLab
-func (c *Client) GetFileContent(branch, filePath string) ([]byte, error) {
-	file, _, err := c.Client.RepositoryFiles.GetFile(c.ProjectID, filePath, &gitlab.GetFileOptions{Ref: gitlab.Ptr(branch)})
+func (c *Client) GetFileContent(ctx context.Context, branch, filePath string) ([]byte, error) {
+	file, _, err := c.Client.RepositoryFiles.GetRawFile(c.ProjectID, filePath, &gitlab.GetRawFileOptions{Ref: gitlab.Ptr(branch)}, gitlab.WithContext(ctx))
 	if err != nil {
 		return nil, fmt.Errorf("не удалось получить файл %s из ветки %s: %w", filePath, branch, err)
 	}
-	return []byte(file.Content), nil
+	return file, nil
 }
 
 // CreateOrUpdateFile создает или обновляет файл в репозитории GitLab
-func (c *Client) CreateOrUpdateFile(branch, filePath, content, commitMessage string) error {
+func (c *Client) CreateOrUpdateFile(ctx context.Context, branch, filePath, content, commitMessage string) error {
 	// Проверяем, существует ли файл
-	_, resp, err := c.Client.RepositoryFiles.GetFile(c.ProjectID, filePath, &gitlab.GetFileOptions{Ref: gitlab.Ptr(branch)})
-	if err != nil && resp != nil && resp.StatusCode != http.StatusNotFound {
+	_, resp, err := c.Client.RepositoryFiles.GetFileMetaData(c.ProjectID, filePath, &gitlab.GetFileMetaDataOptions{Ref: gitlab.Ptr(branch)}, gitlab.WithContext(ctx))
+	exists := err == nil
+	if err != nil && (resp == nil || resp.StatusCode != http.StatusNotFound) {
 		return fmt.Errorf("ошибка при проверке существования файла %s: %w", filePath, err)
 	}
 
-	if resp != nil && resp.StatusCode == http.StatusNotFound {
-		// Файл не существует, создаем
+	if !exists {
 		_, _, err = c.Client.RepositoryFiles.CreateFile(c.ProjectID, filePath, &gitlab.CreateFileOptions{
 			Branch:        gitlab.Ptr(branch),
 			Content:       gitlab.Ptr(content),
 			CommitMessage: gitlab.Ptr(commitMessage),
-		})
+		}, gitlab.WithContext(ctx))
 		if err != nil {
 			return fmt.Errorf("не удалось создать файл %s: %w", filePath, err)
 		}
-	} else {
-		// Файл существует, обновляем
-		_, _, err = c.Client.RepositoryFiles.UpdateFile(c.ProjectID, filePath, &gitlab.UpdateFileOptions{
-			Branch:        gitlab.Ptr(branch),
-			Content:       gitlab.Ptr(content),
-			CommitMessage: gitlab.Ptr(commitMessage),
-		})
-		if err != nil {
-			return fmt.Errorf("не удалось обновить файл %s: %w", filePath, err)
-		}
+		return nil
+	}
+
+	_, _, err = c.Client.RepositoryFiles.UpdateFile(c.ProjectID, filePath, &gitlab.UpdateFileOptions{
+		Branch:        gitlab.Ptr(branch),
+		Content:       gitlab.Ptr(content),
+		CommitMessage: gitlab.Ptr(commitMessage),
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("не удалось обновить файл %s: %w", filePath, err)
 	}
 	return nil
 }
 
-// ListBranches получает список веток в репозитории GitLab
-func (c *Client) ListBranches() ([]*gitlab.Branch, error) {
-	branches, _, err := c.Client.Branches.ListBranches(c.ProjectID, &gitlab.ListBranchesOptions{})
+// ListBranches получает список всех веток в репозитории GitLab, проходя по всем страницам ответа
+func (c *Client) ListBranches(ctx context.Context) ([]*gitlab.Branch, error) {
+	branches, err := listAll(func(page int64) ([]*gitlab.Branch, *gitlab.Response, error) {
+		return c.Client.Branches.ListBranches(c.ProjectID, &gitlab.ListBranchesOptions{ListOptions: gitlab.ListOptions{PerPage: perPage, Page: page}}, gitlab.WithContext(ctx))
+	})
 	if err != nil {
 		return nil, fmt.Errorf("не удалось получить список веток: %w", err)
 	}
@@ -115,44 +136,233 @@ func (c *Client) ListBranches() ([]*gitlab.Branch, error) {
 }
 
 // GetBranchHeadCommitID получает ID последнего коммита ветки
-func (c *Client) GetBranchHeadCommitID(branch string) (string, error) {
-	b, _, err := c.Client.Branches.GetBranch(c.ProjectID, branch)
+func (c *Client) GetBranchHeadCommitID(ctx context.Context, branch string) (string, error) {
+	b, _, err := c.Client.Branches.GetBranch(c.ProjectID, branch, gitlab.WithContext(ctx))
 	if err != nil {
 		return "", fmt.Errorf("не удалось получить ветку %s: %w", branch, err)
 	}
+	if b.Commit == nil {
+		return "", fmt.Errorf("GitLab не вернул последний коммит ветки %s", branch)
+	}
 	return b.Commit.ID, nil
 }
 
 // CreateBranch создает новую ветку из существующей
-func (c *Client) CreateBranch(branchName, ref string) error {
+func (c *Client) CreateBranch(ctx context.Context, branchName, ref string) error {
 	_, _, err := c.Client.Branches.CreateBranch(c.ProjectID, &gitlab.CreateBranchOptions{
 		Branch: gitlab.Ptr(branchName),
 		Ref:    gitlab.Ptr(ref),
-	})
+	}, gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("не удалось создать ветку %s из %s: %w", branchName, ref, err)
 	}
 	return nil
 }
 
-// UpdateBranchHead обновляет ветку до определенного коммита (имитация reset --hard)
-// В GitLab API нет прямого аналога git reset --hard.
-// Это может быть реализовано через создание нового коммита, который делает содержимое ветки идентичным целевому коммиту,
-// или через удаление и пересоздание ветки.
-// Для простоты, пока оставим это как заглушку или будем использовать CreateOrUpdateFile для каждого файла.
-// Более сложная логика может включать сравнение деревьев и создание коммита с изменениями.
-func (c *Client) UpdateBranchHead(branchName, commitSHA string) error {
-	// Это сложная операция, которая требует более глубокой работы с API.
-	// Для MVP, возможно, потребуется пересмотреть подход к синхронизации веток.
-	// Пока что, это будет заглушка.
-	return fmt.Errorf("операция UpdateBranchHead не реализована напрямую через GitLab API")
+// UpdateBranchHead приводит содержимое ветки к дереву коммита commitSHA (аналог reset --hard для файлов).
+// В GitLab API нет прямого способа передвинуть ветку на произвольный коммит, поэтому дерево ветки
+// сравнивается с деревом commitSHA и все различия записываются одним коммитом с сообщением и автором
+// commitSHA. Коммит commitSHA должен быть доступен в проекте. Если деревья совпадают, коммит не создается.
+// Если ветку передвинули во время сравнения, возвращается ошибка и коммит не создается.
+func (c *Client) UpdateBranchHead(ctx context.Context, branchName, commitSHA string) error {
+	head, err := c.GetBranchHeadCommitID(ctx, branchName)
+	if err != nil {
+		return err
+	}
+	target, err := c.GetCommit(ctx, commitSHA)
+	if err != nil {
+		return err
+	}
+
+	current, err := c.walkTree(ctx, head)
+	if err != nil {
+		return err
+	}
+	wanted, err := c.walkTree(ctx, target.ID)
+	if err != nil {
+		return err
+	}
+	changes, err := diffTrees(current, wanted)
+	if err != nil {
+		return fmt.Errorf("не удалось перенести коммит %s в ветку %s: %w", commitSHA, branchName, err)
+	}
+	if len(changes) == 0 {
+		return nil
+	}
+
+	actions := make([]*gitlab.CommitActionOptions, 0, len(changes))
+	for _, change := range changes {
+		action, err := c.commitAction(ctx, change, head)
+		if err != nil {
+			return err
+		}
+		actions = append(actions, action)
+	}
+
+	// Создание файлов и смена режима не проверяют last_commit_id, поэтому перед коммитом
+	// убеждаемся, что ветка все еще указывает на прочитанный коммит
+	latest, err := c.GetBranchHeadCommitID(ctx, branchName)
+	if err != nil {
+		return err
+	}
+	if latest != head {
+		return fmt.Errorf("не удалось обновить ветку %s до коммита %s: ветка изменилась во время обновления (%s вместо %s)",
+			branchName, commitSHA, latest, head)
+	}
+
+	_, err = c.CreateCommit(ctx, &gitlab.CreateCommitOptions{
+		Branch:        gitlab.Ptr(branchName),
+		CommitMessage: gitlab.Ptr(target.Message),
+		AuthorName:    gitlab.Ptr(target.AuthorName),
+		AuthorEmail:   gitlab.Ptr(target.AuthorEmail),
+		Actions:       actions,
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось обновить ветку %s до коммита %s: %w", branchName, commitSHA, err)
+	}
+	return nil
 }
 
 // DeleteBranch удаляет ветку
-func (c *Client) DeleteBranch(branchName string) error {
-	_, err := c.Client.Branches.DeleteBranch(c.ProjectID, branchName)
+func (c *Client) DeleteBranch(ctx context.Context, branchName string) error {
+	_, err := c.Client.Branches.DeleteBranch(c.ProjectID, branchName, gitlab.WithContext(ctx))
 	if err != nil {
 		return fmt.Errorf("не удалось удалить ветку %s: %w", branchName, err)
 	}
 	return nil
 }
+
+// ListProtectedBranches получает правила защиты веток проекта
+func (c *Client) ListProtectedBranches(ctx context.Context) ([]*gitlab.ProtectedBranch, error) {
+	protected, err := listAll(func(page int64) ([]*gitlab.ProtectedBranch, *gitlab.Response, error) {
+		return c.Client.ProtectedBranches.ListProtectedBranches(c.ProjectID, &gitlab.ListProtectedBranchesOptions{ListOptions: gitlab.ListOptions{PerPage: perPage, Page: page}}, gitlab.WithContext(ctx))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список защищенных веток: %w", err)
+	}
+	return protected, nil
+}
+
+// GetProtectedBranch получает правило защиты ветки
+func (c *Client) GetProtectedBranch(ctx context.Context, branch string) (*gitlab.ProtectedBranch, error) {
+	protected, _, err := c.Client.ProtectedBranches.GetProtectedBranch(c.ProjectID, branch, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить защиту ветки %s: %w", branch, err)
+	}
+	return protected, nil
+}
+
+// ProtectBranch создает правило защиты ветки
+func (c *Client) ProtectBranch(ctx context.Context, opt *gitlab.ProtectRepositoryBranchesOptions) error {
+	_, _, err := c.Client.ProtectedBranches.ProtectRepositoryBranches(c.ProjectID, opt, gitlab.WithContext(ctx))
+	if err != nil {
+		var name string
+		if opt.Name != nil {
+			name = *opt.Name
+		}
+		return fmt.Errorf("не удалось защитить ветку %s: %w", name, err)
+	}
+	return nil
+}
+
+// UpdateProtectedBranch изменяет правило защиты ветки
+func (c *Client) UpdateProtectedBranch(ctx context.Context, name string, opt *gitlab.UpdateProtectedBranchOptions) error {
+	_, _, err := c.Client.ProtectedBranches.UpdateProtectedBranch(c.ProjectID, name, opt, gitlab.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("не удалось изменить защиту ветки %s: %w", name, err)
+	}
+	return nil
+}
+
+// UnprotectBranch снимает защиту ветки
+func (c *Client) UnprotectBranch(ctx context.Context, name string) error {
+	_, err := c.Client.ProtectedBranches.UnprotectRepositoryBranches(c.ProjectID, name, gitlab.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("не удалось снять защиту ветки %s: %w", name, err)
+	}
+	return nil
+}
+
+// GetCommit получает коммит по SHA
+func (c *Client) GetCommit(ctx context.Context, sha string) (*gitlab.Commit, error) {
+	commit, _, err := c.Client.Commits.GetCommit(c.ProjectID, sha, &gitlab.GetCommitOptions{}, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить коммит %s: %w", sha, err)
+	}
+	return commit, nil
+}
+
+// CreateCommit создает коммит с действиями над файлами
+func (c *Client) CreateCommit(ctx context.Context, opt *gitlab.CreateCommitOptions) (*gitlab.Commit, error) {
+	commit, _, err := c.Client.Commits.CreateCommit(c.ProjectID, opt, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать коммит: %w", err)
+	}
+	return commit, nil
+}
+
+// ListTags получает список всех тегов проекта
+func (c *Client) ListTags(ctx context.Context) ([]*gitlab.Tag, error) {
+	tags, err := listAll(func(page int64) ([]*gitlab.Tag, *gitlab.Response, error) {
+		return c.Client.Tags.ListTags(c.ProjectID, &gitlab.ListTagsOptions{ListOptions: gitlab.ListOptions{PerPage: perPage, Page: page}}, gitlab.WithContext(ctx))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить список тегов: %w", err)
+	}
+	return tags, nil
+}
+
+// CreateTag создает легковесный тег на ref
+func (c *Client) CreateTag(ctx context.Context, tagName, ref string) error {
+	_, _, err := c.Client.Tags.CreateTag(c.ProjectID, &gitlab.CreateTagOptions{
+		TagName: gitlab.Ptr(tagName),
+		Ref:     gitlab.Ptr(ref),
+	}, gitlab.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("не удалось создать тег %s на %s: %w", tagName, ref, err)
+	}
+	return nil
+}
+
+// DeleteTag удаляет тег
+func (c *Client) DeleteTag(ctx context.Context, tagName string) error {
+	_, err := c.Client.Tags.DeleteTag(c.ProjectID, tagName, gitlab.WithContext(ctx))
+	if err != nil {
+		return fmt.Errorf("не удалось удалить тег %s: %w", tagName, err)
+	}
+	return nil
+}
+
+// ListOpenMergeRequests получает открытые merge request между ветками
+func (c *Client) ListOpenMergeRequests(ctx context.Context, sourceBranch, targetBranch string) ([]*gitlab.BasicMergeRequest, error) {
+	mergeRequests, err := listAll(func(page int64) ([]*gitlab.BasicMergeRequest, *gitlab.Response, error) {
+		return c.Client.MergeRequests.ListProjectMergeRequests(c.ProjectID, &gitlab.ListProjectMergeRequestsOptions{
+			ListOptions:  gitlab.ListOptions{PerPage: perPage, Page: page},
+			State:        gitlab.Ptr("opened"),
+			SourceBranch: gitlab.Ptr(sourceBranch),
+			TargetBranch: gitlab.Ptr(targetBranch),
+		}, gitlab.WithContext(ctx))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить merge request из %s в %s: %w", sourceBranch, targetBranch, err)
+	}
+	return mergeRequests, nil
+}
+
+// CreateMergeRequest создает merge request
+func (c *Client) CreateMergeRequest(ctx context.Context, opt *gitlab.CreateMergeRequestOptions) (*gitlab.MergeRequest, error) {
+	mergeRequest, _, err := c.Client.MergeRequests.CreateMergeRequest(c.ProjectID, opt, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать merge request: %w", err)
+	}
+	return mergeRequest, nil
+}
+
+// UpdateMergeRequest изменяет merge request
+func (c *Client) UpdateMergeRequest(ctx context.Context, iid int64, opt *gitlab.UpdateMergeRequestOptions) (*gitlab.MergeRequest, error) {
+	mergeRequest, _, err := c.Client.MergeRequests.UpdateMergeRequest(c.ProjectID, iid, opt, gitlab.WithContext(ctx))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось изменить merge request !%d: %w", iid, err)
+	}
+	return mergeRequest, nil
+}
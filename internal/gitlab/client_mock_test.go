@@ -1,128 +1,194 @@
 package gitlab
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 
 	gitlab "gitlab.com/gitlab-org/api/client-go"
 )
 
-// MockClient implements the Client interface for testing purposes.
+// MockClient реализует интерфейс API для тестов. Каждый метод вызывает соответствующее поле Mock*.
 type MockClient struct {
-	MockGetProject          func(projectID interface{}) (*gitlab.Project, *gitlab.Response, error)
-	MockGetFile             func(projectID int, filePath string, options *gitlab.GetFileOptions) (*gitlab.File, *gitlab.Response, error)
-	MockCreateFile          func(projectID int, filePath string, opt *gitlab.CreateFileOptions) (*gitlab.File, *gitlab.Response, error)
-	MockUpdateFile          func(projectID int, filePath string, opt *gitlab.UpdateFileOptions) (*gitlab.File, *gitlab.Response, error)
-	MockDeleteFile          func(projectID int, filePath string, opt *gitlab.DeleteFileOptions) (*gitlab.Response, error)
-	MockListProjectBranches func(projectID int, opt *gitlab.ListBranchesOptions) ([]*gitlab.Branch, *gitlab.Response, error)
-	MockGetBranch           func(projectID int, branch string) (*gitlab.Branch, *gitlab.Response, error)
-	MockCreateBranch        func(projectID int, opt *gitlab.CreateBranchOptions) (*gitlab.Branch, *gitlab.Response, error)
-	MockDeleteBranch        func(projectID int, branch string) (*gitlab.Response, error)
-	MockGetCommit           func(projectID int, sha string) (*gitlab.Commit, *gitlab.Response, error)
-	MockListProjectCommits  func(projectID int, opt *gitlab.ListCommitsOptions) ([]*gitlab.Commit, *gitlab.Response, error)
-	MockCreateCommit        func(projectID int, opt *gitlab.CreateCommitOptions) (*gitlab.Commit, *gitlab.Response, error)
-	MockGetBranchHeadCommitID func(branch string) (string, error)
-}
-
-// NewMockClient creates a new MockClient with default (panic) implementations.
+	MockGetProject            func(ctx context.Context) (*gitlab.Project, error)
+	MockGetFileContent        func(ctx context.Context, branch, filePath string) ([]byte, error)
+	MockCreateOrUpdateFile    func(ctx context.Context, branch, filePath, content, commitMessage string) error
+	MockListBranches          func(ctx context.Context) ([]*gitlab.Branch, error)
+	MockGetBranchHeadCommitID func(ctx context.Context, branch string) (string, error)
+	MockCreateBranch          func(ctx context.Context, branchName, ref string) error
+	MockUpdateBranchHead      func(ctx context.Context, branchName, commitSHA string) error
+	MockDeleteBranch          func(ctx context.Context, branchName string) error
+	MockListProtectedBranches func(ctx context.Context) ([]*gitlab.ProtectedBranch, error)
+	MockGetProtectedBranch    func(ctx context.Context, branch string) (*gitlab.ProtectedBranch, error)
+	MockProtectBranch         func(ctx context.Context, opt *gitlab.ProtectRepositoryBranchesOptions) error
+	MockUpdateProtectedBranch func(ctx context.Context, name string, opt *gitlab.UpdateProtectedBranchOptions) error
+	MockUnprotectBranch       func(ctx context.Context, name string) error
+	MockGetCommit             func(ctx context.Context, sha string) (*gitlab.Commit, error)
+	MockCreateCommit          func(ctx context.Context, opt *gitlab.CreateCommitOptions) (*gitlab.Commit, error)
+	MockListTags              func(ctx context.Context) ([]*gitlab.Tag, error)
+	MockCreateTag             func(ctx context.Context, tagName, ref string) error
+	MockDeleteTag             func(ctx context.Context, tagName string) error
+	MockListOpenMergeRequests func(ctx context.Context, sourceBranch, targetBranch string) ([]*gitlab.BasicMergeRequest, error)
+	MockCreateMergeRequest    func(ctx context.Context, opt *gitlab.CreateMergeRequestOptions) (*gitlab.MergeRequest, error)
+	MockUpdateMergeRequest    func(ctx context.Context, iid int64, opt *gitlab.UpdateMergeRequestOptions) (*gitlab.MergeRequest, error)
+}
+
+var _ API = (*MockClient)(nil)
+
+// NewMockClient создает MockClient, методы которого по умолчанию паникуют
 func NewMockClient() *MockClient {
 	return &MockClient{
-		MockGetProject: func(projectID interface{}) (*gitlab.Project, *gitlab.Response, error) {
-			panic("GetProject not mocked")
+		MockGetProject: func(context.Context) (*gitlab.Project, error) {
+			panic("GetProject не подменен")
 		},
-		MockGetFile: func(projectID int, filePath string, options *gitlab.GetFileOptions) (*gitlab.File, *gitlab.Response, error) {
-			panic("GetFile not mocked")
+		MockGetFileContent: func(context.Context, string, string) ([]byte, error) {
+			panic("GetFileContent не подменен")
 		},
-		MockCreateFile: func(projectID int, filePath string, opt *gitlab.CreateFileOptions) (*gitlab.File, *gitlab.Response, error) {
-			panic("CreateFile not mocked")
+		MockCreateOrUpdateFile: func(context.Context, string, string, string, string) error {
+			panic("CreateOrUpdateFile не подменен")
 		},
-		MockUpdateFile: func(projectID int, filePath string, opt *gitlab.UpdateFileOptions) (*gitlab.File, *gitlab.Response, error) {
-			panic("UpdateFile not mocked")
+		MockListBranches: func(context.Context) ([]*gitlab.Branch, error) {
+			panic("ListBranches не подменен")
 		},
-		MockDeleteFile: func(projectID int, filePath string, opt *gitlab.DeleteFileOptions) (*gitlab.Response, error) {
-			panic("DeleteFile not mocked")
+		MockGetBranchHeadCommitID: func(context.Context, string) (string, error) {
+			panic("GetBranchHeadCommitID не подменен")
 		},
-		MockListProjectBranches: func(projectID int, opt *gitlab.ListBranchesOptions) ([]*gitlab.Branch, *gitlab.Response, error) {
-			panic("ListProjectBranches not mocked")
+		MockCreateBranch: func(context.Context, string, string) error {
+			panic("CreateBranch не подменен")
 		},
-		MockGetBranch: func(projectID int, branch string) (*gitlab.Branch, *gitlab.Response, error) {
-			panic("GetBranch not mocked")
+		MockUpdateBranchHead: func(context.Context, string, string) error {
+			panic("UpdateBranchHead не подменен")
 		},
-		MockCreateBranch: func(projectID int, opt *gitlab.CreateBranchOptions) (*gitlab.Branch, *gitlab.Response, error) {
-			panic("CreateBranch not mocked")
+		MockDeleteBranch: func(context.Context, string) error {
+			panic("DeleteBranch не подменен")
 		},
-		MockDeleteBranch: func(projectID int, branch string) (*gitlab.Response, error) {
-			panic("DeleteBranch not mocked")
+		MockListProtectedBranches: func(context.Context) ([]*gitlab.ProtectedBranch, error) {
+			panic("ListProtectedBranches не подменен")
 		},
-		MockGetCommit: func(projectID int, sha string) (*gitlab.Commit, *gitlab.Response, error) {
-			panic("GetCommit not mocked")
+		MockGetProtectedBranch: func(context.Context, string) (*gitlab.ProtectedBranch, error) {
+			panic("GetProtectedBranch не подменен")
 		},
-		MockListProjectCommits: func(projectID int, opt *gitlab.ListCommitsOptions) ([]*gitlab.Commit, *gitlab.Response, error) {
-			panic("ListProjectCommits not mocked")
+		MockProtectBranch: func(context.Context, *gitlab.ProtectRepositoryBranchesOptions) error {
+			panic("ProtectBranch не подменен")
 		},
-		MockCreateCommit: func(projectID int, opt *gitlab.CreateCommitOptions) (*gitlab.Commit, *gitlab.Response, error) {
-			panic("CreateCommit not mocked")
+		MockUpdateProtectedBranch: func(context.Context, string, *gitlab.UpdateProtectedBranchOptions) error {
+			panic("UpdateProtectedBranch не подменен")
 		},
-		MockGetBranchHeadCommitID: func(branch string) (string, error) {
-			panic("GetBranchHeadCommitID not mocked")
+		MockUnprotectBranch: func(context.Context, string) error {
+			panic("UnprotectBranch не подменен")
+		},
+		MockGetCommit: func(context.Context, string) (*gitlab.Commit, error) {
+			panic("GetCommit не подменен")
+		},
+		MockCreateCommit: func(context.Context, *gitlab.CreateCommitOptions) (*gitlab.Commit, error) {
+			panic("CreateCommit не подменен")
+		},
+		MockListTags: func(context.Context) ([]*gitlab.Tag, error) {
+			panic("ListTags не подменен")
+		},
+		MockCreateTag: func(context.Context, string, string) error {
+			panic("CreateTag не подменен")
+		},
+		MockDeleteTag: func(context.Context, string) error {
+			panic("DeleteTag не подменен")
+		},
+		MockListOpenMergeRequests: func(context.Context, string, string) ([]*gitlab.BasicMergeRequest, error) {
+			panic("ListOpenMergeRequests не подменен")
+		},
+		MockCreateMergeRequest: func(context.Context, *gitlab.CreateMergeRequestOptions) (*gitlab.MergeRequest, error) {
+			panic("CreateMergeRequest не подменен")
+		},
+		MockUpdateMergeRequest: func(context.Context, int64, *gitlab.UpdateMergeRequestOptions) (*gitlab.MergeRequest, error) {
+			panic("UpdateMergeRequest не подменен")
 		},
 	}
 }
 
+func (m *MockClient) GetProject(ctx context.Context) (*gitlab.Project, error) {
+	return m.MockGetProject(ctx)
+}
+
+func (m *MockClient) GetFileContent(ctx context.Context, branch, filePath string) ([]byte, error) {
+	return m.MockGetFileContent(ctx, branch, filePath)
+}
+
+func (m *MockClient) CreateOrUpdateFile(ctx context.Context, branch, filePath, content, commitMessage string) error {
+	return m.MockCreateOrUpdateFile(ctx, branch, filePath, content, commitMessage)
+}
+
+func (m *MockClient) ListBranches(ctx context.Context) ([]*gitlab.Branch, error) {
+	return m.MockListBranches(ctx)
+}
+
+func (m *MockClient) GetBranchHeadCommitID(ctx context.Context, branch string) (string, error) {
+	return m.MockGetBranchHeadCommitID(ctx, branch)
+}
+
+func (m *MockClient) CreateBranch(ctx context.Context, branchName, ref string) error {
+	return m.MockCreateBranch(ctx, branchName, ref)
+}
+
+func (m *MockClient) UpdateBranchHead(ctx context.Context, branchName, commitSHA string) error {
+	return m.MockUpdateBranchHead(ctx, branchName, commitSHA)
+}
 
-func (m *MockClient) GetProject(projectID interface{}) (*gitlab.Project, *gitlab.Response, error) {
-	return m.MockGetProject(projectID)
+func (m *MockClient) DeleteBranch(ctx context.Context, branchName string) error {
+	return m.MockDeleteBranch(ctx, branchName)
 }
 
-func (m *MockClient) GetFile(projectID int, filePath string, options *gitlab.GetFileOptions) (*gitlab.File, *gitlab.Response, error) {
-	return m.MockGetFile(projectID, filePath, options)
+func (m *MockClient) ListProtectedBranches(ctx context.Context) ([]*gitlab.ProtectedBranch, error) {
+	return m.MockListProtectedBranches(ctx)
 }
 
-func (m *MockClient) CreateFile(projectID int, filePath string, opt *gitlab.CreateFileOptions) (*gitlab.File, *gitlab.Response, error) {
-	return m.MockCreateFile(projectID, filePath, opt)
+func (m *MockClient) GetProtectedBranch(ctx context.Context, branch string) (*gitlab.ProtectedBranch, error) {
+	return m.MockGetProtectedBranch(ctx, branch)
 }
 
-func (m *MockClient) UpdateFile(projectID int, filePath string, opt *gitlab.UpdateFileOptions) (*gitlab.File, *gitlab.Response, error) {
-	return m.MockUpdateFile(projectID, filePath, opt)
+func (m *MockClient) ProtectBranch(ctx context.Context, opt *gitlab.ProtectRepositoryBranchesOptions) error {
+	return m.MockProtectBranch(ctx, opt)
 }
 
-func (m *MockClient) DeleteFile(projectID int, filePath string, opt *gitlab.DeleteFileOptions) (*gitlab.Response, error) {
-	return m.MockDeleteFile(projectID, filePath, opt)
+func (m *MockClient) UpdateProtectedBranch(ctx context.Context, name string, opt *gitlab.UpdateProtectedBranchOptions) error {
+	return m.MockUpdateProtectedBranch(ctx, name, opt)
 }
 
-func (m *MockClient) ListProjectBranches(projectID int, opt *gitlab.ListBranchesOptions) ([]*gitlab.Branch, *gitlab.Response, error) {
-	return m.MockListProjectBranches(projectID, opt)
+func (m *MockClient) UnprotectBranch(ctx context.Context, name string) error {
+	return m.MockUnprotectBranch(ctx, name)
 }
 
-func (m *MockClient) GetBranch(projectID int, branch string) (*gitlab.Branch, *gitlab.Response, error) {
-	return m.MockGetBranch(projectID, branch)
+func (m *MockClient) GetCommit(ctx context.Context, sha string) (*gitlab.Commit, error) {
+	return m.MockGetCommit(ctx, sha)
 }
 
-func (m *MockClient) CreateBranch(projectID int, opt *gitlab.CreateBranchOptions) (*gitlab.Branch, *gitlab.Response, error) {
-	return m.MockCreateBranch(projectID, opt)
+func (m *MockClient) CreateCommit(ctx context.Context, opt *gitlab.CreateCommitOptions) (*gitlab.Commit, error) {
+	return m.MockCreateCommit(ctx, opt)
 }
 
-func (m *MockClient) DeleteBranch(projectID int, branch string) (*gitlab.Response, error) {
-	return m.MockDeleteBranch(projectID, branch)
+func (m *MockClient) ListTags(ctx context.Context) ([]*gitlab.Tag, error) {
+	return m.MockListTags(ctx)
 }
 
-func (m *MockClient) GetCommit(projectID int, sha string) (*gitlab.Commit, *gitlab.Response, error) {
-	return m.MockGetCommit(projectID, sha)
+func (m *MockClient) CreateTag(ctx context.Context, tagName, ref string) error {
+	return m.MockCreateTag(ctx, tagName, ref)
 }
 
-func (m *MockClient) ListProjectCommits(projectID int, opt *gitlab.ListCommitsOptions) ([]*gitlab.Commit, *gitlab.Response, error) {
-	return m.MockListProjectCommits(projectID, opt)
+func (m *MockClient) DeleteTag(ctx context.Context, tagName string) error {
+	return m.MockDeleteTag(ctx, tagName)
 }
 
-func (m *MockClient) CreateCommit(projectID int, opt *gitlab.CreateCommitOptions) (*gitlab.Commit, *gitlab.Response, error) {
-	return m.MockCreateCommit(projectID, opt)
+func (m *MockClient) ListOpenMergeRequests(ctx context.Context, sourceBranch, targetBranch string) ([]*gitlab.BasicMergeRequest, error) {
+	return m.MockListOpenMergeRequests(ctx, sourceBranch, targetBranch)
 }
 
-func (m *MockClient) GetBranchHeadCommitID(branch string) (string, error) {
-	return m.MockGetBranchHeadCommitID(branch)
+func (m *MockClient) CreateMergeRequest(ctx context.Context, opt *gitlab.CreateMergeRequestOptions) (*gitlab.MergeRequest, error) {
+	return m.MockCreateMergeRequest(ctx, opt)
 }
 
-// Helper function to create a gitlab.Response
+func (m *MockClient) UpdateMergeRequest(ctx context.Context, iid int64, opt *gitlab.UpdateMergeRequestOptions) (*gitlab.MergeRequest, error) {
+	return m.MockUpdateMergeRequest(ctx, iid, opt)
+}
+
+// NewGitlabResponse создает gitlab.Response с кодом statusCode
 func NewGitlabResponse(statusCode int) *gitlab.Response {
 	return &gitlab.Response{
 		Response: &http.Response{
@@ -131,12 +197,12 @@ func NewGitlabResponse(statusCode int) *gitlab.Response {
 	}
 }
 
-// Helper function to create a gitlab.Project
-func NewGitlabProject(id int, name string, defaultBranch string) *gitlab.Project {
+// NewGitlabProject создает gitlab.Project в группе group
+func NewGitlabProject(id int64, name string, defaultBranch string) *gitlab.Project {
 	return &gitlab.Project{
-		ID:            id,
-		Name:          name,
-		DefaultBranch: defaultBranch,
+		ID:                id,
+		Name:              name,
+		DefaultBranch:     defaultBranch,
 		PathWithNamespace: fmt.Sprintf("group/%s", name),
 	}
-}
\ No newline at end of file
+}
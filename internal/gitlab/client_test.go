@@ -0,0 +1,238 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestCreateOrUpdateFile(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.addBranch("main", "aaa111", map[string]string{"README.md": "old"})
+	ctx := context.Background()
+
+	// Существующий файл обновляется
+	if err := client.CreateOrUpdateFile(ctx, "main", "README.md", "new", "Обновить README"); err != nil {
+		t.Fatalf("Не удалось обновить файл: %v", err)
+	}
+	if content, _ := fake.file("main", "README.md"); content != "new" {
+		t.Errorf("Ожидалось содержимое new, получено %q", content)
+	}
+
+	// Отсутствующий файл во вложенном каталоге создается
+	if err := client.CreateOrUpdateFile(ctx, "main", "docs/guide.md", "guide", "Добавить руководство"); err != nil {
+		t.Fatalf("Не удалось создать файл: %v", err)
+	}
+	if content, ok := fake.file("main", "docs/guide.md"); !ok || content != "guide" {
+		t.Errorf("Ожидался созданный файл с содержимым guide, получено %q (есть: %v)", content, ok)
+	}
+
+	want := []string{
+		"HEAD /api/v4/projects/42/repository/files/README.md",
+		"PUT /api/v4/projects/42/repository/files/README.md",
+		"HEAD /api/v4/projects/42/repository/files/docs/guide.md",
+		"POST /api/v4/projects/42/repository/files/docs/guide.md",
+	}
+	if got := fake.requestLog(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Ожидались запросы:\n%s\nвыполнены:\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestCreateOrUpdateFileErrors(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	ctx := context.Background()
+
+	// Ветки нет: проверка существования отвечает 404, создание отклоняется
+	err := client.CreateOrUpdateFile(ctx, "missing", "README.md", "content", "Добавить README")
+	if err == nil || !strings.Contains(err.Error(), "не удалось создать файл README.md") {
+		t.Errorf("Ожидалась ошибка создания файла, получено: %v", err)
+	}
+
+	// Сервер недоступен: ошибка проверки не должна приводить к попытке обновления
+	fake.server.Close()
+	before := len(fake.requestLog())
+	err = client.CreateOrUpdateFile(ctx, "main", "README.md", "content", "Добавить README")
+	if err == nil || !strings.Contains(err.Error(), "ошибка при проверке существования файла") {
+		t.Errorf("Ожидалась ошибка проверки файла, получено: %v", err)
+	}
+	if after := len(fake.requestLog()); after != before {
+		t.Errorf("После ошибки проверки выполнено запросов: %d", after-before)
+	}
+}
+
+func TestListBranches(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.perPage = 2
+	for i := range 5 {
+		fake.addBranch(fmt.Sprintf("branch-%d", i), fmt.Sprintf("sha%d", i), nil)
+	}
+
+	branches, err := client.ListBranches(context.Background())
+	if err != nil {
+		t.Fatalf("Не удалось получить ветки: %v", err)
+	}
+	if len(branches) != 5 {
+		t.Fatalf("Ожидалось 5 веток со всех страниц, получено %d", len(branches))
+	}
+	for i, branch := range branches {
+		if branch.Name != fmt.Sprintf("branch-%d", i) || branch.Commit.ID != fmt.Sprintf("sha%d", i) {
+			t.Errorf("Ветка %d: %s -> %s", i, branch.Name, branch.Commit.ID)
+		}
+	}
+
+	pages := 0
+	for _, request := range fake.requestLog() {
+		if strings.HasPrefix(request, "GET /api/v4/projects/42/repository/branches") {
+			pages++
+		}
+	}
+	if pages != 3 {
+		t.Errorf("Ожидалось 3 запроса страниц, выполнено %d", pages)
+	}
+}
+
+func TestGetBranchHeadCommitID(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.addBranch("feature/login", "4b825dc642cb6eb9a060e54bf8d69288fbee4904", nil)
+	ctx := context.Background()
+
+	sha, err := client.GetBranchHeadCommitID(ctx, "feature/login")
+	if err != nil {
+		t.Fatalf("Не удалось получить коммит ветки: %v", err)
+	}
+	if sha != "4b825dc642cb6eb9a060e54bf8d69288fbee4904" {
+		t.Errorf("Получен коммит %s", sha)
+	}
+
+	_, err = client.GetBranchHeadCommitID(ctx, "missing")
+	if err == nil || !strings.Contains(err.Error(), "не удалось получить ветку missing") || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Ожидалась ошибка 404 для отсутствующей ветки, получено: %v", err)
+	}
+}
+
+func TestMergeRequests(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.addBranch("main", "aaa111", nil)
+	fake.addBranch("sync/main", "bbb222", nil)
+	ctx := context.Background()
+
+	open, err := client.ListOpenMergeRequests(ctx, "sync/main", "main")
+	if err != nil || len(open) != 0 {
+		t.Fatalf("Не ожидалось открытых merge request: %v, %v", open, err)
+	}
+
+	created, err := client.CreateMergeRequest(ctx, &gitlab.CreateMergeRequestOptions{
+		SourceBranch: gitlab.Ptr("sync/main"),
+		TargetBranch: gitlab.Ptr("main"),
+		Title:        gitlab.Ptr("Синхронизация main"),
+		Description:  gitlab.Ptr("Описание"),
+	})
+	if err != nil {
+		t.Fatalf("Не удалось создать merge request: %v", err)
+	}
+	if created.IID != 1 || created.WebURL == "" {
+		t.Errorf("Неверный merge request: %+v", created.BasicMergeRequest)
+	}
+
+	updated, err := client.UpdateMergeRequest(ctx, created.IID, &gitlab.UpdateMergeRequestOptions{Description: gitlab.Ptr("Новое описание")})
+	if err != nil {
+		t.Fatalf("Не удалось изменить merge request: %v", err)
+	}
+	if updated.Title != "Синхронизация main" || updated.Description != "Новое описание" {
+		t.Errorf("Неверный merge request после изменения: %q, %q", updated.Title, updated.Description)
+	}
+
+	open, err = client.ListOpenMergeRequests(ctx, "sync/main", "main")
+	if err != nil || len(open) != 1 || open[0].IID != created.IID {
+		t.Fatalf("Ожидался открытый merge request !%d: %v, %v", created.IID, open, err)
+	}
+	if open, _ := client.ListOpenMergeRequests(ctx, "sync/main", "develop"); len(open) != 0 {
+		t.Errorf("Не ожидалось merge request в develop: %v", open)
+	}
+
+	if _, err := client.UpdateMergeRequest(ctx, 7, &gitlab.UpdateMergeRequestOptions{}); err == nil || !strings.Contains(err.Error(), "не удалось изменить merge request !7") {
+		t.Errorf("Ожидалась ошибка изменения отсутствующего merge request, получено: %v", err)
+	}
+}
+
+func TestMockClient(t *testing.T) {
+	mock := NewMockClient()
+	mock.MockGetBranchHeadCommitID = func(_ context.Context, branch string) (string, error) {
+		return "sha-" + branch, nil
+	}
+
+	var api API = mock
+	if sha, err := api.GetBranchHeadCommitID(context.Background(), "main"); err != nil || sha != "sha-main" {
+		t.Errorf("Получено %q, %v", sha, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Не подмененный метод должен паниковать")
+		}
+	}()
+	api.ListBranches(context.Background())
+}
+
+func TestGetProjectIDByPath(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = append(requested, r.URL.EscapedPath())
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, `{"id": 7, "path_with_namespace": "a/b/c/project"}`)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		repoURL  string
+		instance string
+		wantPath string
+	}{
+		{
+			name:     "NestedGroups",
+			repoURL:  "https://gitlab.example.com/a/b/c/project.git",
+			instance: server.URL,
+			wantPath: "/api/v4/projects/a%2Fb%2Fc%2Fproject",
+		},
+		{
+			name:     "SCP",
+			repoURL:  "git@gitlab.example.com:a/b/c/project.git",
+			instance: server.URL,
+			wantPath: "/api/v4/projects/a%2Fb%2Fc%2Fproject",
+		},
+		{
+			name:     "InstanceSubpath",
+			repoURL:  server.URL + "/gitlab/a/b/c/project.git",
+			instance: server.URL + "/gitlab",
+			wantPath: "/gitlab/api/v4/projects/a%2Fb%2Fc%2Fproject",
+		},
+		{
+			// Адрес экземпляра берется из адреса репозитория
+			name:     "WithoutInstance",
+			repoURL:  server.URL + "/a/b/c/project.git",
+			wantPath: "/api/v4/projects/a%2Fb%2Fc%2Fproject",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requested = nil
+			id, err := GetProjectIDByPath(context.Background(), tt.repoURL, tt.instance, "token")
+			if err != nil {
+				t.Fatalf("Не удалось получить ID проекта: %v", err)
+			}
+			if id != 7 {
+				t.Errorf("Ожидался ID 7, получен %d", id)
+			}
+			if len(requested) != 1 || requested[0] != tt.wantPath {
+				t.Errorf("Ожидался запрос %s, выполнены %v", tt.wantPath, requested)
+			}
+		})
+	}
+}
@@ -0,0 +1,713 @@
+package gitlab
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// fakeProjectID ID проекта, который обслуживает fakeGitlab
+const fakeProjectID = 42
+
+// fakeFile файл в дереве коммита поддельного GitLab
+type fakeFile struct {
+	Content string
+	Mode    string
+}
+
+// fakeCommit коммит поддельного GitLab с полным деревом файлов
+type fakeCommit struct {
+	commit *gitlab.Commit
+	files  map[string]fakeFile
+}
+
+// fakeGitlab поддельный GitLab с одним проектом: хранит коммиты, ветки, защищенные ветки,
+// теги и merge request в памяти и отвечает на запросы REST API v4, которые выполняет Client
+type fakeGitlab struct {
+	t      *testing.T
+	server *httptest.Server
+
+	mu        sync.Mutex
+	commits   map[string]*fakeCommit
+	branches  map[string]string // ветка -> SHA последнего коммита
+	protected map[string]*gitlab.ProtectedBranch
+	tags      map[string]string             // тег -> SHA
+	merges    []*gitlab.MergeRequest        // merge request в порядке создания, iid - номер в списке
+	created   []*gitlab.CreateCommitOptions // запросы на создание коммитов
+	requests  []string                      // "METHOD путь" выполненных запросов, путь без экранирования
+	perPage   int                           // наибольший размер страницы, который отдает сервер
+	// beforeRequest вызывается перед обработкой каждого запроса, например чтобы изменить ветку между запросами
+	beforeRequest func(r *http.Request)
+}
+
+// newFakeGitlab запускает поддельный GitLab и возвращает клиент для его проекта
+func newFakeGitlab(t *testing.T) (*fakeGitlab, *Client) {
+	t.Helper()
+	f := &fakeGitlab{
+		t:         t,
+		commits:   map[string]*fakeCommit{},
+		branches:  map[string]string{},
+		protected: map[string]*gitlab.ProtectedBranch{},
+		tags:      map[string]string{},
+		perPage:   perPage,
+	}
+
+	project := "/api/v4/projects/" + strconv.Itoa(fakeProjectID)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+project, f.getProject)
+	mux.HandleFunc("HEAD "+project+"/repository/files/{path}", f.fileMetaData)
+	mux.HandleFunc("GET "+project+"/repository/files/{path}/raw", f.rawFile)
+	mux.HandleFunc("POST "+project+"/repository/files/{path}", f.writeFile)
+	mux.HandleFunc("PUT "+project+"/repository/files/{path}", f.writeFile)
+	mux.HandleFunc("GET "+project+"/repository/branches", f.listBranches)
+	mux.HandleFunc("GET "+project+"/repository/branches/{branch}", f.getBranch)
+	mux.HandleFunc("POST "+project+"/repository/branches", f.createBranch)
+	mux.HandleFunc("DELETE "+project+"/repository/branches/{branch}", f.deleteBranch)
+	mux.HandleFunc("GET "+project+"/protected_branches", f.listProtectedBranches)
+	mux.HandleFunc("GET "+project+"/protected_branches/{branch}", f.getProtectedBranch)
+	mux.HandleFunc("POST "+project+"/protected_branches", f.protectBranch)
+	mux.HandleFunc("PATCH "+project+"/protected_branches/{branch}", f.updateProtectedBranch)
+	mux.HandleFunc("DELETE "+project+"/protected_branches/{branch}", f.unprotectBranch)
+	mux.HandleFunc("GET "+project+"/repository/tags", f.listTags)
+	mux.HandleFunc("GET "+project+"/repository/tree", f.listTree)
+	mux.HandleFunc("GET "+project+"/repository/blobs/{sha}/raw", f.rawBlob)
+	mux.HandleFunc("GET "+project+"/repository/commits/{sha}", f.getCommit)
+	mux.HandleFunc("POST "+project+"/repository/commits", f.createCommit)
+	mux.HandleFunc("GET "+project+"/merge_requests", f.listMergeRequests)
+	mux.HandleFunc("POST "+project+"/merge_requests", f.createMergeRequest)
+	mux.HandleFunc("PUT "+project+"/merge_requests/{iid}", f.updateMergeRequest)
+
+	f.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		f.requests = append(f.requests, r.Method+" "+r.URL.Path)
+		before := f.beforeRequest
+		f.mu.Unlock()
+		if before != nil {
+			before(r)
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(f.server.Close)
+
+	gitClient, err := gitlab.NewClient("token", gitlab.WithBaseURL(f.server.URL), gitlab.WithoutRetries())
+	if err != nil {
+		t.Fatalf("Не удалось создать клиент: %v", err)
+	}
+	return f, &Client{Client: gitClient, ProjectID: fakeProjectID}
+}
+
+// addCommit добавляет коммит sha с файлами files, содержимое которых задано строкой
+func (f *fakeGitlab) addCommit(sha string, files map[string]string) {
+	tree := make(map[string]fakeFile, len(files))
+	for path, content := range files {
+		tree[path] = fakeFile{Content: content, Mode: modeFile}
+	}
+	f.addTree(sha, tree)
+}
+
+// addTree добавляет коммит sha с файлами files
+func (f *fakeGitlab) addTree(sha string, files map[string]fakeFile) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commits[sha] = &fakeCommit{
+		commit: &gitlab.Commit{
+			ID:          sha,
+			Message:     "Коммит " + sha + "\n",
+			AuthorName:  "Автор " + sha,
+			AuthorEmail: sha + "@example.com",
+		},
+		files: files,
+	}
+}
+
+// addBranch создает ветку на новом коммите sha с файлами files
+func (f *fakeGitlab) addBranch(name, sha string, files map[string]string) {
+	f.addCommit(sha, files)
+	f.setBranch(name, sha)
+}
+
+// addBranchTree добавляет коммит sha с файлами files и указывает на него ветку name
+func (f *fakeGitlab) addBranchTree(name, sha string, files map[string]fakeFile) {
+	f.addTree(sha, files)
+	f.setBranch(name, sha)
+}
+
+// setBranch указывает веткой name на коммит sha
+func (f *fakeGitlab) setBranch(name, sha string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.branches[name] = sha
+}
+
+// tree возвращает файлы последнего коммита ветки
+func (f *fakeGitlab) tree(branch string) map[string]fakeFile {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if sha, ok := f.branches[branch]; ok {
+		return f.commits[sha].files
+	}
+	return nil
+}
+
+// file возвращает содержимое файла ветки
+func (f *fakeGitlab) file(branch, path string) (string, bool) {
+	file, ok := f.tree(branch)[path]
+	return file.Content, ok
+}
+
+// requestLog возвращает выполненные запросы
+func (f *fakeGitlab) requestLog() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.requests...)
+}
+
+// createdCommits возвращает запросы на создание коммитов
+func (f *fakeGitlab) createdCommits() []*gitlab.CreateCommitOptions {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*gitlab.CreateCommitOptions(nil), f.created...)
+}
+
+// commit создает на ветке branch коммит с деревом files и возвращает его SHA; вызывается под f.mu
+func (f *fakeGitlab) commit(branch string, files map[string]fakeFile, message string) *gitlab.Commit {
+	sha := fmt.Sprintf("c0ffee%034d", len(f.commits))
+	commit := &gitlab.Commit{ID: sha, Message: message, ParentIDs: []string{f.branches[branch]}}
+	f.commits[sha] = &fakeCommit{commit: commit, files: files}
+	f.branches[branch] = sha
+	return commit
+}
+
+// blobID возвращает SHA объекта blob git с содержимым content
+func blobID(content string) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("blob %d\x00%s", len(content), content)))
+	return hex.EncodeToString(sum[:])
+}
+
+func (f *fakeGitlab) writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		f.t.Errorf("Не удалось записать ответ: %v", err)
+	}
+}
+
+func (f *fakeGitlab) notFound(w http.ResponseWriter, what string) {
+	f.writeJSON(w, http.StatusNotFound, map[string]string{"message": "404 " + what + " Not Found"})
+}
+
+func (f *fakeGitlab) decode(r *http.Request, v any) {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		f.t.Errorf("Не удалось разобрать запрос %s %s: %v", r.Method, r.URL.Path, err)
+	}
+}
+
+// paginate отдает страницу page списка items и выставляет заголовки постраничной навигации GitLab
+func paginate[T any](f *fakeGitlab, w http.ResponseWriter, r *http.Request, items []T) {
+	size, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if size <= 0 || size > f.perPage {
+		size = f.perPage
+	}
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page <= 0 {
+		page = 1
+	}
+	start := min((page-1)*size, len(items))
+	end := min(start+size, len(items))
+	totalPages := max((len(items)+size-1)/size, 1)
+
+	w.Header().Set("X-Page", strconv.Itoa(page))
+	w.Header().Set("X-Per-Page", strconv.Itoa(size))
+	w.Header().Set("X-Total", strconv.Itoa(len(items)))
+	w.Header().Set("X-Total-Pages", strconv.Itoa(totalPages))
+	if page < totalPages {
+		w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+	}
+	f.writeJSON(w, http.StatusOK, items[start:end])
+}
+
+func (f *fakeGitlab) getProject(w http.ResponseWriter, _ *http.Request) {
+	f.writeJSON(w, http.StatusOK, NewGitlabProject(fakeProjectID, "project", "main"))
+}
+
+func (f *fakeGitlab) fileMetaData(w http.ResponseWriter, r *http.Request) {
+	if _, ok := f.file(r.URL.Query().Get("ref"), r.PathValue("path")); !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("X-Gitlab-File-Path", r.PathValue("path"))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (f *fakeGitlab) rawFile(w http.ResponseWriter, r *http.Request) {
+	content, ok := f.file(r.URL.Query().Get("ref"), r.PathValue("path"))
+	if !ok {
+		f.notFound(w, "File")
+		return
+	}
+	w.Write([]byte(content))
+}
+
+func (f *fakeGitlab) writeFile(w http.ResponseWriter, r *http.Request) {
+	var opt struct {
+		Branch        string `json:"branch"`
+		Content       string `json:"content"`
+		CommitMessage string `json:"commit_message"`
+	}
+	f.decode(r, &opt)
+	path := r.PathValue("path")
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sha, ok := f.branches[opt.Branch]
+	if !ok {
+		f.writeJSON(w, http.StatusBadRequest, map[string]string{"message": "You can only create or edit files when you are on a branch"})
+		return
+	}
+	files := maps.Clone(f.commits[sha].files)
+	if _, exists := files[path]; exists != (r.Method == http.MethodPut) {
+		f.writeJSON(w, http.StatusBadRequest, map[string]string{"message": "A file with this name already exists"})
+		return
+	}
+	files[path] = fakeFile{Content: opt.Content, Mode: modeFile}
+	f.commit(opt.Branch, files, opt.CommitMessage)
+	status := http.StatusOK
+	if r.Method == http.MethodPost {
+		status = http.StatusCreated
+	}
+	f.writeJSON(w, status, gitlab.FileInfo{FilePath: path, Branch: opt.Branch})
+}
+
+// branch возвращает ветку в виде ответа API; вызывается под f.mu
+func (f *fakeGitlab) branch(name string) *gitlab.Branch {
+	_, protected := f.protected[name]
+	return &gitlab.Branch{Name: name, Protected: protected, Commit: &gitlab.Commit{ID: f.branches[name]}}
+}
+
+func (f *fakeGitlab) listBranches(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	names := make([]string, 0, len(f.branches))
+	for name := range f.branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	branches := make([]*gitlab.Branch, 0, len(names))
+	for _, name := range names {
+		branches = append(branches, f.branch(name))
+	}
+	f.mu.Unlock()
+	paginate(f, w, r, branches)
+}
+
+func (f *fakeGitlab) getBranch(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.branches[r.PathValue("branch")]; !ok {
+		f.notFound(w, "Branch")
+		return
+	}
+	f.writeJSON(w, http.StatusOK, f.branch(r.PathValue("branch")))
+}
+
+func (f *fakeGitlab) createBranch(w http.ResponseWriter, r *http.Request) {
+	var opt struct {
+		Branch string `json:"branch"`
+		Ref    string `json:"ref"`
+	}
+	f.decode(r, &opt)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	sha, ok := f.branches[opt.Ref]
+	if !ok {
+		f.writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Invalid reference name"})
+		return
+	}
+	f.branches[opt.Branch] = sha
+	f.writeJSON(w, http.StatusCreated, f.branch(opt.Branch))
+}
+
+func (f *fakeGitlab) deleteBranch(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := r.PathValue("branch")
+	if _, ok := f.branches[name]; !ok {
+		f.notFound(w, "Branch")
+		return
+	}
+	delete(f.branches, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeGitlab) listProtectedBranches(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	protected := make([]*gitlab.ProtectedBranch, 0, len(f.protected))
+	for _, p := range f.protected {
+		protected = append(protected, p)
+	}
+	f.mu.Unlock()
+	sort.Slice(protected, func(i, j int) bool { return protected[i].Name < protected[j].Name })
+	paginate(f, w, r, protected)
+}
+
+func (f *fakeGitlab) getProtectedBranch(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.protected[r.PathValue("branch")]
+	if !ok {
+		f.notFound(w, "Protected Branch")
+		return
+	}
+	f.writeJSON(w, http.StatusOK, p)
+}
+
+func (f *fakeGitlab) protectBranch(w http.ResponseWriter, r *http.Request) {
+	var opt gitlab.ProtectRepositoryBranchesOptions
+	f.decode(r, &opt)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if opt.Name == nil {
+		f.writeJSON(w, http.StatusBadRequest, map[string]string{"message": "name is missing"})
+		return
+	}
+	if _, ok := f.protected[*opt.Name]; ok {
+		f.writeJSON(w, http.StatusConflict, map[string]string{"message": "Protected branch '" + *opt.Name + "' already exists"})
+		return
+	}
+	p := &gitlab.ProtectedBranch{
+		ID:                    int64(len(f.requests)),
+		Name:                  *opt.Name,
+		PushAccessLevels:      accessLevels(opt.PushAccessLevel, opt.AllowedToPush),
+		MergeAccessLevels:     accessLevels(opt.MergeAccessLevel, opt.AllowedToMerge),
+		UnprotectAccessLevels: accessLevels(opt.UnprotectAccessLevel, opt.AllowedToUnprotect),
+	}
+	if opt.AllowForcePush != nil {
+		p.AllowForcePush = *opt.AllowForcePush
+	}
+	if opt.CodeOwnerApprovalRequired != nil {
+		p.CodeOwnerApprovalRequired = *opt.CodeOwnerApprovalRequired
+	}
+	f.protected[p.Name] = p
+	f.writeJSON(w, http.StatusCreated, p)
+}
+
+// accessLevels переводит параметры создания правила в уровни доступа, как их возвращает GitLab
+func accessLevels(role *gitlab.AccessLevelValue, allowed *[]*gitlab.BranchPermissionOptions) []*gitlab.BranchAccessDescription {
+	var levels []*gitlab.BranchAccessDescription
+	if role != nil {
+		levels = append(levels, &gitlab.BranchAccessDescription{AccessLevel: *role})
+	}
+	if allowed != nil {
+		for _, option := range *allowed {
+			level := &gitlab.BranchAccessDescription{}
+			if option.AccessLevel != nil {
+				level.AccessLevel = *option.AccessLevel
+			}
+			if option.UserID != nil {
+				level.UserID = *option.UserID
+			}
+			if option.GroupID != nil {
+				level.GroupID = *option.GroupID
+			}
+			if option.DeployKeyID != nil {
+				level.DeployKeyID = *option.DeployKeyID
+			}
+			levels = append(levels, level)
+		}
+	}
+	return levels
+}
+
+func (f *fakeGitlab) updateProtectedBranch(w http.ResponseWriter, r *http.Request) {
+	var opt gitlab.UpdateProtectedBranchOptions
+	f.decode(r, &opt)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	p, ok := f.protected[r.PathValue("branch")]
+	if !ok {
+		f.notFound(w, "Protected Branch")
+		return
+	}
+	if opt.AllowForcePush != nil {
+		p.AllowForcePush = *opt.AllowForcePush
+	}
+	if opt.CodeOwnerApprovalRequired != nil {
+		p.CodeOwnerApprovalRequired = *opt.CodeOwnerApprovalRequired
+	}
+	f.writeJSON(w, http.StatusOK, p)
+}
+
+func (f *fakeGitlab) unprotectBranch(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	name := r.PathValue("branch")
+	if _, ok := f.protected[name]; !ok {
+		f.notFound(w, "Protected Branch")
+		return
+	}
+	delete(f.protected, name)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeGitlab) listTags(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	tags := make([]*gitlab.Tag, 0, len(f.tags))
+	for name, sha := range f.tags {
+		tags = append(tags, &gitlab.Tag{Name: name, Commit: &gitlab.Commit{ID: sha}})
+	}
+	f.mu.Unlock()
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+	paginate(f, w, r, tags)
+}
+
+// resolve возвращает коммит по имени ветки или SHA; вызывается под f.mu
+func (f *fakeGitlab) resolve(ref string) (*fakeCommit, bool) {
+	if sha, ok := f.branches[ref]; ok {
+		ref = sha
+	}
+	commit, ok := f.commits[ref]
+	return commit, ok
+}
+
+func (f *fakeGitlab) listTree(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	commit, ok := f.resolve(r.URL.Query().Get("ref"))
+	f.mu.Unlock()
+	if !ok {
+		f.notFound(w, "Tree")
+		return
+	}
+	if r.URL.Query().Get("recursive") != "true" {
+		f.t.Errorf("Ожидался рекурсивный обход дерева: %s", r.URL)
+	}
+
+	// Рекурсивный список GitLab содержит и файлы, и все каталоги на пути к ним
+	nodes := map[string]*gitlab.TreeNode{}
+	for filePath, file := range commit.files {
+		nodeType := "blob"
+		if file.Mode == modeGitlink {
+			nodeType = "commit"
+		}
+		nodes[filePath] = &gitlab.TreeNode{ID: blobID(file.Content), Name: path.Base(filePath), Type: nodeType, Path: filePath, Mode: file.Mode}
+		for dir := path.Dir(filePath); dir != "."; dir = path.Dir(dir) {
+			nodes[dir] = &gitlab.TreeNode{ID: blobID(dir), Name: path.Base(dir), Type: "tree", Path: dir, Mode: "040000"}
+		}
+	}
+	tree := make([]*gitlab.TreeNode, 0, len(nodes))
+	for _, node := range nodes {
+		tree = append(tree, node)
+	}
+	sort.Slice(tree, func(i, j int) bool { return tree[i].Path < tree[j].Path })
+	paginate(f, w, r, tree)
+}
+
+func (f *fakeGitlab) rawBlob(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, commit := range f.commits {
+		for _, file := range commit.files {
+			if blobID(file.Content) == r.PathValue("sha") {
+				w.Write([]byte(file.Content))
+				return
+			}
+		}
+	}
+	f.notFound(w, "Blob")
+}
+
+func (f *fakeGitlab) getCommit(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	commit, ok := f.resolve(r.PathValue("sha"))
+	if !ok {
+		f.notFound(w, "Commit")
+		return
+	}
+	f.writeJSON(w, http.StatusOK, commit.commit)
+}
+
+// createCommit применяет действия коммита к дереву ветки так же, как GitLab: действия выполняются
+// по порядку, и любое недопустимое действие отклоняет весь коммит
+func (f *fakeGitlab) createCommit(w http.ResponseWriter, r *http.Request) {
+	var opt gitlab.CreateCommitOptions
+	f.decode(r, &opt)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.created = append(f.created, &opt)
+	branch := *opt.Branch
+	head, ok := f.branches[branch]
+	if !ok {
+		f.writeJSON(w, http.StatusBadRequest, map[string]string{"message": "You can only create or edit files when you are on a branch"})
+		return
+	}
+
+	files := maps.Clone(f.commits[head].files)
+	for _, action := range opt.Actions {
+		if err := applyAction(files, action, head); err != nil {
+			f.writeJSON(w, http.StatusBadRequest, map[string]string{"message": err.Error()})
+			return
+		}
+	}
+	f.writeJSON(w, http.StatusCreated, f.commit(branch, files, *opt.CommitMessage))
+}
+
+// applyAction применяет действие коммита к файлам дерева
+func applyAction(files map[string]fakeFile, action *gitlab.CommitActionOptions, head string) error {
+	filePath := *action.FilePath
+	file, exists := files[filePath]
+	if action.LastCommitID != nil && *action.LastCommitID != head {
+		return fmt.Errorf("You are attempting to update a file that has changed since you started editing it.")
+	}
+
+	content := ""
+	if action.Content != nil {
+		content = *action.Content
+		if action.Encoding != nil && *action.Encoding == "base64" {
+			decoded, err := base64.StdEncoding.DecodeString(content)
+			if err != nil {
+				return fmt.Errorf("Invalid base64 content for %s", filePath)
+			}
+			content = string(decoded)
+		}
+	}
+
+	switch *action.Action {
+	case gitlab.FileCreate:
+		if exists {
+			return fmt.Errorf("A file with this name already exists: %s", filePath)
+		}
+		files[filePath] = fakeFile{Content: content, Mode: fileMode(modeFile, action.ExecuteFilemode)}
+	case gitlab.FileUpdate:
+		if !exists {
+			return fmt.Errorf("A file with this name doesn't exist: %s", filePath)
+		}
+		file.Content = content
+		file.Mode = fileMode(file.Mode, action.ExecuteFilemode)
+		files[filePath] = file
+	case gitlab.FileDelete:
+		if !exists {
+			return fmt.Errorf("A file with this name doesn't exist: %s", filePath)
+		}
+		delete(files, filePath)
+	case gitlab.FileMove:
+		previous, ok := files[*action.PreviousPath]
+		if !ok || exists {
+			return fmt.Errorf("Cannot move %s to %s", *action.PreviousPath, filePath)
+		}
+		delete(files, *action.PreviousPath)
+		if action.Content != nil {
+			previous.Content = content
+		}
+		files[filePath] = previous
+	case gitlab.FileChmod:
+		if !exists {
+			return fmt.Errorf("A file with this name doesn't exist: %s", filePath)
+		}
+		file.Mode = fileMode(file.Mode, action.ExecuteFilemode)
+		files[filePath] = file
+	}
+	return nil
+}
+
+// fileMode возвращает режим файла после действия с флагом исполнения executable
+func fileMode(mode string, executable *bool) string {
+	switch {
+	case executable == nil:
+		return mode
+	case *executable:
+		return modeExecutable
+	default:
+		return modeFile
+	}
+}
+
+func (f *fakeGitlab) listMergeRequests(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	f.mu.Lock()
+	mergeRequests := []*gitlab.BasicMergeRequest{}
+	for _, mr := range f.merges {
+		if (query.Get("state") == "" || query.Get("state") == mr.State) &&
+			(query.Get("source_branch") == "" || query.Get("source_branch") == mr.SourceBranch) &&
+			(query.Get("target_branch") == "" || query.Get("target_branch") == mr.TargetBranch) {
+			basic := mr.BasicMergeRequest
+			mergeRequests = append(mergeRequests, &basic)
+		}
+	}
+	f.mu.Unlock()
+	paginate(f, w, r, mergeRequests)
+}
+
+func (f *fakeGitlab) createMergeRequest(w http.ResponseWriter, r *http.Request) {
+	var opt gitlab.CreateMergeRequestOptions
+	f.decode(r, &opt)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.branches[*opt.SourceBranch]; !ok {
+		f.writeJSON(w, http.StatusBadRequest, map[string]string{"message": "Source branch does not exist"})
+		return
+	}
+	for _, mr := range f.merges {
+		if mr.State == "opened" && mr.SourceBranch == *opt.SourceBranch && mr.TargetBranch == *opt.TargetBranch {
+			f.writeJSON(w, http.StatusConflict, map[string][]string{"message": {"Another open merge request already exists for this source branch"}})
+			return
+		}
+	}
+	mr := &gitlab.MergeRequest{BasicMergeRequest: gitlab.BasicMergeRequest{
+		IID:          int64(len(f.merges) + 1),
+		ProjectID:    fakeProjectID,
+		State:        "opened",
+		SourceBranch: *opt.SourceBranch,
+		TargetBranch: *opt.TargetBranch,
+		Title:        *opt.Title,
+	}}
+	if opt.Description != nil {
+		mr.Description = *opt.Description
+	}
+	mr.WebURL = fmt.Sprintf("%s/group/project/-/merge_requests/%d", f.server.URL, mr.IID)
+	f.merges = append(f.merges, mr)
+	f.writeJSON(w, http.StatusCreated, mr)
+}
+
+func (f *fakeGitlab) updateMergeRequest(w http.ResponseWriter, r *http.Request) {
+	var opt gitlab.UpdateMergeRequestOptions
+	f.decode(r, &opt)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	iid, _ := strconv.Atoi(r.PathValue("iid"))
+	if iid < 1 || iid > len(f.merges) {
+		f.notFound(w, "Merge Request")
+		return
+	}
+	mr := f.merges[iid-1]
+	if opt.Title != nil {
+		mr.Title = *opt.Title
+	}
+	if opt.Description != nil {
+		mr.Description = *opt.Description
+	}
+	f.writeJSON(w, http.StatusOK, mr)
+}
+
+// mergeRequests возвращает все merge request проекта
+func (f *fakeGitlab) mergeRequests() []*gitlab.MergeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*gitlab.MergeRequest(nil), f.merges...)
+}
@@ -0,0 +1,132 @@
+package gitlab
+
+import (
+	"strings"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// MatchProtectedBranches возвращает правила защиты, под которые попадает ветка branch: правило для
+// ее имени и правила-шаблоны, в которых * заменяет любую последовательность символов, в том числе /
+func MatchProtectedBranches(rules []*gitlab.ProtectedBranch, branch string) []*gitlab.ProtectedBranch {
+	var matched []*gitlab.ProtectedBranch
+	for _, rule := range rules {
+		if matchWildcard(rule.Name, branch) {
+			matched = append(matched, rule)
+		}
+	}
+	return matched
+}
+
+// matchWildcard сравнивает имя ветки с шаблоном GitLab
+func matchWildcard(pattern, name string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == name
+	}
+	if !strings.HasPrefix(name, parts[0]) {
+		return false
+	}
+	name = name[len(parts[0]):]
+	last := parts[len(parts)-1]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(name, part)
+		if i < 0 {
+			return false
+		}
+		name = name[i+len(part):]
+	}
+	return len(name) >= len(last) && strings.HasSuffix(name, last)
+}
+
+// ProjectAccessLevel возвращает уровень доступа пользователя токена к проекту: наибольший из доступа
+// к проекту и к его группе. false означает, что GitLab не сообщил уровень доступа, например
+// администратору или при доступе через родительскую группу.
+func ProjectAccessLevel(project *gitlab.Project) (gitlab.AccessLevelValue, bool) {
+	if project.Permissions == nil {
+		return gitlab.NoPermissions, false
+	}
+	level, known := gitlab.NoPermissions, false
+	if access := project.Permissions.ProjectAccess; access != nil {
+		level, known = max(level, access.AccessLevel), true
+	}
+	if access := project.Permissions.GroupAccess; access != nil {
+		level, known = max(level, access.AccessLevel), true
+	}
+	return level, known
+}
+
+// PushAllowed сообщает, разрешает ли хотя бы одно из правил rules push пользователю с уровнем доступа
+// access: GitLab применяет самое мягкое из совпавших правил. Разрешения для отдельных пользователей,
+// групп и ключей развертывания считаются разрешающими, так как их нельзя проверить без лишних запросов.
+// Если уровень доступа неизвестен, запрещающими считаются только правила "No one".
+func PushAllowed(rules []*gitlab.ProtectedBranch, access gitlab.AccessLevelValue, known bool) bool {
+	for _, rule := range rules {
+		for _, level := range rule.PushAccessLevels {
+			if level.UserID != 0 || level.GroupID != 0 || level.DeployKeyID != 0 {
+				return true
+			}
+			if level.AccessLevel != gitlab.NoPermissions && (!known || access >= level.AccessLevel) {
+				return true
+			}
+		}
+	}
+	return len(rules) == 0
+}
+
+// ForcePushAllowed сообщает, разрешают ли правила rules force push: GitLab разрешает его,
+// только если это разрешено во всех совпавших правилах
+func ForcePushAllowed(rules []*gitlab.ProtectedBranch) bool {
+	for _, rule := range rules {
+		if !rule.AllowForcePush {
+			return false
+		}
+	}
+	return true
+}
+
+// ProtectOptions возвращает параметры, с которыми правило rule создается заново после снятия защиты.
+// Первый уровень доступа по роли передается как push_access_level и т.п., что поддерживает и GitLab CE;
+// остальные уровни и разрешения пользователей, групп и ключей - списками allowed_to_*.
+func ProtectOptions(rule *gitlab.ProtectedBranch) *gitlab.ProtectRepositoryBranchesOptions {
+	opt := &gitlab.ProtectRepositoryBranchesOptions{
+		Name:                      gitlab.Ptr(rule.Name),
+		AllowForcePush:            gitlab.Ptr(rule.AllowForcePush),
+		CodeOwnerApprovalRequired: gitlab.Ptr(rule.CodeOwnerApprovalRequired),
+	}
+	opt.PushAccessLevel, opt.AllowedToPush = permissionOptions(rule.PushAccessLevels)
+	opt.MergeAccessLevel, opt.AllowedToMerge = permissionOptions(rule.MergeAccessLevels)
+	opt.UnprotectAccessLevel, opt.AllowedToUnprotect = permissionOptions(rule.UnprotectAccessLevels)
+	return opt
+}
+
+// permissionOptions переводит уровни доступа правила в уровень по роли и список дополнительных разрешений
+func permissionOptions(levels []*gitlab.BranchAccessDescription) (*gitlab.AccessLevelValue, *[]*gitlab.BranchPermissionOptions) {
+	var role *gitlab.AccessLevelValue
+	var allowed []*gitlab.BranchPermissionOptions
+	for _, level := range levels {
+		option := &gitlab.BranchPermissionOptions{}
+		switch {
+		case level.UserID != 0:
+			option.UserID = gitlab.Ptr(level.UserID)
+		case level.GroupID != 0:
+			option.GroupID = gitlab.Ptr(level.GroupID)
+		case level.DeployKeyID != 0:
+			option.DeployKeyID = gitlab.Ptr(level.DeployKeyID)
+		case role == nil:
+			role = gitlab.Ptr(level.AccessLevel)
+			continue
+		default:
+			option.AccessLevel = gitlab.Ptr(level.AccessLevel)
+		}
+		allowed = append(allowed, option)
+	}
+	if role == nil && len(allowed) > 0 {
+		// Без уровня по роли GitLab разрешил бы доступ сопровождающим
+		role = gitlab.Ptr(gitlab.NoPermissions)
+	}
+	if len(allowed) == 0 {
+		return role, nil
+	}
+	return role, &allowed
+}
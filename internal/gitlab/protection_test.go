@@ -0,0 +1,159 @@
+package gitlab
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+func TestMatchProtectedBranches(t *testing.T) {
+	rules := []*gitlab.ProtectedBranch{
+		{Name: "main"},
+		{Name: "release/*"},
+		{Name: "*-stable"},
+		{Name: "feature/*/v*"},
+	}
+
+	tests := []struct {
+		branch string
+		want   []string
+	}{
+		{"main", []string{"main"}},
+		{"main-2", nil},
+		{"release/1.0", []string{"release/*"}},
+		{"release/1.0/hotfix", []string{"release/*"}},
+		{"release", nil},
+		{"release/1-stable", []string{"release/*", "*-stable"}},
+		{"feature/x/y/v2", []string{"feature/*/v*"}},
+		{"feature/v", nil},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, rule := range MatchProtectedBranches(rules, tt.branch) {
+			got = append(got, rule.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: совпали правила %v, ожидалось %v", tt.branch, got, tt.want)
+		}
+	}
+}
+
+func TestPushAllowed(t *testing.T) {
+	rule := func(forcePush bool, levels ...*gitlab.BranchAccessDescription) *gitlab.ProtectedBranch {
+		return &gitlab.ProtectedBranch{Name: "main", PushAccessLevels: levels, AllowForcePush: forcePush}
+	}
+	role := func(level gitlab.AccessLevelValue) *gitlab.BranchAccessDescription {
+		return &gitlab.BranchAccessDescription{AccessLevel: level}
+	}
+	maintainers := rule(false, role(gitlab.MaintainerPermissions))
+	developers := rule(true, role(gitlab.DeveloperPermissions))
+	noOne := rule(false, role(gitlab.NoPermissions))
+	user := rule(false, role(gitlab.NoPermissions), &gitlab.BranchAccessDescription{UserID: 7})
+
+	tests := []struct {
+		name      string
+		rules     []*gitlab.ProtectedBranch
+		access    gitlab.AccessLevelValue
+		known     bool
+		push      bool
+		forcePush bool
+	}{
+		{"NoRules", nil, gitlab.DeveloperPermissions, true, true, true},
+		{"Maintainer", []*gitlab.ProtectedBranch{maintainers}, gitlab.MaintainerPermissions, true, true, false},
+		{"Developer", []*gitlab.ProtectedBranch{maintainers}, gitlab.DeveloperPermissions, true, false, false},
+		{"UnknownAccess", []*gitlab.ProtectedBranch{maintainers}, gitlab.NoPermissions, false, true, false},
+		{"NoOne", []*gitlab.ProtectedBranch{noOne}, gitlab.OwnerPermissions, true, false, false},
+		{"NoOneUnknownAccess", []*gitlab.ProtectedBranch{noOne}, gitlab.NoPermissions, false, false, false},
+		{"UserGrant", []*gitlab.ProtectedBranch{user}, gitlab.DeveloperPermissions, true, true, false},
+		{"MostPermissive", []*gitlab.ProtectedBranch{maintainers, developers}, gitlab.DeveloperPermissions, true, true, false},
+		{"ForcePush", []*gitlab.ProtectedBranch{developers}, gitlab.DeveloperPermissions, true, true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := PushAllowed(tt.rules, tt.access, tt.known); got != tt.push {
+				t.Errorf("PushAllowed = %v, ожидалось %v", got, tt.push)
+			}
+			if got := ForcePushAllowed(tt.rules); got != tt.forcePush {
+				t.Errorf("ForcePushAllowed = %v, ожидалось %v", got, tt.forcePush)
+			}
+		})
+	}
+}
+
+func TestProjectAccessLevel(t *testing.T) {
+	project := &gitlab.Project{Permissions: &gitlab.Permissions{
+		ProjectAccess: &gitlab.ProjectAccess{AccessLevel: gitlab.DeveloperPermissions},
+		GroupAccess:   &gitlab.GroupAccess{AccessLevel: gitlab.MaintainerPermissions},
+	}}
+	if level, known := ProjectAccessLevel(project); level != gitlab.MaintainerPermissions || !known {
+		t.Errorf("Уровень доступа %v (%v), ожидался %v", level, known, gitlab.MaintainerPermissions)
+	}
+	if _, known := ProjectAccessLevel(&gitlab.Project{Permissions: &gitlab.Permissions{}}); known {
+		t.Error("Уровень доступа без сведений о правах должен быть неизвестен")
+	}
+}
+
+func TestUnprotectAndRestore(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	ctx := context.Background()
+	original := &gitlab.ProtectedBranch{
+		Name: "release/*",
+		PushAccessLevels: []*gitlab.BranchAccessDescription{
+			{AccessLevel: gitlab.MaintainerPermissions},
+			{UserID: 7},
+		},
+		MergeAccessLevels:         []*gitlab.BranchAccessDescription{{AccessLevel: gitlab.DeveloperPermissions}},
+		UnprotectAccessLevels:     []*gitlab.BranchAccessDescription{{GroupID: 3}},
+		CodeOwnerApprovalRequired: true,
+	}
+	if err := client.ProtectBranch(ctx, ProtectOptions(original)); err != nil {
+		t.Fatalf("Не удалось защитить ветку: %v", err)
+	}
+	rule, err := client.GetProtectedBranch(ctx, "release/*")
+	if err != nil {
+		t.Fatalf("Не удалось получить защиту ветки: %v", err)
+	}
+
+	if err := client.UpdateProtectedBranch(ctx, rule.Name, &gitlab.UpdateProtectedBranchOptions{AllowForcePush: gitlab.Ptr(true)}); err != nil {
+		t.Fatalf("Не удалось изменить защиту ветки: %v", err)
+	}
+	if updated, _ := client.GetProtectedBranch(ctx, rule.Name); !updated.AllowForcePush {
+		t.Error("Ожидалось разрешение force push после изменения защиты")
+	}
+	if err := client.UpdateProtectedBranch(ctx, rule.Name, &gitlab.UpdateProtectedBranchOptions{AllowForcePush: gitlab.Ptr(false)}); err != nil {
+		t.Fatalf("Не удалось изменить защиту ветки: %v", err)
+	}
+
+	if err := client.UnprotectBranch(ctx, rule.Name); err != nil {
+		t.Fatalf("Не удалось снять защиту: %v", err)
+	}
+	if rules, _ := client.ListProtectedBranches(ctx); len(rules) != 0 {
+		t.Fatalf("После снятия защиты остались правила: %d", len(rules))
+	}
+	if err := client.ProtectBranch(ctx, ProtectOptions(rule)); err != nil {
+		t.Fatalf("Не удалось восстановить защиту: %v", err)
+	}
+
+	restored, err := client.GetProtectedBranch(ctx, "release/*")
+	if err != nil {
+		t.Fatalf("Не удалось получить восстановленную защиту: %v", err)
+	}
+	restored.ID, rule.ID = 0, 0
+	if !reflect.DeepEqual(restored, rule) {
+		t.Errorf("Восстановленное правило отличается:\n%+v\nожидалось:\n%+v", restored, rule)
+	}
+
+	if err := client.UnprotectBranch(ctx, "main"); err == nil {
+		t.Error("Ожидалась ошибка снятия защиты с незащищенной ветки")
+	}
+	if err := client.ProtectBranch(ctx, ProtectOptions(rule)); err == nil {
+		t.Error("Ожидалась ошибка повторной защиты ветки")
+	}
+	if requests := fake.requestLog(); requests[len(requests)-1] != "POST /api/v4/projects/42/protected_branches" {
+		t.Errorf("Последний запрос: %s", requests[len(requests)-1])
+	}
+}
@@ -0,0 +1,201 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"unicode/utf8"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// Режимы записей дерева git, которые возвращает GitLab
+const (
+	modeFile       = "100644"
+	modeExecutable = "100755"
+	modeSymlink    = "120000"
+	modeGitlink    = "160000"
+)
+
+// treeEntry файл или подмодуль дерева коммита: SHA содержимого или коммита подмодуля и режим
+type treeEntry struct {
+	BlobID string
+	Mode   string
+}
+
+// treeChange изменение файла, которое переводит одно дерево в другое
+type treeChange struct {
+	Action       gitlab.FileActionValue
+	Path         string
+	PreviousPath string // путь до перемещения, только для FileMove
+	BlobID       string // содержимое для FileCreate и FileUpdate
+	Executable   *bool  // новое значение флага исполнения; nil, если режим файла не меняется
+}
+
+// walkTree возвращает все файлы и подмодули дерева ref, обходя рекурсивный список дерева постранично
+func (c *Client) walkTree(ctx context.Context, ref string) (map[string]treeEntry, error) {
+	nodes, err := listAll(func(page int64) ([]*gitlab.TreeNode, *gitlab.Response, error) {
+		return c.Client.Repositories.ListTree(c.ProjectID, &gitlab.ListTreeOptions{
+			ListOptions: gitlab.ListOptions{PerPage: perPage, Page: page},
+			Ref:         gitlab.Ptr(ref),
+			Recursive:   gitlab.Ptr(true),
+		}, gitlab.WithContext(ctx))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить дерево %s: %w", ref, err)
+	}
+
+	tree := make(map[string]treeEntry, len(nodes))
+	for _, node := range nodes {
+		switch node.Type {
+		case "tree":
+			// Каталоги возникают и исчезают вместе со своими файлами
+		default:
+			// Файлы, символические ссылки и подмодули сравниваются по SHA и режиму
+			tree[node.Path] = treeEntry{BlobID: node.ID, Mode: node.Mode}
+		}
+	}
+	return tree, nil
+}
+
+// diffTrees возвращает изменения, которые переводят дерево current в дерево target. Удаленный файл,
+// содержимое и режим которого совпадают с добавленным, становится перемещением. Каждый путь
+// изменяется одним действием. Символические ссылки и подмодули GitLab API создать не позволяет,
+// поэтому ошибкой считается только их добавление или изменение, неизмененные остаются как есть.
+func diffTrees(current, target map[string]treeEntry) ([]treeChange, error) {
+	var added, deleted []string
+	var changes []treeChange
+	for _, path := range sortedPaths(target) {
+		want := target[path]
+		have, ok := current[path]
+		if ok && have == want {
+			continue
+		}
+		if err := unsupportedChange(path, want.Mode); err != nil {
+			return nil, err
+		}
+		if ok {
+			if err := unsupportedChange(path, have.Mode); err != nil {
+				return nil, err
+			}
+		}
+		switch {
+		case !ok:
+			added = append(added, path)
+		case have.BlobID != want.BlobID:
+			change := treeChange{Action: gitlab.FileUpdate, Path: path, BlobID: want.BlobID}
+			if have.Mode != want.Mode {
+				change.Executable = executable(want.Mode)
+			}
+			changes = append(changes, change)
+		default:
+			changes = append(changes, treeChange{Action: gitlab.FileChmod, Path: path, Executable: executable(want.Mode)})
+		}
+	}
+	for _, path := range sortedPaths(current) {
+		if _, ok := target[path]; !ok {
+			deleted = append(deleted, path)
+		}
+	}
+
+	// Сопоставляем удаленные и добавленные файлы с одинаковым содержимым
+	moved := make(map[string]string, len(deleted)) // добавленный путь -> удаленный путь
+	sources := make(map[treeEntry][]string)
+	for _, path := range deleted {
+		sources[current[path]] = append(sources[current[path]], path)
+	}
+	for _, path := range added {
+		if candidates := sources[target[path]]; len(candidates) > 0 {
+			moved[path] = candidates[0]
+			sources[target[path]] = candidates[1:]
+		}
+	}
+	movedFrom := make(map[string]bool, len(moved))
+	for _, from := range moved {
+		movedFrom[from] = true
+	}
+
+	for _, path := range deleted {
+		if !movedFrom[path] {
+			changes = append(changes, treeChange{Action: gitlab.FileDelete, Path: path})
+		}
+	}
+	for _, path := range added {
+		want := target[path]
+		if from, ok := moved[path]; ok {
+			changes = append(changes, treeChange{Action: gitlab.FileMove, Path: path, PreviousPath: from})
+			continue
+		}
+		change := treeChange{Action: gitlab.FileCreate, Path: path, BlobID: want.BlobID}
+		if want.Mode == modeExecutable {
+			change.Executable = executable(want.Mode)
+		}
+		changes = append(changes, change)
+	}
+	return changes, nil
+}
+
+// unsupportedChange возвращает ошибку, если запись с режимом mode нельзя изменить через GitLab API
+func unsupportedChange(path, mode string) error {
+	switch mode {
+	case modeSymlink:
+		return fmt.Errorf("символическая ссылка %s не может быть создана или изменена через GitLab API", path)
+	case modeGitlink:
+		return fmt.Errorf("подмодуль %s не может быть добавлен или изменен через GitLab API", path)
+	}
+	return nil
+}
+
+// executable возвращает значение флага исполнения файла с режимом mode
+func executable(mode string) *bool {
+	return gitlab.Ptr(mode == modeExecutable)
+}
+
+// sortedPaths возвращает пути дерева по возрастанию, чтобы набор действий коммита был детерминированным
+func sortedPaths(tree map[string]treeEntry) []string {
+	paths := make([]string, 0, len(tree))
+	for path := range tree {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// commitAction превращает изменение в действие коммита, загружая содержимое файла.
+// Текстовое содержимое передается как есть, двоичное - в base64. lastCommitID защищает
+// изменяемые файлы от одновременной правки ветки.
+func (c *Client) commitAction(ctx context.Context, change treeChange, lastCommitID string) (*gitlab.CommitActionOptions, error) {
+	action := &gitlab.CommitActionOptions{
+		Action:   gitlab.Ptr(change.Action),
+		FilePath: gitlab.Ptr(change.Path),
+	}
+	switch change.Action {
+	case gitlab.FileCreate, gitlab.FileUpdate:
+		content, _, err := c.Client.Repositories.RawBlobContent(c.ProjectID, change.BlobID, gitlab.WithContext(ctx))
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить содержимое %s (%s): %w", change.Path, change.BlobID, err)
+		}
+		if isBinary(content) {
+			action.Content = gitlab.Ptr(base64.StdEncoding.EncodeToString(content))
+			action.Encoding = gitlab.Ptr("base64")
+		} else {
+			action.Content = gitlab.Ptr(string(content))
+			action.Encoding = gitlab.Ptr("text")
+		}
+	case gitlab.FileMove:
+		action.PreviousPath = gitlab.Ptr(change.PreviousPath)
+	}
+	// Флаг исполнения передается в том же действии, что и содержимое файла
+	action.ExecuteFilemode = change.Executable
+	if change.Action != gitlab.FileCreate && change.Action != gitlab.FileChmod {
+		action.LastCommitID = gitlab.Ptr(lastCommitID)
+	}
+	return action, nil
+}
+
+// isBinary сообщает, что содержимое нельзя передать в JSON как текст без потерь
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content, 0) >= 0 || !utf8.Valid(content)
+}
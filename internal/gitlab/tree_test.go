@@ -0,0 +1,290 @@
+package gitlab
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	gitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// pngHeader начало PNG-файла: содержит нулевые байты и не является UTF-8
+const pngHeader = "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+
+func TestUpdateBranchHead(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.perPage = 2
+	fake.addBranch("main", "aaa111", map[string]string{
+		"README.md":       "old readme",
+		"docs/guide.md":   "guide",
+		"obsolete.txt":    "obsolete",
+		"src/old/name.go": "package name",
+	})
+	target := map[string]fakeFile{
+		"README.md":       {Content: "new readme", Mode: modeFile},
+		"docs/guide.md":   {Content: "guide", Mode: modeFile},
+		"src/new/name.go": {Content: "package name", Mode: modeFile},
+		"assets/logo.png": {Content: pngHeader, Mode: modeFile},
+		"scripts/run.sh":  {Content: "#!/bin/sh\n", Mode: modeExecutable},
+	}
+	fake.addTree("bbb222", target)
+
+	if err := client.UpdateBranchHead(context.Background(), "main", "bbb222"); err != nil {
+		t.Fatalf("Не удалось обновить ветку: %v", err)
+	}
+
+	if got := fake.tree("main"); !maps.Equal(got, target) {
+		t.Errorf("Дерево ветки после обновления:\n%v\nожидалось:\n%v", got, target)
+	}
+
+	created := fake.createdCommits()
+	if len(created) != 1 {
+		t.Fatalf("Ожидался один коммит, создано %d", len(created))
+	}
+	commit := created[0]
+	if *commit.CommitMessage != "Коммит bbb222\n" || *commit.AuthorName != "Автор bbb222" || *commit.AuthorEmail != "bbb222@example.com" {
+		t.Errorf("Коммит должен повторять сообщение и автора bbb222: %q, %s <%s>", *commit.CommitMessage, *commit.AuthorName, *commit.AuthorEmail)
+	}
+
+	var actions []string
+	for _, action := range commit.Actions {
+		description := fmt.Sprintf("%s %s", *action.Action, *action.FilePath)
+		if action.PreviousPath != nil {
+			description += " <- " + *action.PreviousPath
+		}
+		if action.Encoding != nil {
+			description += " (" + *action.Encoding + ")"
+		}
+		if action.ExecuteFilemode != nil {
+			description += fmt.Sprintf(" executable=%t", *action.ExecuteFilemode)
+		}
+		actions = append(actions, description)
+	}
+	want := []string{
+		"update README.md (text)",
+		"delete obsolete.txt",
+		"create assets/logo.png (base64)",
+		"create scripts/run.sh (text) executable=true",
+		"move src/new/name.go <- src/old/name.go",
+	}
+	if strings.Join(actions, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Действия коммита:\n%s\nожидалось:\n%s", strings.Join(actions, "\n"), strings.Join(want, "\n"))
+	}
+
+	// Оба дерева не помещаются на одну страницу
+	pages := 0
+	for _, request := range fake.requestLog() {
+		if strings.HasSuffix(request, "/repository/tree") {
+			pages++
+		}
+	}
+	if pages < 6 {
+		t.Errorf("Ожидался постраничный обход деревьев, выполнено запросов дерева: %d", pages)
+	}
+}
+
+func TestUpdateBranchHeadUnchanged(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	files := map[string]string{"README.md": "readme"}
+	fake.addBranch("main", "aaa111", files)
+	fake.addCommit("bbb222", files)
+
+	if err := client.UpdateBranchHead(context.Background(), "main", "bbb222"); err != nil {
+		t.Fatalf("Не удалось обновить ветку: %v", err)
+	}
+	if created := fake.createdCommits(); len(created) != 0 {
+		t.Errorf("Для одинаковых деревьев не должен создаваться коммит, создано %d", len(created))
+	}
+}
+
+// Тест, что ветка, которую передвинули во время обновления, не перезаписывается: действия создания
+// файлов не проверяют last_commit_id
+func TestUpdateBranchHeadBranchMoved(t *testing.T) {
+	fake, client := newFakeGitlab(t)
+	fake.addBranch("main", "aaa111", map[string]string{"README.md": "readme"})
+	fake.addCommit("bbb222", map[string]string{"README.md": "readme", "new.txt": "new"})
+
+	// Пока загружается содержимое нового файла, ветку передвигает другой клиент
+	var once sync.Once
+	fake.beforeRequest = func(r *http.Request) {
+		if strings.Contains(r.URL.Path, "/repository/blobs/") {
+			once.Do(func() {
+				fake.addBranch("main", "ccc333", map[string]string{"README.md": "readme", "other.txt": "other"})
+			})
+		}
+	}
+
+	err := client.UpdateBranchHead(context.Background(), "main", "bbb222")
+	if err == nil || !strings.Contains(err.Error(), "ветка изменилась во время обновления") {
+		t.Errorf("Ожидалась ошибка изменения ветки, получено: %v", err)
+	}
+	if created := fake.createdCommits(); len(created) != 0 {
+		t.Errorf("Коммит не должен создаваться поверх передвинутой ветки, создано %d", len(created))
+	}
+	if _, ok := fake.file("main", "other.txt"); !ok {
+		t.Error("Изменения другого клиента потеряны")
+	}
+}
+
+func TestUpdateBranchHeadErrors(t *testing.T) {
+	// По умолчанию ветка main содержит только README.md
+	readme := fakeFile{Content: "readme", Mode: modeFile}
+	link := fakeFile{Content: "README.md", Mode: modeSymlink}
+	submodule := fakeFile{Content: "0123456789abcdef", Mode: modeGitlink}
+
+	tests := []struct {
+		name    string
+		branch  string
+		commit  string
+		current map[string]fakeFile
+		target  map[string]fakeFile
+		wantErr string // пустая строка - обновление выполняется
+	}{
+		{
+			name:    "MissingBranch",
+			branch:  "missing",
+			commit:  "bbb222",
+			wantErr: "не удалось получить ветку missing",
+		},
+		{
+			name:    "MissingCommit",
+			branch:  "main",
+			commit:  "ccc333",
+			wantErr: "не удалось получить коммит ccc333",
+		},
+		{
+			name:    "Symlink",
+			branch:  "main",
+			commit:  "bbb222",
+			target:  map[string]fakeFile{"link": link},
+			wantErr: "символическая ссылка link",
+		},
+		{
+			name:    "ChangedSymlink",
+			branch:  "main",
+			commit:  "bbb222",
+			current: map[string]fakeFile{"README.md": readme, "link": link},
+			target:  map[string]fakeFile{"README.md": readme, "link": {Content: "docs/README.md", Mode: modeSymlink}},
+			wantErr: "символическая ссылка link",
+		},
+		{
+			name:    "Submodule",
+			branch:  "main",
+			commit:  "bbb222",
+			target:  map[string]fakeFile{"README.md": readme, "vendor/lib": submodule},
+			wantErr: "подмодуль vendor/lib",
+		},
+		{
+			// Неизмененные символическая ссылка и подмодуль не мешают обновить остальные файлы
+			name:    "UnchangedSymlink",
+			branch:  "main",
+			commit:  "bbb222",
+			current: map[string]fakeFile{"README.md": readme, "link": link, "vendor/lib": submodule},
+			target:  map[string]fakeFile{"README.md": {Content: "new readme", Mode: modeFile}, "link": link, "vendor/lib": submodule},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake, client := newFakeGitlab(t)
+			current := tt.current
+			if current == nil {
+				current = map[string]fakeFile{"README.md": readme}
+			}
+			fake.addBranchTree("main", "aaa111", current)
+			fake.addTree("bbb222", tt.target)
+
+			err := client.UpdateBranchHead(context.Background(), tt.branch, tt.commit)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Не удалось обновить ветку: %v", err)
+				}
+				if got := fake.tree("main"); !maps.Equal(got, tt.target) {
+					t.Errorf("Дерево ветки после обновления:\n%v\nожидалось:\n%v", got, tt.target)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Ожидалась ошибка %q, получено: %v", tt.wantErr, err)
+			}
+			if created := fake.createdCommits(); len(created) != 0 {
+				t.Errorf("После ошибки не должен создаваться коммит, создано %d", len(created))
+			}
+		})
+	}
+}
+
+func TestDiffTrees(t *testing.T) {
+	file := func(blob string) treeEntry { return treeEntry{BlobID: blob, Mode: modeFile} }
+	script := func(blob string) treeEntry { return treeEntry{BlobID: blob, Mode: modeExecutable} }
+	link := treeEntry{BlobID: "l", Mode: modeSymlink}
+	submodule := treeEntry{BlobID: "s", Mode: modeGitlink}
+
+	tests := []struct {
+		name    string
+		current map[string]treeEntry
+		target  map[string]treeEntry
+		want    []treeChange
+	}{
+		{
+			name:    "Equal",
+			current: map[string]treeEntry{"a": file("1")},
+			target:  map[string]treeEntry{"a": file("1")},
+		},
+		{
+			name:    "ModeOnly",
+			current: map[string]treeEntry{"run.sh": file("1")},
+			target:  map[string]treeEntry{"run.sh": script("1")},
+			want:    []treeChange{{Action: gitlab.FileChmod, Path: "run.sh", Executable: gitlab.Ptr(true)}},
+		},
+		{
+			name:    "ContentAndMode",
+			current: map[string]treeEntry{"run.sh": script("1")},
+			target:  map[string]treeEntry{"run.sh": file("2")},
+			want:    []treeChange{{Action: gitlab.FileUpdate, Path: "run.sh", BlobID: "2", Executable: gitlab.Ptr(false)}},
+		},
+		{
+			// Одинаковое содержимое у двух удаленных файлов: перемещением становится только один
+			name:    "DuplicateContent",
+			current: map[string]treeEntry{"a": file("1"), "b": file("1")},
+			target:  map[string]treeEntry{"c": file("1")},
+			want: []treeChange{
+				{Action: gitlab.FileDelete, Path: "b"},
+				{Action: gitlab.FileMove, Path: "c", PreviousPath: "a"},
+			},
+		},
+		{
+			// Перемещение с изменением режима - это удаление и создание
+			name:    "MoveWithModeChange",
+			current: map[string]treeEntry{"a": file("1")},
+			target:  map[string]treeEntry{"b": script("1")},
+			want: []treeChange{
+				{Action: gitlab.FileDelete, Path: "a"},
+				{Action: gitlab.FileCreate, Path: "b", BlobID: "1", Executable: gitlab.Ptr(true)},
+			},
+		},
+		{
+			name:    "UnchangedSymlinkAndSubmodule",
+			current: map[string]treeEntry{"a": file("1"), "link": link, "lib": submodule},
+			target:  map[string]treeEntry{"a": file("2"), "link": link, "lib": submodule},
+			want:    []treeChange{{Action: gitlab.FileUpdate, Path: "a", BlobID: "2"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffTrees(tt.current, tt.target)
+			if err != nil {
+				t.Fatalf("Ошибка сравнения деревьев: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Получены изменения %+v, ожидалось %+v", got, tt.want)
+			}
+		})
+	}
+}
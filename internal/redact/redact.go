@@ -0,0 +1,84 @@
+package redact
+
+import (
+	"io"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Mask строка, которой заменяются секреты
+const Mask = "***"
+
+// Redactor заменяет известные секреты маской. Нулевое значение готово к использованию,
+// методы безопасны для одновременного вызова.
+type Redactor struct {
+	mu       sync.RWMutex
+	secrets  []string
+	replacer *strings.Replacer
+}
+
+// Add добавляет секреты, которые нужно скрывать. Пустые строки игнорируются.
+func (r *Redactor) Add(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set(append(r.secrets, secrets...))
+}
+
+// Set заменяет скрываемые секреты, например после перезагрузки конфигурации. Пустые строки игнорируются.
+func (r *Redactor) Set(secrets ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.set(secrets)
+}
+
+// set запоминает секреты без повторов и пересоздает замену; вызывается под r.mu
+func (r *Redactor) set(secrets []string) {
+	seen := make(map[string]bool, len(secrets))
+	kept := make([]string, 0, len(secrets))
+	for _, secret := range secrets {
+		if secret != "" && !seen[secret] {
+			seen[secret] = true
+			kept = append(kept, secret)
+		}
+	}
+	// Более длинные секреты заменяются первыми, чтобы секрет, содержащий другой секрет, скрывался целиком
+	sort.Slice(kept, func(i, j int) bool { return len(kept[i]) > len(kept[j]) })
+	r.secrets = kept
+
+	pairs := make([]string, 0, 2*len(kept))
+	for _, secret := range kept {
+		pairs = append(pairs, secret, Mask)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// String возвращает s, в которой все известные секреты заменены маской
+func (r *Redactor) String(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.replacer == nil {
+		return s
+	}
+	return r.replacer.Replace(s)
+}
+
+// Writer возвращает io.Writer, который скрывает секреты перед записью в w. Секрет скрывается,
+// если он целиком содержится в одном вызове Write: log.Logger и fmt.Fprint* записывают сообщение за один вызов.
+func (r *Redactor) Writer(w io.Writer) io.Writer {
+	return &writer{redactor: r, w: w}
+}
+
+// writer io.Writer, скрывающий секреты
+type writer struct {
+	redactor *Redactor
+	w        io.Writer
+}
+
+func (w *writer) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(w.w, w.redactor.String(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
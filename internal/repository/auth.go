@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"git-sync/configs"
+
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// AuthFunc возвращает метод аутентификации транспорта для обращения к репозиторию repoURL
+// с учетными данными cred
+type AuthFunc func(repoURL string, cred configs.Credential) (transport.AuthMethod, error)
+
+// AuthMethod возвращает метод аутентификации транспорта для учетных данных.
+// Для учетных данных без типа возвращается nil - доступ без аутентификации.
+func AuthMethod(cred configs.Credential) (transport.AuthMethod, error) {
+	return authMethod(cred, log.Default())
+}
+
+// authMethod строит метод аутентификации; ключи SSH-серверов, принятые в режиме accept-new,
+// записываются в журнал logger
+func authMethod(cred configs.Credential, logger *log.Logger) (transport.AuthMethod, error) {
+	switch cred.Type {
+	case "":
+		return nil, nil
+	case configs.CredentialToken, configs.CredentialBasic:
+		secret, err := cred.SecretValue()
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить секрет для аутентификации %s: %w", cred.Type, err)
+		}
+		username := cred.Username
+		if cred.Type == configs.CredentialToken {
+			username = cred.UsernameOr(configs.DefaultTokenUsername)
+		}
+		return &http.BasicAuth{Username: username, Password: secret}, nil
+	case configs.CredentialSSHKey:
+		signer, err := loadSigner(cred.KeyPath, cred.Passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось создать SSH-аутентификацию: %w", err)
+		}
+		keys := &ssh.PublicKeys{User: cred.UsernameOr(configs.DefaultSSHUsername), Signer: signer}
+		keys.HostKeyCallback = hostKeyCallback(cred, logger)
+		return keys, nil
+	case configs.CredentialSSHAgent:
+		agentAuth, err := ssh.NewSSHAgentAuth(cred.UsernameOr(configs.DefaultSSHUsername))
+		if err != nil {
+			return nil, fmt.Errorf("не удалось подключиться к SSH-агенту: %w", err)
+		}
+		agentAuth.HostKeyCallback = hostKeyCallback(cred, logger)
+		return agentAuth, nil
+	default:
+		return nil, fmt.Errorf("неизвестный тип учетных данных %q", cred.Type)
+	}
+}
+
+// loadSigner читает приватный ключ из файла. Зашифрованный ключ расшифровывается паролем passphrase.
+func loadSigner(keyPath, passphrase string) (gossh.Signer, error) {
+	pemBytes, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := gossh.ParsePrivateKey(pemBytes)
+	var missing *gossh.PassphraseMissingError
+	if errors.As(err, &missing) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("ключ %s защищен паролем, задайте passphrase", keyPath)
+		}
+		signer, err = gossh.ParsePrivateKeyWithPassphrase(pemBytes, []byte(passphrase))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать ключ %s: %w", keyPath, err)
+	}
+	return signer, nil
+}
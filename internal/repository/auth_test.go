@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"git-sync/configs"
+
+	"github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// writeTestKey создает приватный ключ ed25519 в формате OpenSSH и возвращает путь к нему
+func writeTestKey(t *testing.T) string {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Не удалось создать ключ: %v", err)
+	}
+	block, err := gossh.MarshalPrivateKey(key, "")
+	if err != nil {
+		t.Fatalf("Не удалось закодировать ключ: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Не удалось сохранить ключ: %v", err)
+	}
+	return keyPath
+}
+
+func TestAuthMethod(t *testing.T) {
+	keyPath := writeTestKey(t)
+	t.Setenv("GIT_SYNC_TEST_TOKEN", "env-token")
+
+	t.Run("None", func(t *testing.T) {
+		auth, err := AuthMethod(configs.Credential{})
+		if err != nil || auth != nil {
+			t.Errorf("Ожидался доступ без аутентификации, получено %v, %v", auth, err)
+		}
+	})
+
+	t.Run("Token", func(t *testing.T) {
+		auth, err := AuthMethod(configs.Credential{Type: configs.CredentialToken, SecretEnv: "GIT_SYNC_TEST_TOKEN"})
+		if err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+		basic, ok := auth.(*http.BasicAuth)
+		if !ok || basic.Username != configs.DefaultTokenUsername || basic.Password != "env-token" {
+			t.Errorf("Неверная аутентификация: %#v", auth)
+		}
+	})
+
+	t.Run("Basic", func(t *testing.T) {
+		auth, err := AuthMethod(configs.Credential{Type: configs.CredentialBasic, Username: "bot", Secret: "password"})
+		if err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+		basic, ok := auth.(*http.BasicAuth)
+		if !ok || basic.Username != "bot" || basic.Password != "password" {
+			t.Errorf("Неверная аутентификация: %#v", auth)
+		}
+	})
+
+	t.Run("MissingSecret", func(t *testing.T) {
+		if _, err := AuthMethod(configs.Credential{Type: configs.CredentialToken, SecretEnv: "GIT_SYNC_TEST_MISSING"}); err == nil {
+			t.Error("Ожидалась ошибка для незаданной переменной окружения")
+		}
+	})
+
+	t.Run("SSHKey", func(t *testing.T) {
+		auth, err := AuthMethod(configs.Credential{Type: configs.CredentialSSHKey, KeyPath: keyPath, Username: "deploy"})
+		if err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+		keys, ok := auth.(*ssh.PublicKeys)
+		if !ok || keys.User != "deploy" {
+			t.Errorf("Неверная аутентификация: %#v", auth)
+		}
+	})
+
+	t.Run("SSHKeyDefaultUser", func(t *testing.T) {
+		auth, err := AuthMethod(configs.Credential{Type: configs.CredentialSSHKey, KeyPath: keyPath})
+		if err != nil {
+			t.Fatalf("Неожиданная ошибка: %v", err)
+		}
+		if keys := auth.(*ssh.PublicKeys); keys.User != configs.DefaultSSHUsername {
+			t.Errorf("Ожидался пользователь %q, получен %q", configs.DefaultSSHUsername, keys.User)
+		}
+	})
+
+	t.Run("SSHAgentWithoutSocket", func(t *testing.T) {
+		t.Setenv("SSH_AUTH_SOCK", "")
+		if _, err := AuthMethod(configs.Credential{Type: configs.CredentialSSHAgent}); err == nil {
+			t.Error("Ожидалась ошибка без SSH_AUTH_SOCK")
+		}
+	})
+
+	t.Run("UnknownType", func(t *testing.T) {
+		if _, err := AuthMethod(configs.Credential{Type: "kerberos"}); err == nil {
+			t.Error("Ожидалась ошибка для неизвестного типа")
+		}
+	})
+}
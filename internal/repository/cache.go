@@ -0,0 +1,359 @@
+package repository
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"git-sync/configs"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+const (
+	// mirrorMetaFile файл зеркала с нормализованным адресом remote. Время его изменения - время
+	// последнего использования зеркала, по нему команда cache prune находит неиспользуемые зеркала.
+	mirrorMetaFile = "git-sync-mirror"
+	// mirrorSuffix суффикс директорий зеркал в кэше
+	mirrorSuffix = ".git"
+)
+
+// mirrorRefSpecs отображение ссылок remote в зеркало: ветки хранятся как refs/remotes/origin/*,
+// теги - под своими именами. Локальных веток и рабочей копии в зеркале нет.
+var mirrorRefSpecs = []config.RefSpec{
+	"+refs/heads/*:refs/remotes/origin/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// CacheEntry зеркало стороны пары в кэше
+type CacheEntry struct {
+	// Path путь к bare-репозиторию зеркала
+	Path string
+	// URL нормализованный адрес remote; пуст, если зеркало повреждено
+	URL string
+	// LastUsed время последнего обновления зеркала
+	LastUsed time.Time
+	// Size размер зеркала на диске в байтах
+	Size int64
+}
+
+// SetCacheDir задает директорию кэша зеркал. По умолчанию используется поддиректория mirrors в tempDir.
+// Зеркала каждой пары хранятся в отдельной поддиректории, см. PairDir.
+func (m *Manager) SetCacheDir(cacheDir string) {
+	m.cacheDir = cacheDir
+}
+
+// CacheDir возвращает директорию кэша зеркал
+func (m *Manager) CacheDir() string {
+	return m.cacheDir
+}
+
+// PairID возвращает имя директории пары репозиториев: имя репозитория и hash нормализованных адресов
+// обеих сторон. Пары одноименных репозиториев из разных групп получают разные директории.
+func PairID(gitlabURL, privateRepoURL string) string {
+	sum := sha256.Sum256([]byte(configs.NormalizeURL(gitlabURL) + "\n" + configs.NormalizeURL(privateRepoURL)))
+	return dirName(gitlabURL) + "-" + hex.EncodeToString(sum[:6])
+}
+
+// PairDir возвращает директорию зеркал пары в кэше
+func (m *Manager) PairDir(pairID string) string {
+	return filepath.Join(m.cacheDir, pairID)
+}
+
+// MirrorPath возвращает путь к зеркалу стороны side пары pairID: <side>-<имя репозитория>.git
+func (m *Manager) MirrorPath(pairID, side, repoURL string) string {
+	return filepath.Join(m.PairDir(pairID), side+"-"+dirName(repoURL)+mirrorSuffix)
+}
+
+// dirName возвращает имя репозитория из адреса, пригодное для имени директории
+func dirName(repoURL string) string {
+	normalized := configs.NormalizeURL(repoURL)
+	name := strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' {
+			return r
+		}
+		return '_'
+	}, normalized[strings.LastIndex(normalized, "/")+1:])
+	if strings.Trim(name, "._") == "" {
+		return "repo"
+	}
+	return name
+}
+
+// Mirror возвращает bare-зеркало remote repoURL в директории path, получив в него изменения remote.
+// Зеркало создается при первом обращении, затем обновляется инкрементально. Поврежденное
+// зеркало удаляется и создается заново.
+func (m *Manager) Mirror(ctx context.Context, repoURL, path string, cred configs.Credential) (*git.Repository, error) {
+	repo, err := openMirror(path, repoURL)
+	if err != nil {
+		m.logger.Printf("Предупреждение: зеркало %s повреждено и будет создано заново: %v", path, err)
+		repo = nil
+	}
+	if repo != nil {
+		err = m.fetchMirror(ctx, repo, repoURL, cred)
+		if err == nil {
+			return repo, touchMirror(path, repoURL)
+		}
+		if !errors.Is(err, plumbing.ErrObjectNotFound) {
+			return nil, err
+		}
+		m.logger.Printf("Предупреждение: в зеркале %s отсутствуют объекты, оно будет создано заново: %v", path, err)
+	}
+
+	if err := os.RemoveAll(path); err != nil {
+		return nil, fmt.Errorf("не удалось удалить зеркало %s: %w", path, err)
+	}
+	repo, err = git.PlainInit(path, true)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось создать зеркало %s: %w", path, err)
+	}
+	if err := m.fetchMirror(ctx, repo, repoURL, cred); err != nil {
+		// Незаполненное зеркало не сохраняется, следующий запуск начнет заново
+		os.RemoveAll(path)
+		return nil, err
+	}
+	return repo, touchMirror(path, repoURL)
+}
+
+// openMirror открывает существующее зеркало и проверяет его целостность.
+// Для отсутствующего зеркала возвращает nil без ошибки.
+func openMirror(path, repoURL string) (*git.Repository, error) {
+	meta, err := os.ReadFile(filepath.Join(path, mirrorMetaFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		if _, statErr := os.Stat(path); errors.Is(statErr, fs.ErrNotExist) {
+			return nil, nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать %s: %w", mirrorMetaFile, err)
+	}
+	if stored := strings.TrimSpace(string(meta)); stored != configs.NormalizeURL(repoURL) {
+		return nil, fmt.Errorf("зеркало принадлежит другому remote: %s", stored)
+	}
+
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyMirror(repo); err != nil {
+		return nil, err
+	}
+	return repo, nil
+}
+
+// verifyMirror проверяет, что все ссылки зеркала указывают на существующие объекты,
+// а у коммитов есть деревья
+func verifyMirror(repo *git.Repository) error {
+	refs, err := repo.References()
+	if err != nil {
+		return fmt.Errorf("не удалось прочитать ссылки: %w", err)
+	}
+	defer refs.Close()
+
+	return refs.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+		obj, err := repo.Object(plumbing.AnyObject, ref.Hash())
+		if err != nil {
+			return fmt.Errorf("ссылка %s: объект %s: %w", ref.Name(), ref.Hash(), err)
+		}
+		if commit, ok := obj.(*object.Commit); ok {
+			if _, err := commit.Tree(); err != nil {
+				return fmt.Errorf("ссылка %s: дерево коммита %s: %w", ref.Name(), commit.Hash, err)
+			}
+		}
+		return nil
+	})
+}
+
+// fetchMirror получает в зеркало ветки и теги remote. Ссылки, удаленные в remote, удаляются из зеркала.
+func (m *Manager) fetchMirror(ctx context.Context, repo *git.Repository, repoURL string, cred configs.Credential) error {
+	// Адрес origin обновляется при каждом обращении: один remote может быть записан в конфигурации по-разному
+	if err := repo.DeleteRemote("origin"); err != nil && !errors.Is(err, git.ErrRemoteNotFound) {
+		return fmt.Errorf("не удалось удалить remote origin: %w", err)
+	}
+	if _, err := repo.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{repoURL}, Fetch: mirrorRefSpecs}); err != nil {
+		return fmt.Errorf("не удалось создать remote origin: %w", err)
+	}
+	if err := m.FetchRemote(ctx, repo, "origin", mirrorRefSpecs, cred); err != nil {
+		return fmt.Errorf("не удалось обновить зеркало: %w", err)
+	}
+	return nil
+}
+
+// touchMirror записывает адрес remote в зеркало и отмечает время его использования
+func touchMirror(path, repoURL string) error {
+	if err := os.WriteFile(filepath.Join(path, mirrorMetaFile), []byte(configs.NormalizeURL(repoURL)+"\n"), 0644); err != nil {
+		return fmt.Errorf("не удалось записать %s: %w", mirrorMetaFile, err)
+	}
+	return nil
+}
+
+// CacheEntries возвращает зеркала в кэше, отсортированные по пути
+func (m *Manager) CacheEntries() ([]CacheEntry, error) {
+	pairDirs, err := readDirs(m.cacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать кэш %s: %w", m.cacheDir, err)
+	}
+
+	var entries []CacheEntry
+	for _, pairDir := range pairDirs {
+		mirrors, err := readDirs(pairDir)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось прочитать кэш %s: %w", pairDir, err)
+		}
+		for _, path := range mirrors {
+			if !strings.HasSuffix(path, mirrorSuffix) {
+				continue
+			}
+			entry, err := cacheEntry(path)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// readDirs возвращает отсортированные пути поддиректорий; для отсутствующей директории - пустой список
+func readDirs(dir string) ([]string, error) {
+	dirEntries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var dirs []string
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, dirEntry.Name()))
+		}
+	}
+	sort.Strings(dirs)
+	return dirs, nil
+}
+
+// cacheEntry читает метаданные и размер зеркала
+func cacheEntry(path string) (CacheEntry, error) {
+	entry := CacheEntry{Path: path}
+	metaPath := filepath.Join(path, mirrorMetaFile)
+	if info, err := os.Stat(metaPath); err == nil {
+		entry.LastUsed = info.ModTime()
+		if meta, err := os.ReadFile(metaPath); err == nil {
+			entry.URL = strings.TrimSpace(string(meta))
+		}
+	}
+	size, err := dirSize(path)
+	if err != nil {
+		return entry, fmt.Errorf("не удалось определить размер зеркала %s: %w", path, err)
+	}
+	entry.Size = size
+	return entry, nil
+}
+
+// PruneCache удаляет из кэша зеркала, путей которых нет в keep, поврежденные зеркала и, если unusedFor
+// больше нуля, зеркала, которые не обновлялись дольше unusedFor. Директории пар, в которых не осталось
+// зеркал, удаляются. Пары, занятые другим процессом, пропускаются с предупреждением в журнале.
+// При dryRun ничего не удаляется.
+// Возвращает удаленные (при dryRun - подлежащие удалению) зеркала.
+func (m *Manager) PruneCache(keep []string, unusedFor time.Duration, dryRun bool) ([]CacheEntry, error) {
+	kept := make(map[string]bool, len(keep))
+	for _, path := range keep {
+		kept[filepath.Clean(path)] = true
+	}
+	pairDirs, err := readDirs(m.cacheDir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать кэш %s: %w", m.cacheDir, err)
+	}
+
+	var pruned []CacheEntry
+	var errs []error
+	for _, pairDir := range pairDirs {
+		removed, err := m.prunePairDir(pairDir, kept, unusedFor, dryRun)
+		pruned = append(pruned, removed...)
+		if errors.Is(err, ErrLocked) {
+			// Пара синхронизируется другим процессом: ее зеркала используются
+			m.logger.Printf("Предупреждение: пропуск пары: %v", err)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return pruned, errors.Join(errs...)
+}
+
+// prunePairDir удаляет ненужные зеркала одной пары, удерживая блокировку ее директории
+func (m *Manager) prunePairDir(pairDir string, kept map[string]bool, unusedFor time.Duration, dryRun bool) ([]CacheEntry, error) {
+	lock, err := LockDir(pairDir)
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Unlock()
+
+	mirrors, err := readDirs(pairDir)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать кэш %s: %w", pairDir, err)
+	}
+	var pruned []CacheEntry
+	remaining := 0
+	for _, path := range mirrors {
+		entry, err := cacheEntry(path)
+		if err != nil {
+			// Зеркало, которое не удается прочитать, считается поврежденным
+			m.logger.Printf("Предупреждение: %v, зеркало будет удалено", err)
+			entry.URL = ""
+		}
+		stale := unusedFor > 0 && time.Since(entry.LastUsed) > unusedFor
+		if entry.URL != "" && kept[path] && !stale {
+			remaining++
+			continue
+		}
+		if !dryRun {
+			if err := os.RemoveAll(path); err != nil {
+				return pruned, fmt.Errorf("не удалось удалить зеркало %s: %w", path, err)
+			}
+		}
+		pruned = append(pruned, entry)
+	}
+	if remaining == 0 && !dryRun {
+		// Блокировка снимается удалением директории вместе с файлом блокировки
+		if err := os.RemoveAll(pairDir); err != nil {
+			return pruned, fmt.Errorf("не удалось удалить директорию %s: %w", pairDir, err)
+		}
+	}
+	return pruned, nil
+}
+
+// dirSize возвращает суммарный размер файлов в директории
+func dirSize(path string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(path, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
@@ -0,0 +1,234 @@
+package repository
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git-sync/configs"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestMirrorPath(t *testing.T) {
+	manager := NewManager("/tmp/test-git-sync")
+
+	pairA := PairID("git@gitlab.com:group-a/app.git", "git@github.com:org/app.git")
+	if same := PairID("ssh://git@gitlab.com/group-a/app", "https://github.com/org/app.git"); same == pairA {
+		t.Errorf("Пары с разными адресами приватного репозитория используют одну директорию %s", pairA)
+	}
+	if same := PairID("ssh://git@GitLab.com:22/group-a/app", "git@github.com:org/app"); same != pairA {
+		t.Errorf("Записи одних адресов должны давать одну директорию: %s и %s", pairA, same)
+	}
+	pairB := PairID("git@gitlab.com:group-b/app.git", "git@github.com:org/app.git")
+	if pairB == pairA {
+		t.Errorf("Одноименные репозитории разных групп используют одну директорию %s", pairA)
+	}
+	if !strings.HasPrefix(pairA, "app-") {
+		t.Errorf("Имя директории пары должно начинаться с имени репозитория: %s", pairA)
+	}
+
+	gitlabPath := manager.MirrorPath(pairA, "gitlab", "git@gitlab.com:group-a/app.git")
+	privatePath := manager.MirrorPath(pairA, "private", "git@github.com:org/app.git")
+	if gitlabPath == privatePath {
+		t.Errorf("Стороны пары используют одно зеркало %s", gitlabPath)
+	}
+	if expected := filepath.Join("/tmp/test-git-sync", "mirrors", pairA, "gitlab-app.git"); gitlabPath != expected {
+		t.Errorf("Ожидался путь %s, получен %s", expected, gitlabPath)
+	}
+
+	manager.SetCacheDir("/var/cache/git-sync")
+	if dir := manager.PairDir(pairA); dir != filepath.Join("/var/cache/git-sync", pairA) {
+		t.Errorf("Директория пары вне заданной директории кэша: %s", dir)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	barePath := newTestBareRepo(t)
+	manager := NewManager(t.TempDir())
+	mirrorPath := manager.MirrorPath("pair", "gitlab", barePath)
+
+	repo, err := manager.Mirror(context.Background(), barePath, mirrorPath, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Не удалось создать зеркало: %v", err)
+	}
+	if _, err := repo.Worktree(); err != git.ErrIsBareRepository {
+		t.Errorf("Зеркало должно быть bare-репозиторием, получено: %v", err)
+	}
+	feature, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", "feature"), true)
+	if err != nil {
+		t.Fatalf("Ветка feature отсутствует в зеркале: %v", err)
+	}
+
+	// Изменения remote получаются инкрементально, удаленные ветки удаляются из зеркала
+	remote, err := git.PlainOpen(barePath)
+	if err != nil {
+		t.Fatalf("Не удалось открыть remote: %v", err)
+	}
+	if err := remote.Storer.SetReference(plumbing.NewHashReference("refs/heads/release", feature.Hash())); err != nil {
+		t.Fatalf("Не удалось создать ветку: %v", err)
+	}
+	if err := remote.Storer.RemoveReference("refs/heads/feature"); err != nil {
+		t.Fatalf("Не удалось удалить ветку: %v", err)
+	}
+
+	repo, err = manager.Mirror(context.Background(), barePath, mirrorPath, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Не удалось обновить зеркало: %v", err)
+	}
+	if _, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", "release"), true); err != nil {
+		t.Errorf("Новая ветка release не получена: %v", err)
+	}
+	if _, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", "feature"), true); err == nil {
+		t.Error("Удаленная в remote ветка feature осталась в зеркале")
+	}
+
+	entries, err := manager.CacheEntries()
+	if err != nil {
+		t.Fatalf("Не удалось получить содержимое кэша: %v", err)
+	}
+	if len(entries) != 1 || entries[0].URL != configs.NormalizeURL(barePath) || entries[0].Size == 0 {
+		t.Errorf("Неверное содержимое кэша: %+v", entries)
+	}
+}
+
+func TestMirrorRebuildsCorrupted(t *testing.T) {
+	barePath := newTestBareRepo(t)
+	manager := NewManager(t.TempDir())
+	mirrorPath := manager.MirrorPath("pair", "gitlab", barePath)
+
+	repo, err := manager.Mirror(context.Background(), barePath, mirrorPath, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Не удалось создать зеркало: %v", err)
+	}
+	feature, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", "feature"), true)
+	if err != nil {
+		t.Fatalf("Ветка feature отсутствует в зеркале: %v", err)
+	}
+
+	// Ссылка на несуществующий объект
+	missing := plumbing.NewHash("0123456789abcdef0123456789abcdef01234567")
+	if err := repo.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/broken", missing)); err != nil {
+		t.Fatalf("Не удалось повредить зеркало: %v", err)
+	}
+
+	repo, err = manager.Mirror(context.Background(), barePath, mirrorPath, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Поврежденное зеркало не создано заново: %v", err)
+	}
+	if _, err := repo.Reference("refs/remotes/origin/broken", true); err == nil {
+		t.Error("Поврежденная ссылка осталась в зеркале")
+	}
+	if ref, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", "feature"), true); err != nil || ref.Hash() != feature.Hash() {
+		t.Errorf("Ветка feature не восстановлена: %v", err)
+	}
+}
+
+func TestPruneCache(t *testing.T) {
+	used := newTestBareRepo(t)
+	unused := newTestBareRepo(t)
+	manager := NewManager(t.TempDir())
+	usedPath := manager.MirrorPath("used", "gitlab", used)
+	unusedPath := manager.MirrorPath("unused", "gitlab", unused)
+
+	for repoURL, path := range map[string]string{used: usedPath, unused: unusedPath} {
+		if _, err := manager.Mirror(context.Background(), repoURL, path, configs.Credential{}); err != nil {
+			t.Fatalf("Не удалось создать зеркало: %v", err)
+		}
+	}
+	// Директория без метаданных - прерванное или поврежденное зеркало
+	broken := filepath.Join(manager.PairDir("used"), "private-broken.git")
+	if err := os.MkdirAll(broken, 0755); err != nil {
+		t.Fatalf("Не удалось создать директорию: %v", err)
+	}
+
+	pruned, err := manager.PruneCache([]string{usedPath}, 0, true)
+	if err != nil {
+		t.Fatalf("Ошибка очистки кэша: %v", err)
+	}
+	if len(pruned) != 2 {
+		t.Fatalf("Ожидалось 2 зеркала для удаления, получено %+v", pruned)
+	}
+	if _, err := os.Stat(unusedPath); err != nil {
+		t.Errorf("Dry-run удалил зеркало: %v", err)
+	}
+
+	// Занятая другим процессом пара пропускается без ошибки
+	lock, err := LockDir(manager.PairDir("unused"))
+	if err != nil {
+		t.Fatalf("Не удалось захватить директорию: %v", err)
+	}
+	if _, err := manager.PruneCache([]string{usedPath}, 0, false); err != nil {
+		t.Errorf("Занятая пара должна пропускаться, получено: %v", err)
+	}
+	if _, err := os.Stat(unusedPath); err != nil {
+		t.Errorf("Удалено зеркало занятой пары: %v", err)
+	}
+	lock.Unlock()
+
+	if _, err := manager.PruneCache([]string{usedPath}, 0, false); err != nil {
+		t.Fatalf("Ошибка очистки кэша: %v", err)
+	}
+	for _, path := range []string{manager.PairDir("unused"), broken} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s не удалено", path)
+		}
+	}
+	if _, err := os.Stat(usedPath); err != nil {
+		t.Errorf("Используемое зеркало удалено: %v", err)
+	}
+
+	// Зеркало, которое не обновлялось дольше срока, удаляется, даже если оно есть в конфигурации
+	old := time.Now().Add(-48 * time.Hour)
+	if err := os.Chtimes(filepath.Join(usedPath, mirrorMetaFile), old, old); err != nil {
+		t.Fatalf("Не удалось изменить время: %v", err)
+	}
+	pruned, err = manager.PruneCache([]string{usedPath}, 24*time.Hour, false)
+	if err != nil || len(pruned) != 1 {
+		t.Errorf("Ожидалось удаление устаревшего зеркала, получено %+v, %v", pruned, err)
+	}
+	if _, err := os.Stat(manager.PairDir("used")); !os.IsNotExist(err) {
+		t.Errorf("Пустая директория пары не удалена: %v", err)
+	}
+}
+
+// Тест, что зеркало, которое не удается прочитать, удаляется как поврежденное, а остальные зеркала пары
+// обрабатываются
+func TestPruneCacheUnreadableMirror(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("Права на директорию не ограничивают пользователя root")
+	}
+
+	repoURL := newTestBareRepo(t)
+	manager := NewManager(t.TempDir())
+	path := manager.MirrorPath("pair", "gitlab", repoURL)
+	if _, err := manager.Mirror(context.Background(), repoURL, path, configs.Credential{}); err != nil {
+		t.Fatalf("Не удалось создать зеркало: %v", err)
+	}
+	unreadable := filepath.Join(manager.PairDir("pair"), "private-unreadable.git")
+	if err := os.MkdirAll(filepath.Join(unreadable, "objects"), 0755); err != nil {
+		t.Fatalf("Не удалось создать директорию: %v", err)
+	}
+	if err := os.Chmod(filepath.Join(unreadable, "objects"), 0); err != nil {
+		t.Fatalf("Не удалось изменить права директории: %v", err)
+	}
+	t.Cleanup(func() { os.Chmod(filepath.Join(unreadable, "objects"), 0755) })
+
+	pruned, err := manager.PruneCache([]string{path, unreadable}, 0, false)
+	if err != nil {
+		t.Fatalf("Ошибка очистки кэша: %v", err)
+	}
+	if len(pruned) != 1 || pruned[0].Path != unreadable || pruned[0].URL != "" {
+		t.Errorf("Ожидалось удаление только нечитаемого зеркала, получено %+v", pruned)
+	}
+	if _, err := os.Stat(unreadable); !os.IsNotExist(err) {
+		t.Errorf("Нечитаемое зеркало не удалено: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Используемое зеркало удалено: %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"git-sync/configs"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// knownHostsMu защищает дописывание ключей в файлы known_hosts
+var knownHostsMu sync.Mutex
+
+// hostKeyCallback возвращает проверку ключей SSH-серверов для учетных данных. Файлы known_hosts
+// читаются при каждом подключении, поэтому ошибки чтения возвращаются при подключении, а ключи,
+// добавленные в режиме accept-new, сразу учитываются следующими подключениями и записываются в logger.
+func hostKeyCallback(cred configs.Credential, logger *log.Logger) gossh.HostKeyCallback {
+	if cred.HostKeyCheck == configs.HostKeyInsecure {
+		return gossh.InsecureIgnoreHostKey()
+	}
+	acceptNew := cred.HostKeyCheck == configs.HostKeyAcceptNew
+
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		files, err := knownHostsFiles(cred.KnownHosts)
+		if err != nil {
+			return err
+		}
+		if acceptNew {
+			// Новые ключи записываются в первый файл, поэтому он должен существовать
+			if err := ensureFile(files[0]); err != nil {
+				return fmt.Errorf("не удалось создать файл known_hosts: %w", err)
+			}
+		}
+
+		existing := existingFiles(files)
+		if len(existing) == 0 {
+			return fmt.Errorf("не найден файл known_hosts (%s): добавьте ключ сервера %s или задайте known_hosts", files[0], hostname)
+		}
+		check, err := knownhosts.New(existing...)
+		if err != nil {
+			return fmt.Errorf("не удалось прочитать known_hosts: %w", err)
+		}
+
+		err = check(hostname, remote, key)
+		var keyErr *knownhosts.KeyError
+		if err == nil || !errors.As(err, &keyErr) {
+			return err
+		}
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("ключ SSH-сервера %s не совпадает с known_hosts, возможна подмена сервера: %w", hostname, err)
+		}
+		// go-git перед подключением опрашивает проверку поддельным ключом, чтобы узнать алгоритмы
+		// известных ключей; такой ключ нельзя разобрать, и он не должен попасть в known_hosts
+		if _, parseErr := gossh.ParsePublicKey(key.Marshal()); !acceptNew || parseErr != nil {
+			return fmt.Errorf("ключ SSH-сервера %s отсутствует в known_hosts: %w", hostname, err)
+		}
+		return appendKnownHost(files[0], hostname, key, logger)
+	}
+}
+
+// knownHostsFiles возвращает файлы known_hosts: заданный в конфигурации, иначе $SSH_KNOWN_HOSTS
+// или ~/.ssh/known_hosts и /etc/ssh/ssh_known_hosts
+func knownHostsFiles(configured string) ([]string, error) {
+	if configured != "" {
+		return []string{configured}, nil
+	}
+	if env := filepath.SplitList(os.Getenv("SSH_KNOWN_HOSTS")); len(env) > 0 {
+		return env, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось определить путь к known_hosts: %w", err)
+	}
+	return []string{filepath.Join(home, ".ssh", "known_hosts"), "/etc/ssh/ssh_known_hosts"}, nil
+}
+
+// existingFiles возвращает файлы, которые существуют
+func existingFiles(files []string) []string {
+	var existing []string
+	for _, file := range files {
+		if _, err := os.Stat(file); err == nil {
+			existing = append(existing, file)
+		}
+	}
+	return existing
+}
+
+// ensureFile создает пустой файл с родительскими директориями, если его нет
+func ensureFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// appendKnownHost дописывает ключ сервера в файл known_hosts и сообщает об этом в logger
+func appendKnownHost(path, hostname string, key gossh.PublicKey, logger *log.Logger) error {
+	knownHostsMu.Lock()
+	defer knownHostsMu.Unlock()
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return fmt.Errorf("не удалось открыть known_hosts: %w", err)
+	}
+	defer f.Close()
+
+	line := knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)
+	if _, err := fmt.Fprintln(f, line); err != nil {
+		return fmt.Errorf("не удалось записать ключ в known_hosts: %w", err)
+	}
+	logger.Printf("Ключ SSH-сервера %s (%s) добавлен в %s", hostname, gossh.FingerprintSHA256(key), path)
+	return nil
+}
@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// lockFileName файл блокировки в директории пары
+const lockFileName = ".lock"
+
+// ErrLocked директория занята другим процессом
+var ErrLocked = errors.New("директория используется другим процессом")
+
+// Lock блокировка директории, которую удерживает текущий процесс
+type Lock struct {
+	path string
+}
+
+// LockDir захватывает директорию dir, создавая в ней файл блокировки с PID, именем хоста и временем захвата.
+// Если директория занята другим работающим процессом, возвращается ошибка ErrLocked. Блокировка процесса,
+// который завершился, не освободив ее, снимается автоматически, если процесс работал на этом же хосте.
+func LockDir(dir string) (*Lock, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("не удалось создать директорию %s: %w", dir, err)
+	}
+	path := filepath.Join(dir, lockFileName)
+	hostname, _ := os.Hostname()
+
+	for attempt := 0; attempt < 2; attempt++ {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			_, err = fmt.Fprintf(f, "%d %s %s\n", os.Getpid(), hostname, time.Now().UTC().Format(time.RFC3339))
+			if closeErr := f.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("не удалось записать файл блокировки %s: %w", path, err)
+			}
+			return &Lock{path: path}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("не удалось создать файл блокировки %s: %w", path, err)
+		}
+
+		holder, stale := lockHolder(path, hostname)
+		if !stale {
+			return nil, fmt.Errorf("%w: %s (%s)", ErrLocked, dir, holder)
+		}
+		log.Printf("Предупреждение: снята блокировка завершившегося процесса %s: %s", holder, path)
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, fmt.Errorf("не удалось удалить файл блокировки %s: %w", path, err)
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrLocked, dir)
+}
+
+// Unlock освобождает директорию
+func (l *Lock) Unlock() error {
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("не удалось удалить файл блокировки %s: %w", l.path, err)
+	}
+	return nil
+}
+
+// lockHolder возвращает описание владельца блокировки и признак того, что владелец завершился.
+// Блокировка процесса с другого хоста никогда не считается устаревшей.
+func lockHolder(path, hostname string) (string, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		// Файл удален владельцем между попытками - можно пробовать снова
+		return "файл блокировки недоступен", errors.Is(err, fs.ErrNotExist)
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 3 {
+		// Владелец еще записывает файл
+		return "владелец неизвестен", false
+	}
+	holder := fmt.Sprintf("PID %s на %s с %s", fields[0], fields[1], fields[2])
+	pid, err := strconv.Atoi(fields[0])
+	if err != nil || fields[1] != hostname {
+		return holder, false
+	}
+	return holder, !processAlive(pid)
+}
+
+// processAlive проверяет, работает ли процесс с указанным PID
+func processAlive(pid int) bool {
+	if pid == os.Getpid() {
+		return true
+	}
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	err = process.Signal(syscall.Signal(0))
+	return !errors.Is(err, os.ErrProcessDone) && !errors.Is(err, syscall.ESRCH)
+}
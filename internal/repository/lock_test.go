@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestLockDir(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pair")
+
+	lock, err := LockDir(dir)
+	if err != nil {
+		t.Fatalf("Не удалось захватить директорию: %v", err)
+	}
+	if _, err := LockDir(dir); !errors.Is(err, ErrLocked) {
+		t.Errorf("Ожидалась ошибка ErrLocked для занятой директории, получено: %v", err)
+	}
+	if err := lock.Unlock(); err != nil {
+		t.Fatalf("Не удалось освободить директорию: %v", err)
+	}
+
+	lock, err = LockDir(dir)
+	if err != nil {
+		t.Fatalf("Не удалось захватить освобожденную директорию: %v", err)
+	}
+	lock.Unlock()
+}
+
+func TestLockDirStale(t *testing.T) {
+	// PID процесса, который уже завершился
+	cmd := exec.Command("true")
+	if err := cmd.Run(); err != nil {
+		t.Skipf("Не удалось запустить процесс: %v", err)
+	}
+	deadPID := cmd.Process.Pid
+	hostname, _ := os.Hostname()
+
+	testCases := []struct {
+		name    string
+		content string
+		locked  bool
+	}{
+		{"DeadProcess", fmt.Sprintf("%d %s 2024-01-01T00:00:00Z\n", deadPID, hostname), false},
+		{"LiveProcess", fmt.Sprintf("%d %s 2024-01-01T00:00:00Z\n", os.Getpid(), hostname), true},
+		{"OtherHost", fmt.Sprintf("%d other-host.example.com 2024-01-01T00:00:00Z\n", deadPID), true},
+		{"Incomplete", "", true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, lockFileName), []byte(tc.content), 0644); err != nil {
+				t.Fatalf("Не удалось создать файл блокировки: %v", err)
+			}
+			lock, err := LockDir(dir)
+			if tc.locked {
+				if !errors.Is(err, ErrLocked) {
+					t.Errorf("Ожидалась ошибка ErrLocked, получено: %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Блокировка завершившегося процесса не снята: %v", err)
+			}
+			lock.Unlock()
+		})
+	}
+}
@@ -1,47 +1,165 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
 	"os"
 	"path/filepath"
+	"time"
+
+	"git-sync/configs"
 
 	"github.com/go-git/go-git/v5"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	gitssh "github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	gossh "golang.org/x/crypto/ssh"
 )
 
 // Manager управляет операциями с Git-репозиториями
 type Manager struct {
 	tempDir string
+	// cacheDir директория постоянного кэша bare-зеркал remote
+	cacheDir string
+	// auth строит метод аутентификации для каждого обращения к remote. nil - по учетным данным
+	auth AuthFunc
+	// logger журнал операций с зеркалами
+	logger *log.Logger
+	// timeout ограничение времени одной попытки сетевой операции: clone, fetch, pull или push. 0 - без ограничения
+	timeout time.Duration
+	// retryPolicy повторы сетевых операций после временных ошибок. По умолчанию повторов нет
+	retryPolicy RetryPolicy
 }
 
 // NewManager создает новый экземпляр Manager
 func NewManager(tempDir string) *Manager {
 	return &Manager{
-		tempDir: tempDir,
+		tempDir:  tempDir,
+		cacheDir: filepath.Join(tempDir, "mirrors"),
+		logger:   log.Default(),
+	}
+}
+
+// WithLogger возвращает копию Manager, которая пишет журнал в logger
+func (m *Manager) WithLogger(logger *log.Logger) *Manager {
+	copied := *m
+	copied.logger = logger
+	return &copied
+}
+
+// SetAuthFunc заменяет построение методов аутентификации, например, чтобы в тестах
+// проверить, с какими учетными данными выполняется обращение к каждому репозиторию.
+// nil возвращает выбор метода только по учетным данным.
+func (m *Manager) SetAuthFunc(auth AuthFunc) {
+	m.auth = auth
+}
+
+// SetOperationTimeout ограничивает время каждой попытки сетевой операции с remote. 0 снимает ограничение.
+func (m *Manager) SetOperationTimeout(timeout time.Duration) {
+	m.timeout = timeout
+}
+
+// SetRetryPolicy задает повторы сетевых операций после временных ошибок
+func (m *Manager) SetRetryPolicy(policy RetryPolicy) {
+	m.retryPolicy = policy
+}
+
+// Auth возвращает метод аутентификации для обращения к репозиторию repoURL с учетными данными cred.
+// Для SSH ограничение времени операции распространяется и на установку TCP-соединения.
+func (m *Manager) Auth(repoURL string, cred configs.Credential) (transport.AuthMethod, error) {
+	var auth transport.AuthMethod
+	var err error
+	if m.auth != nil {
+		auth, err = m.auth(repoURL, cred)
+	} else {
+		auth, err = authMethod(cred, m.logger)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if sshAuth, ok := auth.(gitssh.AuthMethod); ok && m.timeout > 0 {
+		return &dialTimeoutAuth{AuthMethod: sshAuth, timeout: m.timeout}, nil
+	}
+	return auth, nil
+}
+
+// dialTimeoutAuth метод аутентификации SSH с ограничением времени подключения: go-git не передает
+// контекст операции в подключение к SSH-серверу, поэтому без него недоступный хост блокирует операцию
+type dialTimeoutAuth struct {
+	gitssh.AuthMethod
+	timeout time.Duration
+}
+
+func (a *dialTimeoutAuth) ClientConfig() (*gossh.ClientConfig, error) {
+	cfg, err := a.AuthMethod.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	cfg.Timeout = a.timeout
+	return cfg, nil
+}
+
+// withTimeout ограничивает ctx временем одной сетевой операции
+func (m *Manager) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.timeout)
+}
+
+// contextError дополняет ошибку операции причиной отмены ctx, чтобы прерывание и превышение
+// времени можно было распознать через errors.Is: go-git не всегда возвращает ошибку контекста
+func contextError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil && !errors.Is(err, ctxErr) {
+		return fmt.Errorf("%w: %w", ctxErr, err)
+	}
+	return err
+}
+
+// originAuth возвращает адрес remote origin репозитория и метод аутентификации для него
+func (m *Manager) originAuth(repo *git.Repository, cred configs.Credential) (string, transport.AuthMethod, error) {
+	remote, err := repo.Remote("origin")
+	if err != nil {
+		return "", nil, fmt.Errorf("не удалось получить remote origin: %w", err)
 	}
+	originURL := remote.Config().URLs[0]
+	auth, err := m.Auth(originURL, cred)
+	return originURL, auth, err
 }
 
-// Clone клонирует репозиторий по URL в указанную директорию
-func (m *Manager) Clone(repoURL, path, token, sshKeyPath string) (*git.Repository, error) {
+// ignoreUpToDate считает отсутствие изменений успешным завершением операции
+func ignoreUpToDate(err error) error {
+	if err == git.NoErrAlreadyUpToDate {
+		return nil
+	}
+	return err
+}
+
+// Clone клонирует репозиторий по URL в указанную директорию. Если клонирование прервано
+// или завершилось ошибкой, частично записанная директория удаляется.
+func (m *Manager) Clone(ctx context.Context, repoURL, path string, cred configs.Credential) (*git.Repository, error) {
+	auth, err := m.Auth(repoURL, cred)
+	if err != nil {
+		return nil, err
+	}
+
 	cloneOptions := &git.CloneOptions{
-		URL: repoURL,
+		URL:  repoURL,
+		Auth: auth,
+		Tags: git.AllTags,
 	}
 
-	if token != "" {
-		cloneOptions.Auth = &http.BasicAuth{
-			Username: "oauth2", // Для GitLab Personal Access Token
-			Password: token,
-		}
-	} else if sshKeyPath != "" {
-		sshAuth, err := ssh.NewPublicKeysFromFile("git", sshKeyPath, "")
+	var repo *git.Repository
+	err = m.retry(ctx, "clone", repoURL, func(ctx context.Context) error {
+		repo, err = git.PlainCloneContext(ctx, path, false, cloneOptions)
 		if err != nil {
-			return nil, fmt.Errorf("не удалось создать SSH-аутентификацию: %w", err)
+			os.RemoveAll(path)
 		}
-		cloneOptions.Auth = sshAuth
-	}
-
-	repo, err := git.PlainClone(path, false, cloneOptions)
+		return err
+	})
 	if err != nil {
 		return nil, fmt.Errorf("не удалось клонировать репозиторий %s: %w", repoURL, err)
 	}
@@ -49,57 +167,125 @@ func (m *Manager) Clone(repoURL, path, token, sshKeyPath string) (*git.Repositor
 }
 
 // Pull обновляет репозиторий
-func (m *Manager) Pull(repo *git.Repository, token, sshKeyPath string) error {
+func (m *Manager) Pull(ctx context.Context, repo *git.Repository, cred configs.Credential) error {
 	w, err := repo.Worktree()
 	if err != nil {
 		return fmt.Errorf("не удалось получить Worktree: %w", err)
 	}
 
-	pullOptions := &git.PullOptions{}
-	if token != "" {
-		pullOptions.Auth = &http.BasicAuth{
-			Username: "oauth2",
-			Password: token,
-		}
-	} else if sshKeyPath != "" {
-		sshAuth, err := ssh.NewPublicKeysFromFile("git", sshKeyPath, "")
-		if err != nil {
-			return fmt.Errorf("не удалось создать SSH-аутентификацию: %w", err)
-		}
-		pullOptions.Auth = sshAuth
+	originURL, auth, err := m.originAuth(repo, cred)
+	if err != nil {
+		return err
 	}
 
-	err = w.Pull(pullOptions)
-	if err != nil && err != git.NoErrAlreadyUpToDate {
+	err = m.retry(ctx, "pull", originURL, func(ctx context.Context) error {
+		return ignoreUpToDate(w.PullContext(ctx, &git.PullOptions{Auth: auth}))
+	})
+	if err != nil {
 		return fmt.Errorf("не удалось выполнить pull: %w", err)
 	}
 	return nil
 }
 
 // Push отправляет изменения в удаленный репозиторий
-func (m *Manager) Push(repo *git.Repository, token, sshKeyPath string) error {
-	pushOptions := &git.PushOptions{}
-	if token != "" {
-		pushOptions.Auth = &http.BasicAuth{
-			Username: "oauth2",
-			Password: token,
-		}
-	} else if sshKeyPath != "" {
-		sshAuth, err := ssh.NewPublicKeysFromFile("git", sshKeyPath, "")
-		if err != nil {
-			return fmt.Errorf("не удалось создать SSH-аутентификацию: %w", err)
-		}
-		pushOptions.Auth = sshAuth
+func (m *Manager) Push(ctx context.Context, repo *git.Repository, cred configs.Credential) error {
+	originURL, auth, err := m.originAuth(repo, cred)
+	if err != nil {
+		return err
 	}
 
-	err := repo.Push(pushOptions)
-	if err != nil && err != git.NoErrAlreadyUpToDate {
+	err = m.retry(ctx, "push", originURL, func(ctx context.Context) error {
+		return ignoreUpToDate(repo.PushContext(ctx, &git.PushOptions{Auth: auth}))
+	})
+	if err != nil {
 		return fmt.Errorf("не удалось выполнить push: %w", err)
 	}
 	return nil
 }
 
-// CleanTempDir очищает временную директорию
+// FetchRemote получает в repo ссылки remote remoteName по refSpecs. Теги получаются только через refSpecs;
+// ссылки, которых больше нет в remote, удаляются.
+func (m *Manager) FetchRemote(ctx context.Context, repo *git.Repository, remoteName string, refSpecs []config.RefSpec, cred configs.Credential) error {
+	remote, err := repo.Remote(remoteName)
+	if err != nil {
+		return fmt.Errorf("не удалось получить remote %s: %w", remoteName, err)
+	}
+	remoteURL := remote.Config().URLs[0]
+	auth, err := m.Auth(remoteURL, cred)
+	if err != nil {
+		return err
+	}
+
+	err = m.retry(ctx, "fetch", remoteURL, func(ctx context.Context) error {
+		return ignoreUpToDate(remote.FetchContext(ctx, &git.FetchOptions{
+			RefSpecs: refSpecs,
+			Auth:     auth,
+			Tags:     git.NoTags,
+			Prune:    true,
+		}))
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось выполнить fetch из %s: %w", remoteURL, err)
+	}
+	return nil
+}
+
+// PushRefSpecs отправляет в remote origin ссылки по явно заданным refspec
+func (m *Manager) PushRefSpecs(ctx context.Context, repo *git.Repository, refSpecs []config.RefSpec, cred configs.Credential) error {
+	originURL, auth, err := m.originAuth(repo, cred)
+	if err != nil {
+		return err
+	}
+
+	err = m.retry(ctx, "push", originURL, func(ctx context.Context) error {
+		return ignoreUpToDate(repo.PushContext(ctx, &git.PushOptions{
+			RemoteName: "origin",
+			RefSpecs:   refSpecs,
+			Auth:       auth,
+		}))
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось выполнить push %v: %w", refSpecs, err)
+	}
+	return nil
+}
+
+// ForcePushWithLease перезаписывает ветку branch в remote origin коммитом hash, только если ветка
+// в remote все еще указывает на expected. Коммит записывается в локальную ветку с тем же именем:
+// go-git проверяет lease относительно refs/remotes/origin/<branch>, поэтому источником push
+// должна быть локальная ветка.
+func (m *Manager) ForcePushWithLease(ctx context.Context, repo *git.Repository, branch string, hash, expected plumbing.Hash, cred configs.Credential) error {
+	originURL, auth, err := m.originAuth(repo, cred)
+	if err != nil {
+		return err
+	}
+
+	ref := plumbing.NewBranchReferenceName(branch)
+	if err := repo.Storer.SetReference(plumbing.NewHashReference(ref, hash)); err != nil {
+		return fmt.Errorf("не удалось обновить локальную ветку %s: %w", branch, err)
+	}
+
+	err = m.retry(ctx, "push", originURL, func(ctx context.Context) error {
+		return ignoreUpToDate(repo.PushContext(ctx, &git.PushOptions{
+			RemoteName:     "origin",
+			RefSpecs:       []config.RefSpec{config.RefSpec(fmt.Sprintf("+%s:%s", ref, ref))},
+			Auth:           auth,
+			ForceWithLease: &git.ForceWithLease{RefName: ref, Hash: expected},
+		}))
+	})
+	if err != nil {
+		return fmt.Errorf("не удалось выполнить force push ветки %s: %w", branch, err)
+	}
+	return nil
+}
+
+// DeleteRef удаляет ссылку в remote origin явным refspec вида ":refs/heads/foo"
+func (m *Manager) DeleteRef(ctx context.Context, repo *git.Repository, ref plumbing.ReferenceName, cred configs.Credential) error {
+	return m.PushRefSpecs(ctx, repo, []config.RefSpec{config.RefSpec(":" + ref.String())}, cred)
+}
+
+// CleanTempDir удаляет временную директорию целиком, включая кэш зеркал всех пар, если он расположен в ней.
+// Синхронизация не использует этот метод: каждая пара работает только со своей директорией в кэше.
 func (m *Manager) CleanTempDir() error {
 	return os.RemoveAll(m.tempDir)
 }
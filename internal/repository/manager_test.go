@@ -1,9 +1,18 @@
 package repository
 
 import (
+	"context"
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
+
+	"git-sync/configs"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 func TestNewManager(t *testing.T) {
@@ -142,7 +151,7 @@ func TestCloneOptionsCreation(t *testing.T) {
 	t.Run("WithToken", func(t *testing.T) {
 		// Этот тест проверяет, что метод Clone не паникует при вызове с валидными параметрами
 		// Реальное клонирование требует существующего репозитория
-		_, err := manager.Clone("https://invalid-url.git", "/tmp/invalid-path", "test-token", "")
+		_, err := manager.Clone(context.Background(), "https://invalid-url.git", "/tmp/invalid-path", configs.Credential{Type: configs.CredentialToken, Secret: "test-token"})
 		// Ожидаем ошибку, так как URL невалидный, но не панику
 		if err == nil {
 			t.Error("Ожидалась ошибка при клонировании с невалидным URL")
@@ -151,7 +160,7 @@ func TestCloneOptionsCreation(t *testing.T) {
 
 	t.Run("WithSSHKey", func(t *testing.T) {
 		// Тест с SSH ключом (невалидный путь к ключу)
-		_, err := manager.Clone("git@invalid-host:user/repo.git", "/tmp/invalid-path", "", "/invalid/ssh/key/path")
+		_, err := manager.Clone(context.Background(), "git@invalid-host:user/repo.git", "/tmp/invalid-path", configs.Credential{Type: configs.CredentialSSHKey, KeyPath: "/invalid/ssh/key/path"})
 		// Ожидаем ошибку, так как SSH ключ не существует
 		if err == nil {
 			t.Error("Ожидалась ошибка при клонировании с невалидным SSH ключом")
@@ -160,7 +169,7 @@ func TestCloneOptionsCreation(t *testing.T) {
 
 	t.Run("WithoutAuth", func(t *testing.T) {
 		// Тест без аутентификации
-		_, err := manager.Clone("https://invalid-url.git", "/tmp/invalid-path", "", "")
+		_, err := manager.Clone(context.Background(), "https://invalid-url.git", "/tmp/invalid-path", configs.Credential{})
 		// Ожидаем ошибку, так как URL невалидный
 		if err == nil {
 			t.Error("Ожидалась ошибка при клонировании с невалидным URL")
@@ -177,3 +186,139 @@ func TestManagerStructure(t *testing.T) {
 		t.Errorf("Поле tempDir не установлено корректно: ожидалось '%s', получено '%s'", tempDir, manager.tempDir)
 	}
 }
+
+// newTestBareRepo создает bare репозиторий с ветками main и feature, возвращает путь к нему
+func newTestBareRepo(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	barePath := filepath.Join(dir, "remote.git")
+	if _, err := git.PlainInit(barePath, true); err != nil {
+		t.Fatalf("Не удалось создать bare репозиторий: %v", err)
+	}
+
+	workPath := filepath.Join(dir, "work")
+	work, err := git.PlainInit(workPath, false)
+	if err != nil {
+		t.Fatalf("Не удалось создать рабочий репозиторий: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(workPath, "README.md"), []byte("test"), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл: %v", err)
+	}
+	w, err := work.Worktree()
+	if err != nil {
+		t.Fatalf("Не удалось получить worktree: %v", err)
+	}
+	if _, err := w.Add("README.md"); err != nil {
+		t.Fatalf("Не удалось добавить файл: %v", err)
+	}
+	hash, err := w.Commit("initial", &git.CommitOptions{
+		Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+	})
+	if err != nil {
+		t.Fatalf("Не удалось создать коммит: %v", err)
+	}
+	if err := work.Storer.SetReference(plumbing.NewHashReference("refs/heads/feature", hash)); err != nil {
+		t.Fatalf("Не удалось создать ветку feature: %v", err)
+	}
+
+	if _, err := work.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{barePath}}); err != nil {
+		t.Fatalf("Не удалось создать remote: %v", err)
+	}
+	err = work.Push(&git.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/*:refs/heads/*"}})
+	if err != nil {
+		t.Fatalf("Не удалось выполнить push в bare репозиторий: %v", err)
+	}
+	return barePath
+}
+
+func TestDeleteRef(t *testing.T) {
+	barePath := newTestBareRepo(t)
+	manager := NewManager(t.TempDir())
+
+	repo, err := manager.Clone(context.Background(), barePath, manager.CreateTempRepoPath("clone"), configs.Credential{})
+	if err != nil {
+		t.Fatalf("Не удалось клонировать репозиторий: %v", err)
+	}
+
+	if err := manager.DeleteRef(context.Background(), repo, plumbing.NewBranchReferenceName("feature"), configs.Credential{}); err != nil {
+		t.Fatalf("DeleteRef вернул ошибку: %v", err)
+	}
+
+	remote, err := git.PlainOpen(barePath)
+	if err != nil {
+		t.Fatalf("Не удалось открыть bare репозиторий: %v", err)
+	}
+	if _, err := remote.Reference(plumbing.NewBranchReferenceName("feature"), false); err != plumbing.ErrReferenceNotFound {
+		t.Errorf("Ветка feature не удалена в remote: %v", err)
+	}
+	if _, err := remote.Reference(plumbing.NewBranchReferenceName("master"), false); err != nil {
+		t.Errorf("Ветка master не должна удаляться: %v", err)
+	}
+
+	// Ссылка удаленного отслеживания тоже удаляется
+	if _, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", "feature"), false); err != plumbing.ErrReferenceNotFound {
+		t.Errorf("Ссылка origin/feature не удалена в клоне: %v", err)
+	}
+}
+
+func TestForcePushWithLease(t *testing.T) {
+	barePath := newTestBareRepo(t)
+	manager := NewManager(t.TempDir())
+
+	repo, err := manager.Clone(context.Background(), barePath, manager.CreateTempRepoPath("clone"), configs.Credential{})
+	if err != nil {
+		t.Fatalf("Не удалось клонировать репозиторий: %v", err)
+	}
+	feature, err := repo.Reference(plumbing.NewRemoteReferenceName("origin", "feature"), true)
+	if err != nil {
+		t.Fatalf("Не удалось получить ветку origin/feature: %v", err)
+	}
+	current, err := repo.CommitObject(feature.Hash())
+	if err != nil {
+		t.Fatalf("Не удалось получить коммит: %v", err)
+	}
+
+	// Коммит без родителей: его push не является fast-forward
+	orphan := &object.Commit{
+		Author:    object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Committer: object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message:   "orphan",
+		TreeHash:  current.TreeHash,
+	}
+	obj := repo.Storer.NewEncodedObject()
+	if err := orphan.Encode(obj); err != nil {
+		t.Fatalf("Не удалось закодировать коммит: %v", err)
+	}
+	orphanHash, err := repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		t.Fatalf("Не удалось сохранить коммит: %v", err)
+	}
+
+	remoteFeature := func() plumbing.Hash {
+		remote, err := git.PlainOpen(barePath)
+		if err != nil {
+			t.Fatalf("Не удалось открыть bare репозиторий: %v", err)
+		}
+		ref, err := remote.Reference(plumbing.NewBranchReferenceName("feature"), false)
+		if err != nil {
+			t.Fatalf("Не удалось получить ветку feature: %v", err)
+		}
+		return ref.Hash()
+	}
+
+	// Ветка в remote не совпадает с ожидаемым значением — push отклоняется
+	if err := manager.ForcePushWithLease(context.Background(), repo, "feature", orphanHash, orphanHash, configs.Credential{}); err == nil {
+		t.Error("Ожидалась ошибка при нарушении lease")
+	}
+	if got := remoteFeature(); got != current.Hash {
+		t.Errorf("Ветка feature изменена при нарушении lease: %s", got)
+	}
+
+	if err := manager.ForcePushWithLease(context.Background(), repo, "feature", orphanHash, current.Hash, configs.Credential{}); err != nil {
+		t.Fatalf("ForcePushWithLease вернул ошибку: %v", err)
+	}
+	if got := remoteFeature(); got != orphanHash {
+		t.Errorf("Ожидалось, что ветка feature указывает на %s, получено %s", orphanHash, got)
+	}
+}
@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"git-sync/configs"
+)
+
+// RemoteURL разобранный адрес удаленного репозитория
+type RemoteURL struct {
+	// Scheme схема адреса в нижнем регистре: https, http, ssh, git или file. SCP-подобные адреса
+	// git@host:path имеют схему ssh.
+	Scheme string
+	// User имя пользователя из адреса, без пароля
+	User string
+	// Host имя хоста в нижнем регистре, без порта
+	Host string
+	// Port порт из адреса; пуст, если не указан
+	Port string
+	// BaseURL адрес веб-интерфейса экземпляра, например https://gitlab.example.com/gitlab. Для SSH-адресов
+	// схема https, порт SSH не переносится. Пуст для локальных путей.
+	BaseURL string
+	// PathWithNamespace полный путь проекта без относительного корня экземпляра и суффикса .git,
+	// например group/subgroup/project
+	PathWithNamespace string
+}
+
+// Namespace возвращает путь группы проекта, например group/subgroup
+func (r RemoteURL) Namespace() string {
+	if !strings.Contains(r.PathWithNamespace, "/") {
+		return ""
+	}
+	return path.Dir(r.PathWithNamespace)
+}
+
+// Name возвращает имя проекта - последний сегмент пути
+func (r RemoteURL) Name() string {
+	return path.Base(r.PathWithNamespace)
+}
+
+// ParseRemoteURL разбирает адрес репозитория: https://, http://, ssh://, git://, SCP-подобный
+// [user@]host:path, file:// или локальный путь. instanceURL - адрес экземпляра GitLab, в том числе
+// с относительным корнем (https://host/gitlab) или адрес его API (https://host/gitlab/api/v4); может быть пуст.
+// Экземпляр применяется только к адресам того же хоста: его относительный корень отбрасывается из пути
+// HTTP-адресов (в SSH-адресах его нет), а BaseURL становится адресом экземпляра.
+func ParseRemoteURL(repoURL, instanceURL string) (RemoteURL, error) {
+	raw := strings.TrimSpace(repoURL)
+	if raw == "" {
+		return RemoteURL{}, fmt.Errorf("пустой адрес репозитория")
+	}
+
+	var remote RemoteURL
+	switch {
+	case strings.Contains(raw, "://"):
+		u, err := url.Parse(raw)
+		if err != nil {
+			return RemoteURL{}, fmt.Errorf("не удалось разобрать адрес репозитория %s: %w", raw, err)
+		}
+		remote = RemoteURL{
+			Scheme: strings.ToLower(u.Scheme),
+			User:   u.User.Username(),
+			Host:   strings.ToLower(u.Hostname()),
+			Port:   u.Port(),
+		}
+		remote.PathWithNamespace = u.Path
+		if remote.Scheme == "file" {
+			remote.Host, remote.Port = "", ""
+			remote.PathWithNamespace = filepath.ToSlash(filepath.Clean(u.Path))
+		}
+	default:
+		if host, repoPath, ok := configs.SplitSCP(raw); ok {
+			remote = RemoteURL{Scheme: "ssh", Host: host, PathWithNamespace: repoPath}
+			if at := strings.LastIndex(raw[:strings.Index(raw, ":")], "@"); at >= 0 {
+				remote.User = raw[:at]
+			}
+		} else {
+			remote = RemoteURL{Scheme: "file", PathWithNamespace: filepath.ToSlash(filepath.Clean(raw))}
+		}
+	}
+
+	if remote.Scheme != "file" {
+		if remote.Host == "" {
+			return RemoteURL{}, fmt.Errorf("в адресе репозитория %s нет хоста", raw)
+		}
+		remote.PathWithNamespace = strings.Trim(configs.TrimRepoSuffix(strings.Trim(remote.PathWithNamespace, "/")), "/")
+		remote.BaseURL = remote.webURL()
+	} else {
+		remote.PathWithNamespace = configs.TrimRepoSuffix(remote.PathWithNamespace)
+	}
+
+	if instanceURL != "" && remote.Scheme != "file" {
+		if err := remote.applyInstance(instanceURL); err != nil {
+			return RemoteURL{}, err
+		}
+	}
+	if remote.PathWithNamespace == "" || remote.PathWithNamespace == "." {
+		return RemoteURL{}, fmt.Errorf("в адресе репозитория %s нет пути проекта", raw)
+	}
+	return remote, nil
+}
+
+// webURL возвращает адрес веб-интерфейса хоста адреса
+func (r RemoteURL) webURL() string {
+	if r.Scheme != "http" && r.Scheme != "https" {
+		return "https://" + r.Host
+	}
+	host := r.Host
+	if r.Port != "" && r.Port != configs.DefaultPort(r.Scheme) {
+		host += ":" + r.Port
+	}
+	return r.Scheme + "://" + host
+}
+
+// OnInstance сообщает, что репозиторий находится на экземпляре GitLab instanceURL: хосты адресов совпадают
+func (r RemoteURL) OnInstance(instanceURL string) bool {
+	u, err := url.Parse(strings.TrimSpace(instanceURL))
+	return err == nil && r.Scheme != "file" && r.Host != "" && strings.EqualFold(r.Host, u.Hostname())
+}
+
+// applyInstance задает BaseURL по адресу экземпляра и отбрасывает его относительный корень из пути.
+// Адрес другого хоста остается без изменений.
+func (r *RemoteURL) applyInstance(instanceURL string) error {
+	u, err := url.Parse(strings.TrimSpace(instanceURL))
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("некорректный адрес экземпляра GitLab %s", instanceURL)
+	}
+	if !r.OnInstance(instanceURL) {
+		return nil
+	}
+	root := strings.Trim(u.Path, "/")
+	root = strings.Trim(strings.TrimSuffix(root, "api/v4"), "/")
+
+	r.BaseURL = strings.ToLower(u.Scheme) + "://" + strings.ToLower(u.Host)
+	if root != "" {
+		r.BaseURL += "/" + root
+	}
+
+	if isHTTP := r.Scheme == "http" || r.Scheme == "https"; root != "" && isHTTP {
+		r.PathWithNamespace = strings.TrimPrefix(r.PathWithNamespace, root+"/")
+	}
+	return nil
+}
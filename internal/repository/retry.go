@@ -0,0 +1,256 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand"
+	"net"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// RetryPolicy правила повтора сетевых операций после временных ошибок
+type RetryPolicy struct {
+	// MaxAttempts наибольшее число попыток операции, включая первую. 0 и 1 - без повторов
+	MaxAttempts int
+	// InitialBackoff задержка перед первым повтором, каждая следующая удваивается
+	InitialBackoff time.Duration
+	// MaxBackoff наибольшая задержка между попытками
+	MaxBackoff time.Duration
+	// Jitter доля случайного отклонения задержки от 0 до 1, чтобы одновременные пары не повторяли запросы разом
+	Jitter float64
+}
+
+// backoff возвращает задержку перед повтором после неудачной попытки attempt (начиная с 1)
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	delay := p.InitialBackoff
+	for i := 1; i < attempt && (p.MaxBackoff <= 0 || delay < p.MaxBackoff); i++ {
+		delay *= 2
+	}
+	if p.MaxBackoff > 0 && delay > p.MaxBackoff {
+		delay = p.MaxBackoff
+	}
+	if p.Jitter > 0 {
+		delay += time.Duration((rand.Float64()*2 - 1) * p.Jitter * float64(delay))
+	}
+	return delay
+}
+
+// Retry сетевая операция, которая выполнилась не с первой попытки или исчерпала попытки
+type Retry struct {
+	// Operation вид операции: clone, fetch, pull или push
+	Operation string
+	URL       string
+	// Attempts число выполненных попыток
+	Attempts int
+	// Errors ошибки неудачных попыток по порядку
+	Errors []string
+	// Succeeded операция в итоге выполнена
+	Succeeded bool
+}
+
+// RetryLog собирает повторы операций, выполненных с контекстом из WithRetryLog.
+// Методы безопасны для одновременного вызова.
+type RetryLog struct {
+	mu      sync.Mutex
+	retries []Retry
+}
+
+// Retries возвращает записанные повторы
+func (l *RetryLog) Retries() []Retry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return append([]Retry(nil), l.retries...)
+}
+
+func (l *RetryLog) add(retry Retry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.retries = append(l.retries, retry)
+}
+
+// retryLogKey ключ RetryLog в контексте
+type retryLogKey struct{}
+
+// WithRetryLog возвращает контекст, в RetryLog которого операции Manager записывают свои повторы
+func WithRetryLog(ctx context.Context, log *RetryLog) context.Context {
+	return context.WithValue(ctx, retryLogKey{}, log)
+}
+
+// retry выполняет сетевую операцию op с remote repoURL, повторяя ее после временных ошибок по политике
+// Manager. Каждая попытка ограничена временем операции; задержка между попытками прерывается отменой ctx.
+func (m *Manager) retry(ctx context.Context, operation, repoURL string, op func(ctx context.Context) error) error {
+	record := Retry{Operation: operation, URL: repoURL}
+	var err error
+	for {
+		record.Attempts++
+		attemptCtx, cancel := m.withTimeout(ctx)
+		err = op(attemptCtx)
+		if err != nil {
+			err = contextError(attemptCtx, err)
+		}
+		cancel()
+
+		if err == nil || record.Attempts >= m.retryPolicy.MaxAttempts || ctx.Err() != nil || !IsRetryable(err) {
+			break
+		}
+		record.Errors = append(record.Errors, err.Error())
+		delay := m.retryPolicy.backoff(record.Attempts)
+		m.logger.Printf("Предупреждение: %s %s, попытка %d из %d не удалась: %v. Повтор через %s",
+			operation, repoURL, record.Attempts, m.retryPolicy.MaxAttempts, err, delay.Round(time.Millisecond))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if ctx.Err() != nil {
+			err = contextError(ctx, err)
+			break
+		}
+	}
+
+	if record.Attempts > 1 {
+		record.Succeeded = err == nil
+		if err != nil {
+			record.Errors = append(record.Errors, err.Error())
+		}
+		if log, ok := ctx.Value(retryLogKey{}).(*RetryLog); ok {
+			log.add(record)
+		}
+	}
+	return err
+}
+
+// IsRetryable сообщает, может ли повтор операции завершиться успешно: ошибка временная (превышено время,
+// сброс соединения, ответ сервера 5xx), а не постоянная (ошибка аутентификации, репозиторий не найден,
+// push отклонен). Неизвестные ошибки считаются постоянными.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+	chain := causes(err)
+	for _, cause := range chain {
+		if isPermanent(cause) {
+			return false
+		}
+	}
+	for _, cause := range chain {
+		if isTransient(cause) {
+			return true
+		}
+	}
+
+	// go-git и x/crypto/ssh часто передают ошибку соединения только текстом
+	message := strings.ToLower(err.Error())
+	for _, text := range permanentMessages {
+		if strings.Contains(message, text) {
+			return false
+		}
+	}
+	for _, text := range transientMessages {
+		if strings.Contains(message, text) {
+			return true
+		}
+	}
+	return false
+}
+
+// Фрагменты текста ошибок, по которым классифицируются ошибки без типа
+var (
+	permanentMessages = []string{
+		"unable to authenticate", "permission denied", "authentication required", "authorization failed",
+		"repository not found", "does not appear to be a git repository", "non-fast-forward", "rejected",
+		"stale info", "host key",
+	}
+	transientMessages = []string{
+		"connection reset", "connection refused", "broken pipe", "i/o timeout", "timed out",
+		"unexpected eof", "tls handshake timeout", "no route to host", "network is unreachable",
+		"temporarily unavailable", "status code: 5", "status code: 429",
+	}
+)
+
+// isPermanent проверяет ошибки, повтор которых не изменит результат
+func isPermanent(err error) bool {
+	var permanent *plumbing.PermanentError
+	var keyErr *knownhosts.KeyError
+	switch {
+	case errors.As(err, &permanent), errors.As(err, &keyErr):
+		return true
+	}
+	for _, target := range []error{
+		context.Canceled,
+		transport.ErrAuthenticationRequired, transport.ErrAuthorizationFailed, transport.ErrInvalidAuthMethod,
+		transport.ErrRepositoryNotFound, transport.ErrEmptyRemoteRepository,
+		git.ErrNonFastForwardUpdate, git.ErrForceNeeded, git.ErrExactSHA1NotSupported,
+		plumbing.ErrObjectNotFound, plumbing.ErrReferenceNotFound,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// isTransient проверяет ошибки сети и сервера, которые могут пройти при повторе
+func isTransient(err error) bool {
+	var httpErr *githttp.Err
+	if errors.As(err, &httpErr) {
+		code := httpErr.StatusCode()
+		return code >= 500 || code == 429
+	}
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return dnsErr.IsTimeout || dnsErr.IsTemporary
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	for _, target := range []error{
+		context.DeadlineExceeded, io.ErrUnexpectedEOF,
+		syscall.ECONNRESET, syscall.ECONNREFUSED, syscall.ECONNABORTED, syscall.EPIPE,
+		syscall.ETIMEDOUT, syscall.EHOSTUNREACH, syscall.ENETUNREACH,
+	} {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// causes возвращает err и все вложенные в нее ошибки, включая обертки go-git без метода Unwrap
+func causes(err error) []error {
+	var chain []error
+	queue := []error{err}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current == nil {
+			continue
+		}
+		chain = append(chain, current)
+
+		switch e := current.(type) {
+		case *plumbing.UnexpectedError:
+			queue = append(queue, e.Err)
+		case *plumbing.PermanentError:
+			queue = append(queue, e.Err)
+		case interface{ Unwrap() error }:
+			queue = append(queue, e.Unwrap())
+		case interface{ Unwrap() []error }:
+			queue = append(queue, e.Unwrap()...)
+		}
+	}
+	return chain
+}
@@ -0,0 +1,190 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"git-sync/configs"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"Nil", nil, false},
+		{"ConnectionReset", fmt.Errorf("push: %w", syscall.ECONNRESET), true},
+		{"ConnectionRefused", &plumbing.UnexpectedError{Err: syscall.ECONNREFUSED}, true},
+		{"UnexpectedEOF", io.ErrUnexpectedEOF, true},
+		{"OperationTimeout", fmt.Errorf("%w: %w", context.DeadlineExceeded, errors.New("read failed")), true},
+		{"ServerErrorText", errors.New("unexpected client error: unexpected requesting \"x\" status code: 503"), true},
+		{"Canceled", fmt.Errorf("%w: %w", context.Canceled, syscall.ECONNRESET), false},
+		{"Authentication", transport.ErrAuthenticationRequired, false},
+		{"AuthorizationWrapped", &plumbing.UnexpectedError{Err: transport.ErrAuthorizationFailed}, false},
+		{"NotFound", fmt.Errorf("fetch: %w", transport.ErrRepositoryNotFound), false},
+		{"NonFastForward", git.ErrNonFastForwardUpdate, false},
+		{"HostKey", &knownhosts.KeyError{}, false},
+		{"SSHAuthText", errors.New("ssh: handshake failed: ssh: unable to authenticate"), false},
+		{"Unknown", errors.New("something went wrong"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsRetryable(tt.err); got != tt.want {
+				t.Errorf("IsRetryable(%v) = %v, ожидалось %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+	for attempt, want := range []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second} {
+		if got := policy.backoff(attempt + 1); got != want {
+			t.Errorf("Задержка после попытки %d: %s, ожидалось %s", attempt+1, got, want)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if got := policy.backoff(2); got < time.Second || got > 3*time.Second {
+			t.Fatalf("Задержка с отклонением 0.5 вне диапазона: %s", got)
+		}
+	}
+}
+
+func TestManagerRetry(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	manager.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	t.Run("TransientThenSuccess", func(t *testing.T) {
+		retries := &RetryLog{}
+		calls := 0
+		err := manager.retry(WithRetryLog(context.Background(), retries), "push", "https://example.com/repo.git", func(ctx context.Context) error {
+			if calls++; calls == 1 {
+				return syscall.ECONNRESET
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("retry вернул ошибку: %v", err)
+		}
+		got := retries.Retries()
+		if calls != 2 || len(got) != 1 || got[0].Attempts != 2 || !got[0].Succeeded || len(got[0].Errors) != 1 {
+			t.Errorf("Неверная запись повторов после %d вызовов: %+v", calls, got)
+		}
+	})
+
+	t.Run("Permanent", func(t *testing.T) {
+		retries := &RetryLog{}
+		calls := 0
+		err := manager.retry(WithRetryLog(context.Background(), retries), "fetch", "https://example.com/repo.git", func(ctx context.Context) error {
+			calls++
+			return transport.ErrAuthenticationRequired
+		})
+		if !errors.Is(err, transport.ErrAuthenticationRequired) || calls != 1 {
+			t.Errorf("Постоянная ошибка повторена: вызовов %d, ошибка %v", calls, err)
+		}
+		if got := retries.Retries(); len(got) != 0 {
+			t.Errorf("Операция без повторов записана: %+v", got)
+		}
+	})
+
+	t.Run("Exhausted", func(t *testing.T) {
+		retries := &RetryLog{}
+		calls := 0
+		err := manager.retry(WithRetryLog(context.Background(), retries), "fetch", "https://example.com/repo.git", func(ctx context.Context) error {
+			calls++
+			return io.ErrUnexpectedEOF
+		})
+		if !errors.Is(err, io.ErrUnexpectedEOF) || calls != 3 {
+			t.Errorf("Ожидалось 3 попытки и последняя ошибка, получено %d и %v", calls, err)
+		}
+		got := retries.Retries()
+		if len(got) != 1 || got[0].Succeeded || len(got[0].Errors) != 3 {
+			t.Errorf("Неверная запись исчерпанных попыток: %+v", got)
+		}
+	})
+
+	t.Run("CanceledDuringBackoff", func(t *testing.T) {
+		slow := manager.WithLogger(manager.logger)
+		slow.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Hour})
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := slow.retry(ctx, "push", "https://example.com/repo.git", func(ctx context.Context) error {
+			calls++
+			cancel()
+			return syscall.ECONNRESET
+		})
+		if !errors.Is(err, context.Canceled) || calls != 1 {
+			t.Errorf("Ожидание повтора не прервано отменой: вызовов %d, ошибка %v", calls, err)
+		}
+	})
+}
+
+func TestCloneRetriesServerErrors(t *testing.T) {
+	manager := NewManager(t.TempDir())
+	manager.SetRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	tests := []struct {
+		name     string
+		status   int
+		attempts int32
+	}{
+		{"ServiceUnavailable", http.StatusServiceUnavailable, 3},
+		{"Unauthorized", http.StatusUnauthorized, 1},
+		{"NotFound", http.StatusNotFound, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			retries := &RetryLog{}
+			repoURL := server.URL + "/group/repo.git"
+			path := filepath.Join(t.TempDir(), "clone")
+			_, err := manager.Clone(WithRetryLog(context.Background(), retries), repoURL, path, configs.Credential{})
+			if err == nil {
+				t.Fatal("Clone не вернул ошибку")
+			}
+			if got := atomic.LoadInt32(&requests); got != tt.attempts {
+				t.Errorf("Ожидалось попыток: %d, получено %d (%v)", tt.attempts, got, err)
+			}
+
+			got := retries.Retries()
+			if tt.attempts == 1 {
+				if len(got) != 0 {
+					t.Errorf("Постоянная ошибка записана как повтор: %+v", got)
+				}
+				return
+			}
+			if len(got) != 1 || got[0].Operation != "clone" || got[0].URL != repoURL || int32(got[0].Attempts) != tt.attempts {
+				t.Errorf("Неверная запись повторов: %+v", got)
+			}
+			if !strings.Contains(got[0].Errors[0], "503") {
+				t.Errorf("В ошибке попытки нет кода ответа: %v", got[0].Errors)
+			}
+		})
+	}
+}
@@ -0,0 +1,342 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"git-sync/configs"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testSSHServer SSH-сервер, который выполняет команды git для одного пользователя с одним ключом
+type testSSHServer struct {
+	addr    string
+	hostKey gossh.Signer
+}
+
+// newTestSigner создает ключ ed25519 и возвращает его вместе с подписчиком
+func newTestSigner(t *testing.T) (ed25519.PrivateKey, gossh.Signer) {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Не удалось создать ключ: %v", err)
+	}
+	signer, err := gossh.NewSignerFromKey(key)
+	if err != nil {
+		t.Fatalf("Не удалось создать подписчика: %v", err)
+	}
+	return key, signer
+}
+
+// startTestSSHServer запускает SSH-сервер на 127.0.0.1, принимающий пользователя user с ключом clientKey
+func startTestSSHServer(t *testing.T, user string, clientKey gossh.PublicKey) *testSSHServer {
+	t.Helper()
+	if _, err := exec.LookPath("git-upload-pack"); err != nil {
+		t.Skip("git-upload-pack не найден")
+	}
+
+	_, hostKey := newTestSigner(t)
+	config := &gossh.ServerConfig{
+		PublicKeyCallback: func(conn gossh.ConnMetadata, key gossh.PublicKey) (*gossh.Permissions, error) {
+			if conn.User() == user && bytes.Equal(key.Marshal(), clientKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("доступ запрещен для %s", conn.User())
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Не удалось запустить SSH-сервер: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go serveSSHConn(conn, config)
+		}
+	}()
+	return &testSSHServer{addr: listener.Addr().String(), hostKey: hostKey}
+}
+
+// serveSSHConn обслуживает соединение: каждый канал session выполняет одну команду exec
+func serveSSHConn(conn net.Conn, config *gossh.ServerConfig) {
+	defer conn.Close()
+	_, channels, requests, err := gossh.NewServerConn(conn, config)
+	if err != nil {
+		return
+	}
+	go gossh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(gossh.UnknownChannelType, "поддерживается только session")
+			continue
+		}
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			return
+		}
+		go serveSession(channel, requests)
+	}
+}
+
+// serveSession выполняет команду из запроса exec и возвращает код ее завершения
+func serveSession(channel gossh.Channel, requests <-chan *gossh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "exec" || len(req.Payload) < 4 {
+			req.Reply(false, nil)
+			continue
+		}
+		command := string(req.Payload[4:])
+		req.Reply(true, nil)
+
+		cmd := exec.Command("sh", "-c", command)
+		cmd.Stdin = channel
+		cmd.Stdout = channel
+		cmd.Stderr = channel.Stderr()
+		status := uint32(0)
+		if err := cmd.Run(); err != nil {
+			status = 1
+		}
+		payload := make([]byte, 4)
+		binary.BigEndian.PutUint32(payload, status)
+		channel.SendRequest("exit-status", false, payload)
+		return
+	}
+}
+
+// writeEncryptedKey сохраняет ключ в формате OpenSSH, зашифрованный паролем passphrase
+func writeEncryptedKey(t *testing.T, key ed25519.PrivateKey, passphrase string) string {
+	t.Helper()
+
+	block, err := gossh.MarshalPrivateKeyWithPassphrase(key, "", []byte(passphrase))
+	if err != nil {
+		t.Fatalf("Не удалось закодировать ключ: %v", err)
+	}
+	keyPath := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(keyPath, pem.EncodeToMemory(block), 0600); err != nil {
+		t.Fatalf("Не удалось сохранить ключ: %v", err)
+	}
+	return keyPath
+}
+
+// cloneOverSSH клонирует репозиторий barePath с сервера в новую директорию
+func cloneOverSSH(t *testing.T, server *testSSHServer, user, barePath string, cred configs.Credential) error {
+	t.Helper()
+
+	manager := NewManager(t.TempDir())
+	repoURL := fmt.Sprintf("ssh://%s@%s%s", user, server.addr, barePath)
+	_, err := manager.Clone(context.Background(), repoURL, filepath.Join(manager.tempDir, "clone"), cred)
+	return err
+}
+
+func TestSSHKeyAuth(t *testing.T) {
+	barePath := newTestBareRepo(t)
+	key, signer := newTestSigner(t)
+	server := startTestSSHServer(t, "deploy", signer.PublicKey())
+	keyPath := writeEncryptedKey(t, key, "correct horse")
+	knownHosts := filepath.Join(t.TempDir(), "ssh", "known_hosts")
+
+	cred := configs.Credential{
+		Type:       configs.CredentialSSHKey,
+		Username:   "deploy",
+		KeyPath:    keyPath,
+		Passphrase: "correct horse",
+		KnownHosts: knownHosts,
+	}
+
+	t.Run("StrictUnknownHost", func(t *testing.T) {
+		strict := cred
+		strict.HostKeyCheck = configs.HostKeyStrict
+		if err := cloneOverSSH(t, server, "deploy", barePath, strict); err == nil {
+			t.Fatal("Ожидалась ошибка для сервера без known_hosts")
+		}
+	})
+
+	t.Run("AcceptNew", func(t *testing.T) {
+		acceptNew := cred
+		acceptNew.HostKeyCheck = configs.HostKeyAcceptNew
+		// Принятие ключа пишется в журнал пары, а не в глобальный
+		var logs bytes.Buffer
+		manager := NewManager(t.TempDir()).WithLogger(log.New(&logs, "[pair] ", 0))
+		repoURL := fmt.Sprintf("ssh://deploy@%s%s", server.addr, barePath)
+		if _, err := manager.Clone(context.Background(), repoURL, filepath.Join(manager.tempDir, "clone"), acceptNew); err != nil {
+			t.Fatalf("Не удалось клонировать: %v", err)
+		}
+		if !strings.Contains(logs.String(), "[pair] Ключ SSH-сервера") {
+			t.Errorf("Принятие ключа не записано в журнал пары: %q", logs.String())
+		}
+		data, err := os.ReadFile(knownHosts)
+		if err != nil {
+			t.Fatalf("Не удалось прочитать known_hosts: %v", err)
+		}
+		if lines := strings.Split(strings.TrimSpace(string(data)), "\n"); len(lines) != 1 {
+			t.Errorf("Ожидалась одна запись в known_hosts, получено:\n%s", data)
+		}
+		if !strings.Contains(string(data), strings.TrimSpace(string(gossh.MarshalAuthorizedKey(server.hostKey.PublicKey())))) {
+			t.Errorf("Ключ сервера не записан в known_hosts:\n%s", data)
+		}
+	})
+
+	t.Run("StrictKnownHost", func(t *testing.T) {
+		strict := cred
+		strict.HostKeyCheck = configs.HostKeyStrict
+		if err := cloneOverSSH(t, server, "deploy", barePath, strict); err != nil {
+			t.Fatalf("Не удалось клонировать с известным ключом сервера: %v", err)
+		}
+	})
+
+	t.Run("ChangedHostKey", func(t *testing.T) {
+		// В known_hosts для адреса другого сервера записан чужой ключ
+		other := startTestSSHServer(t, "deploy", signer.PublicKey())
+		changed := filepath.Join(t.TempDir(), "known_hosts")
+		line := knownhosts.Line([]string{knownhosts.Normalize(other.addr)}, server.hostKey.PublicKey())
+		if err := os.WriteFile(changed, []byte(line+"\n"), 0600); err != nil {
+			t.Fatalf("Не удалось записать known_hosts: %v", err)
+		}
+
+		acceptNew := cred
+		acceptNew.KnownHosts = changed
+		acceptNew.HostKeyCheck = configs.HostKeyAcceptNew
+		err := cloneOverSSH(t, other, "deploy", barePath, acceptNew)
+		if err == nil {
+			t.Fatal("Ожидалась ошибка для измененного ключа сервера")
+		}
+		if !strings.Contains(err.Error(), "не совпадает") {
+			t.Errorf("Ожидалась ошибка несовпадения ключа, получено: %v", err)
+		}
+	})
+
+	t.Run("Insecure", func(t *testing.T) {
+		insecure := cred
+		insecure.KnownHosts = filepath.Join(t.TempDir(), "missing")
+		insecure.HostKeyCheck = configs.HostKeyInsecure
+		if err := cloneOverSSH(t, server, "deploy", barePath, insecure); err != nil {
+			t.Fatalf("Не удалось клонировать без проверки ключа: %v", err)
+		}
+	})
+
+	t.Run("WrongUser", func(t *testing.T) {
+		wrongUser := cred
+		wrongUser.Username = "git"
+		if err := cloneOverSSH(t, server, "git", barePath, wrongUser); err == nil {
+			t.Fatal("Ожидалась ошибка для другого пользователя")
+		}
+	})
+
+	t.Run("MissingPassphrase", func(t *testing.T) {
+		noPassphrase := cred
+		noPassphrase.Passphrase = ""
+		if _, err := AuthMethod(noPassphrase); err == nil || !strings.Contains(err.Error(), "passphrase") {
+			t.Errorf("Ожидалась ошибка о пароле ключа, получено: %v", err)
+		}
+	})
+
+	t.Run("WrongPassphrase", func(t *testing.T) {
+		wrong := cred
+		wrong.Passphrase = "wrong"
+		if _, err := AuthMethod(wrong); err == nil {
+			t.Error("Ожидалась ошибка для неверного пароля")
+		}
+	})
+}
+
+func TestSSHAgentAuth(t *testing.T) {
+	barePath := newTestBareRepo(t)
+	key, signer := newTestSigner(t)
+	server := startTestSSHServer(t, "deploy", signer.PublicKey())
+
+	// SSH-агент с ключом на unix-сокете
+	keyring := agent.NewKeyring()
+	if err := keyring.Add(agent.AddedKey{PrivateKey: key}); err != nil {
+		t.Fatalf("Не удалось добавить ключ в агент: %v", err)
+	}
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Не удалось запустить SSH-агент: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+	t.Setenv("SSH_AUTH_SOCK", socket)
+
+	cred := configs.Credential{
+		Type:         configs.CredentialSSHAgent,
+		Username:     "deploy",
+		KnownHosts:   filepath.Join(t.TempDir(), "known_hosts"),
+		HostKeyCheck: configs.HostKeyAcceptNew,
+	}
+	if err := cloneOverSSH(t, server, "deploy", barePath, cred); err != nil {
+		t.Fatalf("Не удалось клонировать с ключом из агента: %v", err)
+	}
+}
+
+func TestSSHOperationTimeout(t *testing.T) {
+	key, signer := newTestSigner(t)
+	cred := configs.Credential{
+		Type:         configs.CredentialSSHKey,
+		Username:     "deploy",
+		KeyPath:      writeEncryptedKey(t, key, "passphrase"),
+		Passphrase:   "passphrase",
+		HostKeyCheck: configs.HostKeyInsecure,
+	}
+	const timeout = 300 * time.Millisecond
+	const hang = 20 * time.Second
+
+	// git-upload-pack на сервере не отвечает после подключения
+	bin := t.TempDir()
+	script := fmt.Sprintf("#!/bin/sh\nexec sleep %d\n", int(hang.Seconds()))
+	if err := os.WriteFile(filepath.Join(bin, "git-upload-pack"), []byte(script), 0755); err != nil {
+		t.Fatalf("Не удалось создать скрипт: %v", err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	server := startTestSSHServer(t, "deploy", signer.PublicKey())
+
+	manager := NewManager(t.TempDir())
+	manager.SetOperationTimeout(timeout)
+	path := filepath.Join(manager.tempDir, "clone")
+	start := time.Now()
+	_, err := manager.Clone(context.Background(), "ssh://deploy@"+server.addr+"/srv/git/repo.git", path, cred)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Ожидалась ошибка context.DeadlineExceeded, получено: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > hang/2 {
+		t.Errorf("Операция не прервана по времени: %s", elapsed)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Директория прерванного клонирования не удалена: %v", err)
+	}
+}
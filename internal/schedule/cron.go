@@ -0,0 +1,184 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron расписание в формате cron из пяти полей. Время вычисляется в часовом поясе аргумента Next.
+type Cron struct {
+	spec                              string
+	minute, hour, day, month, weekday uint64
+	// dayRestricted и weekdayRestricted означают, что поле задано не звездочкой: как и в cron,
+	// если заданы оба поля, достаточно совпадения любого из них
+	dayRestricted, weekdayRestricted bool
+}
+
+// cronField допустимый диапазон и имена значений поля cron-выражения
+type cronField struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var cronFields = []cronField{
+	{name: "минута", min: 0, max: 59},
+	{name: "час", min: 0, max: 23},
+	{name: "день месяца", min: 1, max: 31},
+	{name: "месяц", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}},
+	// 7 - воскресенье, как и 0
+	{name: "день недели", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}},
+}
+
+// cronMacros сокращения cron-выражений
+var cronMacros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// cronSearchLimit период, в котором ищется следующий запуск: за четыре года повторяются все дни, включая 29 февраля
+const cronSearchLimit = 4*366*24*time.Hour + 24*time.Hour
+
+// ParseCron разбирает cron-выражение. Поле может быть звездочкой, числом, именем (jan, mon),
+// диапазоном a-b, шагом */n или a-b/n и списком таких значений через запятую.
+func ParseCron(spec string) (*Cron, error) {
+	expr := strings.TrimSpace(spec)
+	if macro, ok := cronMacros[strings.ToLower(expr)]; ok {
+		expr = macro
+	}
+	fields := strings.Fields(expr)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("cron-выражение %q: ожидается %d полей, получено %d", spec, len(cronFields), len(fields))
+	}
+
+	c := &Cron{spec: strings.TrimSpace(spec)}
+	masks := []*uint64{&c.minute, &c.hour, &c.day, &c.month, &c.weekday}
+	for i, field := range fields {
+		mask, err := cronFields[i].parse(field)
+		if err != nil {
+			return nil, fmt.Errorf("cron-выражение %q: %w", spec, err)
+		}
+		*masks[i] = mask
+	}
+	// Воскресенье может быть задано как 7
+	if c.weekday&(1<<7) != 0 {
+		c.weekday = c.weekday&^(1<<7) | 1
+	}
+	c.dayRestricted = fields[2] != "*"
+	c.weekdayRestricted = fields[4] != "*"
+
+	if c.Next(time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)).IsZero() {
+		return nil, fmt.Errorf("cron-выражение %q никогда не срабатывает", spec)
+	}
+	return c, nil
+}
+
+// parse возвращает битовую маску значений поля
+func (f cronField) parse(field string) (uint64, error) {
+	var mask uint64
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("поле %s: неверный шаг %q", f.name, stepPart)
+			}
+		}
+
+		low, high := f.min, f.max
+		if rangePart != "*" {
+			lowPart, highPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = f.value(lowPart); err != nil {
+				return 0, err
+			}
+			high = low
+			if isRange {
+				if high, err = f.value(highPart); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				// a/n означает от a до конца диапазона с шагом n
+				high = f.max
+			}
+			if low > high {
+				return 0, fmt.Errorf("поле %s: неверный диапазон %q", f.name, rangePart)
+			}
+		}
+
+		for v := low; v <= high; v += step {
+			mask |= 1 << uint(v)
+		}
+	}
+	return mask, nil
+}
+
+// value разбирает одно значение поля: число или имя
+func (f cronField) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("поле %s: неверное значение %q", f.name, s)
+	}
+	if v < f.min || v > f.max {
+		return 0, fmt.Errorf("поле %s: значение %d вне диапазона %d-%d", f.name, v, f.min, f.max)
+	}
+	return v, nil
+}
+
+// Next возвращает первую минуту строго после after, подходящую под все поля выражения
+func (c *Cron) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := t.Add(cronSearchLimit)
+
+	for t.Before(limit) {
+		switch {
+		case !has(c.month, int(t.Month())):
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		case !c.dayMatches(t):
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		case !has(c.hour, t.Hour()):
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+		case !has(c.minute, t.Minute()):
+			t = t.Add(time.Minute)
+		default:
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// dayMatches проверяет день месяца и день недели по правилам cron
+func (c *Cron) dayMatches(t time.Time) bool {
+	day := has(c.day, t.Day())
+	weekday := has(c.weekday, int(t.Weekday()))
+	if c.dayRestricted && c.weekdayRestricted {
+		return day || weekday
+	}
+	return day && weekday
+}
+
+func (c *Cron) String() string {
+	return c.spec
+}
+
+// has проверяет, установлен ли в маске бит value
+func has(mask uint64, value int) bool {
+	return mask&(1<<uint(value)) != 0
+}
@@ -0,0 +1,50 @@
+package schedule
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Schedule расписание запусков синхронизации пары
+type Schedule interface {
+	// Next возвращает время первого запуска строго после after.
+	// Нулевое время означает, что расписание больше не срабатывает.
+	Next(after time.Time) time.Time
+	// String возвращает расписание в виде, заданном в конфигурации
+	String() string
+}
+
+// Interval запуск через равные промежутки времени
+type Interval time.Duration
+
+// Next возвращает after, сдвинутое на интервал
+func (i Interval) Next(after time.Time) time.Time {
+	return after.Add(time.Duration(i))
+}
+
+func (i Interval) String() string {
+	return time.Duration(i).String()
+}
+
+// Parse разбирает расписание: интервал в формате time.ParseDuration (например, 15m или 1h30m)
+// или cron-выражение из пяти полей (минута, час, день месяца, месяц, день недели), а также
+// сокращения @hourly, @daily, @weekly, @monthly и @yearly.
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, fmt.Errorf("пустое расписание")
+	}
+	if interval, err := time.ParseDuration(spec); err == nil {
+		if interval <= 0 {
+			return nil, fmt.Errorf("интервал %s должен быть положительным", spec)
+		}
+		return Interval(interval), nil
+	}
+
+	cron, err := ParseCron(spec)
+	if err != nil {
+		return nil, fmt.Errorf("ожидается интервал (например, 15m) или cron-выражение: %w", err)
+	}
+	return cron, nil
+}
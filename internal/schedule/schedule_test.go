@@ -0,0 +1,99 @@
+package schedule
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		spec    string
+		want    string
+		wantErr string
+	}{
+		{spec: "15m", want: "15m0s"},
+		{spec: " 1h30m ", want: "1h30m0s"},
+		{spec: "*/5 * * * *", want: "*/5 * * * *"},
+		{spec: "@daily", want: "@daily"},
+		{spec: "", wantErr: "пустое расписание"},
+		{spec: "-5m", wantErr: "должен быть положительным"},
+		{spec: "0s", wantErr: "должен быть положительным"},
+		{spec: "every hour", wantErr: "ожидается 5 полей"},
+		{spec: "60 * * * *", wantErr: "поле минута: значение 60 вне диапазона 0-59"},
+		{spec: "* * * foo *", wantErr: "поле месяц: неверное значение \"foo\""},
+		{spec: "*/0 * * * *", wantErr: "неверный шаг"},
+		{spec: "5-1 * * * *", wantErr: "неверный диапазон"},
+		{spec: "0 0 30 2 *", wantErr: "никогда не срабатывает"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			got, err := Parse(tt.spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Ожидалась ошибка %q, получено: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse вернул ошибку: %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Ожидалось расписание %q, получено %q", tt.want, got.String())
+			}
+		})
+	}
+}
+
+func TestIntervalNext(t *testing.T) {
+	start := time.Date(2024, 3, 1, 10, 7, 30, 0, time.UTC)
+	if got := Interval(15 * time.Minute).Next(start); !got.Equal(start.Add(15 * time.Minute)) {
+		t.Errorf("Неверный следующий запуск: %s", got)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	// 1 марта 2024 года - пятница
+	after := time.Date(2024, 3, 1, 10, 7, 30, 0, time.UTC)
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 1, 10, 8, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 1, 10, 15, 0, 0, time.UTC)},
+		{"7 * * * *", time.Date(2024, 3, 1, 11, 7, 0, 0, time.UTC)},
+		{"30 2 * * *", time.Date(2024, 3, 2, 2, 30, 0, 0, time.UTC)},
+		{"0 9-17/4 * * mon-fri", time.Date(2024, 3, 1, 13, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 jan,jul *", time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Заданы и день месяца, и день недели: достаточно совпадения одного из них
+		{"0 12 15 * sat", time.Date(2024, 3, 2, 12, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			cron, err := ParseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseCron вернул ошибку: %v", err)
+			}
+			if got := cron.Next(after); !got.Equal(tt.want) {
+				t.Errorf("Ожидался запуск %s, получено %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCronNextLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+3", 3*60*60)
+	cron, err := ParseCron("0 3 * * *")
+	if err != nil {
+		t.Fatalf("ParseCron вернул ошибку: %v", err)
+	}
+	got := cron.Next(time.Date(2024, 3, 1, 23, 0, 0, 0, time.UTC).In(loc))
+	if want := time.Date(2024, 3, 2, 3, 0, 0, 0, loc); !got.Equal(want) {
+		t.Errorf("Время должно вычисляться в поясе аргумента: ожидалось %s, получено %s", want, got)
+	}
+}
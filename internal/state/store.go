@@ -0,0 +1,101 @@
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// State состояние ссылок пары репозиториев на момент, когда обе стороны последний раз совпадали.
+// Используется как общая база при трехсторонней сверке веток и тегов.
+type State struct {
+	Branches  map[string]string `json:"branches"`
+	Tags      map[string]string `json:"tags"`
+	UpdatedAt time.Time         `json:"updated_at"`
+}
+
+// NewState создает пустое состояние
+func NewState() *State {
+	return &State{
+		Branches: make(map[string]string),
+		Tags:     make(map[string]string),
+	}
+}
+
+// Store хранит состояние синхронизации пар репозиториев в JSON-файлах внутри директории
+type Store struct {
+	dir string
+}
+
+// NewStore создает новый экземпляр Store
+func NewStore(dir string) *Store {
+	return &Store{
+		dir: dir,
+	}
+}
+
+// Path возвращает путь к файлу состояния пары с ключом key
+func (s *Store) Path(key string) string {
+	return filepath.Join(s.dir, key+".json")
+}
+
+// Load загружает состояние пары. Если файл состояния еще не создан, возвращается пустое состояние.
+func (s *Store) Load(key string) (*State, error) {
+	path := s.Path(key)
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return NewState(), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("не удалось прочитать файл состояния %s: %w", path, err)
+	}
+
+	st := NewState()
+	if err := json.Unmarshal(data, st); err != nil {
+		return nil, fmt.Errorf("не удалось распарсить файл состояния %s: %w", path, err)
+	}
+	if st.Branches == nil {
+		st.Branches = make(map[string]string)
+	}
+	if st.Tags == nil {
+		st.Tags = make(map[string]string)
+	}
+	return st, nil
+}
+
+// Save атомарно сохраняет состояние пары: данные пишутся во временный файл,
+// который затем переименовывается, поэтому прерванная запись не портит предыдущее состояние.
+func (s *Store) Save(key string, st *State) error {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return fmt.Errorf("не удалось создать директорию состояния %s: %w", s.dir, err)
+	}
+
+	st.UpdatedAt = time.Now().UTC()
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("не удалось сериализовать состояние: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(s.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("не удалось создать временный файл состояния: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("не удалось записать файл состояния: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("не удалось записать файл состояния: %w", err)
+	}
+
+	path := s.Path(key)
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("не удалось сохранить файл состояния %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package state
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadMissingState(t *testing.T) {
+	store := NewStore(t.TempDir())
+
+	st, err := store.Load("pair")
+	if err != nil {
+		t.Fatalf("Load для отсутствующего файла вернул ошибку: %v", err)
+	}
+
+	if len(st.Branches) != 0 || len(st.Tags) != 0 {
+		t.Errorf("Ожидалось пустое состояние, получено %+v", st)
+	}
+}
+
+func TestSaveAndLoad(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "state")
+	store := NewStore(dir)
+
+	st := NewState()
+	st.Branches["main"] = "1111111111111111111111111111111111111111"
+	st.Tags["v1.0"] = "2222222222222222222222222222222222222222"
+
+	if err := store.Save("pair", st); err != nil {
+		t.Fatalf("Save вернул ошибку: %v", err)
+	}
+
+	loaded, err := store.Load("pair")
+	if err != nil {
+		t.Fatalf("Load вернул ошибку: %v", err)
+	}
+
+	if loaded.Branches["main"] != st.Branches["main"] {
+		t.Errorf("Неверное состояние ветки main: %s", loaded.Branches["main"])
+	}
+	if loaded.Tags["v1.0"] != st.Tags["v1.0"] {
+		t.Errorf("Неверное состояние тега v1.0: %s", loaded.Tags["v1.0"])
+	}
+	if loaded.UpdatedAt.IsZero() {
+		t.Error("UpdatedAt не установлен")
+	}
+
+	// Временные файлы не должны оставаться в директории состояния
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("Не удалось прочитать директорию состояния: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("Ожидался один файл состояния, найдено %d", len(entries))
+	}
+}
+
+func TestLoadCorruptedState(t *testing.T) {
+	dir := t.TempDir()
+	store := NewStore(dir)
+
+	if err := os.WriteFile(store.Path("pair"), []byte("{not json"), 0644); err != nil {
+		t.Fatalf("Не удалось создать файл состояния: %v", err)
+	}
+
+	if _, err := store.Load("pair"); err == nil {
+		t.Error("Ожидалась ошибка при загрузке поврежденного файла состояния")
+	}
+}
@@ -0,0 +1,175 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"git-sync/configs"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+const (
+	// conflictBranchPrefix префикс веток, в которых сохраняется версия приватного репозитория
+	conflictBranchPrefix = "sync-conflict/"
+	// syncMergePrefix пространство локальных ссылок зеркала, из которых отправляются коммиты слияния
+	syncMergePrefix = "refs/sync-merge/"
+)
+
+// applyConflictStrategy применяет стратегию пары к разошедшимся веткам.
+// Конфликты тегов не разрешаются автоматически ни одной из стратегий.
+func applyConflictStrategy(decisions []Decision, pair configs.RepositoryPair) ([]Decision, error) {
+	strategy := pair.ConflictStrategy
+	switch strategy {
+	case "", configs.ConflictSkip, configs.ConflictPreferGitlab, configs.ConflictPreferPrivate,
+		configs.ConflictBranch, configs.ConflictMerge, configs.ConflictMergeRequest:
+	default:
+		return nil, fmt.Errorf("неизвестная стратегия разрешения конфликтов %q", strategy)
+	}
+	if strategy == configs.ConflictMergeRequest {
+		decisions = withoutMergeRequestBranches(decisions)
+	}
+
+	for i := range decisions {
+		d := &decisions[i]
+		if d.Kind != KindBranch || d.Action != ActionConflict {
+			continue
+		}
+
+		switch strategy {
+		case configs.ConflictPreferGitlab:
+			d.Action = ActionForce
+			d.Direction = DirectionToPrivate
+			d.Resolved = d.Gitlab
+		case configs.ConflictPreferPrivate:
+			d.Action = ActionForce
+			d.Direction = DirectionToGitlab
+			d.Resolved = d.Private
+		case configs.ConflictBranch:
+			d.Action = ActionConflictBranch
+		case configs.ConflictMerge:
+			if d.MergeBase.IsZero() {
+				// Без общего предка трехстороннее слияние невозможно
+				continue
+			}
+			d.Action = ActionMerge
+		case configs.ConflictMergeRequest:
+			d.Action = ActionMergeRequest
+		}
+	}
+	return decisions, nil
+}
+
+// conflictBranchName возвращает имя ветки для сохранения версии приватного репозитория
+func conflictBranchName(branch string, now time.Time) string {
+	return conflictBranchPrefix + branch + "/" + now.UTC().Format("2006-01-02")
+}
+
+// existingConflictBranch ищет среди веток ранее созданную ветку конфликта для branch, указывающую на hash
+func existingConflictBranch(branches map[string]plumbing.Hash, branch string, hash plumbing.Hash) string {
+	prefix := conflictBranchPrefix + branch + "/"
+	for _, name := range sortedRefNames(branches) {
+		if strings.HasPrefix(name, prefix) && branches[name] == hash {
+			return name
+		}
+	}
+	return ""
+}
+
+// applyConflictBranch сохраняет версию приватного репозитория в ветке sync-conflict/<branch>/<date>
+// на обеих сторонах. Сама ветка остается без изменений, пока конфликт не будет разрешен вручную.
+func (l *Logic) applyConflictBranch(ctx context.Context, d *Decision, gitlabSide, privateSide *side) (RefResult, error) {
+	refResult := RefResult{Name: d.Name, GitlabHash: d.Gitlab, PrivateHash: d.Private, Status: StatusConflictBranch}
+
+	name, exists := l.conflictBranch(d, gitlabSide, privateSide)
+	if exists {
+		refResult.Message = fmt.Sprintf("%s, версия Private уже сохранена в ветке %s", d.Reason, name)
+		l.logger.Printf("Предупреждение: конфликт, ветка %s: %s", d.Name, refResult.Message)
+		return refResult, nil
+	}
+
+	dest := plumbing.NewBranchReferenceName(name)
+	pushes := []struct {
+		target *side
+		source plumbing.ReferenceName
+	}{
+		{gitlabSide, plumbing.NewRemoteReferenceName(syncRemoteName, d.Name)},
+		{privateSide, plumbing.NewRemoteReferenceName("origin", d.Name)},
+	}
+	for _, p := range pushes {
+		refSpec := gitconfig.RefSpec(fmt.Sprintf("%s:%s", p.source, dest))
+		if err := l.repoManager.PushRefSpecs(ctx, p.target.repo, []gitconfig.RefSpec{refSpec}, p.target.cred); err != nil {
+			return refResult, fmt.Errorf("не удалось создать ветку конфликта %s: %w", name, err)
+		}
+	}
+
+	refResult.Message = fmt.Sprintf("%s, версия Private сохранена в ветке %s", d.Reason, name)
+	l.logger.Printf("Предупреждение: конфликт, ветка %s: %s", d.Name, refResult.Message)
+	return refResult, nil
+}
+
+// conflictBranch возвращает имя ветки конфликта для решения и признак того, что версия Private
+// уже сохранена в этой ветке. Если имя на текущую дату занято другой версией, к нему добавляется номер.
+func (l *Logic) conflictBranch(d *Decision, gitlabSide, privateSide *side) (string, bool) {
+	if existing := existingConflictBranch(gitlabSide.branches, d.Name, d.Private); existing != "" {
+		return existing, true
+	}
+
+	base := conflictBranchName(d.Name, l.now())
+	name := base
+	for i := 2; branchExists(name, gitlabSide, privateSide); i++ {
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+	return name, false
+}
+
+// branchExists проверяет, есть ли ветка хотя бы на одной из сторон
+func branchExists(name string, sides ...*side) bool {
+	for _, s := range sides {
+		if _, ok := s.branches[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// applyMerge создает коммит слияния разошедшихся веток и отправляет его на обе стороны.
+// Если одни и те же файлы изменены на обеих сторонах, решение заменяется конфликтом.
+func (l *Logic) applyMerge(ctx context.Context, d *Decision, gitlabSide, privateSide *side) (RefResult, error) {
+	refResult := RefResult{Name: d.Name, GitlabHash: d.Gitlab, PrivateHash: d.Private}
+
+	// Приватное зеркало содержит историю обеих сторон, объекты слияния сохраняются в оба зеркала
+	hash, conflicts, err := createMergeCommit([]*git.Repository{privateSide.repo, gitlabSide.repo}, *d, l.now())
+	if err != nil {
+		return refResult, fmt.Errorf("не удалось создать коммит слияния ветки %s: %w", d.Name, err)
+	}
+	if len(conflicts) > 0 {
+		d.Action = ActionConflict
+		d.Reason = fmt.Sprintf("%s, слияние невозможно: конфликтующие файлы %s", d.Reason, strings.Join(conflicts, ", "))
+		l.logger.Printf("Предупреждение: конфликт, ветка %s: %s. Ссылка не будет изменена.", d.Name, d.Reason)
+		refResult.Status = StatusConflict
+		refResult.Message = d.Reason
+		return refResult, nil
+	}
+
+	source := plumbing.ReferenceName(syncMergePrefix + d.Name)
+	refSpec := gitconfig.RefSpec(fmt.Sprintf("%s:%s", source, plumbing.NewBranchReferenceName(d.Name)))
+	for _, target := range []*side{gitlabSide, privateSide} {
+		if err := target.repo.Storer.SetReference(plumbing.NewHashReference(source, hash)); err != nil {
+			return refResult, fmt.Errorf("не удалось сохранить коммит слияния ветки %s: %w", d.Name, err)
+		}
+		if err := l.repoManager.PushRefSpecs(ctx, target.repo, []gitconfig.RefSpec{refSpec}, target.cred); err != nil {
+			return refResult, fmt.Errorf("не удалось отправить коммит слияния ветки %s: %w", d.Name, err)
+		}
+	}
+
+	d.Resolved = hash
+	refResult.Status = StatusMerged
+	refResult.Message = fmt.Sprintf("%s, создан коммит слияния %s", d.Reason, hash.String()[:7])
+	l.logger.Printf("Ветка %s: %s", d.Name, refResult.Message)
+	return refResult, nil
+}
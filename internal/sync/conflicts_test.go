@@ -0,0 +1,159 @@
+package sync
+
+import (
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"git-sync/configs"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestApplyConflictStrategy(t *testing.T) {
+	a1, b1, root := testHash("a1"), testHash("b1"), testHash("root")
+	newDecisions := func() []Decision {
+		return []Decision{
+			{Kind: KindBranch, Name: "main", Gitlab: a1, Private: b1, MergeBase: root, Action: ActionConflict},
+			{Kind: KindBranch, Name: "orphan", Gitlab: a1, Private: testHash("orphan"), Action: ActionConflict},
+			{Kind: KindTag, Name: "v1.0", Gitlab: a1, Private: b1, Action: ActionConflict},
+		}
+	}
+
+	testCases := []struct {
+		strategy  string
+		expected  []Action
+		direction string
+		resolved  plumbing.Hash
+	}{
+		{"", []Action{ActionConflict, ActionConflict, ActionConflict}, "", plumbing.ZeroHash},
+		{configs.ConflictSkip, []Action{ActionConflict, ActionConflict, ActionConflict}, "", plumbing.ZeroHash},
+		{configs.ConflictPreferGitlab, []Action{ActionForce, ActionForce, ActionConflict}, DirectionToPrivate, a1},
+		{configs.ConflictPreferPrivate, []Action{ActionForce, ActionForce, ActionConflict}, DirectionToGitlab, b1},
+		{configs.ConflictBranch, []Action{ActionConflictBranch, ActionConflictBranch, ActionConflict}, "", plumbing.ZeroHash},
+		{configs.ConflictMerge, []Action{ActionMerge, ActionConflict, ActionConflict}, "", plumbing.ZeroHash},
+		{configs.ConflictMergeRequest, []Action{ActionMergeRequest, ActionMergeRequest, ActionConflict}, "", plumbing.ZeroHash},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.strategy, func(t *testing.T) {
+			decisions, err := applyConflictStrategy(newDecisions(), configs.RepositoryPair{ConflictStrategy: tc.strategy})
+			if err != nil {
+				t.Fatalf("applyConflictStrategy вернул ошибку: %v", err)
+			}
+			for i, d := range decisions {
+				if d.Action != tc.expected[i] {
+					t.Errorf("%s: ожидалось действие %s, получено %s", d.Name, tc.expected[i], d.Action)
+				}
+			}
+			if decisions[0].Direction != tc.direction {
+				t.Errorf("Ожидалось направление %q, получено %q", tc.direction, decisions[0].Direction)
+			}
+			if decisions[0].Resolved != tc.resolved {
+				t.Errorf("Ожидалось итоговое значение %s, получено %s", tc.resolved, decisions[0].Resolved)
+			}
+		})
+	}
+
+	// Ветки merge request существуют только в GitLab и не синхронизируются. Ветка sync/... без
+	// ветки назначения или существующая в приватном репозитории синхронизируется как обычная.
+	withSource := append(newDecisions(),
+		Decision{Kind: KindBranch, Name: "sync/main", Gitlab: b1, Action: ActionCreate, Direction: DirectionToPrivate},
+		Decision{Kind: KindBranch, Name: "sync/release", Gitlab: b1, Action: ActionCreate, Direction: DirectionToPrivate},
+		Decision{Kind: KindBranch, Name: "sync/orphan", Private: b1, Action: ActionCreate, Direction: DirectionToGitlab},
+	)
+	decisions, err := applyConflictStrategy(withSource, configs.RepositoryPair{ConflictStrategy: configs.ConflictMergeRequest})
+	if err != nil {
+		t.Fatalf("applyConflictStrategy вернул ошибку: %v", err)
+	}
+	var names []string
+	for _, d := range decisions {
+		names = append(names, d.Name)
+	}
+	if want := []string{"main", "orphan", "v1.0", "sync/release", "sync/orphan"}; !slices.Equal(names, want) {
+		t.Errorf("Из сверки должна быть исключена только ветка sync/main: %v", names)
+	}
+
+	if _, err := applyConflictStrategy(newDecisions(), configs.RepositoryPair{ConflictStrategy: "rebase"}); err == nil {
+		t.Error("Ожидалась ошибка для неизвестной стратегии")
+	}
+}
+
+func TestConflictBranchName(t *testing.T) {
+	now := time.Date(2024, 3, 5, 23, 30, 0, 0, time.FixedZone("UTC+3", 3*60*60))
+	if got := conflictBranchName("feature/x", now); got != "sync-conflict/feature/x/2024-03-05" {
+		t.Errorf("Неверное имя ветки конфликта: %s", got)
+	}
+
+	branches := map[string]plumbing.Hash{
+		"sync-conflict/main/2024-03-01":      testHash("a1"),
+		"sync-conflict/main-2/2024-03-02":    testHash("b1"),
+		"sync-conflict/main/2024-03-04":      testHash("b1"),
+		"sync-conflict/feature/x/2024-03-05": testHash("b1"),
+	}
+	if got := existingConflictBranch(branches, "main", testHash("b1")); got != "sync-conflict/main/2024-03-04" {
+		t.Errorf("Ожидалась существующая ветка sync-conflict/main/2024-03-04, получено %q", got)
+	}
+	if got := existingConflictBranch(branches, "main", testHash("a2")); got != "" {
+		t.Errorf("Не ожидалась существующая ветка конфликта, получено %q", got)
+	}
+}
+
+func TestMergeFiles(t *testing.T) {
+	entry := func(content string) object.TreeEntry {
+		return object.TreeEntry{Mode: filemode.Regular, Hash: plumbing.ComputeHash(plumbing.BlobObject, []byte(content))}
+	}
+
+	base := map[string]object.TreeEntry{
+		"same.txt":     entry("same"),
+		"gitlab.txt":   entry("gitlab"),
+		"private.txt":  entry("private"),
+		"both.txt":     entry("both"),
+		"removed.txt":  entry("removed"),
+		"conflict.txt": entry("conflict"),
+	}
+	gitlab := map[string]object.TreeEntry{
+		"same.txt":     entry("same"),
+		"gitlab.txt":   entry("gitlab-2"),
+		"private.txt":  entry("private"),
+		"both.txt":     entry("both-2"),
+		"conflict.txt": entry("conflict-gitlab"),
+		"dir/new.txt":  entry("new"),
+	}
+	private := map[string]object.TreeEntry{
+		"same.txt":     entry("same"),
+		"gitlab.txt":   entry("gitlab"),
+		"private.txt":  entry("private-2"),
+		"both.txt":     entry("both-2"),
+		"conflict.txt": entry("conflict-private"),
+		"removed.txt":  entry("removed"),
+	}
+
+	merged, conflicts := mergeFiles(base, gitlab, private)
+
+	if !reflect.DeepEqual(conflicts, []string{"conflict.txt"}) {
+		t.Errorf("Ожидался конфликт только в conflict.txt, получено %v", conflicts)
+	}
+	expected := map[string]object.TreeEntry{
+		"same.txt":    entry("same"),
+		"gitlab.txt":  entry("gitlab-2"),
+		"private.txt": entry("private-2"),
+		"both.txt":    entry("both-2"),
+		"dir/new.txt": entry("new"),
+	}
+	delete(merged, "conflict.txt")
+	if !reflect.DeepEqual(merged, expected) {
+		t.Errorf("Неверный результат слияния: %v", merged)
+	}
+
+	// Файл на одной стороне совпадает по имени с директорией на другой
+	_, conflicts = mergeFiles(nil,
+		map[string]object.TreeEntry{"docs": entry("file")},
+		map[string]object.TreeEntry{"docs/readme.md": entry("readme")})
+	if !reflect.DeepEqual(conflicts, []string{"docs"}) {
+		t.Errorf("Ожидался конфликт файла и директории docs, получено %v", conflicts)
+	}
+}
@@ -0,0 +1,53 @@
+package sync
+
+import (
+	"fmt"
+	"path"
+
+	"git-sync/configs"
+)
+
+// applyDeletionPolicy применяет настройки пары к решениям об удалении ссылок.
+// Если распространение удалений выключено или ссылка защищена, удаление заменяется пропуском,
+// а в режиме dry-run удаление только сообщается.
+func applyDeletionPolicy(decisions []Decision, pair configs.RepositoryPair) ([]Decision, error) {
+	for i := range decisions {
+		d := &decisions[i]
+		if d.Action != ActionDelete {
+			continue
+		}
+
+		protected, err := isProtectedRef(d.Name, pair.ProtectedRefs)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case !pair.PropagateDeletions:
+			d.Action = ActionSkip
+			d.Direction = ""
+			d.Reason += ", распространение удалений выключено"
+		case protected:
+			d.Action = ActionSkip
+			d.Direction = ""
+			d.Reason += ", ссылка защищена от удаления"
+		case pair.DeletionsDryRun:
+			d.DryRun = true
+		}
+	}
+	return decisions, nil
+}
+
+// isProtectedRef проверяет, совпадает ли имя ветки или тега с одним из защищенных шаблонов
+func isProtectedRef(name string, patterns []string) (bool, error) {
+	for _, pattern := range patterns {
+		matched, err := path.Match(pattern, name)
+		if err != nil {
+			return false, fmt.Errorf("неверный шаблон защищенной ссылки %q: %w", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,70 @@
+package sync
+
+import (
+	"testing"
+
+	"git-sync/configs"
+)
+
+func TestApplyDeletionPolicy(t *testing.T) {
+	newDecisions := func() []Decision {
+		return []Decision{
+			{Kind: KindBranch, Name: "feature", Action: ActionDelete, Direction: DirectionToPrivate, Reason: "удалена в GitLab"},
+			{Kind: KindBranch, Name: "release/1.0", Action: ActionDelete, Direction: DirectionToPrivate, Reason: "удалена в GitLab"},
+			{Kind: KindBranch, Name: "main", Action: ActionFastForward, Direction: DirectionToGitlab},
+		}
+	}
+
+	testCases := []struct {
+		name     string
+		pair     configs.RepositoryPair
+		expected []Action
+		dryRun   []bool
+	}{
+		{
+			name:     "Disabled",
+			pair:     configs.RepositoryPair{ProtectedRefs: []string{"release/*"}},
+			expected: []Action{ActionSkip, ActionSkip, ActionFastForward},
+			dryRun:   []bool{false, false, false},
+		},
+		{
+			name:     "EnabledWithProtected",
+			pair:     configs.RepositoryPair{PropagateDeletions: true, ProtectedRefs: []string{"release/*"}},
+			expected: []Action{ActionDelete, ActionSkip, ActionFastForward},
+			dryRun:   []bool{false, false, false},
+		},
+		{
+			name:     "DryRun",
+			pair:     configs.RepositoryPair{PropagateDeletions: true, DeletionsDryRun: true},
+			expected: []Action{ActionDelete, ActionDelete, ActionFastForward},
+			dryRun:   []bool{true, true, false},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			decisions, err := applyDeletionPolicy(newDecisions(), tc.pair)
+			if err != nil {
+				t.Fatalf("applyDeletionPolicy вернул ошибку: %v", err)
+			}
+			for i, d := range decisions {
+				if d.Action != tc.expected[i] {
+					t.Errorf("%s: ожидалось действие %s, получено %s", d.Name, tc.expected[i], d.Action)
+				}
+				if d.DryRun != tc.dryRun[i] {
+					t.Errorf("%s: ожидался DryRun %v, получено %v", d.Name, tc.dryRun[i], d.DryRun)
+				}
+				if d.Action == ActionSkip && d.Direction != "" {
+					t.Errorf("%s: у пропущенной ссылки не должно быть направления", d.Name)
+				}
+			}
+		})
+	}
+
+	t.Run("InvalidPattern", func(t *testing.T) {
+		pair := configs.RepositoryPair{PropagateDeletions: true, ProtectedRefs: []string{"release/["}}
+		if _, err := applyDeletionPolicy(newDecisions(), pair); err == nil {
+			t.Error("Ожидалась ошибка для неверного шаблона защищенной ссылки")
+		}
+	})
+}
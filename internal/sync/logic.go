@@ -1,260 +1,579 @@
 package sync
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
+	"os"
+	"sort"
 	"strings"
+	"time"
 
+	"git-sync/configs"
+	"git-sync/internal/gitlab"
 	"git-sync/internal/repository"
+	"git-sync/internal/state"
 
 	"github.com/go-git/go-git/v5"
 	gitconfig "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
-	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 )
 
 // Logic содержит логику синхронизации репозиториев
 type Logic struct {
 	repoManager *repository.Manager
+	stateStore  *state.Store
+	// gitlabAPI создает клиент GitLab API для проверки защиты веток; nil - защита не проверяется
+	gitlabAPI GitlabAPIFactory
+	// logger журнал синхронизации; при параллельной синхронизации у каждой пары свой префикс
+	logger *log.Logger
+	// now возвращает текущее время для имен веток конфликтов и коммитов слияния
+	now func() time.Time
 }
 
 // NewLogic создает новый экземпляр Logic
 func NewLogic(repoManager *repository.Manager) *Logic {
 	return &Logic{
 		repoManager: repoManager,
+		logger:      log.Default(),
+		now:         time.Now,
 	}
 }
 
-// Synchronize выполняет двустороннюю синхронизацию между двумя репозиториями
-func (l *Logic) Synchronize(gitlabURL, privateRepoURL, gitlabToken, sshKeyPath string) error {
-	gitlabRepoName := getRepoNameFromURL(gitlabURL)
-	privateRepoName := getRepoNameFromURL(privateRepoURL)
+// WithLogger возвращает копию Logic, которая пишет журнал в logger. Копия использует то же
+// хранилище состояния и кэш зеркал, поэтому несколько копий могут синхронизировать разные пары одновременно.
+func (l *Logic) WithLogger(logger *log.Logger) *Logic {
+	copied := *l
+	copied.logger = logger
+	copied.repoManager = l.repoManager.WithLogger(logger)
+	return &copied
+}
 
-	gitlabLocalPath := l.repoManager.CreateTempRepoPath(gitlabRepoName)
-	privateLocalPath := l.repoManager.CreateTempRepoPath(privateRepoName)
+// SetStateStore задает хранилище состояния синхронизации. Без него каждая синхронизация
+// выполняется без общей базы: ссылки, которых нет на одной из сторон, всегда создаются заново.
+func (l *Logic) SetStateStore(store *state.Store) {
+	l.stateStore = store
+}
+
+// side одна из сторон синхронизации: bare-зеркало из кэша и доступ к его remote origin.
+// В зеркало каждой стороны получены ветки и теги другой стороны, поэтому из него можно выполнить push.
+type side struct {
+	repo     *git.Repository
+	cred     configs.Credential
+	branches map[string]plumbing.Hash
+	tags     map[string]plumbing.Hash
+	// protection правила защиты веток GitLab; заполняется только для стороны GitLab, если они проверялись
+	protection *protection
+	// api клиент GitLab API и mergeRequests шаблоны merge request; заполняются только для стороны GitLab,
+	// если они нужны решениям
+	api           gitlab.API
+	mergeRequests *mergeRequestTemplates
+}
 
+// Synchronize выполняет двустороннюю синхронизацию между двумя репозиториями
+// и возвращает итог синхронизации веток и тегов.
+// Отмена ctx прерывает получение ссылок, а на этапе отправки останавливает синхронизацию перед следующей
+// ссылкой: уже начатый push завершается (в пределах ограничения времени операции), чтобы стороны не остались
+// в промежуточном состоянии. Состояние прерванной синхронизации не сохраняется.
+func (l *Logic) Synchronize(ctx context.Context, pair configs.RepositoryPair, gitlabCred, privateCred configs.Credential) (*Result, error) {
+	result := &Result{GitlabURL: pair.GitlabURL, PrivateRepoURL: pair.PrivateRepoURL}
+	start := l.now()
+	retries := &repository.RetryLog{}
+	ctx = repository.WithRetryLog(ctx, retries)
 	defer func() {
-		log.Printf("Очистка временных директорий: %s, %s", gitlabLocalPath, privateLocalPath)
-		if err := l.repoManager.CleanTempDir(); err != nil {
-			log.Printf("Ошибка очистки временной директории: %v", err)
-		}
+		result.Duration = l.now().Sub(start)
+		result.Retries = retries.Retries()
 	}()
 
-	// Клонирование/обновление GitLab репозитория
-	log.Printf("Клонирование/обновление GitLab репозитория: %s в %s", gitlabURL, gitlabLocalPath)
-	gitlabRepo, err := l.repoManager.Clone(gitlabURL, gitlabLocalPath, gitlabToken, "")
+	unlock, err := l.lockPair(pair)
 	if err != nil {
-		return fmt.Errorf("не удалось клонировать/обновить GitLab репозиторий: %w", err)
-	}
-	if err := l.repoManager.Pull(gitlabRepo, gitlabToken, ""); err != nil {
-		log.Printf("Предупреждение: не удалось выполнить pull для GitLab репозитория: %v", err)
+		return result, err
 	}
+	defer unlock()
 
-	// Клонирование/обновление приватного репозитория
-	log.Printf("Клонирование/обновление приватного репозитория: %s в %s", privateRepoURL, privateLocalPath)
-	privateRepo, err := l.repoManager.Clone(privateRepoURL, privateLocalPath, "", sshKeyPath)
+	prepared, err := l.prepare(ctx, pair, gitlabCred, privateCred)
 	if err != nil {
-		return fmt.Errorf("не удалось клонировать/обновить приватный репозиторий: %w", err)
+		return result, err
 	}
-	if err := l.repoManager.Pull(privateRepo, "", sshKeyPath); err != nil {
-		log.Printf("Предупреждение: не удалось выполнить pull для приватного репозитория: %v", err)
+	defer l.cleanup(prepared)
+
+	pushCtx := context.WithoutCancel(ctx)
+	decisions := prepared.decisions
+	for i := range decisions {
+		if err := ctx.Err(); err != nil {
+			return result, fmt.Errorf("синхронизация прервана: %w", err)
+		}
+		decision := &decisions[i]
+		refResult, err := l.apply(pushCtx, decision, prepared.gitlab, prepared.private)
+		if err != nil {
+			return result, err
+		}
+		if decision.Kind == KindTag {
+			result.Tags = append(result.Tags, refResult)
+		} else {
+			result.Branches = append(result.Branches, refResult)
+		}
+	}
+
+	if l.stateStore != nil {
+		if err := l.stateStore.Save(prepared.stateKey, nextState(prepared.base, decisions)); err != nil {
+			return result, err
+		}
 	}
 
-	// Синхронизация GitLab -> Private
-	log.Printf("Синхронизация GitLab -> Private для %s", gitlabURL)
-	if err := l.syncBranches(gitlabRepo, privateRepo, gitlabToken, sshKeyPath); err != nil {
-		return fmt.Errorf("ошибка синхронизации GitLab -> Private: %w", err)
+	return result, nil
+}
+
+// Status сверяет ветки и теги пары репозиториев и возвращает решения, которые приняла бы синхронизация.
+// Репозитории и сохраненное состояние не изменяются.
+func (l *Logic) Status(ctx context.Context, pair configs.RepositoryPair, gitlabCred, privateCred configs.Credential) ([]Decision, error) {
+	unlock, err := l.lockPair(pair)
+	if err != nil {
+		return nil, err
 	}
+	defer unlock()
 
-	// Синхронизация Private -> GitLab
-	log.Printf("Синхронизация Private -> GitLab для %s", privateRepoURL)
-	if err := l.syncBranches(privateRepo, gitlabRepo, "", gitlabToken); err != nil {
-		return fmt.Errorf("ошибка синхронизации Private -> GitLab: %w", err)
+	prepared, err := l.prepare(ctx, pair, gitlabCred, privateCred)
+	if err != nil {
+		return nil, err
 	}
+	return prepared.decisions, nil
+}
 
-	return nil
+// prepared подготовленная синхронизация пары: зеркала обеих сторон, база и решения сверки
+type prepared struct {
+	gitlab    *side
+	private   *side
+	base      *state.State
+	stateKey  string
+	decisions []Decision
 }
 
-// syncBranches синхронизирует ветки между source и destination репозиториями
-func (l *Logic) syncBranches(sourceRepo, destinationRepo *git.Repository, sourceToken, destToken string) error {
-	// Получаем remote для source репозитория
-	sourceRemote, err := sourceRepo.Remote("origin")
+// lockPair захватывает директорию зеркал пары, чтобы другой экземпляр сервиса не использовал ее одновременно.
+// Возвращает функцию, освобождающую директорию.
+func (l *Logic) lockPair(pair configs.RepositoryPair) (func(), error) {
+	pairDir := l.repoManager.PairDir(repository.PairID(pair.GitlabURL, pair.PrivateRepoURL))
+	lock, err := repository.LockDir(pairDir)
 	if err != nil {
-		return fmt.Errorf("не удалось получить remote 'origin' для source репозитория: %w", err)
+		return nil, fmt.Errorf("не удалось захватить директорию пары: %w", err)
 	}
+	return func() {
+		if err := lock.Unlock(); err != nil {
+			l.logger.Printf("Ошибка освобождения директории пары: %v", err)
+		}
+	}, nil
+}
 
-	// Получаем URL source репозитория
-	sourceURL := sourceRemote.Config().URLs[0]
-	log.Printf("Source URL: %s", sourceURL)
+// cleanup удаляет из зеркал пары локальные ссылки, через которые отправлялись изменения:
+// ветки force push и коммиты слияния. Зеркала и полученные ссылки сохраняются для следующего запуска.
+func (l *Logic) cleanup(p *prepared) {
+	for _, s := range []*side{p.gitlab, p.private} {
+		for _, prefix := range []string{"refs/heads/", syncMergePrefix} {
+			refs, err := refsWithPrefix(s.repo, prefix)
+			if err != nil {
+				l.logger.Printf("Ошибка очистки ссылок зеркала: %v", err)
+				continue
+			}
+			for name := range refs {
+				if err := s.repo.Storer.RemoveReference(plumbing.ReferenceName(prefix + name)); err != nil {
+					l.logger.Printf("Ошибка очистки ссылки %s%s: %v", prefix, name, err)
+				}
+			}
+		}
+	}
+}
+
+// prepare обновляет зеркала обеих сторон, получает в каждое зеркало ссылки другой стороны,
+// сверяет ветки и теги с базой и применяет к решениям настройки пары
+func (l *Logic) prepare(ctx context.Context, pair configs.RepositoryPair, gitlabCred, privateCred configs.Credential) (*prepared, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("обработка пары прервана: %w", err)
+	}
+	gitlabURL, privateRepoURL := pair.GitlabURL, pair.PrivateRepoURL
+	gitlabMirrorPath, privateMirrorPath := l.mirrorPaths(pair)
 
-	// Получаем метод аутентификации для source репозитория
-	sourceAuth, err := l.getAuthMethod(sourceToken, "")
+	// Зеркала хранятся в кэше между запусками, поэтому получаются только новые объекты
+	l.logger.Printf("Обновление зеркала GitLab репозитория: %s в %s", gitlabURL, gitlabMirrorPath)
+	gitlabRepo, err := l.repoManager.Mirror(ctx, gitlabURL, gitlabMirrorPath, gitlabCred)
 	if err != nil {
-		return fmt.Errorf("не удалось получить метод аутентификации для source: %w", err)
+		return nil, fmt.Errorf("не удалось обновить зеркало GitLab репозитория: %w", err)
 	}
 
-	// Получаем метод аутентификации для destination репозитория
-	destAuth, err := l.getAuthMethod(destToken, "")
+	l.logger.Printf("Обновление зеркала приватного репозитория: %s в %s", privateRepoURL, privateMirrorPath)
+	privateRepo, err := l.repoManager.Mirror(ctx, privateRepoURL, privateMirrorPath, privateCred)
 	if err != nil {
-		return fmt.Errorf("не удалось получить метод аутентификации для destination: %w", err)
+		return nil, fmt.Errorf("не удалось обновить зеркало приватного репозитория: %w", err)
 	}
 
-	// Добавляем source репозиторий как remote в destination репозиторий
-	remoteName := "sync-source"
-	_, err = destinationRepo.CreateRemote(&gitconfig.RemoteConfig{
-		Name: remoteName,
-		URLs: []string{sourceURL},
-	})
-	if err != nil && err != git.ErrRemoteExists {
-		return fmt.Errorf("не удалось создать remote %s: %w", remoteName, err)
+	// Каждая сторона получает ветки и теги другой стороны, чтобы иметь все объекты для push и сравнения истории
+	l.logger.Printf("Получение веток и тегов GitLab репозитория %s в приватный репозиторий", gitlabURL)
+	if err := l.fetchSource(ctx, privateRepo, gitlabURL, gitlabCred); err != nil {
+		return nil, fmt.Errorf("ошибка получения ссылок GitLab репозитория: %w", err)
+	}
+	l.logger.Printf("Получение веток и тегов приватного репозитория %s в GitLab репозиторий", privateRepoURL)
+	if err := l.fetchSource(ctx, gitlabRepo, privateRepoURL, privateCred); err != nil {
+		return nil, fmt.Errorf("ошибка получения ссылок приватного репозитория: %w", err)
 	}
 
-	// Получаем remote для fetch
-	syncRemote, err := destinationRepo.Remote(remoteName)
+	gitlabSide, err := newSide(gitlabRepo, gitlabCred)
 	if err != nil {
-		return fmt.Errorf("не удалось получить remote %s: %w", remoteName, err)
+		return nil, fmt.Errorf("не удалось получить ссылки GitLab репозитория: %w", err)
+	}
+	privateSide, err := newSide(privateRepo, privateCred)
+	if err != nil {
+		return nil, fmt.Errorf("не удалось получить ссылки приватного репозитория: %w", err)
 	}
 
-	// Выполняем fetch из source репозитория
-	log.Printf("Выполняем fetch из source репозитория")
-	err = syncRemote.Fetch(&git.FetchOptions{
-		Auth: sourceAuth,
-	})
-	if err != nil && err != git.NoErrAlreadyUpToDate {
-		return fmt.Errorf("не удалось выполнить fetch из source репозитория: %w", err)
+	// Состояние и зеркала пары хранятся под одним ключом, поэтому косметическое изменение адреса
+	// (суффикс .git, завершающий слеш, порт по умолчанию) не теряет базу сверки
+	stateKey := repository.PairID(gitlabURL, privateRepoURL)
+	base, err := l.loadState(stateKey, pair)
+	if err != nil {
+		return nil, err
 	}
 
-	// Получаем ветки из source репозитория
-	sourceBranches, err := sourceRepo.Branches()
+	// Сравнение истории выполняется в приватном зеркале: в нем есть объекты обеих сторон
+	decisions, err := reconcile(base, gitlabSide, privateSide, repoHistory{repo: privateRepo})
+	if err != nil {
+		return nil, fmt.Errorf("ошибка сверки веток и тегов: %w", err)
+	}
+	decisions, err = applyDeletionPolicy(decisions, pair)
 	if err != nil {
-		return fmt.Errorf("не удалось получить ветки из source репозитория: %w", err)
+		return nil, err
 	}
+	decisions, err = applyConflictStrategy(decisions, pair)
+	if err != nil {
+		return nil, err
+	}
+	if l.gitlabAPI != nil && (pushesToGitlab(decisions) || opensMergeRequests(decisions)) {
+		gitlabSide.api, err = l.gitlabAPI(ctx, pair, gitlabCred)
+		if err != nil {
+			l.logger.Printf("Предупреждение: GitLab API недоступен: %v", err)
+		}
+	}
+	// Правила защиты проверяются до push: GitLab отклоняет запрещенные изменения с общей ошибкой pre-receive
+	gitlabSide.protection, err = loadProtection(ctx, gitlabSide.api, pair, decisions)
+	if err != nil {
+		l.logger.Printf("Предупреждение: не удалось получить правила защиты веток GitLab, защита не проверяется: %v", err)
+	} else if gitlabSide.protection != nil {
+		decisions = applyProtection(decisions, gitlabSide.protection)
+	}
+	if opensMergeRequests(decisions) {
+		gitlabSide.mergeRequests, err = newMergeRequestTemplates(pair)
+		if err != nil {
+			return nil, err
+		}
+		if gitlabSide.api == nil {
+			decisions = withoutGitlabAPI(decisions)
+		}
+	}
+
+	return &prepared{
+		gitlab:    gitlabSide,
+		private:   privateSide,
+		base:      base,
+		stateKey:  stateKey,
+		decisions: decisions,
+	}, nil
+}
 
-	// Получаем worktree для destination репозитория
-	destWorktree, err := destinationRepo.Worktree()
+// mirrorPaths возвращает пути зеркал GitLab и приватного репозитория в директории пары
+func (l *Logic) mirrorPaths(pair configs.RepositoryPair) (string, string) {
+	pairID := repository.PairID(pair.GitlabURL, pair.PrivateRepoURL)
+	return l.repoManager.MirrorPath(pairID, SideGitlab, pair.GitlabURL),
+		l.repoManager.MirrorPath(pairID, SidePrivate, pair.PrivateRepoURL)
+}
+
+// newSide собирает ветки и теги remote origin из зеркала
+func newSide(repo *git.Repository, cred configs.Credential) (*side, error) {
+	branches, err := remoteBranches(repo, "origin")
+	if err != nil {
+		return nil, err
+	}
+	// Зеркало содержит все теги своего remote: Manager.Mirror получает их вместе с ветками
+	tags, err := refsWithPrefix(repo, "refs/tags/")
 	if err != nil {
-		return fmt.Errorf("не удалось получить worktree для destination репозитория: %w", err)
+		return nil, err
 	}
+	return &side{repo: repo, cred: cred, branches: branches, tags: tags}, nil
+}
 
-	err = sourceBranches.ForEach(func(branchRef *plumbing.Reference) error {
-		branchName := branchRef.Name().Short()
-		if strings.HasPrefix(branchName, "HEAD") {
-			return nil // Пропускаем HEAD
-		}
+// reconcile сверяет все ветки и теги обеих сторон с базой и возвращает решения в детерминированном порядке
+func reconcile(base *state.State, gitlabSide, privateSide *side, h history) ([]Decision, error) {
+	var decisions []Decision
+	kinds := []struct {
+		kind            RefKind
+		base            map[string]string
+		gitlab, private map[string]plumbing.Hash
+	}{
+		{KindBranch, base.Branches, gitlabSide.branches, privateSide.branches},
+		{KindTag, base.Tags, gitlabSide.tags, privateSide.tags},
+	}
 
-		log.Printf("Синхронизация ветки: %s", branchName)
+	for _, k := range kinds {
+		names := make(map[string]plumbing.Hash)
+		for name := range k.base {
+			names[name] = plumbing.ZeroHash
+		}
+		for name := range k.gitlab {
+			names[name] = plumbing.ZeroHash
+		}
+		for name := range k.private {
+			names[name] = plumbing.ZeroHash
+		}
 
-		// Получаем reference из fetched данных
-		fetchedRef := plumbing.NewRemoteReferenceName(remoteName, branchName)
-		fetchedBranchRef, err := destinationRepo.Reference(fetchedRef, true)
-		if err != nil {
-			log.Printf("Предупреждение: не удалось найти fetched ветку %s: %v", branchName, err)
-			return nil // Пропускаем эту ветку
+		for _, name := range sortedRefNames(names) {
+			if k.gitlab[name].IsZero() && k.private[name].IsZero() {
+				// Ссылка удалена на обеих сторонах и больше не отслеживается
+				continue
+			}
+			decision, err := reconcileRef(k.kind, name, plumbing.NewHash(k.base[name]), k.gitlab[name], k.private[name], h)
+			if err != nil {
+				return nil, fmt.Errorf("не удалось сравнить историю, %s %s: %w", k.kind.label(), name, err)
+			}
+			decisions = append(decisions, decision)
 		}
+	}
+	return decisions, nil
+}
 
-		// Проверяем, существует ли ветка в destination репозитории
-		destBranchRef, err := destinationRepo.Reference(branchRef.Name(), true)
-		needsUpdate := false
+// apply выполняет решение сверки и возвращает результат синхронизации ссылки.
+// Решение о слиянии дополняется созданным коммитом или заменяется конфликтом, если слияние невозможно.
+func (l *Logic) apply(ctx context.Context, d *Decision, gitlabSide, privateSide *side) (RefResult, error) {
+	refResult := RefResult{Name: d.Name, Direction: d.Direction, GitlabHash: d.Gitlab, PrivateHash: d.Private, Message: d.Reason}
+
+	switch d.Action {
+	case ActionNone:
+		l.logger.Printf("%s %s: синхронизация не требуется", d.Kind.label(), d.Name)
+		refResult.Status = StatusUpToDate
+		return refResult, nil
+	case ActionSkip:
+		l.logger.Printf("Предупреждение: пропуск, %s %s: %s. Ссылка не будет изменена.", d.Kind.label(), d.Name, d.Reason)
+		refResult.Status = StatusSkipped
+		return refResult, nil
+	case ActionConflict:
+		l.logger.Printf("Предупреждение: конфликт, %s %s: %s. Ссылка не будет изменена.", d.Kind.label(), d.Name, d.Reason)
+		refResult.Status = StatusConflict
+		return refResult, nil
+	case ActionBlocked:
+		l.logger.Printf("Предупреждение: ветка %s заблокирована защитой GitLab: %s. Ссылка не будет изменена.", d.Name, d.Reason)
+		refResult.Status = StatusBlocked
+		return refResult, nil
+	case ActionConflictBranch:
+		return l.applyConflictBranch(ctx, d, gitlabSide, privateSide)
+	case ActionMerge:
+		return l.applyMerge(ctx, d, gitlabSide, privateSide)
+	case ActionMergeRequest:
+		return l.applyMergeRequest(ctx, d, gitlabSide, privateSide)
+	}
 
-		if err == plumbing.ErrReferenceNotFound {
-			// Ветка не существует в destination репозитории
-			log.Printf("Ветка %s не существует в destination репозитории, создаем новую", branchName)
-			needsUpdate = true
-		} else if err != nil {
-			return fmt.Errorf("ошибка при поиске ветки %s в destination репозитории: %w", branchName, err)
-		} else {
-			// Ветка существует, проверяем, нужно ли обновление
-			if destBranchRef.Hash() != fetchedBranchRef.Hash() {
-				log.Printf("Ветка %s требует обновления (dest: %s, source: %s)", branchName, destBranchRef.Hash().String(), fetchedBranchRef.Hash().String())
-				needsUpdate = true
-			} else {
-				log.Printf("Ветка %s уже синхронизирована", branchName)
-			}
+	target := privateSide
+	if d.Direction == DirectionToGitlab {
+		target = gitlabSide
+	}
+
+	source, dest := plumbing.NewRemoteReferenceName(syncRemoteName, d.Name), plumbing.NewBranchReferenceName(d.Name)
+	if d.Kind == KindTag {
+		source, dest = plumbing.ReferenceName(syncTagsPrefix+d.Name), plumbing.NewTagReferenceName(d.Name)
+	}
+
+	if d.Action == ActionDelete {
+		if d.DryRun {
+			l.logger.Printf("Dry-run: %s %s будет удалена (%s): %s", d.Kind.label(), d.Name, d.Direction, d.Reason)
+			refResult.Status = StatusWouldDelete
+			return refResult, nil
 		}
+		l.logger.Printf("Удаление ссылки %s (%s): %s", dest, d.Direction, d.Reason)
+		if err := l.repoManager.DeleteRef(ctx, target.repo, dest, target.cred); err != nil {
+			return refResult, fmt.Errorf("не удалось удалить ссылку %s (%s): %w", dest, d.Direction, err)
+		}
+		refResult.Status = StatusDeleted
+		return refResult, nil
+	}
 
-		if needsUpdate {
-			// Переключаемся на ветку или создаем её
-			checkoutOptions := &git.CheckoutOptions{
-				Branch: branchRef.Name(),
-				Create: err == plumbing.ErrReferenceNotFound,
-				Force:  true,
-			}
+	if d.Action == ActionForce {
+		expected := d.Private
+		if target == gitlabSide {
+			expected = d.Gitlab
+		}
+		return l.forcePush(ctx, d, target, expected, refResult)
+	}
 
-			if checkoutOptions.Create {
-				// Для новой ветки устанавливаем hash из fetched данных
-				checkoutOptions.Hash = fetchedBranchRef.Hash()
-			}
+	l.logger.Printf("Синхронизация %s %s: %s (%s)", d.Kind.label(), d.Name, d.Action, d.Direction)
+	refSpec := gitconfig.RefSpec(fmt.Sprintf("%s:%s", source, dest))
+	if err := l.repoManager.PushRefSpecs(ctx, target.repo, []gitconfig.RefSpec{refSpec}, target.cred); err != nil {
+		return refResult, fmt.Errorf("не удалось выполнить push, %s %s (%s): %w", d.Kind.label(), d.Name, d.Direction, err)
+	}
 
-			err = destWorktree.Checkout(checkoutOptions)
-			if err != nil {
-				return fmt.Errorf("не удалось переключиться на ветку %s в destination репозитории: %w", branchName, err)
-			}
+	refResult.Status = StatusUpdated
+	if d.Action == ActionCreate {
+		refResult.Status = StatusCreated
+	}
+	l.logger.Printf("Ссылка %s успешно синхронизирована", dest)
+	return refResult, nil
+}
 
-			// Если ветка уже существует, обновляем её до нужного коммита
-			if !checkoutOptions.Create {
-				err = destWorktree.Reset(&git.ResetOptions{
-					Commit: fetchedBranchRef.Hash(),
-					Mode:   git.HardReset,
-				})
-				if err != nil {
-					return fmt.Errorf("не удалось обновить ветку %s до коммита %s: %w", branchName, fetchedBranchRef.Hash().String(), err)
-				}
+// forcePush перезаписывает ветку на стороне target. Если для перезаписи в GitLab нужно ослабить защиту ветки,
+// исходная защита восстанавливается и после неудачной перезаписи.
+func (l *Logic) forcePush(ctx context.Context, d *Decision, target *side, expected plumbing.Hash, refResult RefResult) (_ RefResult, err error) {
+	if target.protection != nil {
+		restore, liftErr := l.liftProtection(ctx, target.protection, d.Name)
+		if liftErr != nil {
+			return refResult, liftErr
+		}
+		defer func() {
+			if restoreErr := restore(); restoreErr != nil {
+				l.logger.Printf("Ошибка: %v", restoreErr)
+				err = errors.Join(err, restoreErr)
 			}
+		}()
+	}
 
-			// Выполняем push в remote репозиторий
-			pushOptions := &git.PushOptions{
-				Auth: destAuth,
-			}
+	l.logger.Printf("Перезапись ветки %s (%s): %s", d.Name, d.Direction, d.Reason)
+	if err := l.repoManager.ForcePushWithLease(ctx, target.repo, d.Name, d.Resolved, expected, target.cred); err != nil {
+		return refResult, fmt.Errorf("не удалось перезаписать ветку %s (%s): %w", d.Name, d.Direction, err)
+	}
+	refResult.Status = StatusForced
+	return refResult, nil
+}
 
-			err = destinationRepo.Push(pushOptions)
-			if err != nil {
-				if err == git.ErrNonFastForwardUpdate {
-					log.Printf("Предупреждение: не удалось выполнить fast-forward push для ветки %s. Пропускаем синхронизацию этой ветки, чтобы избежать принудительной перезаписи.", branchName)
-					return nil // Пропускаем эту ветку
-				}
-				if err != git.NoErrAlreadyUpToDate {
-					return fmt.Errorf("не удалось выполнить push ветки %s в destination репозиторий: %w", branchName, err)
-				}
-			}
+// nextState вычисляет новое состояние после применения решений: ссылки, совпадающие на обеих
+// сторонах, записываются с их текущим значением, для остальных сохраняется прежняя база
+func nextState(base *state.State, decisions []Decision) *state.State {
+	next := state.NewState()
+	for _, d := range decisions {
+		refs, previous := next.Branches, base.Branches
+		if d.Kind == KindTag {
+			refs, previous = next.Tags, base.Tags
+		}
 
-			log.Printf("Ветка %s успешно синхронизирована", branchName)
+		switch {
+		case d.Action == ActionDelete && !d.DryRun:
+			// Ссылка удалена на обеих сторонах и больше не отслеживается
+		case !d.Resolved.IsZero():
+			// После push обе стороны указывают на одно значение
+			refs[d.Name] = d.Resolved.String()
+		case d.Action == ActionNone:
+			refs[d.Name] = d.Gitlab.String()
+		default:
+			if hash, ok := previous[d.Name]; ok {
+				refs[d.Name] = hash
+			}
 		}
+	}
+	return next
+}
 
-		return nil
-	})
+// loadState загружает состояние пары по ключу key. Если его еще нет, используется состояние, сохраненное
+// прежними версиями под ключом из адресов пары без нормализации: оно будет сохранено под key.
+func (l *Logic) loadState(key string, pair configs.RepositoryPair) (*state.State, error) {
+	if l.stateStore == nil {
+		return state.NewState(), nil
+	}
+	if _, err := os.Stat(l.stateStore.Path(key)); errors.Is(err, os.ErrNotExist) {
+		return l.stateStore.Load(legacyStateKey(pair.GitlabURL, pair.PrivateRepoURL))
+	}
+	return l.stateStore.Load(key)
+}
 
+// legacyStateKey возвращает ключ состояния пары прежних версий: имя репозитория и короткий hash адресов
+func legacyStateKey(gitlabURL, privateRepoURL string) string {
+	sum := sha256.Sum256([]byte(gitlabURL + "\n" + privateRepoURL))
+	return getRepoNameFromURL(gitlabURL) + "-" + hex.EncodeToString(sum[:6])
+}
+
+// Имя remote и пространства ссылок, под которыми другая сторона добавляется в зеркало.
+// Теги другой стороны получаются в отдельное пространство, чтобы не смешиваться
+// с собственными тегами зеркала в refs/tags.
+const (
+	syncRemoteName = "sync-source"
+	syncTagsPrefix = "refs/sync-source/tags/"
+)
+
+// fetchSource получает все ветки и теги репозитория sourceURL в destination репозиторий
+func (l *Logic) fetchSource(ctx context.Context, destinationRepo *git.Repository, sourceURL string, sourceCred configs.Credential) error {
+	// Добавляем source репозиторий как remote в destination репозиторий
+	fetchRefSpecs := []gitconfig.RefSpec{
+		gitconfig.RefSpec(fmt.Sprintf("+refs/heads/*:refs/remotes/%s/*", syncRemoteName)),
+		gitconfig.RefSpec(fmt.Sprintf("+refs/tags/*:%s*", syncTagsPrefix)),
+	}
+	// Remote пересоздается, чтобы использовать текущий адрес source репозитория из конфигурации;
+	// ссылки, полученные по прежнему адресу, удаляются при fetch с Prune
+	if err := destinationRepo.DeleteRemote(syncRemoteName); err != nil && err != git.ErrRemoteNotFound {
+		return fmt.Errorf("не удалось удалить remote %s: %w", syncRemoteName, err)
+	}
+	_, err := destinationRepo.CreateRemote(&gitconfig.RemoteConfig{
+		Name:  syncRemoteName,
+		URLs:  []string{sourceURL},
+		Fetch: fetchRefSpecs,
+	})
 	if err != nil {
-		return fmt.Errorf("ошибка при итерации по веткам source репозитория: %w", err)
+		return fmt.Errorf("не удалось создать remote %s: %w", syncRemoteName, err)
 	}
 
-	return nil
+	// Теги source репозитория получаются только в отдельное пространство ссылок,
+	// не смешиваясь с тегами destination репозитория в refs/tags
+	return l.repoManager.FetchRemote(ctx, destinationRepo, syncRemoteName, fetchRefSpecs, sourceCred)
 }
 
-// getAuthMethod возвращает метод аутентификации на основе токена или SSH-ключа
-func (l *Logic) getAuthMethod(token, sshKeyPath string) (transport.AuthMethod, error) {
-	if token != "" {
-		return &http.BasicAuth{
-			Username: "oauth2", // Для GitLab Personal Access Token
-			Password: token,
-		}, nil
-	} else if sshKeyPath != "" {
-		sshAuth, err := ssh.NewPublicKeysFromFile("git", sshKeyPath, "")
-		if err != nil {
-			return nil, fmt.Errorf("не удалось создать SSH-аутентификацию: %w", err)
+// remoteBranches возвращает ветки, известные репозиторию для указанного remote (refs/remotes/<remote>/*)
+func remoteBranches(repo *git.Repository, remoteName string) (map[string]plumbing.Hash, error) {
+	branches, err := refsWithPrefix(repo, "refs/remotes/"+remoteName+"/")
+	if err != nil {
+		return nil, err
+	}
+	delete(branches, "HEAD") // Пропускаем HEAD
+	return branches, nil
+}
+
+// refsWithPrefix возвращает ссылки репозитория с указанным префиксом, ключом служит имя без префикса
+func refsWithPrefix(repo *git.Repository, prefix string) (map[string]plumbing.Hash, error) {
+	refs, err := repo.References()
+	if err != nil {
+		return nil, err
+	}
+	defer refs.Close()
+
+	result := make(map[string]plumbing.Hash)
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		name := ref.Name().String()
+		if ref.Type() != plumbing.HashReference || !strings.HasPrefix(name, prefix) {
+			return nil
 		}
-		return sshAuth, nil
+		result[strings.TrimPrefix(name, prefix)] = ref.Hash()
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// sortedRefNames возвращает имена ссылок в детерминированном порядке
+func sortedRefNames(refs map[string]plumbing.Hash) []string {
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
 	}
-	return nil, nil // Нет аутентификации
+	sort.Strings(names)
+	return names
+}
+
+// getAuthMethod возвращает метод аутентификации транспорта для обращения к репозиторию repoURL
+// с учетными данными стороны, которой принадлежит этот репозиторий
+func (l *Logic) getAuthMethod(repoURL string, cred configs.Credential) (transport.AuthMethod, error) {
+	return l.repoManager.Auth(repoURL, cred)
 }
 
-// getRepoNameFromURL извлекает имя репозитория из URL
+// getRepoNameFromURL извлекает имя репозитория из URL: последний сегмент пути проекта без суффикса .git
 func getRepoNameFromURL(repoURL string) string {
-	parts := strings.Split(repoURL, "/")
-	if len(parts) > 0 {
-		nameWithExt := parts[len(parts)-1]
-		return strings.TrimSuffix(nameWithExt, ".git")
+	remote, err := repository.ParseRemoteURL(repoURL, "")
+	if err != nil {
+		return strings.TrimSuffix(repoURL, ".git")
 	}
-	return repoURL
+	return remote.Name()
 }
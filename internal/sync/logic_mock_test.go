@@ -3,6 +3,7 @@ package sync
 import (
 	"context"
 	"fmt"
+	"io"
 	"io/fs"
 	"os"
 	"sync"
@@ -11,7 +12,7 @@ import (
 	"github.com/go-git/go-git/v5"
 	gitconfig "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
@@ -66,7 +67,7 @@ type MockGitRepository struct {
 	MockWorktree     func() (*git.Worktree, error)
 	MockRemote       func(name string) (*git.Remote, error)
 	MockCreateRemote func(config *gitconfig.RemoteConfig) (*git.Remote, error)
-	MockBranches     func() (*object.ReferenceIter, error)
+	MockBranches     func() (storer.ReferenceIter, error)
 	MockReference    func(name plumbing.ReferenceName, resolve bool) (*plumbing.Reference, error)
 	MockPush         func(o *git.PushOptions) error
 }
@@ -92,7 +93,7 @@ func (m *MockGitRepository) CreateRemote(config *gitconfig.RemoteConfig) (*git.R
 	return nil, nil
 }
 
-func (m *MockGitRepository) Branches() (*object.ReferenceIter, error) {
+func (m *MockGitRepository) Branches() (storer.ReferenceIter, error) {
 	if m.MockBranches != nil {
 		return m.MockBranches()
 	}
@@ -161,7 +162,7 @@ func (m *MockGitRemote) Fetch(o *git.FetchOptions) error {
 	return nil
 }
 
-// MockReferenceIter - мок для object.ReferenceIter
+// MockReferenceIter - мок для storer.ReferenceIter
 type MockReferenceIter struct {
 	Refs  []*plumbing.Reference
 	Index int
@@ -173,7 +174,7 @@ func (m *MockReferenceIter) Next() (*plumbing.Reference, error) {
 	defer m.Mu.Unlock()
 
 	if m.Index >= len(m.Refs) {
-		return nil, object.ErrIterDone
+		return nil, io.EOF
 	}
 	ref := m.Refs[m.Index]
 	m.Index++
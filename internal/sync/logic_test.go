@@ -1,10 +1,20 @@
 package sync
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"git-sync/configs"
 	"git-sync/internal/repository"
+	"git-sync/internal/state"
+	"log"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 )
@@ -91,7 +101,7 @@ func TestGetAuthMethod(t *testing.T) {
 
 	t.Run("WithToken", func(t *testing.T) {
 		token := "test-token"
-		auth, err := logic.getAuthMethod(token, "")
+		auth, err := logic.getAuthMethod("https://gitlab.com/user/repo.git", configs.Credential{Type: configs.CredentialToken, Secret: token})
 
 		if err != nil {
 			t.Fatalf("getAuthMethod с токеном вернул ошибку: %v", err)
@@ -118,7 +128,7 @@ func TestGetAuthMethod(t *testing.T) {
 
 	t.Run("WithInvalidSSHKey", func(t *testing.T) {
 		sshKeyPath := "/invalid/path/to/ssh/key"
-		auth, err := logic.getAuthMethod("", sshKeyPath)
+		auth, err := logic.getAuthMethod("https://gitlab.com/user/repo.git", configs.Credential{Type: configs.CredentialSSHKey, KeyPath: sshKeyPath})
 
 		// Ожидаем ошибку, так как SSH ключ не существует
 		if err == nil {
@@ -131,7 +141,7 @@ func TestGetAuthMethod(t *testing.T) {
 	})
 
 	t.Run("WithoutAuth", func(t *testing.T) {
-		auth, err := logic.getAuthMethod("", "")
+		auth, err := logic.getAuthMethod("https://gitlab.com/user/repo.git", configs.Credential{})
 
 		if err != nil {
 			t.Fatalf("getAuthMethod без аутентификации вернул ошибку: %v", err)
@@ -143,14 +153,14 @@ func TestGetAuthMethod(t *testing.T) {
 	})
 
 	t.Run("WithEmptyToken", func(t *testing.T) {
-		auth, err := logic.getAuthMethod("", "")
+		auth, err := logic.getAuthMethod("https://gitlab.com/user/repo.git", configs.Credential{Type: configs.CredentialToken})
 
-		if err != nil {
-			t.Fatalf("getAuthMethod с пустым токеном вернул ошибку: %v", err)
+		if err == nil {
+			t.Error("getAuthMethod с пустым токеном должен вернуть ошибку")
 		}
 
 		if auth != nil {
-			t.Error("getAuthMethod с пустым токеном должен вернуть nil")
+			t.Error("getAuthMethod с пустым токеном не должен возвращать auth")
 		}
 	})
 }
@@ -161,7 +171,7 @@ func TestGetAuthMethodInterface(t *testing.T) {
 
 	// Тестируем, что возвращаемое значение реализует интерфейс transport.AuthMethod
 	token := "test-token"
-	auth, err := logic.getAuthMethod(token, "")
+	auth, err := logic.getAuthMethod("https://gitlab.com/user/repo.git", configs.Credential{Type: configs.CredentialToken, Secret: token})
 
 	if err != nil {
 		t.Fatalf("getAuthMethod вернул ошибку: %v", err)
@@ -248,18 +258,33 @@ func TestComplexURLParsing(t *testing.T) {
 		{
 			name:     "URLWithQuery",
 			url:      "https://gitlab.com/user/repo.git?ref=main",
-			expected: "repo.git?ref=main", // getRepoNameFromURL берет последний сегмент пути
+			expected: "repo", // запрос не входит в путь проекта
 		},
 		{
 			name:     "URLWithFragment",
 			url:      "https://gitlab.com/user/repo.git#readme",
-			expected: "repo.git#readme",
+			expected: "repo",
 		},
 		{
 			name:     "URLWithMultipleDots",
 			url:      "https://gitlab.com/user/my.awesome.repo.git",
 			expected: "my.awesome.repo",
 		},
+		{
+			name:     "SCPNestedGroups",
+			url:      "git@gitlab.example.com:a/b/c/project.git",
+			expected: "project",
+		},
+		{
+			name:     "SCPWithoutGroup",
+			url:      "git@gitlab.example.com:project.git",
+			expected: "project",
+		},
+		{
+			name:     "SSHWithPortAndTrailingSlash",
+			url:      "ssh://git@gitlab.example.com:2222/group/project.git/",
+			expected: "project",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -292,8 +317,8 @@ func TestEdgeCases(t *testing.T) {
 	})
 
 	t.Run("AuthMethodWithBothTokenAndSSH", func(t *testing.T) {
-		// Когда указаны и токен, и SSH ключ, должен использоваться токен
-		auth, err := logic.getAuthMethod("test-token", "/some/ssh/key")
+		// Метод аутентификации выбирается по типу учетных данных, путь к ключу для токена не используется
+		auth, err := logic.getAuthMethod("https://gitlab.com/user/repo.git", configs.Credential{Type: configs.CredentialToken, Secret: "test-token", KeyPath: "/some/ssh/key"})
 
 		if err != nil {
 			t.Fatalf("getAuthMethod с токеном и SSH ключом вернул ошибку: %v", err)
@@ -329,7 +354,7 @@ func BenchmarkGetAuthMethodWithToken(b *testing.B) {
 
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, _ = logic.getAuthMethod(token, "")
+		_, _ = logic.getAuthMethod("https://gitlab.com/user/repo.git", configs.Credential{Type: configs.CredentialToken, Secret: token})
 	}
 }
 
@@ -363,3 +388,732 @@ func TestGetRepoNameFromURLSeparators(t *testing.T) {
 		})
 	}
 }
+
+// Тест синхронизации всех веток между локальными bare репозиториями
+func TestSynchronizeAllBranches(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+
+	// Общая история в обоих репозиториях
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	private.setBranch("main", root)
+
+	// GitLab: main продвинулся вперед, плюс много веток релизов и фич
+	gitlab.setBranch("main", gitlab.commit("main-2", root))
+	for i := 0; i < 15; i++ {
+		name := fmt.Sprintf("feature/f-%02d", i)
+		gitlab.setBranch(name, gitlab.commit(name, root))
+	}
+	gitlab.setBranch("release/1.0", gitlab.commit("release-1.0", root))
+
+	// Private: собственная ветка hotfix
+	private.setBranch("hotfix", private.commit("hotfix", root))
+
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	if _, err := logic.Synchronize(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{}); err != nil {
+		t.Fatalf("Synchronize вернул ошибку: %v", err)
+	}
+
+	gitlabBranches := gitlab.branches()
+	privateBranches := private.branches()
+
+	if len(gitlabBranches) != 18 {
+		t.Errorf("Ожидалось 18 веток в GitLab репозитории, получено %d", len(gitlabBranches))
+	}
+	if len(privateBranches) != len(gitlabBranches) {
+		t.Errorf("Количество веток различается: GitLab %d, Private %d", len(gitlabBranches), len(privateBranches))
+	}
+	for name, hash := range gitlabBranches {
+		if privateBranches[name] != hash {
+			t.Errorf("Ветка %s не синхронизирована: GitLab %s, Private %s", name, hash, privateBranches[name])
+		}
+	}
+}
+
+// Тест, что разошедшиеся ветки не перезаписываются
+func TestSynchronizeSkipsDivergedBranches(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+
+	root := gitlab.commit("root")
+	private.commit("root")
+
+	gitlabTip := gitlab.commit("gitlab-change", root)
+	privateTip := private.commit("private-change", root)
+	gitlab.setBranch("main", gitlabTip)
+	private.setBranch("main", privateTip)
+
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	result, err := logic.Synchronize(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Synchronize вернул ошибку: %v", err)
+	}
+
+	if got := gitlab.branches()["main"]; got != gitlabTip {
+		t.Errorf("Ветка main в GitLab была перезаписана: %s", got)
+	}
+	if got := private.branches()["main"]; got != privateTip {
+		t.Errorf("Ветка main в Private была перезаписана: %s", got)
+	}
+
+	conflicts := result.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].Name != "main" {
+		t.Fatalf("Ожидался конфликт по ветке main, получено %v", conflicts)
+	}
+	if !strings.Contains(conflicts[0].Message, root.String()[:7]) {
+		t.Errorf("Сообщение о конфликте должно содержать общий коммит %s: %s", root.String()[:7], conflicts[0].Message)
+	}
+}
+
+// divergedRemotes создает пару репозиториев, в которых ветка main разошлась после общего коммита.
+// Изменения сторон затрагивают разные файлы, если conflicting равно false.
+func divergedRemotes(t *testing.T, dir string, conflicting bool) (gitlab, private *testRemote, gitlabTip, privateTip plumbing.Hash) {
+	gitlab = newTestRemote(t, dir, "gitlab-repo.git")
+	private = newTestRemote(t, dir, "private-repo.git")
+
+	rootFiles := map[string]string{"README.md": "readme", "src/app.go": "app"}
+	root := gitlab.commitFiles("root", rootFiles)
+	private.commitFiles("root", rootFiles)
+
+	privateFile := "src/lib.go"
+	if conflicting {
+		privateFile = "README.md"
+	}
+	gitlabTip = gitlab.commitFiles("gitlab-change", map[string]string{"README.md": "gitlab readme", "src/app.go": "app"}, root)
+	privateTip = private.commitFiles("private-change", map[string]string{"README.md": "readme", "src/app.go": "app", privateFile: "private"}, root)
+	gitlab.setBranch("main", gitlabTip)
+	private.setBranch("main", privateTip)
+	return gitlab, private, gitlabTip, privateTip
+}
+
+// Тест стратегий разрешения конфликтов для разошедшихся веток
+func TestSynchronizeConflictStrategies(t *testing.T) {
+	now := time.Date(2024, 3, 5, 12, 0, 0, 0, time.UTC)
+
+	synchronize := func(t *testing.T, dir string, pair configs.RepositoryPair) RefResult {
+		t.Helper()
+		logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+		logic.now = func() time.Time { return now }
+		result, err := logic.Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{})
+		if err != nil {
+			t.Fatalf("Synchronize вернул ошибку: %v", err)
+		}
+		for _, branch := range result.Branches {
+			if branch.Name == "main" {
+				return branch
+			}
+		}
+		t.Fatalf("Нет результата для ветки main: %+v", result.Branches)
+		return RefResult{}
+	}
+
+	t.Run("PreferGitlab", func(t *testing.T) {
+		dir := t.TempDir()
+		gitlab, private, gitlabTip, _ := divergedRemotes(t, dir, true)
+		pair := testPair(gitlab, private)
+		pair.ConflictStrategy = configs.ConflictPreferGitlab
+
+		if main := synchronize(t, dir, pair); main.Status != StatusForced || main.Direction != DirectionToPrivate {
+			t.Errorf("Ожидался статус forced (%s), получено %+v", DirectionToPrivate, main)
+		}
+		if got := private.branches()["main"]; got != gitlabTip {
+			t.Errorf("Ветка main в Private не перезаписана версией GitLab: %s", got)
+		}
+	})
+
+	t.Run("PreferPrivate", func(t *testing.T) {
+		dir := t.TempDir()
+		gitlab, private, _, privateTip := divergedRemotes(t, dir, true)
+		pair := testPair(gitlab, private)
+		pair.ConflictStrategy = configs.ConflictPreferPrivate
+
+		if main := synchronize(t, dir, pair); main.Status != StatusForced || main.Direction != DirectionToGitlab {
+			t.Errorf("Ожидался статус forced (%s), получено %+v", DirectionToGitlab, main)
+		}
+		if got := gitlab.branches()["main"]; got != privateTip {
+			t.Errorf("Ветка main в GitLab не перезаписана версией Private: %s", got)
+		}
+	})
+
+	t.Run("ConflictBranch", func(t *testing.T) {
+		dir := t.TempDir()
+		gitlab, private, gitlabTip, privateTip := divergedRemotes(t, dir, true)
+		pair := testPair(gitlab, private)
+		pair.ConflictStrategy = configs.ConflictBranch
+
+		main := synchronize(t, dir, pair)
+		if main.Status != StatusConflictBranch {
+			t.Errorf("Ожидался статус conflict-branch, получено %+v", main)
+		}
+
+		conflictBranch := "sync-conflict/main/2024-03-05"
+		gitlabBranches, privateBranches := gitlab.branches(), private.branches()
+		if gitlabBranches["main"] != gitlabTip || privateBranches["main"] != privateTip {
+			t.Error("Ветка main не должна изменяться при стратегии conflict_branch")
+		}
+		if gitlabBranches[conflictBranch] != privateTip || privateBranches[conflictBranch] != privateTip {
+			t.Errorf("Ветка %s должна указывать на версию Private на обеих сторонах: GitLab %s, Private %s",
+				conflictBranch, gitlabBranches[conflictBranch], privateBranches[conflictBranch])
+		}
+
+		// Повторная синхронизация не создает новых веток конфликта
+		if main := synchronize(t, dir, pair); !strings.Contains(main.Message, conflictBranch) {
+			t.Errorf("Ожидалась ссылка на существующую ветку %s: %s", conflictBranch, main.Message)
+		}
+		if got := len(gitlab.branches()); got != 2 {
+			t.Errorf("Ожидалось 2 ветки в GitLab, получено %d", got)
+		}
+	})
+
+	t.Run("Merge", func(t *testing.T) {
+		dir := t.TempDir()
+		gitlab, private, gitlabTip, privateTip := divergedRemotes(t, dir, false)
+		pair := testPair(gitlab, private)
+		pair.ConflictStrategy = configs.ConflictMerge
+
+		if main := synchronize(t, dir, pair); main.Status != StatusMerged {
+			t.Fatalf("Ожидался статус merged, получено %+v", main)
+		}
+
+		merged := gitlab.branches()["main"]
+		if got := private.branches()["main"]; got != merged {
+			t.Fatalf("Ветка main различается после слияния: GitLab %s, Private %s", merged, got)
+		}
+
+		commit, err := gitlab.repo.CommitObject(merged)
+		if err != nil {
+			t.Fatalf("Не удалось получить коммит слияния: %v", err)
+		}
+		if len(commit.ParentHashes) != 2 || commit.ParentHashes[0] != gitlabTip || commit.ParentHashes[1] != privateTip {
+			t.Errorf("Неверные родители коммита слияния: %v", commit.ParentHashes)
+		}
+		for file, expected := range map[string]string{"README.md": "gitlab readme", "src/app.go": "app", "src/lib.go": "private"} {
+			f, err := commit.File(file)
+			if err != nil {
+				t.Errorf("Файл %s отсутствует в коммите слияния: %v", file, err)
+				continue
+			}
+			if content, _ := f.Contents(); content != expected {
+				t.Errorf("Файл %s: ожидалось %q, получено %q", file, expected, content)
+			}
+		}
+	})
+
+	t.Run("MergeWithConflictingFiles", func(t *testing.T) {
+		dir := t.TempDir()
+		gitlab, private, gitlabTip, privateTip := divergedRemotes(t, dir, true)
+		pair := testPair(gitlab, private)
+		pair.ConflictStrategy = configs.ConflictMerge
+
+		main := synchronize(t, dir, pair)
+		if main.Status != StatusConflict || !strings.Contains(main.Message, "README.md") {
+			t.Errorf("Ожидался конфликт в README.md, получено %+v", main)
+		}
+		if gitlab.branches()["main"] != gitlabTip || private.branches()["main"] != privateTip {
+			t.Error("Ветка main не должна изменяться при конфликте слияния")
+		}
+	})
+}
+
+// Тест синхронизации легковесных и аннотированных тегов
+func TestSynchronizeTags(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	private.setBranch("main", root)
+
+	release := gitlab.commit("release", root)
+	gitlab.setBranch("release", release)
+
+	// Теги, существующие только на одной стороне
+	gitlab.setTag("v1.0", root)
+	annotated := gitlab.annotatedTag("v1.1", release, "release 1.1")
+	privateTag := private.annotatedTag("v0.9", private.commit("private-only", root), "private tag")
+
+	// Тег с одинаковым именем, но разными объектами
+	gitlabConflict := gitlab.annotatedTag("v2.0", root, "gitlab v2.0")
+	privateConflict := private.annotatedTag("v2.0", root, "private v2.0")
+
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	result, err := logic.Synchronize(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Synchronize вернул ошибку: %v", err)
+	}
+
+	gitlabTags := gitlab.tags()
+	privateTags := private.tags()
+
+	expected := map[string]plumbing.Hash{"v1.0": root, "v1.1": annotated, "v0.9": privateTag}
+	for name, hash := range expected {
+		if gitlabTags[name] != hash {
+			t.Errorf("Тег %s в GitLab: ожидался %s, получен %s", name, hash, gitlabTags[name])
+		}
+		if privateTags[name] != hash {
+			t.Errorf("Тег %s в Private: ожидался %s, получен %s", name, hash, privateTags[name])
+		}
+	}
+
+	// Конфликтующий тег не перемещается ни на одной из сторон
+	if gitlabTags["v2.0"] != gitlabConflict {
+		t.Errorf("Тег v2.0 в GitLab был перемещен: %s", gitlabTags["v2.0"])
+	}
+	if privateTags["v2.0"] != privateConflict {
+		t.Errorf("Тег v2.0 в Private был перемещен: %s", privateTags["v2.0"])
+	}
+
+	statuses := make(map[string]RefStatus)
+	for _, tag := range result.Tags {
+		statuses[tag.Name] = tag.Status
+	}
+	if statuses["v1.0"] != StatusCreated || statuses["v1.1"] != StatusCreated {
+		t.Errorf("Ожидался статус created для v1.0 и v1.1, получено %v", statuses)
+	}
+	if statuses["v2.0"] != StatusConflict {
+		t.Errorf("Ожидался статус conflict для v2.0, получен %s", statuses["v2.0"])
+	}
+
+	conflicts := result.Conflicts()
+	if len(conflicts) != 1 || conflicts[0].Name != "v2.0" {
+		t.Errorf("Ожидался конфликт по тегу v2.0, получено %v", conflicts)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("Длительность синхронизации не заполнена: %s", result.Duration)
+	}
+}
+
+// Тест, что сохраненное состояние отличает удаление ветки от ее создания на другой стороне
+func TestSynchronizeWithStateStore(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	private.setBranch("main", root)
+	gitlab.setBranch("feature", gitlab.commit("feature", root))
+
+	store := state.NewStore(filepath.Join(dir, "state"))
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	logic.SetStateStore(store)
+
+	if _, err := logic.Synchronize(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{}); err != nil {
+		t.Fatalf("Первая синхронизация вернула ошибку: %v", err)
+	}
+
+	saved, err := store.Load(repository.PairID(gitlab.path, private.path))
+	if err != nil {
+		t.Fatalf("Не удалось загрузить состояние: %v", err)
+	}
+	if saved.Branches["feature"] != gitlab.branches()["feature"].String() {
+		t.Errorf("Состояние ветки feature не сохранено: %v", saved.Branches)
+	}
+
+	// Ветка удалена в GitLab после слияния и не должна вернуться из приватного репозитория
+	gitlab.deleteBranch("feature")
+
+	result, err := logic.Synchronize(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Вторая синхронизация вернула ошибку: %v", err)
+	}
+
+	if _, exists := gitlab.branches()["feature"]; exists {
+		t.Error("Удаленная в GitLab ветка feature была создана заново")
+	}
+
+	var feature *RefResult
+	for i := range result.Branches {
+		if result.Branches[i].Name == "feature" {
+			feature = &result.Branches[i]
+		}
+	}
+	if feature == nil || feature.Status != StatusSkipped {
+		t.Errorf("Ожидался статус skipped для ветки feature, получено %+v", feature)
+	}
+}
+
+// Тест, что состояние пары сохраняется при косметическом изменении адресов и переносится
+// из ключа прежних версий
+func TestSynchronizeStateSurvivesURLChange(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+
+	root := gitlab.commit("root")
+	private.commit("root")
+	branches := map[string]plumbing.Hash{"main": root}
+	for _, name := range []string{"feature", "bugfix"} {
+		branches[name] = gitlab.commit(name, root)
+		private.commit(name, root)
+	}
+	for name, hash := range branches {
+		gitlab.setBranch(name, hash)
+		private.setBranch(name, hash)
+	}
+
+	// Состояние прежней версии сохранено под ключом из адресов без нормализации
+	store := state.NewStore(filepath.Join(dir, "state"))
+	if err := store.Save(legacyStateKey(gitlab.path, private.path), stateWith(branches)); err != nil {
+		t.Fatalf("Не удалось сохранить состояние: %v", err)
+	}
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	logic.SetStateStore(store)
+
+	synchronize := func(pair configs.RepositoryPair, deleted string) {
+		t.Helper()
+		gitlab.deleteBranch(deleted)
+		if _, err := logic.Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{}); err != nil {
+			t.Fatalf("Синхронизация вернула ошибку: %v", err)
+		}
+		if _, exists := gitlab.branches()[deleted]; exists {
+			t.Errorf("Удаленная в GitLab ветка %s была создана заново", deleted)
+		}
+	}
+	synchronize(testPair(gitlab, private), "feature")
+	// Адреса пары записаны с завершающим слешем: ключ состояния не меняется
+	synchronize(configs.RepositoryPair{GitlabURL: gitlab.path + "/", PrivateRepoURL: private.path + "/"}, "bugfix")
+
+	if _, err := os.Stat(store.Path(repository.PairID(gitlab.path, private.path))); err != nil {
+		t.Errorf("Состояние не сохранено под ключом пары: %v", err)
+	}
+}
+
+// Тест распространения удалений веток и тегов с учетом защищенных ссылок и dry-run
+func TestSynchronizePropagatesDeletions(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	private.setBranch("main", root)
+	gitlab.setBranch("feature", gitlab.commit("feature", root))
+	gitlab.setBranch("release/1.0", gitlab.commit("release", root))
+	gitlab.setTag("v1.0", root)
+
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	logic.SetStateStore(state.NewStore(filepath.Join(dir, "state")))
+
+	pair := testPair(gitlab, private)
+	pair.PropagateDeletions = true
+	pair.ProtectedRefs = []string{"release/*"}
+
+	if _, err := logic.Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{}); err != nil {
+		t.Fatalf("Первая синхронизация вернула ошибку: %v", err)
+	}
+
+	// Ветки и тег удалены в GitLab
+	gitlab.deleteBranch("feature")
+	gitlab.deleteBranch("release/1.0")
+	gitlab.deleteTag("v1.0")
+
+	// В режиме dry-run удаления только сообщаются
+	dryRunPair := pair
+	dryRunPair.DeletionsDryRun = true
+	result, err := logic.Synchronize(context.Background(), dryRunPair, configs.Credential{}, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Синхронизация в режиме dry-run вернула ошибку: %v", err)
+	}
+	if got := len(result.WithStatus(StatusWouldDelete)); got != 2 {
+		t.Errorf("Ожидалось 2 удаления в режиме dry-run, получено %d", got)
+	}
+	if _, exists := private.branches()["feature"]; !exists {
+		t.Error("Ветка feature удалена в режиме dry-run")
+	}
+
+	result, err = logic.Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Синхронизация с удалениями вернула ошибку: %v", err)
+	}
+
+	privateBranches := private.branches()
+	if _, exists := privateBranches["feature"]; exists {
+		t.Error("Ветка feature не удалена в приватном репозитории")
+	}
+	if _, exists := privateBranches["release/1.0"]; !exists {
+		t.Error("Защищенная ветка release/1.0 удалена в приватном репозитории")
+	}
+	if _, exists := private.tags()["v1.0"]; exists {
+		t.Error("Тег v1.0 не удален в приватном репозитории")
+	}
+	if _, exists := gitlab.branches()["release/1.0"]; exists {
+		t.Error("Защищенная ветка release/1.0 не должна создаваться заново в GitLab")
+	}
+	if got := len(result.WithStatus(StatusDeleted)); got != 2 {
+		t.Errorf("Ожидалось 2 удаления, получено %d", got)
+	}
+}
+
+// Тест, что Status сообщает о различиях, не изменяя репозитории и состояние
+func TestStatusDoesNotPush(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	private.setBranch("main", root)
+	gitlab.setBranch("feature", gitlab.commit("feature", root))
+
+	store := state.NewStore(filepath.Join(dir, "state"))
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	logic.SetStateStore(store)
+
+	decisions, err := logic.Status(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Status вернул ошибку: %v", err)
+	}
+
+	actions := make(map[string]Action)
+	for _, d := range decisions {
+		actions[d.Name] = d.Action
+	}
+	if actions["main"] != ActionNone || actions["feature"] != ActionCreate {
+		t.Errorf("Ожидалось main: none, feature: create, получено %v", actions)
+	}
+	if _, exists := private.branches()["feature"]; exists {
+		t.Error("Status не должен создавать ветки")
+	}
+	if _, err := os.Stat(store.Path(repository.PairID(gitlab.path, private.path))); !os.IsNotExist(err) {
+		t.Errorf("Status не должен сохранять состояние: %v", err)
+	}
+}
+
+// Тест, что зеркала сохраняются между синхронизациями и обновляются инкрементально
+func TestSynchronizeReusesMirrors(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	private.setBranch("main", root)
+
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	if _, err := logic.Synchronize(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{}); err != nil {
+		t.Fatalf("Synchronize вернул ошибку: %v", err)
+	}
+	gitlabMirror, privateMirror := logic.mirrorPaths(testPair(gitlab, private))
+	for _, path := range []string{gitlabMirror, privateMirror} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("Зеркало %s не сохранено после синхронизации: %v", path, err)
+		}
+	}
+
+	// Новая ветка и новый коммит появляются в GitLab после первой синхронизации
+	next := gitlab.commit("main-2", root)
+	gitlab.setBranch("main", next)
+	gitlab.setBranch("feature", gitlab.commit("feature", next))
+
+	if _, err := logic.Synchronize(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{}); err != nil {
+		t.Fatalf("Повторный Synchronize вернул ошибку: %v", err)
+	}
+	privateBranches := private.branches()
+	if privateBranches["main"] != next {
+		t.Errorf("Ветка main не обновлена: %s", privateBranches["main"])
+	}
+	if privateBranches["feature"] != gitlab.branches()["feature"] {
+		t.Errorf("Ветка feature не создана в Private: %s", privateBranches["feature"])
+	}
+}
+
+// Тест, что пары одноименных репозиториев из разных групп не используют общие директории
+func TestSynchronizeSameRepoNames(t *testing.T) {
+	dir := t.TempDir()
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+
+	pairs := make([]configs.RepositoryPair, 0, 2)
+	privates := make([]*testRemote, 0, 2)
+	for _, group := range []string{"group-a", "group-b"} {
+		gitlab := newTestRemote(t, dir, filepath.Join("gitlab", group, "app.git"))
+		private := newTestRemote(t, dir, filepath.Join("private", group, "app.git"))
+		root := gitlab.commit("root")
+		private.commit("root")
+		gitlab.setBranch("main", root)
+		private.setBranch("main", root)
+		gitlab.setBranch(group, gitlab.commit(group, root))
+		pairs = append(pairs, testPair(gitlab, private))
+		privates = append(privates, private)
+	}
+
+	seen := make(map[string]bool)
+	for _, pair := range pairs {
+		if _, err := logic.Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{}); err != nil {
+			t.Fatalf("Synchronize вернул ошибку: %v", err)
+		}
+		gitlabMirror, privateMirror := logic.mirrorPaths(pair)
+		for _, path := range []string{gitlabMirror, privateMirror} {
+			if seen[path] {
+				t.Errorf("Зеркало %s используется несколькими сторонами", path)
+			}
+			seen[path] = true
+		}
+	}
+
+	// В приватный репозиторий каждой группы попала только ветка своей группы
+	for i, group := range []string{"group-a", "group-b"} {
+		other := []string{"group-b", "group-a"}[i]
+		branches := privates[i].branches()
+		if _, ok := branches[group]; !ok {
+			t.Errorf("Ветка %s не создана в приватном репозитории своей группы", group)
+		}
+		if _, ok := branches[other]; ok {
+			t.Errorf("Ветка %s попала в приватный репозиторий группы %s", other, group)
+		}
+	}
+}
+
+// Тест, что пара, директория которой занята другим процессом, не синхронизируется
+func TestSynchronizeLockedPair(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	private.setBranch("main", root)
+	feature := gitlab.commit("feature", root)
+	gitlab.setBranch("feature", feature)
+
+	repoManager := repository.NewManager(filepath.Join(dir, "work"))
+	logic := NewLogic(repoManager)
+	pair := testPair(gitlab, private)
+
+	lock, err := repository.LockDir(repoManager.PairDir(repository.PairID(pair.GitlabURL, pair.PrivateRepoURL)))
+	if err != nil {
+		t.Fatalf("Не удалось захватить директорию пары: %v", err)
+	}
+	if _, err := logic.Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{}); !errors.Is(err, repository.ErrLocked) {
+		t.Errorf("Ожидалась ошибка ErrLocked, получено: %v", err)
+	}
+	if _, exists := private.branches()["feature"]; exists {
+		t.Error("Занятая пара не должна синхронизироваться")
+	}
+
+	lock.Unlock()
+	if _, err := logic.Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{}); err != nil {
+		t.Fatalf("Synchronize после освобождения директории вернул ошибку: %v", err)
+	}
+	if private.branches()["feature"] != feature {
+		t.Error("Ветка feature не синхронизирована после освобождения директории")
+	}
+}
+
+// Тест одновременной синхронизации нескольких пар копиями одной Logic с собственными журналами
+func TestSynchronizeConcurrentPairs(t *testing.T) {
+	dir := t.TempDir()
+	const pairsCount = 4
+
+	pairs := make([]configs.RepositoryPair, pairsCount)
+	features := make([]plumbing.Hash, pairsCount)
+	privates := make([]*testRemote, pairsCount)
+	for i := range pairs {
+		gitlab := newTestRemote(t, dir, fmt.Sprintf("gitlab-%d/repo.git", i))
+		private := newTestRemote(t, dir, fmt.Sprintf("private-%d/repo.git", i))
+		root := gitlab.commit("root")
+		private.commit("root")
+		gitlab.setBranch("main", root)
+		private.setBranch("main", root)
+		features[i] = gitlab.commit(fmt.Sprintf("feature-%d", i), root)
+		gitlab.setBranch("feature", features[i])
+		pairs[i], privates[i] = testPair(gitlab, private), private
+	}
+
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	logic.SetStateStore(state.NewStore(filepath.Join(dir, "state")))
+
+	logs := make([]strings.Builder, pairsCount)
+	errs := make(chan error, pairsCount)
+	for i := range pairs {
+		go func(i int) {
+			pairLogic := logic.WithLogger(log.New(&logs[i], fmt.Sprintf("[pair-%d] ", i), log.Lmsgprefix))
+			_, err := pairLogic.Synchronize(context.Background(), pairs[i], configs.Credential{}, configs.Credential{})
+			errs <- err
+		}(i)
+	}
+	for range pairs {
+		if err := <-errs; err != nil {
+			t.Errorf("Synchronize вернул ошибку: %v", err)
+		}
+	}
+
+	for i, private := range privates {
+		if private.branches()["feature"] != features[i] {
+			t.Errorf("Ветка feature пары %d не синхронизирована", i)
+		}
+		for _, line := range strings.Split(strings.TrimSpace(logs[i].String()), "\n") {
+			if !strings.HasPrefix(line, fmt.Sprintf("[pair-%d] ", i)) {
+				t.Errorf("Строка журнала пары %d без префикса пары: %q", i, line)
+			}
+		}
+		if !strings.Contains(logs[i].String(), pairs[i].GitlabURL) {
+			t.Errorf("Журнал пары %d не содержит ее адрес:\n%s", i, logs[i].String())
+		}
+	}
+}
+
+// Тест прерывания синхронизации: начатый push завершается, следующие ссылки не отправляются
+func TestSynchronizeCanceled(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	private.setBranch("main", root)
+	gitlab.setBranch("feature-a", gitlab.commit("feature-a", root))
+	gitlab.setBranch("feature-b", gitlab.commit("feature-b", root))
+	pair := testPair(gitlab, private)
+
+	repoManager := repository.NewManager(filepath.Join(dir, "work"))
+	logic := NewLogic(repoManager)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := logic.Synchronize(canceled, pair, configs.Credential{}, configs.Credential{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Ожидалась ошибка context.Canceled, получено: %v", err)
+	}
+	if len(private.branches()) != 1 {
+		t.Fatalf("Прерванная до начала синхронизация изменила ветки: %v", private.branches())
+	}
+
+	// Отмена во время первого push: зеркала обновлены и ссылки получены за четыре обращения к remote
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	calls := 0
+	repoManager.SetAuthFunc(func(repoURL string, cred configs.Credential) (transport.AuthMethod, error) {
+		if calls++; calls == 5 {
+			cancel()
+		}
+		return nil, nil
+	})
+	if _, err := logic.Synchronize(ctx, pair, configs.Credential{}, configs.Credential{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Ожидалась ошибка context.Canceled, получено: %v", err)
+	}
+	branches := private.branches()
+	if branches["feature-a"] != gitlab.branches()["feature-a"] {
+		t.Errorf("Начатый push ветки feature-a не завершен: %v", branches)
+	}
+	if _, exists := branches["feature-b"]; exists {
+		t.Error("Ветка feature-b отправлена после прерывания")
+	}
+
+	// Директория пары освобождена, следующая синхронизация продолжает работу
+	if _, err := logic.Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{}); err != nil {
+		t.Fatalf("Synchronize после прерывания вернул ошибку: %v", err)
+	}
+	if private.branches()["feature-b"] != gitlab.branches()["feature-b"] {
+		t.Error("Ветка feature-b не синхронизирована после прерывания")
+	}
+}
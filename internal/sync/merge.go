@@ -0,0 +1,243 @@
+package sync
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// repoHistory история коммитов локального зеркала, в котором есть объекты обеих сторон
+type repoHistory struct {
+	repo *git.Repository
+}
+
+// IsAncestor проверяет, является ли коммит ancestor предком коммита descendant
+func (h repoHistory) IsAncestor(ancestor, descendant plumbing.Hash) (bool, error) {
+	ancestorCommit, err := h.repo.CommitObject(ancestor)
+	if err != nil {
+		return false, err
+	}
+	descendantCommit, err := h.repo.CommitObject(descendant)
+	if err != nil {
+		return false, err
+	}
+	return ancestorCommit.IsAncestor(descendantCommit)
+}
+
+// MergeBase возвращает общий предок двух коммитов. Если лучших общих предков несколько,
+// возвращается первый из них, если общей истории нет — нулевой hash.
+func (h repoHistory) MergeBase(a, b plumbing.Hash) (plumbing.Hash, error) {
+	aCommit, err := h.repo.CommitObject(a)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	bCommit, err := h.repo.CommitObject(b)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	bases, err := aCommit.MergeBase(bCommit)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+	if len(bases) == 0 {
+		return plumbing.ZeroHash, nil
+	}
+	return bases[0].Hash, nil
+}
+
+// mergeSignature автор и коммиттер коммитов слияния, создаваемых сервисом
+func mergeSignature(when time.Time) object.Signature {
+	return object.Signature{Name: "git-sync", Email: "git-sync@localhost", When: when}
+}
+
+// createMergeCommit создает коммит слияния версий ветки из GitLab и приватного репозитория.
+// Первый репозиторий в repos должен содержать историю обеих сторон, новые объекты сохраняются во все repos.
+// Слияние выполняется пофайлово: если один и тот же файл изменен на обеих сторонах по-разному,
+// коммит не создается и возвращается список конфликтующих путей.
+func createMergeCommit(repos []*git.Repository, d Decision, when time.Time) (plumbing.Hash, []string, error) {
+	repo := repos[0]
+
+	baseFiles, err := commitFiles(repo, d.MergeBase)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+	gitlabFiles, err := commitFiles(repo, d.Gitlab)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+	privateFiles, err := commitFiles(repo, d.Private)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	merged, conflicts := mergeFiles(baseFiles, gitlabFiles, privateFiles)
+	if len(conflicts) > 0 {
+		return plumbing.ZeroHash, conflicts, nil
+	}
+
+	treeHash, err := buildTree(merged, repos)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+
+	signature := mergeSignature(when)
+	commit := &object.Commit{
+		Author:    signature,
+		Committer: signature,
+		Message: fmt.Sprintf("Merge branch '%s'\n\nСлияние версий GitLab (%s) и Private (%s), созданное git-sync.\n",
+			d.Name, d.Gitlab.String()[:7], d.Private.String()[:7]),
+		TreeHash:     treeHash,
+		ParentHashes: []plumbing.Hash{d.Gitlab, d.Private},
+	}
+	hash, err := storeObject(commit, repos)
+	if err != nil {
+		return plumbing.ZeroHash, nil, err
+	}
+	return hash, nil, nil
+}
+
+// commitFiles возвращает все файлы дерева коммита: полный путь -> запись дерева
+func commitFiles(repo *git.Repository, hash plumbing.Hash) (map[string]object.TreeEntry, error) {
+	commit, err := repo.CommitObject(hash)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, err
+	}
+
+	walker := object.NewTreeWalker(tree, true, nil)
+	defer walker.Close()
+
+	files := make(map[string]object.TreeEntry)
+	for {
+		name, entry, err := walker.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if entry.Mode == filemode.Dir {
+			continue
+		}
+		files[name] = entry
+	}
+	return files, nil
+}
+
+// mergeFiles выполняет трехстороннее слияние наборов файлов. Файл, измененный только на одной стороне,
+// берется с этой стороны; файл, по-разному измененный на обеих сторонах, считается конфликтом.
+func mergeFiles(base, gitlab, private map[string]object.TreeEntry) (map[string]object.TreeEntry, []string) {
+	paths := make(map[string]struct{})
+	for _, files := range []map[string]object.TreeEntry{base, gitlab, private} {
+		for p := range files {
+			paths[p] = struct{}{}
+		}
+	}
+
+	merged := make(map[string]object.TreeEntry)
+	conflicting := make(map[string]struct{})
+	for p := range paths {
+		b, bOK := base[p]
+		g, gOK := gitlab[p]
+		pr, prOK := private[p]
+
+		sameGP := gOK == prOK && sameEntry(g, pr)
+		sameBG := bOK == gOK && sameEntry(b, g)
+		sameBP := bOK == prOK && sameEntry(b, pr)
+
+		switch {
+		case sameGP || sameBG:
+			// Обе стороны совпадают или файл изменен только в приватном репозитории
+			if prOK {
+				merged[p] = pr
+			}
+		case sameBP:
+			// Файл изменен только в GitLab
+			if gOK {
+				merged[p] = g
+			}
+		default:
+			conflicting[p] = struct{}{}
+		}
+	}
+
+	// Файл на одной стороне не может совпадать по имени с директорией на другой
+	for p := range merged {
+		for dir := p; strings.Contains(dir, "/"); {
+			dir = dir[:strings.LastIndex(dir, "/")]
+			if _, isFile := merged[dir]; isFile {
+				conflicting[dir] = struct{}{}
+			}
+		}
+	}
+
+	conflicts := make([]string, 0, len(conflicting))
+	for p := range conflicting {
+		conflicts = append(conflicts, p)
+	}
+	sort.Strings(conflicts)
+	return merged, conflicts
+}
+
+// sameEntry сравнивает содержимое и режим файлов
+func sameEntry(a, b object.TreeEntry) bool {
+	return a.Hash == b.Hash && a.Mode == b.Mode
+}
+
+// buildTree сохраняет дерево из набора файлов с полными путями и возвращает hash корневого дерева
+func buildTree(files map[string]object.TreeEntry, repos []*git.Repository) (plumbing.Hash, error) {
+	var entries []object.TreeEntry
+	subdirs := make(map[string]map[string]object.TreeEntry)
+	for p, entry := range files {
+		dir, rest, nested := strings.Cut(p, "/")
+		if !nested {
+			entry.Name = p
+			entries = append(entries, entry)
+			continue
+		}
+		if subdirs[dir] == nil {
+			subdirs[dir] = make(map[string]object.TreeEntry)
+		}
+		subdirs[dir][rest] = entry
+	}
+
+	for dir, subFiles := range subdirs {
+		hash, err := buildTree(subFiles, repos)
+		if err != nil {
+			return plumbing.ZeroHash, err
+		}
+		entries = append(entries, object.TreeEntry{Name: dir, Mode: filemode.Dir, Hash: hash})
+	}
+
+	sort.Sort(object.TreeEntrySorter(entries))
+	return storeObject(&object.Tree{Entries: entries}, repos)
+}
+
+// storeObject кодирует объект и сохраняет его в хранилища всех репозиториев
+func storeObject(o interface {
+	Encode(plumbing.EncodedObject) error
+}, repos []*git.Repository) (plumbing.Hash, error) {
+	var hash plumbing.Hash
+	for _, repo := range repos {
+		obj := repo.Storer.NewEncodedObject()
+		if err := o.Encode(obj); err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("не удалось закодировать объект: %w", err)
+		}
+		stored, err := repo.Storer.SetEncodedObject(obj)
+		if err != nil {
+			return plumbing.ZeroHash, fmt.Errorf("не удалось сохранить объект: %w", err)
+		}
+		hash = stored
+	}
+	return hash, nil
+}
@@ -0,0 +1,291 @@
+package sync
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"text/template"
+
+	"git-sync/configs"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	gogitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+const (
+	// mergeRequestBranchPrefix префикс веток GitLab, из которых открываются merge request стратегии merge_request
+	mergeRequestBranchPrefix = "sync/"
+	// maxListedCommits наибольшее число коммитов каждой стороны в описании merge request
+	maxListedCommits = 20
+)
+
+// Шаблоны merge request по умолчанию
+const (
+	defaultMergeRequestTitle       = "Синхронизация {{.Branch}}: изменения приватного репозитория"
+	defaultMergeRequestDescription = "Ветка `{{.Branch}}` разошлась между GitLab и приватным репозиторием" +
+		"{{with .MergeBase}} после коммита {{.}}{{end}}. Ветка `{{.SourceBranch}}` содержит версию приватного репозитория; " +
+		"после слияния merge request синхронизация ветки продолжится автоматически.\n\n" +
+		"**Коммиты только в приватном репозитории:**\n" +
+		"{{range .PrivateCommits}}\n- {{.ShortHash}} {{.Subject}} ({{.Author}}){{end}}{{if .PrivateTruncated}}\n- ...{{end}}\n\n" +
+		"**Коммиты только в GitLab:**\n" +
+		"{{range .GitlabCommits}}\n- {{.ShortHash}} {{.Subject}} ({{.Author}}){{end}}{{if .GitlabTruncated}}\n- ...{{end}}\n"
+)
+
+// MergeRequestCommit коммит в шаблоне merge request
+type MergeRequestCommit struct {
+	Hash      string
+	ShortHash string
+	// Subject первая строка сообщения коммита
+	Subject string
+	Author  string
+}
+
+// MergeRequestData данные шаблонов заголовка и описания merge request
+type MergeRequestData struct {
+	// Branch ветка, в которую открывается merge request
+	Branch string
+	// SourceBranch ветка sync/<branch> с версией приватного репозитория
+	SourceBranch string
+	// MergeBase короткий hash общего предка; пуст, если у веток нет общей истории
+	MergeBase string
+	// PrivateCommits и GitlabCommits коммиты, которых нет на другой стороне, от новых к старым.
+	// В списки входят не более 20 самых новых коммитов; признаки *Truncated означают, что коммитов больше.
+	PrivateCommits   []MergeRequestCommit
+	GitlabCommits    []MergeRequestCommit
+	PrivateTruncated bool
+	GitlabTruncated  bool
+}
+
+// mergeRequestTemplates шаблоны заголовка и описания merge request пары
+type mergeRequestTemplates struct {
+	title       *template.Template
+	description *template.Template
+}
+
+// newMergeRequestTemplates разбирает шаблоны merge request пары, пустые заменяются шаблонами по умолчанию
+func newMergeRequestTemplates(pair configs.RepositoryPair) (*mergeRequestTemplates, error) {
+	titleText, descriptionText := pair.MergeRequestTitle, pair.MergeRequestDescription
+	if titleText == "" {
+		titleText = defaultMergeRequestTitle
+	}
+	if descriptionText == "" {
+		descriptionText = defaultMergeRequestDescription
+	}
+	title, err := template.New("merge_request_title").Parse(titleText)
+	if err != nil {
+		return nil, fmt.Errorf("неверный шаблон merge_request_title: %w", err)
+	}
+	description, err := template.New("merge_request_description").Parse(descriptionText)
+	if err != nil {
+		return nil, fmt.Errorf("неверный шаблон merge_request_description: %w", err)
+	}
+	return &mergeRequestTemplates{title: title, description: description}, nil
+}
+
+// render возвращает заголовок и описание merge request
+func (t *mergeRequestTemplates) render(data MergeRequestData) (string, string, error) {
+	var title, description strings.Builder
+	if err := t.title.Execute(&title, data); err != nil {
+		return "", "", fmt.Errorf("не удалось заполнить заголовок merge request: %w", err)
+	}
+	if err := t.description.Execute(&description, data); err != nil {
+		return "", "", fmt.Errorf("не удалось заполнить описание merge request: %w", err)
+	}
+	return strings.TrimSpace(title.String()), description.String(), nil
+}
+
+// mergeRequestBranch возвращает имя ветки GitLab, из которой открывается merge request в branch
+func mergeRequestBranch(branch string) string {
+	return mergeRequestBranchPrefix + branch
+}
+
+// withoutMergeRequestBranches исключает из сверки ветки sync/<branch>, которые есть только в GitLab,
+// если ветка <branch> тоже синхронизируется: они принадлежат merge request. Остальные ветки
+// с префиксом sync/ синхронизируются как обычные.
+func withoutMergeRequestBranches(decisions []Decision) []Decision {
+	branches := make(map[string]bool, len(decisions))
+	for _, d := range decisions {
+		if d.Kind == KindBranch {
+			branches[d.Name] = true
+		}
+	}
+	kept := decisions[:0]
+	for _, d := range decisions {
+		target, ok := strings.CutPrefix(d.Name, mergeRequestBranchPrefix)
+		if d.Kind == KindBranch && ok && branches[target] && d.Private.IsZero() {
+			continue
+		}
+		kept = append(kept, d)
+	}
+	return kept
+}
+
+// opensMergeRequests сообщает, есть ли среди решений merge request
+func opensMergeRequests(decisions []Decision) bool {
+	for _, d := range decisions {
+		if d.Action == ActionMergeRequest {
+			return true
+		}
+	}
+	return false
+}
+
+// withoutGitlabAPI оставляет ветки, для которых нужен merge request, конфликтами: без GitLab API его не открыть
+func withoutGitlabAPI(decisions []Decision) []Decision {
+	for i := range decisions {
+		d := &decisions[i]
+		if d.Action == ActionMergeRequest {
+			d.Action = ActionConflict
+			d.Reason += ", merge request не открыт: GitLab API недоступен"
+		}
+	}
+	return decisions
+}
+
+// applyMergeRequest отправляет версию приватного репозитория в ветку sync/<branch> в GitLab и открывает
+// merge request из нее в <branch> или обновляет описание уже открытого. Сама ветка не изменяется.
+func (l *Logic) applyMergeRequest(ctx context.Context, d *Decision, gitlabSide, privateSide *side) (RefResult, error) {
+	refResult := RefResult{Name: d.Name, GitlabHash: d.Gitlab, PrivateHash: d.Private, Status: StatusMergeRequest}
+	if gitlabSide.api == nil {
+		return refResult, fmt.Errorf("не удалось открыть merge request для ветки %s: GitLab API недоступен", d.Name)
+	}
+
+	source := mergeRequestBranch(d.Name)
+	if gitlabSide.branches[source] != d.Private {
+		refSpec := gitconfig.RefSpec(fmt.Sprintf("+%s:%s",
+			plumbing.NewRemoteReferenceName(syncRemoteName, d.Name), plumbing.NewBranchReferenceName(source)))
+		if err := l.repoManager.PushRefSpecs(ctx, gitlabSide.repo, []gitconfig.RefSpec{refSpec}, gitlabSide.cred); err != nil {
+			return refResult, fmt.Errorf("не удалось отправить ветку %s: %w", source, err)
+		}
+	}
+
+	// Приватное зеркало содержит историю обеих сторон
+	data, err := mergeRequestData(privateSide.repo, d)
+	if err != nil {
+		return refResult, fmt.Errorf("не удалось получить разошедшиеся коммиты ветки %s: %w", d.Name, err)
+	}
+	title, description, err := gitlabSide.mergeRequests.render(data)
+	if err != nil {
+		return refResult, err
+	}
+
+	api := gitlabSide.api
+	open, err := api.ListOpenMergeRequests(ctx, source, d.Name)
+	if err != nil {
+		return refResult, err
+	}
+	var mergeRequest *gogitlab.MergeRequest
+	verb := "открыт"
+	if len(open) > 0 {
+		verb = "обновлен"
+		mergeRequest, err = api.UpdateMergeRequest(ctx, open[0].IID, &gogitlab.UpdateMergeRequestOptions{
+			Title:       gogitlab.Ptr(title),
+			Description: gogitlab.Ptr(description),
+		})
+	} else {
+		mergeRequest, err = api.CreateMergeRequest(ctx, &gogitlab.CreateMergeRequestOptions{
+			SourceBranch:       gogitlab.Ptr(source),
+			TargetBranch:       gogitlab.Ptr(d.Name),
+			Title:              gogitlab.Ptr(title),
+			Description:        gogitlab.Ptr(description),
+			RemoveSourceBranch: gogitlab.Ptr(true),
+		})
+	}
+	if err != nil {
+		return refResult, err
+	}
+
+	refResult.Message = fmt.Sprintf("%s, версия Private отправлена в ветку %s, %s merge request !%d %s",
+		d.Reason, source, verb, mergeRequest.IID, mergeRequest.WebURL)
+	l.logger.Printf("Предупреждение: конфликт, ветка %s: %s", d.Name, refResult.Message)
+	return refResult, nil
+}
+
+// mergeRequestData собирает данные шаблонов merge request для разошедшейся ветки
+func mergeRequestData(repo *git.Repository, d *Decision) (MergeRequestData, error) {
+	data := MergeRequestData{Branch: d.Name, SourceBranch: mergeRequestBranch(d.Name)}
+	if !d.MergeBase.IsZero() {
+		data.MergeBase = d.MergeBase.String()[:7]
+	}
+	var err error
+	data.PrivateCommits, data.PrivateTruncated, err = divergingCommits(repo, d.Private, d.MergeBase, maxListedCommits)
+	if err != nil {
+		return data, err
+	}
+	data.GitlabCommits, data.GitlabTruncated, err = divergingCommits(repo, d.Gitlab, d.MergeBase, maxListedCommits)
+	return data, err
+}
+
+// divergingCommits возвращает не более limit самых новых коммитов, достижимых из tip и не достижимых
+// из mergeBase, от новых к старым, и признак того, что таких коммитов больше. Нулевой mergeBase означает,
+// что общей истории нет и в список входит вся история tip.
+func divergingCommits(repo *git.Repository, tip, mergeBase plumbing.Hash, limit int) ([]MergeRequestCommit, bool, error) {
+	h := repoHistory{repo: repo}
+	tipCommit, err := repo.CommitObject(tip)
+	if err != nil {
+		return nil, false, fmt.Errorf("не удалось прочитать коммит %s: %w", tip, err)
+	}
+
+	// Как git log, история обходится от новых коммитов к старым, поэтому обход останавливается,
+	// когда найдено на один коммит больше limit, а не на всей истории tip
+	var found []*object.Commit
+	seen := map[plumbing.Hash]bool{tip: true}
+	pending := []*object.Commit{tipCommit}
+	for len(pending) > 0 && len(found) <= limit {
+		newest := 0
+		for i, c := range pending {
+			if c.Committer.When.After(pending[newest].Committer.When) {
+				newest = i
+			}
+		}
+		commit := pending[newest]
+		pending = append(pending[:newest], pending[newest+1:]...)
+		if commit.Hash == mergeBase {
+			continue
+		}
+		if !mergeBase.IsZero() {
+			// Коммиты из истории общего предка, например через слияние старой ветки, общие для сторон
+			shared, err := h.IsAncestor(commit.Hash, mergeBase)
+			if err != nil {
+				return nil, false, err
+			}
+			if shared {
+				continue
+			}
+		}
+		found = append(found, commit)
+		for _, parent := range commit.ParentHashes {
+			if seen[parent] {
+				continue
+			}
+			seen[parent] = true
+			parentCommit, err := repo.CommitObject(parent)
+			if err != nil {
+				return nil, false, fmt.Errorf("не удалось прочитать коммит %s: %w", parent, err)
+			}
+			pending = append(pending, parentCommit)
+		}
+	}
+
+	// Даты коммитов не обязаны убывать вдоль истории, поэтому найденные коммиты сортируются до усечения
+	sort.SliceStable(found, func(i, j int) bool { return found[i].Committer.When.After(found[j].Committer.When) })
+	truncated := len(found) > limit
+	if truncated {
+		found = found[:limit]
+	}
+	commits := make([]MergeRequestCommit, 0, len(found))
+	for _, c := range found {
+		subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+		commits = append(commits, MergeRequestCommit{
+			Hash:      c.Hash.String(),
+			ShortHash: c.Hash.String()[:7],
+			Subject:   subject,
+			Author:    c.Author.Name,
+		})
+	}
+	return commits, truncated, nil
+}
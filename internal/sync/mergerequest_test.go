@@ -0,0 +1,279 @@
+package sync
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"git-sync/configs"
+	gitlabapi "git-sync/internal/gitlab"
+	"git-sync/internal/repository"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	gogitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+const fakeMergeRequestProject = 42
+
+// fakeMergeRequestServer поддельный GitLab, который хранит merge request проекта в памяти
+type fakeMergeRequestServer struct {
+	t      *testing.T
+	server *httptest.Server
+	mu     gosync.Mutex
+	merges []*gogitlab.MergeRequest
+}
+
+func newFakeMergeRequestServer(t *testing.T) *fakeMergeRequestServer {
+	t.Helper()
+	f := &fakeMergeRequestServer{t: t}
+
+	project := "/api/v4/projects/" + strconv.Itoa(fakeMergeRequestProject)
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+project+"/merge_requests", f.list)
+	mux.HandleFunc("POST "+project+"/merge_requests", f.create)
+	mux.HandleFunc("PUT "+project+"/merge_requests/{iid}", f.update)
+	f.server = httptest.NewServer(mux)
+	t.Cleanup(f.server.Close)
+	return f
+}
+
+// logic создает Logic, который работает с merge request через поддельный GitLab
+func (f *fakeMergeRequestServer) logic(dir string) *Logic {
+	f.t.Helper()
+	client, err := gitlabapi.NewClient(f.server.URL, "token", fakeMergeRequestProject)
+	if err != nil {
+		f.t.Fatalf("Не удалось создать GitLab клиент: %v", err)
+	}
+	return logicWithAPI(dir, client)
+}
+
+func (f *fakeMergeRequestServer) list(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	query := r.URL.Query()
+	merges := []*gogitlab.MergeRequest{}
+	for _, mr := range f.merges {
+		if mr.State == query.Get("state") && mr.SourceBranch == query.Get("source_branch") && mr.TargetBranch == query.Get("target_branch") {
+			merges = append(merges, mr)
+		}
+	}
+	f.write(w, http.StatusOK, merges)
+}
+
+func (f *fakeMergeRequestServer) create(w http.ResponseWriter, r *http.Request) {
+	var opt gogitlab.CreateMergeRequestOptions
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	iid := int64(len(f.merges) + 1)
+	mr := &gogitlab.MergeRequest{BasicMergeRequest: gogitlab.BasicMergeRequest{
+		IID:                     iid,
+		State:                   "opened",
+		SourceBranch:            *opt.SourceBranch,
+		TargetBranch:            *opt.TargetBranch,
+		Title:                   *opt.Title,
+		Description:             *opt.Description,
+		ForceRemoveSourceBranch: opt.RemoveSourceBranch != nil && *opt.RemoveSourceBranch,
+		WebURL:                  f.server.URL + "/group/project/-/merge_requests/" + strconv.FormatInt(iid, 10),
+	}}
+	f.merges = append(f.merges, mr)
+	f.write(w, http.StatusCreated, mr)
+}
+
+func (f *fakeMergeRequestServer) update(w http.ResponseWriter, r *http.Request) {
+	var opt gogitlab.UpdateMergeRequestOptions
+	if err := json.NewDecoder(r.Body).Decode(&opt); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	iid, _ := strconv.Atoi(r.PathValue("iid"))
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if iid < 1 || iid > len(f.merges) {
+		http.Error(w, `{"message":"404 Not found"}`, http.StatusNotFound)
+		return
+	}
+	mr := f.merges[iid-1]
+	if opt.Title != nil {
+		mr.Title = *opt.Title
+	}
+	if opt.Description != nil {
+		mr.Description = *opt.Description
+	}
+	f.write(w, http.StatusOK, mr)
+}
+
+func (f *fakeMergeRequestServer) write(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		f.t.Errorf("Не удалось записать ответ: %v", err)
+	}
+}
+
+// mergeRequests возвращает копии сохраненных merge request
+func (f *fakeMergeRequestServer) mergeRequests() []gogitlab.MergeRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	merges := make([]gogitlab.MergeRequest, 0, len(f.merges))
+	for _, mr := range f.merges {
+		merges = append(merges, *mr)
+	}
+	return merges
+}
+
+// Тест стратегии merge_request: версия Private отправляется в ветку sync/main, merge request открывается
+// при первой синхронизации и обновляется при следующих
+func TestSynchronizeMergeRequest(t *testing.T) {
+	dir := t.TempDir()
+	gitlab, private, gitlabTip, privateTip := divergedRemotes(t, dir, true)
+	pair := testPair(gitlab, private)
+	pair.ConflictStrategy = configs.ConflictMergeRequest
+	pair.MergeRequestTitle = "Sync {{.Branch}}: {{len .PrivateCommits}} commit(s) from private"
+	fake := newFakeMergeRequestServer(t)
+	logic := fake.logic(dir)
+
+	synchronize := func() RefResult {
+		t.Helper()
+		result, err := logic.Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{})
+		if err != nil {
+			t.Fatalf("Synchronize вернул ошибку: %v", err)
+		}
+		if len(result.Branches) != 1 {
+			t.Fatalf("Ожидался результат только для ветки main: %+v", result.Branches)
+		}
+		if !result.HasConflicts() {
+			t.Error("Ветка с открытым merge request должна считаться конфликтом")
+		}
+		return result.Branches[0]
+	}
+
+	main := synchronize()
+	if main.Status != StatusMergeRequest || !strings.Contains(main.Message, "открыт merge request !1") {
+		t.Errorf("Ожидался открытый merge request !1, получено %+v", main)
+	}
+	gitlabBranches, privateBranches := gitlab.branches(), private.branches()
+	if gitlabBranches["main"] != gitlabTip || privateBranches["main"] != privateTip {
+		t.Error("Ветка main не должна изменяться при стратегии merge_request")
+	}
+	if gitlabBranches["sync/main"] != privateTip {
+		t.Errorf("Ветка sync/main в GitLab должна указывать на версию Private: %s", gitlabBranches["sync/main"])
+	}
+
+	merges := fake.mergeRequests()
+	if len(merges) != 1 {
+		t.Fatalf("Ожидался один merge request, получено %d", len(merges))
+	}
+	mr := merges[0]
+	if mr.SourceBranch != "sync/main" || mr.TargetBranch != "main" || !mr.ForceRemoveSourceBranch {
+		t.Errorf("Неверные ветки merge request: %s -> %s (удаление %v)", mr.SourceBranch, mr.TargetBranch, mr.ForceRemoveSourceBranch)
+	}
+	if mr.Title != "Sync main: 1 commit(s) from private" {
+		t.Errorf("Неверный заголовок: %q", mr.Title)
+	}
+	for _, line := range []string{
+		"- " + privateTip.String()[:7] + " private-change (git-sync test)",
+		"- " + gitlabTip.String()[:7] + " gitlab-change (git-sync test)",
+	} {
+		if !strings.Contains(mr.Description, line) {
+			t.Errorf("Описание не содержит %q:\n%s", line, mr.Description)
+		}
+	}
+	if strings.Contains(mr.Description, " root (") {
+		t.Errorf("Описание не должно содержать общие коммиты:\n%s", mr.Description)
+	}
+
+	// Новый коммит в Private обновляет ветку sync/main и описание открытого merge request
+	newTip := private.commitFiles("private-fix", map[string]string{"README.md": "private fix"}, privateTip)
+	private.setBranch("main", newTip)
+	main = synchronize()
+	if main.Status != StatusMergeRequest || !strings.Contains(main.Message, "обновлен merge request !1") {
+		t.Errorf("Ожидался обновленный merge request !1, получено %+v", main)
+	}
+	if got := gitlab.branches()["sync/main"]; got != newTip {
+		t.Errorf("Ветка sync/main не обновлена: %s", got)
+	}
+	if _, ok := private.branches()["sync/main"]; ok {
+		t.Error("Ветка sync/main не должна попадать в приватный репозиторий")
+	}
+	merges = fake.mergeRequests()
+	if len(merges) != 1 {
+		t.Fatalf("Ожидался один merge request после обновления, получено %d", len(merges))
+	}
+	if merges[0].Title != "Sync main: 2 commit(s) from private" || !strings.Contains(merges[0].Description, "private-fix") {
+		t.Errorf("Merge request не обновлен: %q\n%s", merges[0].Title, merges[0].Description)
+	}
+}
+
+// Тест, что без GitLab API разошедшаяся ветка остается конфликтом
+func TestSynchronizeMergeRequestWithoutAPI(t *testing.T) {
+	dir := t.TempDir()
+	gitlab, private, gitlabTip, _ := divergedRemotes(t, dir, true)
+	pair := testPair(gitlab, private)
+	pair.ConflictStrategy = configs.ConflictMergeRequest
+
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	result, err := logic.Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Synchronize вернул ошибку: %v", err)
+	}
+	main := result.Branches[0]
+	if main.Status != StatusConflict || !strings.Contains(main.Message, "GitLab API недоступен") {
+		t.Errorf("Ожидался конфликт без GitLab API, получено %+v", main)
+	}
+	if got := gitlab.branches(); got["main"] != gitlabTip || !got["sync/main"].IsZero() {
+		t.Errorf("Ветки GitLab не должны изменяться: %v", got)
+	}
+}
+
+// Тест, что в описание попадают самые новые коммиты, даже если старая ветка слита ближе к tip
+func TestDivergingCommits(t *testing.T) {
+	remote := newTestRemote(t, t.TempDir(), "repo.git")
+	root := remote.commitFiles("root", map[string]string{"README.md": "readme"})
+	rootCommit, err := remote.repo.CommitObject(root)
+	if err != nil {
+		t.Fatalf("Не удалось прочитать коммит: %v", err)
+	}
+	commit := func(message string, month time.Month, parents ...plumbing.Hash) plumbing.Hash {
+		signature := object.Signature{Name: "git-sync test", Email: "test@git-sync.local", When: time.Date(2024, month, 1, 0, 0, 0, 0, time.UTC)}
+		return remote.store(&object.Commit{
+			Author: signature, Committer: signature, Message: message, TreeHash: rootCommit.TreeHash, ParentHashes: parents,
+		})
+	}
+
+	// Первый родитель слияния - старая ветка, второй - новые коммиты
+	old1 := commit("old-1", time.February, root)
+	old2 := commit("old-2", time.March, old1)
+	new1 := commit("new-1", time.May, root)
+	new2 := commit("new-2", time.June, new1)
+	merge := commit("merge", time.July, old2, new2)
+
+	commits, truncated, err := divergingCommits(remote.repo, merge, root, 3)
+	if err != nil {
+		t.Fatalf("divergingCommits вернул ошибку: %v", err)
+	}
+	var subjects []string
+	for _, c := range commits {
+		subjects = append(subjects, c.Subject)
+	}
+	if want := []string{"merge", "new-2", "new-1"}; !slices.Equal(subjects, want) || !truncated {
+		t.Errorf("Ожидались самые новые коммиты %v с признаком усечения, получено %v (%v)", want, subjects, truncated)
+	}
+
+	commits, truncated, err = divergingCommits(remote.repo, merge, root, 10)
+	if err != nil || len(commits) != 5 || truncated {
+		t.Errorf("Ожидались все 5 коммитов без усечения, получено %d (%v, %v)", len(commits), truncated, err)
+	}
+}
@@ -0,0 +1,115 @@
+package sync
+
+import (
+	"context"
+	"git-sync/configs"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Стороны синхронизации в плане
+const (
+	SideGitlab  = "gitlab"
+	SidePrivate = "private"
+)
+
+// PlannedUpdate изменение ссылки, которое выполнила бы синхронизация. Пустые OldHash и NewHash означают,
+// что ссылки нет до или после изменения; для пропусков и конфликтов сторона и значения не заполняются.
+type PlannedUpdate struct {
+	Ref     string `json:"ref"`
+	Side    string `json:"side,omitempty"`
+	OldHash string `json:"old,omitempty"`
+	NewHash string `json:"new,omitempty"`
+	Action  Action `json:"action"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// Plan план синхронизации пары репозиториев
+type Plan struct {
+	GitlabURL      string          `json:"gitlab_url"`
+	PrivateRepoURL string          `json:"private_repo_url"`
+	Updates        []PlannedUpdate `json:"updates"`
+}
+
+// Plan обновляет зеркала обеих сторон и получает ссылки так же, как Synchronize, но ничего не отправляет
+// и не сохраняет состояние. Возвращает изменения ссылок, которые выполнила бы синхронизация.
+func (l *Logic) Plan(ctx context.Context, pair configs.RepositoryPair, gitlabCred, privateCred configs.Credential) (*Plan, error) {
+	plan := &Plan{GitlabURL: pair.GitlabURL, PrivateRepoURL: pair.PrivateRepoURL, Updates: []PlannedUpdate{}}
+	unlock, err := l.lockPair(pair)
+	if err != nil {
+		return plan, err
+	}
+	defer unlock()
+
+	prepared, err := l.prepare(ctx, pair, gitlabCred, privateCred)
+	if err != nil {
+		return plan, err
+	}
+	for i := range prepared.decisions {
+		plan.Updates = append(plan.Updates, l.planDecision(&prepared.decisions[i], prepared.gitlab, prepared.private)...)
+	}
+	return plan, nil
+}
+
+// planDecision переводит решение сверки в изменения ссылок на сторонах
+func (l *Logic) planDecision(d *Decision, gitlabSide, privateSide *side) []PlannedUpdate {
+	ref := plumbing.NewBranchReferenceName(d.Name)
+	if d.Kind == KindTag {
+		ref = plumbing.NewTagReferenceName(d.Name)
+	}
+
+	targetSide, targetHash := SidePrivate, d.Private
+	if d.Direction == DirectionToGitlab {
+		targetSide, targetHash = SideGitlab, d.Gitlab
+	}
+	update := PlannedUpdate{Ref: ref.String(), Side: targetSide, OldHash: hashString(targetHash), Action: d.Action, Reason: d.Reason}
+
+	switch d.Action {
+	case ActionNone:
+		return nil
+	case ActionCreate, ActionFastForward, ActionForce:
+		update.NewHash = hashString(d.Resolved)
+	case ActionDelete:
+		if d.DryRun {
+			// Удаление в режиме dry-run не выполняется и при синхронизации
+			update.Action = ActionSkip
+			update.Reason += ", удаление в режиме dry-run"
+		}
+	case ActionConflictBranch:
+		name, exists := l.conflictBranch(d, gitlabSide, privateSide)
+		if exists {
+			return []PlannedUpdate{{Ref: ref.String(), Action: ActionSkip, Reason: d.Reason + ", версия Private уже сохранена в ветке " + name}}
+		}
+		conflictRef := plumbing.NewBranchReferenceName(name).String()
+		reason := d.Reason + ", сохранение версии Private"
+		return []PlannedUpdate{
+			{Ref: conflictRef, Side: SideGitlab, NewHash: d.Private.String(), Action: ActionCreate, Reason: reason},
+			{Ref: conflictRef, Side: SidePrivate, NewHash: d.Private.String(), Action: ActionCreate, Reason: reason},
+		}
+	case ActionMerge:
+		// Коммит слияния создается только при синхронизации, поэтому новое значение неизвестно
+		reason := d.Reason + ", коммит слияния, если изменения не пересекаются"
+		return []PlannedUpdate{
+			{Ref: ref.String(), Side: SideGitlab, OldHash: d.Gitlab.String(), Action: ActionMerge, Reason: reason},
+			{Ref: ref.String(), Side: SidePrivate, OldHash: d.Private.String(), Action: ActionMerge, Reason: reason},
+		}
+	case ActionMergeRequest:
+		source := mergeRequestBranch(d.Name)
+		sourceRef := plumbing.NewBranchReferenceName(source).String()
+		reason := d.Reason + ", merge request из ветки " + source
+		return []PlannedUpdate{{Ref: sourceRef, Side: SideGitlab, OldHash: hashString(gitlabSide.branches[source]),
+			NewHash: d.Private.String(), Action: ActionMergeRequest, Reason: reason}}
+	default:
+		// Пропуски и конфликты ничего не меняют ни на одной из сторон
+		update.Side, update.OldHash = "", ""
+	}
+	return []PlannedUpdate{update}
+}
+
+// hashString возвращает hash в виде строки или пустую строку для нулевого hash
+func hashString(hash plumbing.Hash) string {
+	if hash.IsZero() {
+		return ""
+	}
+	return hash.String()
+}
@@ -0,0 +1,129 @@
+package sync
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"git-sync/configs"
+	"git-sync/internal/repository"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+func TestPlanDecision(t *testing.T) {
+	a1, a2, b1 := testHash("a1"), testHash("a2"), testHash("b1")
+	logic := NewLogic(repository.NewManager(t.TempDir()))
+	logic.now = func() time.Time { return time.Date(2024, 3, 5, 0, 0, 0, 0, time.UTC) }
+	gitlabSide := &side{branches: map[string]plumbing.Hash{"main": a2, "sync-conflict/main/2024-03-05": a1}}
+	privateSide := &side{branches: map[string]plumbing.Hash{"main": b1}}
+
+	testCases := []struct {
+		name     string
+		decision Decision
+		expected []PlannedUpdate
+	}{
+		{
+			name:     "None",
+			decision: Decision{Kind: KindBranch, Name: "main", Gitlab: a1, Private: a1, Action: ActionNone},
+		},
+		{
+			name:     "FastForward",
+			decision: Decision{Kind: KindBranch, Name: "main", Gitlab: a1, Private: a2, Action: ActionFastForward, Direction: DirectionToGitlab, Resolved: a2},
+			expected: []PlannedUpdate{{Ref: "refs/heads/main", Side: SideGitlab, OldHash: a1.String(), NewHash: a2.String(), Action: ActionFastForward}},
+		},
+		{
+			name:     "CreateTag",
+			decision: Decision{Kind: KindTag, Name: "v1.0", Gitlab: a1, Action: ActionCreate, Direction: DirectionToPrivate, Resolved: a1},
+			expected: []PlannedUpdate{{Ref: "refs/tags/v1.0", Side: SidePrivate, NewHash: a1.String(), Action: ActionCreate}},
+		},
+		{
+			name:     "Force",
+			decision: Decision{Kind: KindBranch, Name: "main", Gitlab: a2, Private: b1, Action: ActionForce, Direction: DirectionToPrivate, Resolved: a2, Reason: "разошлись"},
+			expected: []PlannedUpdate{{Ref: "refs/heads/main", Side: SidePrivate, OldHash: b1.String(), NewHash: a2.String(), Action: ActionForce, Reason: "разошлись"}},
+		},
+		{
+			name:     "Delete",
+			decision: Decision{Kind: KindBranch, Name: "old", Private: a1, Action: ActionDelete, Direction: DirectionToPrivate, Reason: "удалена в GitLab"},
+			expected: []PlannedUpdate{{Ref: "refs/heads/old", Side: SidePrivate, OldHash: a1.String(), Action: ActionDelete, Reason: "удалена в GitLab"}},
+		},
+		{
+			name:     "DeleteDryRun",
+			decision: Decision{Kind: KindBranch, Name: "old", Private: a1, Action: ActionDelete, Direction: DirectionToPrivate, Reason: "удалена в GitLab", DryRun: true},
+			expected: []PlannedUpdate{{Ref: "refs/heads/old", Side: SidePrivate, OldHash: a1.String(), Action: ActionSkip, Reason: "удалена в GitLab, удаление в режиме dry-run"}},
+		},
+		{
+			name:     "Conflict",
+			decision: Decision{Kind: KindTag, Name: "v2.0", Gitlab: a1, Private: b1, Action: ActionConflict, Reason: "тег указывает на разные объекты"},
+			expected: []PlannedUpdate{{Ref: "refs/tags/v2.0", Action: ActionConflict, Reason: "тег указывает на разные объекты"}},
+		},
+		{
+			name:     "ConflictBranch",
+			decision: Decision{Kind: KindBranch, Name: "main", Gitlab: a2, Private: b1, Action: ActionConflictBranch, Reason: "разошлись"},
+			expected: []PlannedUpdate{
+				{Ref: "refs/heads/sync-conflict/main/2024-03-05-2", Side: SideGitlab, NewHash: b1.String(), Action: ActionCreate, Reason: "разошлись, сохранение версии Private"},
+				{Ref: "refs/heads/sync-conflict/main/2024-03-05-2", Side: SidePrivate, NewHash: b1.String(), Action: ActionCreate, Reason: "разошлись, сохранение версии Private"},
+			},
+		},
+		{
+			name:     "Merge",
+			decision: Decision{Kind: KindBranch, Name: "main", Gitlab: a2, Private: b1, Action: ActionMerge, Reason: "разошлись"},
+			expected: []PlannedUpdate{
+				{Ref: "refs/heads/main", Side: SideGitlab, OldHash: a2.String(), Action: ActionMerge, Reason: "разошлись, коммит слияния, если изменения не пересекаются"},
+				{Ref: "refs/heads/main", Side: SidePrivate, OldHash: b1.String(), Action: ActionMerge, Reason: "разошлись, коммит слияния, если изменения не пересекаются"},
+			},
+		},
+		{
+			name:     "MergeRequest",
+			decision: Decision{Kind: KindBranch, Name: "main", Gitlab: a2, Private: b1, Action: ActionMergeRequest, Reason: "разошлись"},
+			expected: []PlannedUpdate{{Ref: "refs/heads/sync/main", Side: SideGitlab, NewHash: b1.String(), Action: ActionMergeRequest, Reason: "разошлись, merge request из ветки sync/main"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := logic.planDecision(&tc.decision, gitlabSide, privateSide)
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("Ожидалось %+v, получено %+v", tc.expected, got)
+			}
+		})
+	}
+}
+
+// Тест, что план содержит изменения обеих сторон и не изменяет репозитории
+func TestPlanDoesNotPush(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	private.setBranch("main", root)
+	feature := gitlab.commit("feature", root)
+	gitlab.setBranch("feature", feature)
+	private.setTag("v1.0", root)
+
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	plan, err := logic.Plan(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Plan вернул ошибку: %v", err)
+	}
+
+	expected := []PlannedUpdate{
+		{Ref: "refs/heads/feature", Side: SidePrivate, NewHash: feature.String(), Action: ActionCreate},
+		{Ref: "refs/tags/v1.0", Side: SideGitlab, NewHash: root.String(), Action: ActionCreate},
+	}
+	if !reflect.DeepEqual(plan.Updates, expected) {
+		t.Errorf("Ожидался план %+v, получено %+v", expected, plan.Updates)
+	}
+
+	if _, exists := private.branches()["feature"]; exists {
+		t.Error("Plan не должен создавать ветки")
+	}
+	if _, exists := gitlab.tags()["v1.0"]; exists {
+		t.Error("Plan не должен создавать теги")
+	}
+}
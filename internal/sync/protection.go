@@ -0,0 +1,184 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"git-sync/configs"
+	"git-sync/internal/gitlab"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	gogitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// GitlabAPIFactory создает клиент GitLab API для проекта GitLab пары. Возвращает nil без ошибки,
+// если проект недоступен через API, например если GitLab репозиторий пары - локальный путь.
+type GitlabAPIFactory func(ctx context.Context, pair configs.RepositoryPair, cred configs.Credential) (gitlab.API, error)
+
+// SetGitlabAPI задает способ получения клиента GitLab API. С ним синхронизация проверяет правила защиты
+// веток до push и не отправляет изменения, которые GitLab отклонит. Без него защита не проверяется,
+// а стратегия merge_request оставляет разошедшиеся ветки конфликтами.
+func (l *Logic) SetGitlabAPI(factory GitlabAPIFactory) {
+	l.gitlabAPI = factory
+}
+
+// protection правила защиты веток проекта GitLab и права пользователя токена
+type protection struct {
+	api   gitlab.API
+	rules []*gogitlab.ProtectedBranch
+	// access уровень доступа пользователя к проекту; known - сообщил ли его GitLab
+	access gogitlab.AccessLevelValue
+	known  bool
+	// override способ ослабить защиту для перезаписи ветки, configs.Protection*
+	override string
+}
+
+// loadProtection получает правила защиты веток проекта GitLab пары. Возвращает nil, если GitLab API
+// недоступен или ни одно решение не отправляет ветку в GitLab.
+func loadProtection(ctx context.Context, api gitlab.API, pair configs.RepositoryPair, decisions []Decision) (*protection, error) {
+	if api == nil || !pushesToGitlab(decisions) {
+		return nil, nil
+	}
+
+	rules, err := api.ListProtectedBranches(ctx)
+	if err != nil {
+		return nil, err
+	}
+	p := &protection{api: api, rules: rules, override: pair.ProtectionOverride}
+	if len(rules) > 0 {
+		project, err := api.GetProject(ctx)
+		if err != nil {
+			return nil, err
+		}
+		p.access, p.known = gitlab.ProjectAccessLevel(project)
+	}
+	return p, nil
+}
+
+// pushesToGitlab сообщает, есть ли среди решений изменение ветки в GitLab
+func pushesToGitlab(decisions []Decision) bool {
+	for i := range decisions {
+		if changesGitlabBranch(&decisions[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// changesGitlabBranch сообщает, изменяет ли решение ветку в GitLab. Ветки конфликтов sync-conflict/...
+// создаются под новым именем и не проверяются.
+func changesGitlabBranch(d *Decision) bool {
+	if d.Kind != KindBranch {
+		return false
+	}
+	switch d.Action {
+	case ActionCreate, ActionFastForward, ActionForce, ActionDelete:
+		return d.Direction == DirectionToGitlab
+	case ActionMerge:
+		return true
+	}
+	return false
+}
+
+// applyProtection заменяет изменения веток GitLab, которые запрещены правилами защиты, на блокировку.
+// Заблокированная ветка не изменяется ни на одной из сторон.
+func applyProtection(decisions []Decision, p *protection) []Decision {
+	for i := range decisions {
+		d := &decisions[i]
+		if !changesGitlabBranch(d) {
+			continue
+		}
+		rules := gitlab.MatchProtectedBranches(p.rules, d.Name)
+		if len(rules) == 0 {
+			continue
+		}
+		reason := p.blockReason(d, rules)
+		if reason == "" {
+			continue
+		}
+		d.Action = ActionBlocked
+		d.Resolved = plumbing.ZeroHash
+		d.DryRun = false
+		d.Reason = fmt.Sprintf("%s, ветка защищена в GitLab (%s): %s", d.Reason, ruleNames(rules), reason)
+	}
+	return decisions
+}
+
+// blockReason возвращает причину, по которой GitLab отклонит изменение ветки, или пустую строку
+func (p *protection) blockReason(d *Decision, rules []*gogitlab.ProtectedBranch) string {
+	pushAllowed := gitlab.PushAllowed(rules, p.access, p.known)
+	switch {
+	case d.Action == ActionDelete:
+		return "удаление защищенной ветки через push запрещено"
+	case d.Action == ActionForce && p.override == configs.ProtectionUnprotect:
+		// Защита снимается на время перезаписи
+		return ""
+	case !pushAllowed:
+		return "push запрещен для пользователя токена"
+	case d.Action == ActionForce && !gitlab.ForcePushAllowed(rules) && p.override != configs.ProtectionAllowForcePush:
+		return "force push запрещен"
+	}
+	return ""
+}
+
+// ruleNames возвращает имена правил защиты через запятую
+func ruleNames(rules []*gogitlab.ProtectedBranch) string {
+	names := make([]string, 0, len(rules))
+	for _, rule := range rules {
+		names = append(names, rule.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// liftProtection ослабляет защиту ветки branch для перезаписи способом p.override и возвращает функцию,
+// которая восстанавливает исходную защиту. Если ослабить защиту не удалось, уже измененные правила
+// восстанавливаются до возврата ошибки.
+func (l *Logic) liftProtection(ctx context.Context, p *protection, branch string) (func() error, error) {
+	var restores []func() error
+	restore := func() error {
+		var errs []error
+		for i := len(restores) - 1; i >= 0; i-- {
+			errs = append(errs, restores[i]())
+		}
+		if err := errors.Join(errs...); err != nil {
+			return fmt.Errorf("не удалось восстановить защиту ветки %s в GitLab: %w", branch, err)
+		}
+		if len(restores) > 0 {
+			l.logger.Printf("Защита ветки %s в GitLab восстановлена", branch)
+		}
+		return nil
+	}
+
+	rules := gitlab.MatchProtectedBranches(p.rules, branch)
+	for _, rule := range rules {
+		var err error
+		switch p.override {
+		case configs.ProtectionAllowForcePush:
+			if rule.AllowForcePush {
+				continue
+			}
+			err = p.api.UpdateProtectedBranch(ctx, rule.Name, &gogitlab.UpdateProtectedBranchOptions{AllowForcePush: gogitlab.Ptr(true)})
+			if err == nil {
+				restores = append(restores, func() error {
+					return p.api.UpdateProtectedBranch(ctx, rule.Name, &gogitlab.UpdateProtectedBranchOptions{AllowForcePush: gogitlab.Ptr(false)})
+				})
+			}
+		case configs.ProtectionUnprotect:
+			err = p.api.UnprotectBranch(ctx, rule.Name)
+			if err == nil {
+				restores = append(restores, func() error {
+					return p.api.ProtectBranch(ctx, gitlab.ProtectOptions(rule))
+				})
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("не удалось ослабить защиту ветки %s в GitLab: %w", branch, err), restore())
+		}
+		l.logger.Printf("Защита ветки %s в GitLab временно ослаблена (%s): правило %s", branch, p.override, rule.Name)
+	}
+	return restore, nil
+}
@@ -0,0 +1,343 @@
+package sync
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"git-sync/configs"
+	gitlabapi "git-sync/internal/gitlab"
+	"git-sync/internal/repository"
+
+	gogitlab "gitlab.com/gitlab-org/api/client-go"
+)
+
+// fakeProtectionAPI GitLab API с правилами защиты веток в памяти. Остальные методы API не реализованы:
+// их вызов завершается паникой.
+type fakeProtectionAPI struct {
+	gitlabapi.API
+	rules  map[string]*gogitlab.ProtectedBranch
+	access gogitlab.AccessLevelValue
+	calls  []string
+	// onLift вызывается после ослабления защиты, до перезаписи ветки
+	onLift func()
+	// failRestore - ошибка восстановления защиты
+	failRestore error
+}
+
+func newFakeProtectionAPI(access gogitlab.AccessLevelValue, rules ...*gogitlab.ProtectedBranch) *fakeProtectionAPI {
+	api := &fakeProtectionAPI{rules: map[string]*gogitlab.ProtectedBranch{}, access: access}
+	for _, rule := range rules {
+		api.rules[rule.Name] = rule
+	}
+	return api
+}
+
+// protectedRule возвращает правило, разрешающее push пользователям с уровнем доступа push
+func protectedRule(name string, push gogitlab.AccessLevelValue, forcePush bool) *gogitlab.ProtectedBranch {
+	return &gogitlab.ProtectedBranch{
+		Name:              name,
+		PushAccessLevels:  []*gogitlab.BranchAccessDescription{{AccessLevel: push}},
+		MergeAccessLevels: []*gogitlab.BranchAccessDescription{{AccessLevel: gogitlab.MaintainerPermissions}},
+		AllowForcePush:    forcePush,
+	}
+}
+
+func (f *fakeProtectionAPI) GetProject(context.Context) (*gogitlab.Project, error) {
+	f.calls = append(f.calls, "GetProject")
+	return &gogitlab.Project{Permissions: &gogitlab.Permissions{ProjectAccess: &gogitlab.ProjectAccess{AccessLevel: f.access}}}, nil
+}
+
+func (f *fakeProtectionAPI) ListProtectedBranches(context.Context) ([]*gogitlab.ProtectedBranch, error) {
+	f.calls = append(f.calls, "ListProtectedBranches")
+	var rules []*gogitlab.ProtectedBranch
+	for _, rule := range f.rules {
+		copied := *rule
+		rules = append(rules, &copied)
+	}
+	return rules, nil
+}
+
+func (f *fakeProtectionAPI) UpdateProtectedBranch(_ context.Context, name string, opt *gogitlab.UpdateProtectedBranchOptions) error {
+	f.calls = append(f.calls, "UpdateProtectedBranch "+name)
+	if !*opt.AllowForcePush && f.failRestore != nil {
+		return f.failRestore
+	}
+	f.rules[name].AllowForcePush = *opt.AllowForcePush
+	if *opt.AllowForcePush && f.onLift != nil {
+		f.onLift()
+	}
+	return nil
+}
+
+func (f *fakeProtectionAPI) UnprotectBranch(_ context.Context, name string) error {
+	f.calls = append(f.calls, "UnprotectBranch "+name)
+	delete(f.rules, name)
+	if f.onLift != nil {
+		f.onLift()
+	}
+	return nil
+}
+
+func (f *fakeProtectionAPI) ProtectBranch(_ context.Context, opt *gogitlab.ProtectRepositoryBranchesOptions) error {
+	f.calls = append(f.calls, "ProtectBranch "+*opt.Name)
+	if f.failRestore != nil {
+		return f.failRestore
+	}
+	f.rules[*opt.Name] = &gogitlab.ProtectedBranch{
+		Name:              *opt.Name,
+		PushAccessLevels:  []*gogitlab.BranchAccessDescription{{AccessLevel: *opt.PushAccessLevel}},
+		MergeAccessLevels: []*gogitlab.BranchAccessDescription{{AccessLevel: *opt.MergeAccessLevel}},
+		AllowForcePush:    *opt.AllowForcePush,
+	}
+	return nil
+}
+
+// logicWithAPI создает Logic, который получает правила защиты из api
+func logicWithAPI(dir string, api gitlabapi.API) *Logic {
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	logic.SetGitlabAPI(func(context.Context, configs.RepositoryPair, configs.Credential) (gitlabapi.API, error) {
+		return api, nil
+	})
+	return logic
+}
+
+func TestApplyProtection(t *testing.T) {
+	a1, b1 := testHash("a1"), testHash("b1")
+	rules := []*gogitlab.ProtectedBranch{
+		protectedRule("main", gogitlab.MaintainerPermissions, false),
+		protectedRule("release/*", gogitlab.NoPermissions, false),
+		protectedRule("dev", gogitlab.DeveloperPermissions, true),
+	}
+	newDecisions := func() []Decision {
+		return []Decision{
+			{Kind: KindBranch, Name: "main", Gitlab: a1, Private: b1, Action: ActionForce, Direction: DirectionToGitlab, Resolved: b1},
+			{Kind: KindBranch, Name: "main", Gitlab: a1, Private: b1, Action: ActionFastForward, Direction: DirectionToGitlab, Resolved: b1},
+			{Kind: KindBranch, Name: "release/1.0", Private: b1, Action: ActionCreate, Direction: DirectionToGitlab, Resolved: b1},
+			{Kind: KindBranch, Name: "dev", Gitlab: a1, Action: ActionDelete, Direction: DirectionToGitlab, DryRun: true},
+			{Kind: KindBranch, Name: "dev", Gitlab: a1, Private: b1, Action: ActionForce, Direction: DirectionToGitlab, Resolved: b1},
+			{Kind: KindBranch, Name: "main", Gitlab: a1, Private: b1, Action: ActionFastForward, Direction: DirectionToPrivate, Resolved: a1},
+			{Kind: KindBranch, Name: "feature", Private: b1, Action: ActionCreate, Direction: DirectionToGitlab, Resolved: b1},
+			{Kind: KindTag, Name: "main", Private: b1, Action: ActionCreate, Direction: DirectionToGitlab, Resolved: b1},
+		}
+	}
+	pushed := []Action{ActionForce, ActionFastForward, ActionCreate, ActionDelete, ActionForce, ActionFastForward, ActionCreate, ActionCreate}
+
+	testCases := []struct {
+		name     string
+		access   gogitlab.AccessLevelValue
+		known    bool
+		override string
+		expected []Action
+	}{
+		{"Maintainer", gogitlab.MaintainerPermissions, true, "",
+			[]Action{ActionBlocked, ActionFastForward, ActionBlocked, ActionBlocked, ActionForce, ActionFastForward, ActionCreate, ActionCreate}},
+		{"Developer", gogitlab.DeveloperPermissions, true, "",
+			[]Action{ActionBlocked, ActionBlocked, ActionBlocked, ActionBlocked, ActionForce, ActionFastForward, ActionCreate, ActionCreate}},
+		{"UnknownAccess", gogitlab.NoPermissions, false, "",
+			[]Action{ActionBlocked, ActionFastForward, ActionBlocked, ActionBlocked, ActionForce, ActionFastForward, ActionCreate, ActionCreate}},
+		{"AllowForcePush", gogitlab.MaintainerPermissions, true, configs.ProtectionAllowForcePush,
+			[]Action{ActionForce, ActionFastForward, ActionBlocked, ActionBlocked, ActionForce, ActionFastForward, ActionCreate, ActionCreate}},
+		{"AllowForcePushDeveloper", gogitlab.DeveloperPermissions, true, configs.ProtectionAllowForcePush,
+			[]Action{ActionBlocked, ActionBlocked, ActionBlocked, ActionBlocked, ActionForce, ActionFastForward, ActionCreate, ActionCreate}},
+		{"Unprotect", gogitlab.DeveloperPermissions, true, configs.ProtectionUnprotect,
+			[]Action{ActionForce, ActionBlocked, ActionBlocked, ActionBlocked, ActionForce, ActionFastForward, ActionCreate, ActionCreate}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := &protection{rules: rules, access: tc.access, known: tc.known, override: tc.override}
+			decisions := applyProtection(newDecisions(), p)
+			for i, d := range decisions {
+				if d.Action != tc.expected[i] {
+					t.Errorf("%d %s: ожидалось действие %s, получено %s (%s)", i, d.Name, tc.expected[i], d.Action, d.Reason)
+				}
+				if d.Action == ActionBlocked {
+					if !d.Resolved.IsZero() || d.DryRun {
+						t.Errorf("%d %s: заблокированная ветка не должна изменяться: %+v", i, d.Name, d)
+					}
+					if !strings.Contains(d.Reason, "ветка защищена в GitLab") {
+						t.Errorf("%d %s: причина блокировки не указана: %q", i, d.Name, d.Reason)
+					}
+				} else if d.Action != pushed[i] {
+					t.Errorf("%d %s: действие не должно меняться: %s", i, d.Name, d.Action)
+				}
+			}
+		})
+	}
+
+	if got := newDecisions()[2]; !strings.Contains(applyProtection([]Decision{got}, &protection{rules: rules})[0].Reason, "(release/*)") {
+		t.Error("Причина блокировки должна содержать имя правила")
+	}
+}
+
+// Тест, что push в защищенную ветку не выполняется, а ветка получает отдельный статус
+func TestSynchronizeBlockedByProtection(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	gitlab.setBranch("develop", root)
+	tip := private.commit("private-change", root)
+	private.setBranch("main", tip)
+	private.setBranch("develop", tip)
+	private.setBranch("release/1.0", tip)
+
+	api := newFakeProtectionAPI(gogitlab.DeveloperPermissions,
+		protectedRule("main", gogitlab.MaintainerPermissions, false),
+		protectedRule("release/*", gogitlab.DeveloperPermissions, false),
+	)
+	result, err := logicWithAPI(dir, api).Synchronize(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{})
+	if err != nil {
+		t.Fatalf("Synchronize вернул ошибку: %v", err)
+	}
+
+	statuses := map[string]RefStatus{}
+	for _, branch := range result.Branches {
+		statuses[branch.Name] = branch.Status
+	}
+	expected := map[string]RefStatus{"main": StatusBlocked, "develop": StatusUpdated, "release/1.0": StatusCreated}
+	if !reflect.DeepEqual(statuses, expected) {
+		t.Errorf("Ожидались статусы %v, получено %v", expected, statuses)
+	}
+	if got := gitlab.branches()["main"]; got != root {
+		t.Errorf("Защищенная ветка main в GitLab не должна изменяться: %s", got)
+	}
+	if !result.HasConflicts() || result.Summary().Blocked != 1 {
+		t.Errorf("Заблокированная ветка должна учитываться в итоге: %+v", result.Summary())
+	}
+	if blocked := result.WithStatus(StatusBlocked); !strings.Contains(blocked[0].Message, "push запрещен") {
+		t.Errorf("Неверная причина блокировки: %q", blocked[0].Message)
+	}
+}
+
+// Тест, что без изменений для GitLab правила защиты не запрашиваются
+func TestSynchronizeSkipsProtectionCheck(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+	// Изменения есть только в GitLab
+	root := gitlab.commit("root")
+	private.commit("root")
+	private.setBranch("main", root)
+	gitlab.setBranch("main", gitlab.commit("gitlab-change", root))
+
+	api := newFakeProtectionAPI(gogitlab.DeveloperPermissions)
+	if _, err := logicWithAPI(dir, api).Synchronize(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{}); err != nil {
+		t.Fatalf("Synchronize вернул ошибку: %v", err)
+	}
+	if len(api.calls) != 0 {
+		t.Errorf("Не ожидались запросы к GitLab API: %v", api.calls)
+	}
+}
+
+// Тест временного ослабления защиты для перезаписи ветки по стратегии prefer_private
+func TestSynchronizeProtectionOverride(t *testing.T) {
+	t.Run("AllowForcePush", func(t *testing.T) {
+		dir := t.TempDir()
+		gitlab, private, _, privateTip := divergedRemotes(t, dir, true)
+		pair := testPair(gitlab, private)
+		pair.ConflictStrategy = configs.ConflictPreferPrivate
+		pair.ProtectionOverride = configs.ProtectionAllowForcePush
+
+		api := newFakeProtectionAPI(gogitlab.MaintainerPermissions, protectedRule("main", gogitlab.MaintainerPermissions, false))
+		var forcePushDuringPush bool
+		api.onLift = func() { forcePushDuringPush = api.rules["main"].AllowForcePush }
+
+		result, err := logicWithAPI(dir, api).Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{})
+		if err != nil {
+			t.Fatalf("Synchronize вернул ошибку: %v", err)
+		}
+		if forced := result.WithStatus(StatusForced); len(forced) != 1 {
+			t.Fatalf("Ожидалась перезапись ветки main: %+v", result.Branches)
+		}
+		if got := gitlab.branches()["main"]; got != privateTip {
+			t.Errorf("Ветка main в GitLab не перезаписана версией Private: %s", got)
+		}
+		if !forcePushDuringPush || api.rules["main"].AllowForcePush {
+			t.Error("Force push должен быть разрешен только на время перезаписи")
+		}
+		want := []string{"ListProtectedBranches", "GetProject", "UpdateProtectedBranch main", "UpdateProtectedBranch main"}
+		if !reflect.DeepEqual(api.calls, want) {
+			t.Errorf("Запросы к GitLab API: %v, ожидалось %v", api.calls, want)
+		}
+	})
+
+	t.Run("UnprotectRestoredAfterFailure", func(t *testing.T) {
+		dir := t.TempDir()
+		gitlab, private, gitlabTip, _ := divergedRemotes(t, dir, true)
+		pair := testPair(gitlab, private)
+		pair.ConflictStrategy = configs.ConflictPreferPrivate
+		pair.ProtectionOverride = configs.ProtectionUnprotect
+
+		original := protectedRule("main", gogitlab.NoPermissions, false)
+		api := newFakeProtectionAPI(gogitlab.DeveloperPermissions, original)
+		// Ветка изменяется в GitLab после сверки: перезапись с проверкой значения завершится ошибкой
+		concurrent := gitlab.commit("concurrent", gitlabTip)
+		api.onLift = func() { gitlab.setBranch("main", concurrent) }
+
+		_, err := logicWithAPI(dir, api).Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{})
+		if err == nil || !strings.Contains(err.Error(), "не удалось перезаписать ветку main") {
+			t.Fatalf("Ожидалась ошибка перезаписи ветки main, получено %v", err)
+		}
+		if got := gitlab.branches()["main"]; got != concurrent {
+			t.Errorf("Ветка main в GitLab не должна перезаписываться: %s", got)
+		}
+		if !reflect.DeepEqual(api.rules["main"], original) {
+			t.Errorf("Защита не восстановлена после ошибки: %+v", api.rules["main"])
+		}
+		want := []string{"ListProtectedBranches", "GetProject", "UnprotectBranch main", "ProtectBranch main"}
+		if !reflect.DeepEqual(api.calls, want) {
+			t.Errorf("Запросы к GitLab API: %v, ожидалось %v", api.calls, want)
+		}
+	})
+
+	t.Run("RestoreFailure", func(t *testing.T) {
+		dir := t.TempDir()
+		gitlab, private, _, privateTip := divergedRemotes(t, dir, true)
+		pair := testPair(gitlab, private)
+		pair.ConflictStrategy = configs.ConflictPreferPrivate
+		pair.ProtectionOverride = configs.ProtectionAllowForcePush
+
+		api := newFakeProtectionAPI(gogitlab.MaintainerPermissions, protectedRule("main", gogitlab.MaintainerPermissions, false))
+		api.failRestore = errors.New("403 Forbidden")
+
+		_, err := logicWithAPI(dir, api).Synchronize(context.Background(), pair, configs.Credential{}, configs.Credential{})
+		if err == nil || !strings.Contains(err.Error(), "не удалось восстановить защиту ветки main") {
+			t.Fatalf("Ожидалась ошибка восстановления защиты, получено %v", err)
+		}
+		if got := gitlab.branches()["main"]; got != privateTip {
+			t.Errorf("Ветка main в GitLab должна быть перезаписана до восстановления защиты: %s", got)
+		}
+	})
+}
+
+// Тест, что недоступность GitLab API не останавливает синхронизацию
+func TestSynchronizeProtectionAPIError(t *testing.T) {
+	dir := t.TempDir()
+	gitlab := newTestRemote(t, dir, "gitlab-repo.git")
+	private := newTestRemote(t, dir, "private-repo.git")
+	root := gitlab.commit("root")
+	private.commit("root")
+	gitlab.setBranch("main", root)
+	private.setBranch("main", root)
+	tip := private.commit("feature", root)
+	private.setBranch("feature", tip)
+
+	logic := NewLogic(repository.NewManager(filepath.Join(dir, "work")))
+	logic.SetGitlabAPI(func(context.Context, configs.RepositoryPair, configs.Credential) (gitlabapi.API, error) {
+		return nil, errors.New("401 Unauthorized")
+	})
+	if _, err := logic.Synchronize(context.Background(), testPair(gitlab, private), configs.Credential{}, configs.Credential{}); err != nil {
+		t.Fatalf("Synchronize вернул ошибку: %v", err)
+	}
+	if got := gitlab.branches()["feature"]; got != tip {
+		t.Errorf("Ветка feature не создана в GitLab: %s", got)
+	}
+}
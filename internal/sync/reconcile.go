@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"fmt"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// RefKind вид синхронизируемой ссылки
+type RefKind string
+
+const (
+	KindBranch RefKind = "branch"
+	KindTag    RefKind = "tag"
+)
+
+// label возвращает название вида ссылки для сообщений
+func (k RefKind) label() string {
+	if k == KindTag {
+		return "тег"
+	}
+	return "ветка"
+}
+
+// Action действие над ссылкой, выбранное при сверке
+type Action string
+
+const (
+	// ActionNone ссылка совпадает на обеих сторонах
+	ActionNone Action = "none"
+	// ActionCreate ссылка создается на стороне, где ее нет
+	ActionCreate Action = "create"
+	// ActionFastForward ветка продвигается вперед без перезаписи истории
+	ActionFastForward Action = "fast-forward"
+	// ActionDelete ссылка удаляется на стороне, где она осталась после удаления на другой стороне
+	ActionDelete Action = "delete"
+	// ActionSkip ссылка оставляется без изменений
+	ActionSkip Action = "skip"
+	// ActionConflict ссылку нельзя синхронизировать автоматически
+	ActionConflict Action = "conflict"
+	// ActionForce разошедшаяся ветка перезаписывается версией одной из сторон
+	ActionForce Action = "force"
+	// ActionConflictBranch версия приватного репозитория сохраняется в отдельной ветке
+	ActionConflictBranch Action = "conflict-branch"
+	// ActionMerge разошедшиеся ветки объединяются коммитом слияния
+	ActionMerge Action = "merge"
+	// ActionMergeRequest версия приватного репозитория предлагается в GitLab через merge request
+	ActionMergeRequest Action = "merge-request"
+	// ActionBlocked изменение ветки в GitLab запрещено правилами защиты ветки
+	ActionBlocked Action = "blocked"
+)
+
+// Decision решение по одной ссылке. Нулевой hash означает, что ссылки нет на соответствующей стороне
+// (для Base — что ссылка не была синхронизирована ранее).
+type Decision struct {
+	Kind      RefKind
+	Name      string
+	Base      plumbing.Hash
+	Gitlab    plumbing.Hash
+	Private   plumbing.Hash
+	Action    Action
+	Direction string
+	Reason    string
+	// MergeBase общий предок разошедшихся веток, нулевой, если общей истории нет
+	MergeBase plumbing.Hash
+	// Resolved значение ссылки на обеих сторонах после выполнения решения.
+	// Нулевой hash означает, что после выполнения стороны по-прежнему различаются.
+	Resolved plumbing.Hash
+	// DryRun означает, что действие только сообщается и не выполняется
+	DryRun bool
+}
+
+// history предоставляет сведения об истории коммитов обеих сторон
+type history interface {
+	// IsAncestor проверяет, является ли коммит ancestor предком коммита descendant
+	IsAncestor(ancestor, descendant plumbing.Hash) (bool, error)
+	// MergeBase возвращает общий предок двух коммитов или нулевой hash, если общей истории нет
+	MergeBase(a, b plumbing.Hash) (plumbing.Hash, error)
+}
+
+// reconcileRef определяет действие для ссылки по трем состояниям: base — значение при последней
+// успешной синхронизации, gitlab и private — текущие значения на сторонах.
+// База позволяет отличить удаление на одной стороне от создания на другой и перемотку назад от продвижения вперед.
+func reconcileRef(kind RefKind, name string, base, gitlab, private plumbing.Hash, h history) (Decision, error) {
+	d := Decision{Kind: kind, Name: name, Base: base, Gitlab: gitlab, Private: private}
+
+	switch {
+	case gitlab == private:
+		d.Action = ActionNone
+		return d, nil
+
+	case gitlab.IsZero() || private.IsZero():
+		present, missingSide, direction, opposite := private, "GitLab", DirectionToGitlab, DirectionToPrivate
+		if private.IsZero() {
+			present, missingSide, direction, opposite = gitlab, "Private", DirectionToPrivate, DirectionToGitlab
+		}
+		if !base.IsZero() && present == base {
+			// Ссылка не менялась с последней синхронизации и пропала на другой стороне — это удаление
+			d.Action = ActionDelete
+			d.Direction = opposite
+			d.Reason = "удалена в " + missingSide
+			return d, nil
+		}
+		d.Action = ActionCreate
+		d.Direction = direction
+		d.Resolved = present
+		return d, nil
+
+	case kind == KindTag:
+		// Теги никогда не перемещаются автоматически
+		d.Action = ActionConflict
+		d.Reason = "тег указывает на разные объекты"
+		return d, nil
+	}
+
+	gitlabBehind, err := h.IsAncestor(gitlab, private)
+	if err != nil {
+		return d, err
+	}
+	if gitlabBehind {
+		if !base.IsZero() && private == base {
+			d.Action = ActionSkip
+			d.Reason = "ветка в GitLab перемотана назад"
+			return d, nil
+		}
+		d.Action = ActionFastForward
+		d.Direction = DirectionToGitlab
+		d.Resolved = private
+		return d, nil
+	}
+
+	privateBehind, err := h.IsAncestor(private, gitlab)
+	if err != nil {
+		return d, err
+	}
+	if privateBehind {
+		if !base.IsZero() && gitlab == base {
+			d.Action = ActionSkip
+			d.Reason = "ветка в Private перемотана назад"
+			return d, nil
+		}
+		d.Action = ActionFastForward
+		d.Direction = DirectionToPrivate
+		d.Resolved = gitlab
+		return d, nil
+	}
+
+	// Обе стороны содержат коммиты, которых нет на другой стороне
+	mergeBase, err := h.MergeBase(gitlab, private)
+	if err != nil {
+		return d, err
+	}
+	d.Action = ActionConflict
+	d.MergeBase = mergeBase
+	if mergeBase.IsZero() {
+		d.Reason = "ветки не имеют общей истории"
+	} else {
+		d.Reason = fmt.Sprintf("ветки разошлись после коммита %s", mergeBase.String()[:7])
+	}
+	return d, nil
+}
@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// testHash возвращает детерминированный hash для имени коммита
+func testHash(name string) plumbing.Hash {
+	return plumbing.ComputeHash(plumbing.CommitObject, []byte(name))
+}
+
+// testHistory - история коммитов root -> a1 -> a2, root -> b1 и несвязанный коммит orphan.
+// Ключ - коммит, значение - его родитель.
+type testHistory map[plumbing.Hash]plumbing.Hash
+
+var testLog = testHistory{
+	testHash("a1"): testHash("root"),
+	testHash("a2"): testHash("a1"),
+	testHash("b1"): testHash("root"),
+}
+
+func (h testHistory) IsAncestor(ancestor, descendant plumbing.Hash) (bool, error) {
+	for current := descendant; !current.IsZero(); current = h[current] {
+		if current == ancestor {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (h testHistory) MergeBase(a, b plumbing.Hash) (plumbing.Hash, error) {
+	for current := a; !current.IsZero(); current = h[current] {
+		if ok, _ := h.IsAncestor(current, b); ok {
+			return current, nil
+		}
+	}
+	return plumbing.ZeroHash, nil
+}
+
+func TestReconcileRef(t *testing.T) {
+	zero := plumbing.ZeroHash
+	root, a1, a2, b1 := testHash("root"), testHash("a1"), testHash("a2"), testHash("b1")
+
+	testCases := []struct {
+		name      string
+		kind      RefKind
+		base      plumbing.Hash
+		gitlab    plumbing.Hash
+		private   plumbing.Hash
+		action    Action
+		direction string
+	}{
+		{"InSync", KindBranch, root, a1, a1, ActionNone, ""},
+		{"NewInGitlab", KindBranch, zero, a1, zero, ActionCreate, DirectionToPrivate},
+		{"NewInPrivate", KindBranch, zero, zero, a1, ActionCreate, DirectionToGitlab},
+		{"DeletedInGitlab", KindBranch, a1, zero, a1, ActionDelete, DirectionToPrivate},
+		{"DeletedInPrivate", KindBranch, a1, a1, zero, ActionDelete, DirectionToGitlab},
+		{"ChangedAfterDeletion", KindBranch, a1, a2, zero, ActionCreate, DirectionToPrivate},
+		{"GitlabAdvanced", KindBranch, a1, a2, a1, ActionFastForward, DirectionToPrivate},
+		{"PrivateAdvanced", KindBranch, a1, a1, a2, ActionFastForward, DirectionToGitlab},
+		{"AdvancedWithoutBase", KindBranch, zero, a1, a2, ActionFastForward, DirectionToGitlab},
+		{"GitlabRewound", KindBranch, a2, a1, a2, ActionSkip, ""},
+		{"PrivateRewound", KindBranch, a2, a2, a1, ActionSkip, ""},
+		{"BothAdvancedFastForward", KindBranch, root, a1, a2, ActionFastForward, DirectionToGitlab},
+		{"Diverged", KindBranch, root, a1, b1, ActionConflict, ""},
+		{"DivergedWithoutBase", KindBranch, zero, a2, b1, ActionConflict, ""},
+		{"UnrelatedHistories", KindBranch, zero, a1, testHash("orphan"), ActionConflict, ""},
+		{"NewTag", KindTag, zero, zero, a1, ActionCreate, DirectionToGitlab},
+		{"DeletedTag", KindTag, a1, a1, zero, ActionDelete, DirectionToGitlab},
+		{"MovedTag", KindTag, a1, a2, a1, ActionConflict, ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := reconcileRef(tc.kind, "ref", tc.base, tc.gitlab, tc.private, testLog)
+			if err != nil {
+				t.Fatalf("reconcileRef вернул ошибку: %v", err)
+			}
+			if d.Action != tc.action {
+				t.Errorf("Ожидалось действие %s, получено %s (%s)", tc.action, d.Action, d.Reason)
+			}
+			if d.Direction != tc.direction {
+				t.Errorf("Ожидалось направление %q, получено %q", tc.direction, d.Direction)
+			}
+		})
+	}
+}
+
+func TestReconcileRefMergeBase(t *testing.T) {
+	root, a2, b1 := testHash("root"), testHash("a2"), testHash("b1")
+
+	d, err := reconcileRef(KindBranch, "main", plumbing.ZeroHash, a2, b1, testLog)
+	if err != nil {
+		t.Fatalf("reconcileRef вернул ошибку: %v", err)
+	}
+	if d.MergeBase != root {
+		t.Errorf("Ожидался общий предок %s, получен %s", root, d.MergeBase)
+	}
+	if !d.Resolved.IsZero() {
+		t.Errorf("Разошедшиеся ветки не должны считаться синхронизированными: %s", d.Resolved)
+	}
+
+	d, err = reconcileRef(KindBranch, "main", plumbing.ZeroHash, a2, testHash("orphan"), testLog)
+	if err != nil {
+		t.Fatalf("reconcileRef вернул ошибку: %v", err)
+	}
+	if !d.MergeBase.IsZero() {
+		t.Errorf("Для несвязанной истории ожидался нулевой общий предок, получен %s", d.MergeBase)
+	}
+}
+
+func TestNextState(t *testing.T) {
+	a1, a2 := testHash("a1"), testHash("a2")
+
+	base := stateWith(map[string]plumbing.Hash{"deleted": a1, "diverged": a1, "removed": a1, "would-remove": a1})
+	decisions := []Decision{
+		{Kind: KindBranch, Name: "same", Gitlab: a1, Private: a1, Action: ActionNone},
+		{Kind: KindBranch, Name: "pushed", Gitlab: a2, Private: a1, Action: ActionFastForward, Direction: DirectionToPrivate, Resolved: a2},
+		{Kind: KindBranch, Name: "created", Private: a2, Action: ActionCreate, Direction: DirectionToGitlab, Resolved: a2},
+		{Kind: KindBranch, Name: "forced", Gitlab: a2, Private: testHash("b1"), Action: ActionForce, Direction: DirectionToGitlab, Resolved: testHash("b1")},
+		{Kind: KindBranch, Name: "conflict-branch", Gitlab: a2, Private: testHash("b1"), Action: ActionConflictBranch},
+		{Kind: KindBranch, Name: "deleted", Gitlab: a1, Action: ActionSkip},
+		{Kind: KindBranch, Name: "removed", Gitlab: a1, Action: ActionDelete, Direction: DirectionToGitlab},
+		{Kind: KindBranch, Name: "would-remove", Private: a1, Action: ActionDelete, Direction: DirectionToPrivate, DryRun: true},
+		{Kind: KindBranch, Name: "diverged", Gitlab: a2, Private: testHash("b1"), Action: ActionSkip},
+		{Kind: KindBranch, Name: "new-diverged", Gitlab: a2, Private: testHash("b1"), Action: ActionSkip},
+	}
+
+	next := nextState(base, decisions)
+
+	expected := map[string]plumbing.Hash{
+		"same":         a1,
+		"pushed":       a2,
+		"created":      a2,
+		"forced":       testHash("b1"),
+		"deleted":      a1,
+		"diverged":     a1,
+		"would-remove": a1,
+	}
+	if len(next.Branches) != len(expected) {
+		t.Errorf("Ожидалось %d веток в состоянии, получено %d: %v", len(expected), len(next.Branches), next.Branches)
+	}
+	for name, hash := range expected {
+		if next.Branches[name] != hash.String() {
+			t.Errorf("Ветка %s: ожидалось %s, получено %s", name, hash, next.Branches[name])
+		}
+	}
+}
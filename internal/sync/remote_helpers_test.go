@@ -0,0 +1,233 @@
+package sync
+
+import (
+	"git-sync/configs"
+	"git-sync/internal/state"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// testRemote - локальный bare репозиторий, играющий роль удаленного репозитория в тестах
+type testRemote struct {
+	t    *testing.T
+	path string
+	repo *git.Repository
+}
+
+// newTestRemote создает пустой bare репозиторий в поддиректории name
+func newTestRemote(t *testing.T, dir, name string) *testRemote {
+	t.Helper()
+
+	path := filepath.Join(dir, name)
+	repo, err := git.PlainInit(path, true)
+	if err != nil {
+		t.Fatalf("Не удалось создать bare репозиторий %s: %v", path, err)
+	}
+
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName("main"))
+	if err := repo.Storer.SetReference(head); err != nil {
+		t.Fatalf("Не удалось установить HEAD для %s: %v", path, err)
+	}
+
+	return &testRemote{t: t, path: path, repo: repo}
+}
+
+// commit создает коммит с единственным файлом поверх parents и возвращает его hash.
+// Коммиты детерминированы: одинаковые аргументы в разных репозиториях дают одинаковый hash.
+func (r *testRemote) commit(content string, parents ...plumbing.Hash) plumbing.Hash {
+	r.t.Helper()
+	return r.commitFiles(content, map[string]string{"file.txt": content}, parents...)
+}
+
+// commitFiles создает коммит с указанными файлами (путь -> содержимое) поверх parents и возвращает его hash
+func (r *testRemote) commitFiles(message string, files map[string]string, parents ...plumbing.Hash) plumbing.Hash {
+	r.t.Helper()
+
+	entries := make(map[string]object.TreeEntry)
+	for name, content := range files {
+		entries[name] = object.TreeEntry{Mode: filemode.Regular, Hash: r.blob(content)}
+	}
+	treeHash, err := buildTree(entries, []*git.Repository{r.repo})
+	if err != nil {
+		r.t.Fatalf("Не удалось сохранить дерево: %v", err)
+	}
+
+	signature := object.Signature{
+		Name:  "git-sync test",
+		Email: "test@git-sync.local",
+		When:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	return r.store(&object.Commit{
+		Author:       signature,
+		Committer:    signature,
+		Message:      message,
+		TreeHash:     treeHash,
+		ParentHashes: parents,
+	})
+}
+
+// blob сохраняет содержимое файла и возвращает hash объекта
+func (r *testRemote) blob(content string) plumbing.Hash {
+	r.t.Helper()
+
+	blob := r.repo.Storer.NewEncodedObject()
+	blob.SetType(plumbing.BlobObject)
+	w, err := blob.Writer()
+	if err != nil {
+		r.t.Fatalf("Не удалось создать blob: %v", err)
+	}
+	if _, err := w.Write([]byte(content)); err != nil {
+		r.t.Fatalf("Не удалось записать blob: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		r.t.Fatalf("Не удалось закрыть blob: %v", err)
+	}
+	hash, err := r.repo.Storer.SetEncodedObject(blob)
+	if err != nil {
+		r.t.Fatalf("Не удалось сохранить blob: %v", err)
+	}
+	return hash
+}
+
+// store кодирует и сохраняет объект в хранилище репозитория
+func (r *testRemote) store(o interface {
+	Encode(plumbing.EncodedObject) error
+}) plumbing.Hash {
+	r.t.Helper()
+
+	obj := r.repo.Storer.NewEncodedObject()
+	if err := o.Encode(obj); err != nil {
+		r.t.Fatalf("Не удалось закодировать объект: %v", err)
+	}
+	hash, err := r.repo.Storer.SetEncodedObject(obj)
+	if err != nil {
+		r.t.Fatalf("Не удалось сохранить объект: %v", err)
+	}
+	return hash
+}
+
+// setBranch устанавливает ветку на указанный коммит
+func (r *testRemote) setBranch(branch string, hash plumbing.Hash) {
+	r.t.Helper()
+
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), hash)
+	if err := r.repo.Storer.SetReference(ref); err != nil {
+		r.t.Fatalf("Не удалось установить ветку %s: %v", branch, err)
+	}
+}
+
+// branches возвращает все ветки репозитория
+func (r *testRemote) branches() map[string]plumbing.Hash {
+	r.t.Helper()
+
+	// Открываем репозиторий заново, чтобы увидеть ссылки, записанные push
+	repo, err := git.PlainOpen(r.path)
+	if err != nil {
+		r.t.Fatalf("Не удалось открыть репозиторий %s: %v", r.path, err)
+	}
+	iter, err := repo.Branches()
+	if err != nil {
+		r.t.Fatalf("Не удалось получить ветки %s: %v", r.path, err)
+	}
+
+	result := make(map[string]plumbing.Hash)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		result[ref.Name().Short()] = ref.Hash()
+		return nil
+	})
+	if err != nil {
+		r.t.Fatalf("Не удалось прочитать ветки %s: %v", r.path, err)
+	}
+	return result
+}
+
+// setTag создает легковесный тег на указанный объект
+func (r *testRemote) setTag(tag string, hash plumbing.Hash) {
+	r.t.Helper()
+
+	ref := plumbing.NewHashReference(plumbing.NewTagReferenceName(tag), hash)
+	if err := r.repo.Storer.SetReference(ref); err != nil {
+		r.t.Fatalf("Не удалось установить тег %s: %v", tag, err)
+	}
+}
+
+// annotatedTag создает аннотированный тег на коммит target и возвращает hash объекта тега
+func (r *testRemote) annotatedTag(tag string, target plumbing.Hash, message string) plumbing.Hash {
+	r.t.Helper()
+
+	hash := r.store(&object.Tag{
+		Name: tag,
+		Tagger: object.Signature{
+			Name:  "git-sync test",
+			Email: "test@git-sync.local",
+			When:  time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		Message:    message,
+		TargetType: plumbing.CommitObject,
+		Target:     target,
+	})
+	r.setTag(tag, hash)
+	return hash
+}
+
+// tags возвращает все теги репозитория
+func (r *testRemote) tags() map[string]plumbing.Hash {
+	r.t.Helper()
+
+	repo, err := git.PlainOpen(r.path)
+	if err != nil {
+		r.t.Fatalf("Не удалось открыть репозиторий %s: %v", r.path, err)
+	}
+	iter, err := repo.Tags()
+	if err != nil {
+		r.t.Fatalf("Не удалось получить теги %s: %v", r.path, err)
+	}
+
+	result := make(map[string]plumbing.Hash)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		result[ref.Name().Short()] = ref.Hash()
+		return nil
+	})
+	if err != nil {
+		r.t.Fatalf("Не удалось прочитать теги %s: %v", r.path, err)
+	}
+	return result
+}
+
+// testPair создает пару репозиториев для синхронизации
+func testPair(gitlab, private *testRemote) configs.RepositoryPair {
+	return configs.RepositoryPair{GitlabURL: gitlab.path, PrivateRepoURL: private.path}
+}
+
+// stateWith создает состояние с указанными ветками
+func stateWith(branches map[string]plumbing.Hash) *state.State {
+	st := state.NewState()
+	for name, hash := range branches {
+		st.Branches[name] = hash.String()
+	}
+	return st
+}
+
+// deleteBranch удаляет ветку
+func (r *testRemote) deleteBranch(branch string) {
+	r.t.Helper()
+
+	if err := r.repo.Storer.RemoveReference(plumbing.NewBranchReferenceName(branch)); err != nil {
+		r.t.Fatalf("Не удалось удалить ветку %s: %v", branch, err)
+	}
+}
+
+// deleteTag удаляет тег
+func (r *testRemote) deleteTag(tag string) {
+	r.t.Helper()
+
+	if err := r.repo.Storer.RemoveReference(plumbing.NewTagReferenceName(tag)); err != nil {
+		r.t.Fatalf("Не удалось удалить тег %s: %v", tag, err)
+	}
+}
@@ -0,0 +1,142 @@
+package sync
+
+import (
+	"time"
+
+	"git-sync/internal/repository"
+
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// Направления синхронизации
+const (
+	DirectionToPrivate = "GitLab -> Private"
+	DirectionToGitlab  = "Private -> GitLab"
+)
+
+// RefStatus описывает итог синхронизации одной ссылки
+type RefStatus string
+
+const (
+	// StatusUpToDate ссылка уже совпадает в обоих репозиториях
+	StatusUpToDate RefStatus = "up-to-date"
+	// StatusCreated ссылка создана в destination репозитории
+	StatusCreated RefStatus = "created"
+	// StatusUpdated ссылка обновлена в destination репозитории
+	StatusUpdated RefStatus = "updated"
+	// StatusDeleted ссылка удалена вслед за удалением на другой стороне
+	StatusDeleted RefStatus = "deleted"
+	// StatusWouldDelete ссылка была бы удалена, но включен режим dry-run для удалений
+	StatusWouldDelete RefStatus = "would-delete"
+	// StatusSkipped ссылка пропущена без изменений
+	StatusSkipped RefStatus = "skipped"
+	// StatusConflict ссылка указывает на разные объекты и не может быть синхронизирована автоматически
+	StatusConflict RefStatus = "conflict"
+	// StatusForced разошедшаяся ветка перезаписана версией одной из сторон
+	StatusForced RefStatus = "forced"
+	// StatusConflictBranch ветки разошлись, версия приватного репозитория сохранена в ветке sync-conflict/...
+	StatusConflictBranch RefStatus = "conflict-branch"
+	// StatusMerged разошедшиеся ветки объединены коммитом слияния
+	StatusMerged RefStatus = "merged"
+	// StatusMergeRequest ветки разошлись, для версии приватного репозитория открыт merge request в GitLab
+	StatusMergeRequest RefStatus = "merge-request"
+	// StatusBlocked изменение ветки в GitLab запрещено правилами защиты, ветка оставлена без изменений
+	StatusBlocked RefStatus = "blocked-by-protection"
+)
+
+// RefResult результат синхронизации одной ссылки. Direction заполнено, если ссылка была отправлена
+// на одну из сторон; GitlabHash и PrivateHash содержат значения ссылки до синхронизации.
+type RefResult struct {
+	Name        string
+	Direction   string
+	Status      RefStatus
+	GitlabHash  plumbing.Hash
+	PrivateHash plumbing.Hash
+	Message     string
+}
+
+// Result итог синхронизации пары репозиториев
+type Result struct {
+	GitlabURL      string
+	PrivateRepoURL string
+	Branches       []RefResult
+	Tags           []RefResult
+	// Duration длительность синхронизации пары, включая обновление зеркал
+	Duration time.Duration
+	// Retries сетевые операции, которые повторялись после временных ошибок
+	Retries []repository.Retry
+}
+
+// Summary количество веток и тегов по итогам синхронизации
+type Summary struct {
+	// Updated ссылки, обновленные на одной или обеих сторонах, включая перезапись и слияние
+	Updated int
+	Created int
+	// Deleted ссылки, удаленные вслед за удалением на другой стороне
+	Deleted int
+	// Skipped ссылки, оставленные без изменений, включая удаления в режиме dry-run
+	Skipped int
+	// Conflicted ссылки, которые не удалось синхронизировать автоматически
+	Conflicted int
+	// Blocked ветки, изменение которых в GitLab запрещено правилами защиты
+	Blocked int
+}
+
+// Conflicts возвращает ветки и теги, синхронизация которых завершилась конфликтом, включая
+// конфликты, вынесенные в отдельную ветку или merge request
+func (r *Result) Conflicts() []RefResult {
+	var refs []RefResult
+	for _, group := range [][]RefResult{r.Branches, r.Tags} {
+		for _, ref := range group {
+			switch ref.Status {
+			case StatusConflict, StatusConflictBranch, StatusMergeRequest:
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
+
+// HasConflicts сообщает, остались ли после синхронизации ветки или теги, требующие ручного разрешения,
+// включая ветки, заблокированные защитой GitLab
+func (r *Result) HasConflicts() bool {
+	summary := r.Summary()
+	return summary.Conflicted > 0 || summary.Blocked > 0
+}
+
+// Summary подсчитывает ветки и теги по итоговым статусам
+func (r *Result) Summary() Summary {
+	var summary Summary
+	for _, group := range [][]RefResult{r.Branches, r.Tags} {
+		for _, ref := range group {
+			switch ref.Status {
+			case StatusUpdated, StatusForced, StatusMerged:
+				summary.Updated++
+			case StatusCreated:
+				summary.Created++
+			case StatusDeleted:
+				summary.Deleted++
+			case StatusSkipped, StatusWouldDelete:
+				summary.Skipped++
+			case StatusConflict, StatusConflictBranch, StatusMergeRequest:
+				summary.Conflicted++
+			case StatusBlocked:
+				summary.Blocked++
+			}
+		}
+	}
+	return summary
+}
+
+// WithStatus возвращает ветки и теги с указанным статусом
+func (r *Result) WithStatus(status RefStatus) []RefResult {
+	var refs []RefResult
+	for _, group := range [][]RefResult{r.Branches, r.Tags} {
+		for _, ref := range group {
+			if ref.Status == status {
+				refs = append(refs, ref)
+			}
+		}
+	}
+	return refs
+}
@@ -0,0 +1,43 @@
+package sync
+
+import "testing"
+
+func TestResultSummary(t *testing.T) {
+	result := &Result{
+		Branches: []RefResult{
+			{Name: "main", Status: StatusUpToDate},
+			{Name: "feature", Status: StatusUpdated},
+			{Name: "forced", Status: StatusForced},
+			{Name: "merged", Status: StatusMerged},
+			{Name: "new", Status: StatusCreated},
+			{Name: "old", Status: StatusDeleted},
+			{Name: "gone", Status: StatusWouldDelete},
+			{Name: "rewound", Status: StatusSkipped},
+			{Name: "diverged", Status: StatusConflictBranch},
+			{Name: "release", Status: StatusBlocked},
+		},
+		Tags: []RefResult{
+			{Name: "v1.0", Status: StatusCreated},
+			{Name: "v2.0", Status: StatusConflict},
+		},
+	}
+
+	expected := Summary{Updated: 3, Created: 2, Deleted: 1, Skipped: 2, Conflicted: 2, Blocked: 1}
+	if got := result.Summary(); got != expected {
+		t.Errorf("Ожидалось %+v, получено %+v", expected, got)
+	}
+	if !result.HasConflicts() {
+		t.Error("Ожидалось наличие конфликтов")
+	}
+	// Конфликт, вынесенный в отдельную ветку, тоже требует ручного разрешения
+	if conflicts := result.Conflicts(); len(conflicts) != 2 || conflicts[0].Name != "diverged" || conflicts[1].Name != "v2.0" {
+		t.Errorf("Ожидались конфликты diverged и v2.0, получено %+v", conflicts)
+	}
+
+	if !(&Result{Branches: []RefResult{{Name: "main", Status: StatusBlocked}}}).HasConflicts() {
+		t.Error("Ветка, заблокированная защитой GitLab, требует ручного разрешения")
+	}
+	if (&Result{Branches: []RefResult{{Name: "main", Status: StatusUpdated}}}).HasConflicts() {
+		t.Error("Не ожидалось конфликтов")
+	}
+}
@@ -0,0 +1,164 @@
+package webhook
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Отправители событий
+const (
+	ProviderGitlab = "gitlab"
+	ProviderGithub = "github"
+	ProviderGitea  = "gitea"
+)
+
+var (
+	// ErrUnauthorized событие не подписано секретом конфигурации
+	ErrUnauthorized = errors.New("неверный секретный токен или подпись события")
+	// ErrUnsupported событие не относится к push и не требует синхронизации
+	ErrUnsupported = errors.New("событие не требует синхронизации")
+)
+
+// Event событие push, полученное от GitLab, GitHub или Gitea
+type Event struct {
+	Provider string
+	// Kind вид события: push или tag_push
+	Kind string
+	// Ref обновленная ссылка, например refs/heads/main
+	Ref string
+	// Project путь проекта, например group/repo
+	Project string
+	// URLs адреса репозитория из события: HTTP(S), SSH и веб-страница проекта
+	URLs []string
+}
+
+// gitlabPayload поля события Push Hook и Tag Push Hook GitLab
+type gitlabPayload struct {
+	ObjectKind string `json:"object_kind"`
+	Ref        string `json:"ref"`
+	Project    struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+		WebURL            string `json:"web_url"`
+		GitHTTPURL        string `json:"git_http_url"`
+		GitSSHURL         string `json:"git_ssh_url"`
+	} `json:"project"`
+	Repository struct {
+		GitHTTPURL string `json:"git_http_url"`
+		GitSSHURL  string `json:"git_ssh_url"`
+		Homepage   string `json:"homepage"`
+	} `json:"repository"`
+}
+
+// githubPayload поля события push GitHub и Gitea
+type githubPayload struct {
+	Ref        string `json:"ref"`
+	Repository struct {
+		FullName string `json:"full_name"`
+		HTMLURL  string `json:"html_url"`
+		CloneURL string `json:"clone_url"`
+		SSHURL   string `json:"ssh_url"`
+	} `json:"repository"`
+}
+
+// Parse проверяет подлинность события и разбирает его. Отправитель определяется по заголовку
+// X-Gitlab-Event, X-Gitea-Event или X-GitHub-Event. GitLab передает secret в заголовке X-Gitlab-Token,
+// GitHub и Gitea подписывают тело запроса HMAC-SHA256 с ключом secret.
+// Для событий, отличных от push, возвращается ErrUnsupported.
+func Parse(header http.Header, body []byte, secret string) (*Event, error) {
+	switch {
+	case header.Get("X-Gitlab-Event") != "":
+		return parseGitlab(header, body, secret)
+	case header.Get("X-Gitea-Event") != "":
+		return parseGithub(ProviderGitea, header.Get("X-Gitea-Event"), header, body, secret)
+	case header.Get("X-GitHub-Event") != "":
+		return parseGithub(ProviderGithub, header.Get("X-GitHub-Event"), header, body, secret)
+	}
+	return nil, errors.New("неизвестный отправитель: нет заголовка X-Gitlab-Event, X-GitHub-Event или X-Gitea-Event")
+}
+
+func parseGitlab(header http.Header, body []byte, secret string) (*Event, error) {
+	token := header.Get("X-Gitlab-Token")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(secret)) != 1 {
+		return nil, ErrUnauthorized
+	}
+
+	var payload gitlabPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать событие GitLab: %w", err)
+	}
+	if payload.ObjectKind != "push" && payload.ObjectKind != "tag_push" {
+		return nil, fmt.Errorf("%w: GitLab %s", ErrUnsupported, header.Get("X-Gitlab-Event"))
+	}
+
+	return &Event{
+		Provider: ProviderGitlab,
+		Kind:     payload.ObjectKind,
+		Ref:      payload.Ref,
+		Project:  payload.Project.PathWithNamespace,
+		URLs: nonEmpty(payload.Project.GitHTTPURL, payload.Project.GitSSHURL, payload.Project.WebURL,
+			payload.Repository.GitHTTPURL, payload.Repository.GitSSHURL, payload.Repository.Homepage),
+	}, nil
+}
+
+func parseGithub(provider, kind string, header http.Header, body []byte, secret string) (*Event, error) {
+	if !validSignature(header, body, secret) {
+		return nil, ErrUnauthorized
+	}
+	if kind != "push" {
+		return nil, fmt.Errorf("%w: %s %s", ErrUnsupported, provider, kind)
+	}
+
+	var payload githubPayload
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("не удалось разобрать событие %s: %w", provider, err)
+	}
+
+	eventKind := "push"
+	if strings.HasPrefix(payload.Ref, "refs/tags/") {
+		eventKind = "tag_push"
+	}
+	return &Event{
+		Provider: provider,
+		Kind:     eventKind,
+		Ref:      payload.Ref,
+		Project:  payload.Repository.FullName,
+		URLs:     nonEmpty(payload.Repository.CloneURL, payload.Repository.SSHURL, payload.Repository.HTMLURL),
+	}, nil
+}
+
+// validSignature проверяет подпись тела запроса: X-Hub-Signature-256 (sha256=<hex>) GitHub и Gitea
+// или X-Gitea-Signature (<hex>) Gitea
+func validSignature(header http.Header, body []byte, secret string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	expected := mac.Sum(nil)
+
+	signatures := []string{strings.TrimPrefix(header.Get("X-Hub-Signature-256"), "sha256="), header.Get("X-Gitea-Signature")}
+	for _, signature := range signatures {
+		if signature == "" {
+			continue
+		}
+		if actual, err := hex.DecodeString(signature); err == nil && hmac.Equal(actual, expected) {
+			return true
+		}
+	}
+	return false
+}
+
+// nonEmpty возвращает непустые значения
+func nonEmpty(values ...string) []string {
+	var result []string
+	for _, value := range values {
+		if value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
@@ -0,0 +1,126 @@
+package webhook
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"git-sync/configs"
+)
+
+// maxBodySize наибольший размер события: GitHub ограничивает тело события 25 МБ
+const maxBodySize = 25 << 20
+
+// TriggerFunc ставит в очередь синхронизацию пары, репозиторий которой назван в событии
+type TriggerFunc func(pair configs.RepositoryPair, event *Event)
+
+// Handler HTTP-обработчик событий push. Проверяет подлинность события, находит пары, в которых
+// участвует репозиторий события, и вызывает для каждой из них trigger. Безопасен для одновременного вызова.
+type Handler struct {
+	trigger TriggerFunc
+	logger  *log.Logger
+
+	mu     sync.RWMutex
+	secret string
+	pairs  []configs.RepositoryPair
+}
+
+// NewHandler создает обработчик событий с секретом secret для пар pairs
+func NewHandler(secret string, pairs []configs.RepositoryPair, trigger TriggerFunc) *Handler {
+	return &Handler{trigger: trigger, logger: log.Default(), secret: secret, pairs: pairs}
+}
+
+// SetLogger задает журнал отклоненных событий
+func (h *Handler) SetLogger(logger *log.Logger) {
+	h.logger = logger
+}
+
+// Update заменяет секрет и пары, например после перечитывания конфигурации
+func (h *Handler) Update(secret string, pairs []configs.RepositoryPair) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.secret = secret
+	h.pairs = pairs
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "ожидается POST", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		http.Error(w, "не удалось прочитать тело запроса", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	h.mu.RLock()
+	secret, pairs := h.secret, h.pairs
+	h.mu.RUnlock()
+
+	event, err := Parse(r.Header, body, secret)
+	switch {
+	case errors.Is(err, ErrUnauthorized):
+		h.logger.Printf("Предупреждение: событие от %s отклонено: %v", r.RemoteAddr, err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	case errors.Is(err, ErrUnsupported):
+		// Ответ 200, чтобы отправитель не считал доставку ping и других событий неудачной
+		fmt.Fprintln(w, err)
+		return
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	matched := MatchPairs(event, pairs)
+	if len(matched) == 0 {
+		h.logger.Printf("Предупреждение: событие %s %s для %s не относится ни к одной паре", event.Provider, event.Kind, event.Project)
+		http.Error(w, fmt.Sprintf("репозиторий %s не входит ни в одну пару", event.Project), http.StatusNotFound)
+		return
+	}
+
+	names := make([]string, 0, len(matched))
+	for _, pair := range matched {
+		h.trigger(pair, event)
+		names = append(names, pair.PairName())
+	}
+	w.WriteHeader(http.StatusAccepted)
+	fmt.Fprintf(w, "синхронизация запланирована: %s\n", strings.Join(names, ", "))
+}
+
+// MatchPairs возвращает пары, одна из сторон которых - репозиторий события. Адреса сравниваются
+// без учета схемы, порта, имени пользователя и суффикса .git, поэтому HTTPS-адрес события
+// совпадает с SSH-адресом пары.
+func MatchPairs(event *Event, pairs []configs.RepositoryPair) []configs.RepositoryPair {
+	keys := make(map[string]bool, len(event.URLs))
+	for _, eventURL := range event.URLs {
+		keys[repoKey(eventURL)] = true
+	}
+
+	var matched []configs.RepositoryPair
+	for _, pair := range pairs {
+		if keys[repoKey(pair.GitlabURL)] || keys[repoKey(pair.PrivateRepoURL)] {
+			matched = append(matched, pair)
+		}
+	}
+	return matched
+}
+
+// repoKey приводит адрес репозитория к виду host/path
+func repoKey(repoURL string) string {
+	normalized := configs.NormalizeURL(repoURL)
+	if _, rest, ok := strings.Cut(normalized, "://"); ok {
+		normalized = rest
+	}
+	host, path, _ := strings.Cut(normalized, "/")
+	if colon := strings.LastIndex(host, ":"); colon >= 0 {
+		host = host[:colon]
+	}
+	return strings.ToLower(host + "/" + strings.Trim(path, "/"))
+}
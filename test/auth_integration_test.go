@@ -0,0 +1,169 @@
+package test
+
+import (
+	"context"
+	"path/filepath"
+	gosync "sync"
+	"testing"
+	"time"
+
+	"git-sync/configs"
+	"git-sync/internal/repository"
+	"git-sync/internal/sync"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/transport"
+)
+
+// authCall обращение к remote, для которого запрошен метод аутентификации
+type authCall struct {
+	url  string
+	cred configs.Credential
+}
+
+// recordingAuth заглушка слоя аутентификации: запоминает учетные данные каждого обращения.
+// Локальный транспорт не использует аутентификацию, поэтому метод не возвращается.
+type recordingAuth struct {
+	mu    gosync.Mutex
+	calls []authCall
+}
+
+func (r *recordingAuth) auth(repoURL string, cred configs.Credential) (transport.AuthMethod, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, authCall{url: repoURL, cred: cred})
+	return nil, nil
+}
+
+// TestSynchronizeUsesSideCredentials проверяет, что каждое обращение к репозиторию стороны,
+// включая получение ссылок в зеркало другой стороны, выполняется с учетными данными этой стороны
+func TestSynchronizeUsesSideCredentials(t *testing.T) {
+	dir := t.TempDir()
+	gitlabPath := filepath.Join(dir, "gitlab.git")
+	privatePath := filepath.Join(dir, "private.git")
+
+	// Общая ветка main и по одной ветке, которая есть только на одной стороне
+	createRemote(t, gitlabPath, "main", "feature-gitlab")
+	if _, err := git.PlainClone(privatePath, true, &git.CloneOptions{URL: gitlabPath}); err != nil {
+		t.Fatalf("Не удалось создать приватный репозиторий: %v", err)
+	}
+	pushBranch(t, privatePath, "feature-private")
+
+	gitlabCred := configs.Credential{Type: configs.CredentialToken, Secret: "gitlab-token"}
+	privateCred := configs.Credential{Type: configs.CredentialSSHKey, KeyPath: "/keys/private", Username: "deploy"}
+
+	stub := &recordingAuth{}
+	repoManager := repository.NewManager(filepath.Join(dir, "work"))
+	repoManager.SetAuthFunc(stub.auth)
+	syncLogic := sync.NewLogic(repoManager)
+
+	pair := configs.RepositoryPair{GitlabURL: gitlabPath, PrivateRepoURL: privatePath}
+	if _, err := syncLogic.Synchronize(context.Background(), pair, gitlabCred, privateCred); err != nil {
+		t.Fatalf("Синхронизация завершилась ошибкой: %v", err)
+	}
+
+	expected := map[string]configs.Credential{gitlabPath: gitlabCred, privatePath: privateCred}
+	seen := make(map[string]int)
+	for _, call := range stub.calls {
+		cred, ok := expected[call.url]
+		if !ok {
+			t.Errorf("Обращение к неизвестному репозиторию %s", call.url)
+			continue
+		}
+		if call.cred != cred {
+			t.Errorf("Обращение к %s с учетными данными %+v, ожидались %+v", call.url, call.cred, cred)
+		}
+		seen[call.url]++
+	}
+	// Обновление зеркала, получение ссылок в зеркало другой стороны и push
+	for url := range expected {
+		if seen[url] < 3 {
+			t.Errorf("К %s выполнено %d обращений, ожидалось не менее 3", url, seen[url])
+		}
+	}
+
+	// Ветки каждой стороны отправлены на другую сторону
+	assertBranchExists(t, privatePath, "feature-gitlab")
+	assertBranchExists(t, gitlabPath, "feature-private")
+}
+
+// createRemote создает bare-репозиторий с коммитом в каждой из веток; HEAD указывает на первую ветку
+func createRemote(t *testing.T, path string, branches ...string) {
+	t.Helper()
+
+	repo, err := git.PlainInit(path, true)
+	if err != nil {
+		t.Fatalf("Не удалось создать репозиторий: %v", err)
+	}
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, plumbing.NewBranchReferenceName(branches[0]))
+	if err := repo.Storer.SetReference(head); err != nil {
+		t.Fatalf("Не удалось задать HEAD: %v", err)
+	}
+	hash := storeCommit(t, repo, "initial")
+	for _, branch := range branches {
+		ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), hash)
+		if err := repo.Storer.SetReference(ref); err != nil {
+			t.Fatalf("Не удалось создать ветку %s: %v", branch, err)
+		}
+	}
+}
+
+// pushBranch создает в bare-репозитории ветку с новым коммитом поверх main
+func pushBranch(t *testing.T, path, branch string) {
+	t.Helper()
+
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatalf("Не удалось открыть репозиторий: %v", err)
+	}
+	main, err := repo.Reference(plumbing.NewBranchReferenceName("main"), true)
+	if err != nil {
+		t.Fatalf("Не удалось получить ветку main: %v", err)
+	}
+	hash := storeCommit(t, repo, branch, main.Hash())
+	ref := plumbing.NewHashReference(plumbing.NewBranchReferenceName(branch), hash)
+	if err := repo.Storer.SetReference(ref); err != nil {
+		t.Fatalf("Не удалось создать ветку %s: %v", branch, err)
+	}
+}
+
+// storeCommit сохраняет коммит с пустым деревом
+func storeCommit(t *testing.T, repo *git.Repository, message string, parents ...plumbing.Hash) plumbing.Hash {
+	t.Helper()
+
+	treeObj := repo.Storer.NewEncodedObject()
+	if err := (&object.Tree{}).Encode(treeObj); err != nil {
+		t.Fatalf("Не удалось закодировать дерево: %v", err)
+	}
+	treeHash, err := repo.Storer.SetEncodedObject(treeObj)
+	if err != nil {
+		t.Fatalf("Не удалось сохранить дерево: %v", err)
+	}
+
+	signature := object.Signature{Name: "test", Email: "test@example.com", When: time.Unix(1700000000, 0)}
+	commit := &object.Commit{Author: signature, Committer: signature, Message: message, TreeHash: treeHash, ParentHashes: parents}
+	commitObj := repo.Storer.NewEncodedObject()
+	if err := commit.Encode(commitObj); err != nil {
+		t.Fatalf("Не удалось закодировать коммит: %v", err)
+	}
+	hash, err := repo.Storer.SetEncodedObject(commitObj)
+	if err != nil {
+		t.Fatalf("Не удалось сохранить коммит: %v", err)
+	}
+	return hash
+}
+
+// assertBranchExists проверяет наличие ветки в репозитории
+func assertBranchExists(t *testing.T, path, branch string) {
+	t.Helper()
+
+	repo, err := git.PlainOpen(path)
+	if err != nil {
+		t.Fatalf("Не удалось открыть репозиторий: %v", err)
+	}
+	if _, err := repo.Reference(plumbing.NewBranchReferenceName(branch), false); err != nil {
+		t.Errorf("Ветка %s отсутствует в %s: %v", branch, path, err)
+	}
+}
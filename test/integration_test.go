@@ -364,12 +364,11 @@ func TestSyncLogicIntegration(t *testing.T) {
 
 // getRepoNameFromURL извлекает имя репозитория из URL (копия из sync пакета для тестов)
 func getRepoNameFromURL(repoURL string) string {
-	parts := strings.Split(repoURL, "/")
-	if len(parts) > 0 {
-		nameWithExt := parts[len(parts)-1]
-		return strings.TrimSuffix(nameWithExt, ".git")
+	remote, err := repository.ParseRemoteURL(repoURL, "")
+	if err != nil {
+		return strings.TrimSuffix(repoURL, ".git")
 	}
-	return repoURL
+	return remote.Name()
 }
 
 // getAuthMethod создает метод аутентификации (упрощенная версия для тестов)